@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gomarket/internal/loyalty/config"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/storage"
+)
+
+const checkBalancesUsage = "usage: gophermart [flags] check-balances [repair]"
+
+// balanceCheckActor is the admin name of the repairs made by the subcommand.
+const balanceCheckActor = "check-balances"
+
+// runCheckBalances runs the check-balances subcommand with args following "check-balances".
+// The balances of the default program and of the programs are repaired only with "repair",
+// otherwise the check is a dry run and fails when a balance mismatches, so it can run from cron.
+func runCheckBalances(cfg *config.Config, args []string) error {
+	repair := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "repair":
+		repair = true
+	default:
+		return errors.New(checkBalancesUsage)
+	}
+
+	repo, err := storage.Init(cfg.DBConfig)
+	if err != nil {
+		return err
+	}
+
+	ids := []string{program.DefaultID}
+	for _, p := range cfg.Programs {
+		ids = append(ids, p.ID)
+	}
+
+	mismatched := 0
+	for _, id := range ids {
+		check, err := repo.ForProgram(id).CheckBalances(repair, balanceCheckActor)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+
+		fmt.Printf("%s: %d checked, %d mismatched\n", id, check.Checked, check.Mismatched)
+		for _, m := range check.Mismatches {
+			fmt.Printf("  %s: balance %g, expected %g; withdrawn %g, expected %g\n",
+				m.Login, m.Balance, m.Expected, m.Withdrawn, m.ExpectedWithdrawn)
+		}
+
+		mismatched += check.Mismatched
+	}
+
+	if repair {
+		fmt.Printf("repaired: %d\n", mismatched)
+		return nil
+	}
+
+	if mismatched > 0 {
+		return fmt.Errorf("%d balances mismatch, run check-balances repair to fix them", mismatched)
+	}
+
+	return nil
+}
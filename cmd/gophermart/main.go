@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"gomarket/internal/bruteforce"
 	"gomarket/internal/logger"
 	"gomarket/internal/loyalty/config"
+	"gomarket/internal/loyalty/grpcserver"
 	handlers "gomarket/internal/loyalty/handler"
 	"gomarket/internal/loyalty/storage"
 	"gomarket/internal/loyalty/usecase"
+	mw "gomarket/internal/middleware"
+	"gomarket/pkg/loyaltyapi"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -20,24 +28,72 @@ import (
 func main() {
 	cfg := config.New()
 
+	if flag.Arg(0) == "migrate" {
+		err := runMigrate(cfg, flag.Args()[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if flag.Arg(0) == "check-balances" {
+		err := runCheckBalances(cfg, flag.Args()[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
 	repo, err := storage.Init(cfg.DBConfig)
 	if err != nil {
 		log.Fatalf("Failed to initialize: %s", err.Error())
 	}
 
-	logic := usecase.New(repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logic := usecase.New(repo, cfg.Logic)
+	if cfg.Logic.PointsTTL > 0 && cfg.Logic.ExpiryInterval > 0 {
+		go logic.RunExpiry(ctx)
+	}
+
+	if cfg.Logic.ReconcileInterval > 0 {
+		go logic.RunReconciliation(ctx, cfg.AccrualSystemAddress, cfg.Programs)
+	}
+
+	if cfg.LoginAttemptsStore == "postgres" {
+		db, err := sql.Open("postgres", cfg.DBConfig.DataSourceCred)
+		if err != nil {
+			log.Fatalf("Failed to open login attempts store: %s", err.Error())
+		}
+
+		cfg.BruteForce.Store = bruteforce.NewPostgresStore(db)
+	}
+
+	cfg.Guard = bruteforce.New(*cfg.BruteForce)
+
 	router := chi.NewRouter()
 
 	log := httplog.NewLogger("loyalty", httplog.Options{
 		Concise: true,
 	})
 
+	validator, err := mw.ValidateRequests(loyaltyapi.Spec)
+	if err != nil {
+		log.Fatal().Msg("Failed to load the OpenAPI document: " + err.Error())
+	}
+
 	h := handlers.NewHandler(cfg, logic, logger.New(log))
+	router.Use(middleware.RequestID)
 	router.Use(httplog.RequestLogger(log))
 	router.Use(middleware.Recoverer)
+	router.Use(validator)
 
 	router.Group(h.PublicRoutes)
 	router.Group(h.PrivateRoutes)
+	router.Group(h.AdminRoutes)
 
 	//router.Use(gzip.Gzip(gzip.BestSpeed))
 	go func() {
@@ -48,6 +104,22 @@ func main() {
 		}
 	}()
 
+	if cfg.GRPCHost != "" {
+		go func() {
+			listener, err := net.Listen("tcp", cfg.GRPCHost)
+			if err != nil {
+				log.Error().Msg(err.Error())
+				return
+			}
+
+			log.Info().Msg("Stating loyalty gRPC: " + cfg.GRPCHost)
+			err = grpcserver.New(cfg, logic, logger.New(log)).Serve(listener)
+			if err != nil {
+				log.Error().Msg(err.Error())
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
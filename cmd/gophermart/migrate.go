@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomarket/internal/loyalty/config"
+	"gomarket/internal/loyalty/storage"
+	"strconv"
+)
+
+const migrateUsage = "usage: gophermart [flags] migrate up|down [N]|status|force VERSION"
+
+// runMigrate runs the migrate subcommand with args following "migrate".
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := sql.Open("postgres", cfg.DBConfig.DataSourceCred)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := storage.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		err = m.Down(steps)
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+
+		var version int
+		version, err = strconv.Atoi(args[1])
+		if err != nil {
+			return errors.New(migrateUsage)
+		}
+
+		err = m.Force(version)
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, latest, dirty, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d\nlatest: %d\ndirty: %t\n", version, latest, dirty)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/httplog"
@@ -44,13 +45,19 @@ func main() {
 		log.Info().Msg(fmt.Sprintf("Failed to initialize: %s", err.Error()))
 	}
 
-	logic := usecase.New(repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logic := usecase.New(repo, cfg.Logic)
+	go logic.RunOutbox(ctx)
+
 	e := echo.New()
 
 	h := handlers.NewHandler(cfg, logic, logger.New(log))
 	e.Use(echo.WrapMiddleware(httplog.RequestLogger(log)))
 	e.Use(echo.WrapMiddleware(middleware.Recoverer))
 	e.Use(echosession.New())
+	e.Use(h.RevokedSessions)
 
 	t := &Template{
 		templates: template.Must(template.ParseGlob("templates/html/*.html")),
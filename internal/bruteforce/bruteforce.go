@@ -0,0 +1,193 @@
+// Package bruteforce limits password guessing on login endpoints.
+//
+// Failed attempts are counted per login and per client IP. After a few free
+// attempts every next one for the login has to wait a growing delay, and once
+// the limit is reached the login (or IP) is locked for a while.
+//
+// An allowed attempt is counted as failed in advance, so concurrent guesses
+// can't all pass the check before any of them fails. The caller reports
+// the attempts that turned out successful or were not guesses at all.
+package bruteforce
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultMaxLoginFailures = 5
+	defaultMaxIPFailures    = 20
+	defaultFreeAttempts     = 3
+	defaultBaseDelay        = time.Second
+	defaultMaxDelay         = 30 * time.Second
+	defaultLockout          = 15 * time.Minute
+	defaultWindow           = time.Hour
+)
+
+var ErrTooManyAttempts = errors.New("too many login attempts, try again later")
+
+// Attempts is the failures counter for one key.
+type Attempts struct {
+	Failures int
+	Last     time.Time
+}
+
+// Store keeps failures counters. Failures older than window are forgotten.
+type Store interface {
+	// Reserve counts a failure of the key if allow accepts the failures counted so far and returns them.
+	// The reservations of a key are serialized, so allow always sees the concurrent ones.
+	Reserve(key string, now time.Time, window time.Duration, allow func(Attempts) bool) (Attempts, bool, error)
+	// Release forgets one reserved failure of the key.
+	Release(key string) error
+	Reset(key string) error
+}
+
+// Config of the Guard. Zero values are replaced by defaults.
+type Config struct {
+	MaxLoginFailures int
+	MaxIPFailures    int
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	Lockout          time.Duration
+	Window           time.Duration
+	// Store defaults to the in-memory one.
+	Store Store
+}
+
+type Guard struct {
+	config Config
+	store  Store
+	now    func() time.Time
+}
+
+func New(cfg Config) *Guard {
+	if cfg.MaxLoginFailures <= 0 {
+		cfg.MaxLoginFailures = defaultMaxLoginFailures
+	}
+
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = defaultMaxIPFailures
+	}
+
+	if cfg.FreeAttempts <= 0 {
+		cfg.FreeAttempts = defaultFreeAttempts
+	}
+
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+
+	if cfg.Lockout <= 0 {
+		cfg.Lockout = defaultLockout
+	}
+
+	// the counter must outlive the lockout, otherwise it would be reset while locked
+	if cfg.Window < cfg.Lockout {
+		cfg.Window = defaultWindow
+		if cfg.Window < cfg.Lockout {
+			cfg.Window = cfg.Lockout
+		}
+	}
+
+	store := cfg.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	return &Guard{config: cfg, store: store, now: time.Now}
+}
+
+// Allow reserves the attempt, it is counted as failed until Succeeded or Release.
+// It returns ErrTooManyAttempts and how long the caller has to wait when the wait is not over.
+func (g *Guard) Allow(login, ip string) (time.Duration, error) {
+	now := g.now()
+
+	// the IP goes first, so a locked IP can't add failures to the logins it sprays
+	byIP, ok, err := g.store.Reserve(ipKey(ip), now, g.config.Window, func(a Attempts) bool {
+		return g.lockWait(a, g.config.MaxIPFailures, now) <= 0
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return g.lockWait(byIP, g.config.MaxIPFailures, now), ErrTooManyAttempts
+	}
+
+	byLogin, ok, err := g.store.Reserve(loginKey(login), now, g.config.Window, func(a Attempts) bool {
+		return g.loginWait(a, now) <= 0
+	})
+	if err == nil && ok {
+		return 0, nil
+	}
+
+	if releaseErr := g.store.Release(ipKey(ip)); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return g.loginWait(byLogin, now), ErrTooManyAttempts
+}
+
+// Succeeded forgets failures of the login. The IP counter only forgets the attempt,
+// so logging into an own account doesn't unlock guessing others.
+func (g *Guard) Succeeded(login, ip string) error {
+	err := g.store.Reset(loginKey(login))
+	if err != nil {
+		return err
+	}
+
+	return g.store.Release(ipKey(ip))
+}
+
+// Release forgets the reserved attempt that was neither a success nor a wrong guess,
+// like a blocked user or a server error.
+func (g *Guard) Release(login, ip string) error {
+	err := g.store.Release(loginKey(login))
+	if err != nil {
+		return err
+	}
+
+	return g.store.Release(ipKey(ip))
+}
+
+func (g *Guard) loginWait(a Attempts, now time.Time) time.Duration {
+	if wait := g.lockWait(a, g.config.MaxLoginFailures, now); wait > 0 {
+		return wait
+	}
+
+	if a.Failures < g.config.FreeAttempts {
+		return 0
+	}
+
+	delay := g.config.BaseDelay << (a.Failures - g.config.FreeAttempts)
+	if delay > g.config.MaxDelay || delay <= 0 {
+		delay = g.config.MaxDelay
+	}
+
+	return a.Last.Add(delay).Sub(now)
+}
+
+func (g *Guard) lockWait(a Attempts, max int, now time.Time) time.Duration {
+	if a.Failures < max {
+		return 0
+	}
+
+	return a.Last.Add(g.config.Lockout).Sub(now)
+}
+
+func loginKey(login string) string {
+	return "login:" + login
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
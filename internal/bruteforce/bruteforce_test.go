@@ -0,0 +1,161 @@
+package bruteforce
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestGuard(now *time.Time) *Guard {
+	g := New(Config{
+		MaxLoginFailures: 5,
+		MaxIPFailures:    8,
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         10 * time.Second,
+		Lockout:          time.Minute,
+		Window:           time.Hour,
+	})
+	g.now = func() time.Time { return *now }
+	return g
+}
+
+func TestGuard_Login(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newTestGuard(&now)
+
+	tests := []struct {
+		name     string
+		sleep    time.Duration
+		wantWait time.Duration
+		wantErr  error
+	}{
+		{name: "1st failure"},
+		{name: "2nd failure"},
+		{name: "3rd failure"},
+		{name: "delay after free attempts", wantWait: time.Second, wantErr: ErrTooManyAttempts},
+		{name: "delay is over", sleep: time.Second},
+		{name: "delay grows", wantWait: 2 * time.Second, wantErr: ErrTooManyAttempts},
+		{name: "5th failure", sleep: 2 * time.Second},
+		{name: "locked", wantWait: time.Minute, wantErr: ErrTooManyAttempts},
+		{name: "lock is over", sleep: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.sleep)
+			wait, err := g.Allow("admin", "127.0.0.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Allow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if wait != tt.wantWait {
+				t.Errorf("Allow() wait = %v, want %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestGuard_Succeeded(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newTestGuard(&now)
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.Allow("admin", "127.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := g.Allow("admin", "127.0.0.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Allow() error = %v, wantErr %v", err, ErrTooManyAttempts)
+	}
+
+	if err := g.Succeeded("admin", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Allow("admin", "127.0.0.1"); err != nil {
+		t.Errorf("Allow() after success error = %v, want nil", err)
+	}
+}
+
+func TestGuard_IP(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newTestGuard(&now)
+
+	// spraying different logins from one IP
+	for i := 0; i < 8; i++ {
+		if _, err := g.Allow(string(rune('a'+i)), "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := g.Allow("admin", "10.0.0.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Allow() error = %v, wantErr %v", err, ErrTooManyAttempts)
+	}
+
+	if _, err := g.Allow("admin", "10.0.0.2"); err != nil {
+		t.Errorf("Allow() from another IP error = %v, want nil", err)
+	}
+}
+
+func TestMemoryStore_Window(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+
+	allow := func(Attempts) bool { return true }
+	if _, _, err := s.Reserve("k", now, time.Hour, allow); err != nil {
+		t.Fatal(err)
+	}
+
+	a, _, err := s.Reserve("k", now.Add(2*time.Hour), time.Hour, allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Failures != 0 {
+		t.Errorf("Reserve() failures = %v, want %v", a.Failures, 0)
+	}
+}
+
+func TestGuard_Concurrent(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newTestGuard(&now)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := g.Allow("admin", "127.0.0.1"); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// only the free attempts pass, the rest wait for the delay
+	if allowed != 3 {
+		t.Errorf("concurrent Allow() allowed %v attempts, want %v", allowed, 3)
+	}
+}
+
+func TestGuard_Release(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newTestGuard(&now)
+
+	// the attempts that weren't guesses don't count
+	for i := 0; i < 10; i++ {
+		if _, err := g.Allow("admin", "127.0.0.1"); err != nil {
+			t.Fatalf("Allow() after %v released attempts error = %v", i, err)
+		}
+
+		if err := g.Release("admin", "127.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package bruteforce
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of reservations between removals of stale counters.
+const sweepEvery = 1024
+
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+	writes   int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]Attempts)}
+}
+
+func (s *MemoryStore) Reserve(key string, now time.Time, window time.Duration, allow func(Attempts) bool) (Attempts, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.attempts[key]
+	if stale(a, now, window) {
+		a = Attempts{}
+	}
+
+	if !allow(a) {
+		return a, false, nil
+	}
+
+	s.attempts[key] = Attempts{Failures: a.Failures + 1, Last: now}
+
+	s.writes++
+	if s.writes%sweepEvery == 0 {
+		for k, v := range s.attempts {
+			if stale(v, now, window) {
+				delete(s.attempts, k)
+			}
+		}
+	}
+
+	return a, true, nil
+}
+
+func (s *MemoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.attempts[key]; ok && a.Failures > 0 {
+		a.Failures--
+		s.attempts[key] = a
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+func stale(a Attempts, now time.Time, window time.Duration) bool {
+	return now.Sub(a.Last) > window
+}
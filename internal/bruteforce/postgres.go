@@ -0,0 +1,86 @@
+package bruteforce
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+const createAttempts = `
+INSERT INTO "LoginAttempts" ("Key", "Failures", "Last") VALUES ($1, 0, $2) ON CONFLICT ("Key") DO NOTHING
+`
+const lockAttempts = `
+SELECT "Failures", "Last" FROM "LoginAttempts" WHERE "Key" = $1 FOR UPDATE
+`
+const setAttempts = `
+UPDATE "LoginAttempts" SET "Failures" = $2, "Last" = $3 WHERE "Key" = $1
+`
+const releaseAttempt = `
+UPDATE "LoginAttempts" SET "Failures" = "Failures" - 1 WHERE "Key" = $1 AND "Failures" > 0
+`
+const resetAttempts = `
+DELETE FROM "LoginAttempts" WHERE "Key" = $1
+`
+
+// PostgresStore keeps counters in the "LoginAttempts" table,
+// so they are shared between instances and survive restarts.
+// The keys are stored hashed, the logins and the tokens in them can be longer than the column.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) PostgresStore {
+	return PostgresStore{DB: db}
+}
+
+func (s PostgresStore) Reserve(key string, now time.Time, window time.Duration, allow func(Attempts) bool) (Attempts, bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return Attempts{}, false, err
+	}
+	defer tx.Rollback()
+
+	key = storeKey(key)
+	_, err = tx.Exec(createAttempts, key, now)
+	if err != nil {
+		return Attempts{}, false, err
+	}
+
+	// the row lock serializes the reservations of the key
+	var a Attempts
+	err = tx.QueryRow(lockAttempts, key).Scan(&a.Failures, &a.Last)
+	if err != nil {
+		return Attempts{}, false, err
+	}
+
+	if stale(a, now, window) {
+		a = Attempts{}
+	}
+
+	if !allow(a) {
+		return a, false, nil
+	}
+
+	_, err = tx.Exec(setAttempts, key, a.Failures+1, now)
+	if err != nil {
+		return Attempts{}, false, err
+	}
+
+	return a, true, tx.Commit()
+}
+
+func (s PostgresStore) Release(key string) error {
+	_, err := s.DB.Exec(releaseAttempt, storeKey(key))
+	return err
+}
+
+func (s PostgresStore) Reset(key string) error {
+	_, err := s.DB.Exec(resetAttempts, storeKey(key))
+	return err
+}
+
+func storeKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
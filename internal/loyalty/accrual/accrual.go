@@ -0,0 +1,235 @@
+// Package accrual is the client of the accrual system. The requests go through a circuit breaker
+// of the host, so the polling of the pending orders doesn't hammer a system that is down,
+// the host that limits the requests is left alone for its Retry-After,
+// and the final statuses are cached, as they never change.
+package accrual
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gomarket/internal/loyalty/schema"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Final statuses of the orders.
+const (
+	StatusProcessed = "PROCESSED"
+	StatusInvalid   = "INVALID"
+)
+
+var ErrOpen = errors.New("the accrual system is unavailable, the circuit is open")
+var ErrNotRegistered = errors.New("the order is not registered in the accrual system")
+var ErrUnavailable = errors.New("the accrual system failed to respond")
+var ErrRateLimited = errors.New("the accrual system limits the requests, try again later")
+
+// defaultRetryAfter is waited out when the accrual system limits the requests without a valid Retry-After.
+const defaultRetryAfter = time.Minute
+
+type Config struct {
+	// Failures is the number of consecutive failures that opens the circuit, zero disables the breaker.
+	Failures int
+	// Cooldown is how long the circuit stays open before a trial request.
+	Cooldown time.Duration
+	// Timeout limits a request, zero means no limit.
+	Timeout time.Duration
+	// CacheSize caps the number of the cached final statuses of all the hosts together,
+	// the oldest are evicted first. Zero disables the cache.
+	CacheSize int
+}
+
+// Client is safe for concurrent use, it is shared by the programs. Every host has its own breaker,
+// so the accrual system of one program being down doesn't stop the polling of the others.
+// The statuses are cached by the full URL, so the hosts never share them.
+type Client struct {
+	http     *http.Client
+	failures int
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+	// limited is when the requests to the host can be made again
+	limited map[string]time.Time
+	max     int
+	final   map[string]schema.ResponseFromTheCalculationSystem
+	// keys are in the order of caching
+	keys []string
+}
+
+func New(cfg Config) *Client {
+	return &Client{
+		http:     &http.Client{Timeout: cfg.Timeout},
+		failures: cfg.Failures,
+		cooldown: cfg.Cooldown,
+		breakers: make(map[string]*Breaker),
+		limited:  make(map[string]time.Time),
+		max:      cfg.CacheSize,
+		final:    make(map[string]schema.ResponseFromTheCalculationSystem),
+	}
+}
+
+// Order returns the state of the order in the accrual system at host.
+func (c *Client) Order(host, id string) (schema.ResponseFromTheCalculationSystem, error) {
+	url := host + "/api/orders/" + id
+	if order, ok := c.cached(url); ok {
+		return order, nil
+	}
+
+	return c.get(host, url)
+}
+
+// Recheck requests the order bypassing the cache, the reconciliation compares
+// the final statuses with the ones the accrual system reports now.
+func (c *Client) Recheck(host, id string) (schema.ResponseFromTheCalculationSystem, error) {
+	return c.get(host, host+"/api/orders/"+id)
+}
+
+func (c *Client) get(host, url string) (schema.ResponseFromTheCalculationSystem, error) {
+	if time.Now().Before(c.RetryAt(host)) {
+		return schema.ResponseFromTheCalculationSystem{}, ErrRateLimited
+	}
+
+	breaker := c.breaker(host)
+	if !breaker.Allow() {
+		return schema.ResponseFromTheCalculationSystem{}, ErrOpen
+	}
+
+	var order schema.ResponseFromTheCalculationSystem
+	res, err := c.http.Get(url)
+	if err != nil {
+		breaker.Failure()
+		return order, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		breaker.Success()
+		return order, ErrNotRegistered
+	case http.StatusTooManyRequests:
+		// the host is up, it's only busy, so the limit doesn't count as a failure
+		breaker.Success()
+		c.limit(host, retryAfter(res.Header.Get("Retry-After")))
+		return order, ErrRateLimited
+	default:
+		breaker.Failure()
+		return order, fmt.Errorf("%w: status %d", ErrUnavailable, res.StatusCode)
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&order)
+	if err != nil {
+		breaker.Failure()
+		return order, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	breaker.Success()
+	if order.Status == StatusProcessed || order.Status == StatusInvalid {
+		c.cache(url, order)
+	}
+
+	return order, nil
+}
+
+// Ping requests the host through the breaker, so a ping can close the half-open circuit.
+func (c *Client) Ping(host string) error {
+	breaker := c.breaker(host)
+	if !breaker.Allow() {
+		return ErrOpen
+	}
+
+	res, err := c.http.Get(host)
+	if err != nil {
+		breaker.Failure()
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		breaker.Failure()
+		return fmt.Errorf("%w: status %d", ErrUnavailable, res.StatusCode)
+	}
+
+	breaker.Success()
+	return nil
+}
+
+// RetryAt returns when the host that limited the requests can be requested again,
+// it's in the past for the host that doesn't limit them.
+func (c *Client) RetryAt(host string) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.limited[host]
+}
+
+func (c *Client) limit(host string, wait time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.limited[host] = time.Now().Add(wait)
+}
+
+// retryAfter parses Retry-After given in seconds or as an HTTP date.
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return defaultRetryAfter
+}
+
+// State returns the state of the breaker of the host.
+func (c *Client) State(host string) BreakerState {
+	return c.breaker(host).State()
+}
+
+func (c *Client) breaker(host string) *Breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = NewBreaker(c.failures, c.cooldown)
+		c.breakers[host] = b
+	}
+
+	return b
+}
+
+func (c *Client) cached(url string) (schema.ResponseFromTheCalculationSystem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	order, ok := c.final[url]
+	return order, ok
+}
+
+func (c *Client) cache(url string, order schema.ResponseFromTheCalculationSystem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.max <= 0 {
+		return
+	}
+
+	if _, ok := c.final[url]; ok {
+		c.final[url] = order
+		return
+	}
+
+	if len(c.keys) >= c.max {
+		delete(c.final, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+
+	c.final[url] = order
+	c.keys = append(c.keys, url)
+}
@@ -0,0 +1,198 @@
+package accrual
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Order(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/api/orders/12345678903":
+			w.Write([]byte(`{"order":"12345678903","status":"PROCESSED","accrual":500}`))
+		case "/api/orders/2377225624":
+			w.Write([]byte(`{"order":"2377225624","status":"PROCESSING"}`))
+		case "/api/orders/79927398713":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	c := New(Config{Failures: 2, Cooldown: time.Hour, CacheSize: 10})
+	for i := 0; i < 3; i++ {
+		order, err := c.Order(server.URL, "12345678903")
+		if err != nil || order.Status != StatusProcessed || order.Accrual != 500 {
+			t.Fatalf("Order() = %+v, %v, want processed with 500", order, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("requests of a final status = %d, want 1", n)
+	}
+
+	if _, err := c.Recheck(server.URL, "12345678903"); err != nil {
+		t.Fatalf("Recheck() error = %v", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("requests after Recheck() = %d, want 2", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Order(server.URL, "2377225624"); err != nil {
+			t.Fatalf("Order() error = %v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 4 {
+		t.Errorf("requests of a pending status = %d, want 4", n)
+	}
+
+	if _, err := c.Order(server.URL, "79927398713"); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Order() of an unknown order error = %v, want %v", err, ErrNotRegistered)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Order(server.URL, "1"); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("Order() error = %v, want %v", err, ErrUnavailable)
+		}
+	}
+
+	if _, err := c.Order(server.URL, "2377225624"); !errors.Is(err, ErrOpen) {
+		t.Errorf("Order() with the open circuit error = %v, want %v", err, ErrOpen)
+	}
+
+	if _, err := c.Order(server.URL, "12345678903"); err != nil {
+		t.Errorf("Order() of a cached status with the open circuit error = %v", err)
+	}
+
+	if state := c.State(server.URL); state.State != Open || state.Failures != 2 {
+		t.Errorf("State() = %+v, want open after 2 failures", state)
+	}
+}
+
+func TestClient_CacheSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"INVALID"}`))
+	}))
+	defer server.Close()
+
+	c := New(Config{CacheSize: 2})
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := c.Order(server.URL, id); err != nil {
+			t.Fatalf("Order() error = %v", err)
+		}
+	}
+
+	if _, ok := c.cached(server.URL + "/api/orders/1"); ok {
+		t.Error("the oldest status is cached, want it evicted")
+	}
+
+	if _, ok := c.cached(server.URL + "/api/orders/3"); !ok {
+		t.Error("the latest status is not cached")
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	c := New(Config{Failures: 1, Cooldown: time.Nanosecond})
+	if err := c.Ping(server.URL); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Ping() error = %v, want %v", err, ErrUnavailable)
+	}
+
+	if c.State(server.URL).State != Open {
+		t.Fatalf("State() = %+v, want open", c.State(server.URL))
+	}
+
+	status = http.StatusNotFound
+	time.Sleep(time.Millisecond)
+	if err := c.Ping(server.URL); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	if c.State(server.URL).State != Closed {
+		t.Errorf("State() = %+v, want closed after a successful trial", c.State(server.URL))
+	}
+}
+
+func TestClient_BreakerPerHost(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"PROCESSING"}`))
+	}))
+	defer up.Close()
+
+	c := New(Config{Failures: 1, Cooldown: time.Hour})
+	if _, err := c.Order(down.URL, "1"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Order() error = %v, want %v", err, ErrUnavailable)
+	}
+
+	if _, err := c.Order(down.URL, "1"); !errors.Is(err, ErrOpen) {
+		t.Errorf("Order() of the failed host error = %v, want %v", err, ErrOpen)
+	}
+
+	if _, err := c.Order(up.URL, "1"); err != nil {
+		t.Errorf("Order() of another host error = %v", err)
+	}
+
+	if c.State(up.URL).State != Closed {
+		t.Errorf("State() of another host = %+v, want closed", c.State(up.URL))
+	}
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		w.Write([]byte(`{"status":"PROCESSING"}`))
+	}))
+	defer server.Close()
+
+	c := New(Config{Failures: 1, Cooldown: time.Hour})
+	if _, err := c.Order(server.URL, "1"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Order() error = %v, want %v", err, ErrRateLimited)
+	}
+
+	if c.State(server.URL).State != Closed {
+		t.Errorf("State() = %+v, want closed, the limit is not a failure", c.State(server.URL))
+	}
+
+	if wait := time.Until(c.RetryAt(server.URL)); wait < 59*time.Second || wait > time.Minute {
+		t.Errorf("RetryAt() is in %s, want in a minute", wait)
+	}
+
+	if _, err := c.Order(server.URL, "1"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Order() within Retry-After error = %v, want %v", err, ErrRateLimited)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("requests within Retry-After = %d, want 1", n)
+	}
+
+	c.limit(server.URL, 0)
+	if _, err := c.Order(server.URL, "1"); err != nil {
+		t.Errorf("Order() after Retry-After error = %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package accrual
+
+import (
+	"sync"
+	"time"
+)
+
+// States of the circuit. A closed circuit lets the requests through, an open one rejects them
+// until the cooldown passes, then a half-open one lets a single trial request through
+// and closes on its success or opens again on its failure.
+const (
+	Closed   = "closed"
+	Open     = "open"
+	HalfOpen = "half_open"
+)
+
+// Breaker is a circuit breaker opened by consecutive failures, it is safe for concurrent use.
+type Breaker struct {
+	mu sync.Mutex
+	// threshold is the number of consecutive failures that opens the circuit, zero disables the breaker.
+	threshold int
+	cooldown  time.Duration
+
+	state    string
+	failures int
+	openedAt time.Time
+	// probing is set while the trial request of the half-open circuit is in flight
+	probing bool
+
+	now func() time.Time
+}
+
+// BreakerState is a snapshot of the breaker, RetryAt is when an open circuit half-opens.
+type BreakerState struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, state: Closed, now: time.Now}
+}
+
+// Allow reports whether a request can be made now.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Before(b.openedAt.Add(b.cooldown)) {
+			return false
+		}
+
+		b.state = HalfOpen
+		b.probing = true
+		return true
+	case HalfOpen:
+		if b.probing {
+			return false
+		}
+
+		b.probing = true
+		return true
+	}
+
+	return true
+}
+
+// Success closes the circuit.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = Closed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure opens the circuit after the threshold of consecutive failures or a failed trial request.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state = Open
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerState{State: b.state, Failures: b.failures}
+	if b.state == Open {
+		retryAt := b.openedAt.Add(b.cooldown)
+		s.RetryAt = &retryAt
+	}
+
+	return s
+}
@@ -0,0 +1,58 @@
+package accrual
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	if !b.Allow() || b.State().State != Closed {
+		t.Fatalf("State() after a failure = %+v, want closed", b.State())
+	}
+
+	b.Failure()
+	state := b.State()
+	if b.Allow() || state.State != Open || state.RetryAt == nil || !state.RetryAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("State() after the threshold = %+v, want open until %v", state, now.Add(time.Minute))
+	}
+
+	now = now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatal("Allow() after the cooldown = false, want a trial request")
+	}
+
+	if b.Allow() || b.State().State != HalfOpen {
+		t.Fatalf("Allow() during the trial request = true, want one request at a time")
+	}
+
+	b.Failure()
+	if b.Allow() || b.State().State != Open {
+		t.Fatalf("State() after a failed trial = %+v, want open", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatal("Allow() after the cooldown = false, want a trial request")
+	}
+
+	b.Success()
+	if !b.Allow() || b.State() != (BreakerState{State: Closed}) {
+		t.Errorf("State() after a successful trial = %+v, want closed", b.State())
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b := NewBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		b.Failure()
+	}
+
+	if !b.Allow() || b.State().State != Closed {
+		t.Errorf("State() = %+v, want closed", b.State())
+	}
+}
@@ -0,0 +1,67 @@
+package campaign
+
+import (
+	"gomarket/internal/loyalty/schema"
+	"math"
+	"time"
+)
+
+// Active reports whether the campaign applies to an order processed at the moment,
+// processed is the number of the user's orders processed before.
+func Active(c schema.Campaign, at time.Time, processed int) bool {
+	if c.Disabled || at.Before(c.StartsAt) {
+		return false
+	}
+
+	if c.EndsAt != nil && !at.Before(*c.EndsAt) {
+		return false
+	}
+
+	return c.FirstOrders == 0 || processed < c.FirstOrders
+}
+
+// Apply applies the active campaigns to the accrual and returns the boosted accrual with the applied campaigns.
+// Multipliers are applied one after another, so two "double points" campaigns quadruple the accrual,
+// and the bonuses are added after all the multipliers.
+func Apply(campaigns []schema.Campaign, accrual float64, at time.Time, processed int) (float64, []schema.AppliedCampaign) {
+	applied := make([]schema.AppliedCampaign, 0)
+	// index maps a campaign ID to its place in applied, a campaign can both multiply and add a bonus
+	index := make(map[int64]int)
+	active := make([]schema.Campaign, 0, len(campaigns))
+	for _, c := range campaigns {
+		if Active(c, at, processed) {
+			active = append(active, c)
+		}
+	}
+
+	for _, c := range active {
+		if c.Multiplier == 1 || accrual == 0 {
+			continue
+		}
+
+		boosted := round(accrual * c.Multiplier)
+		index[c.ID] = len(applied)
+		applied = append(applied, schema.AppliedCampaign{ID: c.ID, Name: c.Name, Bonus: round(boosted - accrual)})
+		accrual = boosted
+	}
+
+	for _, c := range active {
+		if c.Bonus == 0 {
+			continue
+		}
+
+		accrual = round(accrual + c.Bonus)
+		if i, ok := index[c.ID]; ok {
+			applied[i].Bonus = round(applied[i].Bonus + c.Bonus)
+			continue
+		}
+
+		applied = append(applied, schema.AppliedCampaign{ID: c.ID, Name: c.Name, Bonus: c.Bonus})
+	}
+
+	return accrual, applied
+}
+
+func round(points float64) float64 {
+	return math.Round(points*100) / 100
+}
@@ -0,0 +1,127 @@
+package campaign
+
+import (
+	"gomarket/internal/loyalty/schema"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActive(t *testing.T) {
+	now := time.Date(2023, 6, 10, 12, 0, 0, 0, time.UTC)
+	end := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+	tests := []struct {
+		name      string
+		campaign  schema.Campaign
+		processed int
+		want      bool
+	}{
+		{
+			name:     "open-ended",
+			campaign: schema.Campaign{StartsAt: past},
+			want:     true,
+		},
+		{
+			name:     "within window",
+			campaign: schema.Campaign{StartsAt: past, EndsAt: &end},
+			want:     true,
+		},
+		{
+			name:     "not started",
+			campaign: schema.Campaign{StartsAt: end},
+		},
+		{
+			name:     "ended",
+			campaign: schema.Campaign{StartsAt: past.Add(-time.Hour), EndsAt: &past},
+		},
+		{
+			name:     "disabled",
+			campaign: schema.Campaign{StartsAt: past, Disabled: true},
+		},
+		{
+			name:      "first order",
+			campaign:  schema.Campaign{StartsAt: past, FirstOrders: 1},
+			processed: 0,
+			want:      true,
+		},
+		{
+			name:      "not the first order",
+			campaign:  schema.Campaign{StartsAt: past, FirstOrders: 1},
+			processed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Active(tt.campaign, now, tt.processed); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	double := schema.Campaign{ID: 1, Name: "Double weekend", Multiplier: 2, StartsAt: past}
+	first := schema.Campaign{ID: 2, Name: "First order", Multiplier: 1, Bonus: 100, FirstOrders: 1, StartsAt: past}
+	both := schema.Campaign{ID: 3, Name: "Both", Multiplier: 1.5, Bonus: 10, StartsAt: past}
+	tests := []struct {
+		name        string
+		campaigns   []schema.Campaign
+		accrual     float64
+		processed   int
+		want        float64
+		wantApplied []schema.AppliedCampaign
+	}{
+		{
+			name:        "none",
+			accrual:     50,
+			want:        50,
+			wantApplied: []schema.AppliedCampaign{},
+		},
+		{
+			name:      "multiplier and first order bonus",
+			campaigns: []schema.Campaign{first, double},
+			accrual:   50,
+			want:      200,
+			wantApplied: []schema.AppliedCampaign{
+				{ID: 1, Name: "Double weekend", Bonus: 50},
+				{ID: 2, Name: "First order", Bonus: 100},
+			},
+		},
+		{
+			name:        "first order bonus is over",
+			campaigns:   []schema.Campaign{first, double},
+			accrual:     50,
+			processed:   1,
+			want:        100,
+			wantApplied: []schema.AppliedCampaign{{ID: 1, Name: "Double weekend", Bonus: 50}},
+		},
+		{
+			name:        "bonus without accrual",
+			campaigns:   []schema.Campaign{first, double},
+			want:        100,
+			wantApplied: []schema.AppliedCampaign{{ID: 2, Name: "First order", Bonus: 100}},
+		},
+		{
+			name:        "multiplier and bonus in one campaign",
+			campaigns:   []schema.Campaign{both},
+			accrual:     10,
+			want:        25,
+			wantApplied: []schema.AppliedCampaign{{ID: 3, Name: "Both", Bonus: 15}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, applied := Apply(tt.campaigns, tt.accrual, now, tt.processed)
+			if got != tt.want {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("Apply() applied = %v, want %v", applied, tt.wantApplied)
+			}
+		})
+	}
+}
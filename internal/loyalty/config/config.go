@@ -2,20 +2,99 @@ package config
 
 import (
 	"flag"
+	"gomarket/internal/bruteforce"
+	"gomarket/internal/loyalty/accrual"
 	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/risk"
 	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/tier"
+	"gomarket/internal/loyalty/usecase"
+	"gomarket/internal/loyalty/withdrawal"
+	"gomarket/internal/notify"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	defaultHost = ":8080"
+	defaultHost           = ":8080"
+	defaultExpiringSoon   = 30
+	defaultExpiryInterval = time.Hour
+	defaultTierWindow     = 365
+	defaultTOTPIssuer     = "Gophermart"
+	defaultOutbox         = "outbox.jsonl"
+	defaultResetTTL       = time.Hour
+	defaultRiskWindow     = time.Hour
+	defaultRiskMinUploads = 10
+	defaultRiskThrottle   = 15 * time.Minute
+	defaultAccrualFails   = 5
+	defaultAccrualCool    = 30 * time.Second
+	defaultAccrualTimeout = 5 * time.Second
+	defaultAccrualCache   = 10000
+	defaultReconcileDays  = 30
+	defaultPollWorkers    = 16
+	defaultPollQueue      = 10000
+	defaultPollTimeout    = 24 * time.Hour
+	day                   = 24 * time.Hour
 )
 
 type Flag struct {
-	host *string
-	dsn  *string
-	asa  *string
-	key  string
+	host           *string
+	dsn            *string
+	driver         *string
+	migrate        *bool
+	asa            *string
+	key            string
+	pointsTTL      *int
+	expiringSoon   *int
+	expiryInterval *time.Duration
+	tiers          *string
+	tierWindow     *int
+	transferMin    *float64
+	transferMax    *float64
+	transferDaily  *float64
+	transferAccept *bool
+	referralBonus  *float64
+	referralMax    *int
+	referralDaily  *int
+	referralMin    *float64
+	withdrawMin    *float64
+	withdrawShare  *float64
+	withdrawDaily  *float64
+	withdrawMonth  *float64
+	withdrawFresh  *time.Duration
+	deletionMode   *string
+	deletionPoints *string
+	totpIssuer     *string
+	stepUpAbove    *float64
+	outbox         *string
+	resetTTL       *time.Duration
+	riskRules      *string
+	riskWindow     *time.Duration
+	riskMinUploads *int
+	riskThrottle   *time.Duration
+	accrualFails   *int
+	accrualCool    *time.Duration
+	accrualTimeout *time.Duration
+	accrualCache   *int
+	reconcileEvery *time.Duration
+	reconcileDays  *int
+	reconcileCount *int
+	reconcileApply *bool
+	pollWorkers    *int
+	pollQueue      *int
+	pollTimeout    *time.Duration
+	adminTokens    *string
+	serviceToken   *string
+	loginFailures  *int
+	ipFailures     *int
+	loginLockout   *time.Duration
+	loginStore     *string
+	grpcHost       *string
+	programs       *string
 }
 
 var f Flag
@@ -23,14 +102,79 @@ var f Flag
 func init() {
 	f.host = flag.String("a", defaultHost, "-a=host")
 	f.dsn = flag.String("d", "", "-d=connection_string")
+	f.driver = flag.String("db-driver", "postgres", "-db-driver=postgres|memory")
+	f.migrate = flag.Bool("migrate-on-start", false, "-migrate-on-start")
 	f.asa = flag.String("r", "", "-r=host")
+	f.pointsTTL = flag.Int("points-ttl", 0, "-points-ttl=days")
+	f.expiringSoon = flag.Int("expiring-soon", defaultExpiringSoon, "-expiring-soon=days")
+	f.expiryInterval = flag.Duration("expiry-interval", defaultExpiryInterval, "-expiry-interval=1h")
+	f.tiers = flag.String("tiers", "", "-tiers=Bronze:0:1,Silver:1000:1.1,Gold:5000:1.25")
+	f.tierWindow = flag.Int("tier-window", defaultTierWindow, "-tier-window=days")
+	f.transferMin = flag.Float64("transfer-min", 0, "-transfer-min=sum")
+	f.transferMax = flag.Float64("transfer-max", 0, "-transfer-max=sum")
+	f.transferDaily = flag.Float64("transfer-daily-max", 0, "-transfer-daily-max=sum")
+	f.transferAccept = flag.Bool("transfer-confirmation", false, "-transfer-confirmation")
+	f.referralBonus = flag.Float64("referral-bonus", 0, "-referral-bonus=points")
+	f.referralMax = flag.Int("referral-max", 0, "-referral-max=referrals")
+	f.referralDaily = flag.Int("referral-daily-max", 0, "-referral-daily-max=sign-ups")
+	f.referralMin = flag.Float64("referral-min-accrual", 0, "-referral-min-accrual=points")
+	f.withdrawMin = flag.Float64("withdraw-min", 0, "-withdraw-min=sum")
+	f.withdrawShare = flag.Float64("withdraw-max-share", 0, "-withdraw-max-share=0.5")
+	f.withdrawDaily = flag.Float64("withdraw-daily-max", 0, "-withdraw-daily-max=sum")
+	f.withdrawMonth = flag.Float64("withdraw-monthly-max", 0, "-withdraw-monthly-max=sum")
+	f.withdrawFresh = flag.Duration("withdraw-cooling-off", 0, "-withdraw-cooling-off=72h")
+	f.deletionMode = flag.String("account-deletion", storage.DeletionAnonymize, "-account-deletion=anonymize|delete")
+	f.deletionPoints = flag.String("deletion-balance", storage.BalanceRefuse, "-deletion-balance=refuse|forfeit")
+	f.totpIssuer = flag.String("totp-issuer", defaultTOTPIssuer, "-totp-issuer=name")
+	f.stepUpAbove = flag.Float64("step-up-above", 0, "-step-up-above=sum")
+	f.outbox = flag.String("notify-outbox", defaultOutbox, "-notify-outbox=outbox.jsonl")
+	f.resetTTL = flag.Duration("password-reset-ttl", defaultResetTTL, "-password-reset-ttl=1h")
+	f.riskRules = flag.String("risk-rules", "", "-risk-rules=invalid_rate:0.5:flag,foreign_rate:0.3:throttle,withdrawals:10:block")
+	f.riskWindow = flag.Duration("risk-window", defaultRiskWindow, "-risk-window=1h")
+	f.riskMinUploads = flag.Int("risk-min-uploads", defaultRiskMinUploads, "-risk-min-uploads=10")
+	f.riskThrottle = flag.Duration("risk-throttle", defaultRiskThrottle, "-risk-throttle=15m")
+	f.accrualFails = flag.Int("accrual-breaker-failures", defaultAccrualFails, "-accrual-breaker-failures=5")
+	f.accrualCool = flag.Duration("accrual-breaker-cooldown", defaultAccrualCool, "-accrual-breaker-cooldown=30s")
+	f.accrualTimeout = flag.Duration("accrual-timeout", defaultAccrualTimeout, "-accrual-timeout=5s")
+	f.accrualCache = flag.Int("accrual-cache-size", defaultAccrualCache, "-accrual-cache-size=orders")
+	f.reconcileEvery = flag.Duration("reconcile-interval", 0, "-reconcile-interval=24h")
+	f.reconcileDays = flag.Int("reconcile-window", defaultReconcileDays, "-reconcile-window=days")
+	f.reconcileCount = flag.Int("reconcile-sample", 0, "-reconcile-sample=orders")
+	f.reconcileApply = flag.Bool("reconcile-apply", false, "-reconcile-apply")
+	f.pollWorkers = flag.Int("poll-workers", defaultPollWorkers, "-poll-workers=16")
+	f.pollQueue = flag.Int("poll-queue", defaultPollQueue, "-poll-queue=orders")
+	f.pollTimeout = flag.Duration("poll-timeout", defaultPollTimeout, "-poll-timeout=24h")
+	f.adminTokens = flag.String("admin-tokens", "", "-admin-tokens=name:token,...")
+	f.serviceToken = flag.String("service-token", "", "-service-token=token")
+	f.loginFailures = flag.Int("login-max-failures", 0, "-login-max-failures=5")
+	f.ipFailures = flag.Int("login-max-ip-failures", 0, "-login-max-ip-failures=20")
+	f.loginLockout = flag.Duration("login-lockout", 0, "-login-lockout=15m")
+	f.loginStore = flag.String("login-attempts-store", "memory", "-login-attempts-store=memory|postgres")
+	f.grpcHost = flag.String("g", "", "-g=host")
+	f.programs = flag.String("programs", "", "-programs=programs.json")
 }
 
 type Config struct {
 	Host                 string
 	Key                  []byte
 	DBConfig             *storage.Config
+	Logic                *usecase.Config
 	AccrualSystemAddress string
+	// Admins maps an admin token to the admin name.
+	Admins     map[string]string
+	BruteForce *bruteforce.Config
+	// Guard is shared by the HTTP and the gRPC servers, so the attempts over both APIs are counted together.
+	// The servers build their own from BruteForce if it's nil.
+	Guard *bruteforce.Guard
+	// ServiceToken is sent by the market in X-Service-Token, its withdrawals skip the step-up code
+	// since the market delivers them later and can't ask the customer for one. Empty trusts no service.
+	ServiceToken string
+	// LoginAttemptsStore is "memory" or "postgres".
+	LoginAttemptsStore string
+	// GRPCHost is the gRPC listen address, empty disables the gRPC API.
+	GRPCHost string
+	// Programs are hosted besides the default program, which serves the requests without a program key.
+	Programs []program.Program
 }
 
 func New() *Config {
@@ -48,19 +192,378 @@ func New() *Config {
 		f.dsn = &dsn
 	}
 
+	if driver, ok := os.LookupEnv("DATABASE_DRIVER"); ok {
+		f.driver = &driver
+	}
+
+	if migrate, ok := lookupBool("MIGRATE_ON_START"); ok {
+		f.migrate = &migrate
+	}
+
 	if key, ok := os.LookupEnv("KEY"); ok {
 		f.key = key
 		cookies.SetSecret([]byte(key))
 	}
 
+	if ttl, ok := lookupInt("POINTS_TTL_DAYS"); ok {
+		f.pointsTTL = &ttl
+	}
+
+	if soon, ok := lookupInt("EXPIRING_SOON_DAYS"); ok {
+		f.expiringSoon = &soon
+	}
+
+	if interval, ok := lookupDuration("EXPIRY_INTERVAL"); ok {
+		f.expiryInterval = &interval
+	}
+
+	if tiers, ok := os.LookupEnv("LOYALTY_TIERS"); ok {
+		f.tiers = &tiers
+	}
+
+	if window, ok := lookupInt("TIER_WINDOW_DAYS"); ok {
+		f.tierWindow = &window
+	}
+
+	if min, ok := lookupFloat("TRANSFER_MIN"); ok {
+		f.transferMin = &min
+	}
+
+	if max, ok := lookupFloat("TRANSFER_MAX"); ok {
+		f.transferMax = &max
+	}
+
+	if daily, ok := lookupFloat("TRANSFER_DAILY_MAX"); ok {
+		f.transferDaily = &daily
+	}
+
+	if accept, ok := lookupBool("TRANSFER_CONFIRMATION"); ok {
+		f.transferAccept = &accept
+	}
+
+	if bonus, ok := lookupFloat("REFERRAL_BONUS"); ok {
+		f.referralBonus = &bonus
+	}
+
+	if max, ok := lookupInt("REFERRAL_MAX"); ok {
+		f.referralMax = &max
+	}
+
+	if daily, ok := lookupInt("REFERRAL_DAILY_MAX"); ok {
+		f.referralDaily = &daily
+	}
+
+	if min, ok := lookupFloat("REFERRAL_MIN_ACCRUAL"); ok {
+		f.referralMin = &min
+	}
+
+	if min, ok := lookupFloat("WITHDRAW_MIN"); ok {
+		f.withdrawMin = &min
+	}
+
+	if share, ok := lookupFloat("WITHDRAW_MAX_SHARE"); ok {
+		f.withdrawShare = &share
+	}
+
+	if daily, ok := lookupFloat("WITHDRAW_DAILY_MAX"); ok {
+		f.withdrawDaily = &daily
+	}
+
+	if monthly, ok := lookupFloat("WITHDRAW_MONTHLY_MAX"); ok {
+		f.withdrawMonth = &monthly
+	}
+
+	if coolingOff, ok := lookupDuration("WITHDRAW_COOLING_OFF"); ok {
+		f.withdrawFresh = &coolingOff
+	}
+
+	if mode, ok := os.LookupEnv("ACCOUNT_DELETION"); ok {
+		f.deletionMode = &mode
+	}
+
+	if points, ok := os.LookupEnv("DELETION_BALANCE"); ok {
+		f.deletionPoints = &points
+	}
+
+	if issuer, ok := os.LookupEnv("TOTP_ISSUER"); ok {
+		f.totpIssuer = &issuer
+	}
+
+	if threshold, ok := lookupFloat("STEP_UP_ABOVE"); ok {
+		f.stepUpAbove = &threshold
+	}
+
+	if outbox, ok := os.LookupEnv("NOTIFY_OUTBOX"); ok {
+		f.outbox = &outbox
+	}
+
+	if ttl, ok := lookupDuration("PASSWORD_RESET_TTL"); ok {
+		f.resetTTL = &ttl
+	}
+
+	if rules, ok := os.LookupEnv("RISK_RULES"); ok {
+		f.riskRules = &rules
+	}
+
+	if window, ok := lookupDuration("RISK_WINDOW"); ok {
+		f.riskWindow = &window
+	}
+
+	if min, ok := lookupInt("RISK_MIN_UPLOADS"); ok {
+		f.riskMinUploads = &min
+	}
+
+	if throttle, ok := lookupDuration("RISK_THROTTLE"); ok {
+		f.riskThrottle = &throttle
+	}
+
+	if failures, ok := lookupInt("ACCRUAL_BREAKER_FAILURES"); ok {
+		f.accrualFails = &failures
+	}
+
+	if cooldown, ok := lookupDuration("ACCRUAL_BREAKER_COOLDOWN"); ok {
+		f.accrualCool = &cooldown
+	}
+
+	if timeout, ok := lookupDuration("ACCRUAL_TIMEOUT"); ok {
+		f.accrualTimeout = &timeout
+	}
+
+	if size, ok := lookupInt("ACCRUAL_CACHE_SIZE"); ok {
+		f.accrualCache = &size
+	}
+
+	if interval, ok := lookupDuration("RECONCILE_INTERVAL"); ok {
+		f.reconcileEvery = &interval
+	}
+
+	if days, ok := lookupInt("RECONCILE_WINDOW"); ok {
+		f.reconcileDays = &days
+	}
+
+	if sample, ok := lookupInt("RECONCILE_SAMPLE"); ok {
+		f.reconcileCount = &sample
+	}
+
+	if apply, ok := lookupBool("RECONCILE_APPLY"); ok {
+		f.reconcileApply = &apply
+	}
+
+	if workers, ok := lookupInt("POLL_WORKERS"); ok {
+		f.pollWorkers = &workers
+	}
+
+	if queue, ok := lookupInt("POLL_QUEUE"); ok {
+		f.pollQueue = &queue
+	}
+
+	if timeout, ok := lookupDuration("POLL_TIMEOUT"); ok {
+		f.pollTimeout = &timeout
+	}
+
+	if tokens, ok := os.LookupEnv("ADMIN_TOKENS"); ok {
+		f.adminTokens = &tokens
+	}
+
+	if token, ok := os.LookupEnv("SERVICE_TOKEN"); ok {
+		f.serviceToken = &token
+	}
+
+	if failures, ok := lookupInt("LOGIN_MAX_FAILURES"); ok {
+		f.loginFailures = &failures
+	}
+
+	if failures, ok := lookupInt("LOGIN_MAX_IP_FAILURES"); ok {
+		f.ipFailures = &failures
+	}
+
+	if lockout, ok := lookupDuration("LOGIN_LOCKOUT"); ok {
+		f.loginLockout = &lockout
+	}
+
+	if store, ok := os.LookupEnv("LOGIN_ATTEMPTS_STORE"); ok {
+		f.loginStore = &store
+	}
+
+	if addr, ok := os.LookupEnv("GRPC_ADDRESS"); ok {
+		f.grpcHost = &addr
+	}
+
+	if programs, ok := os.LookupEnv("PROGRAMS_FILE"); ok {
+		f.programs = &programs
+	}
+
+	tiers, err := tier.Parse(*f.tiers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	riskRules, err := risk.Parse(*f.riskRules)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	programs, err := program.Load(*f.programs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deletion := storage.DeletionPolicy{Mode: *f.deletionMode, Balance: *f.deletionPoints}
+	if err = deletion.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
 	return &Config{
 		Host: *f.host,
 		Key:  []byte("CHANGE ME"),
 		DBConfig: &storage.Config{
-			DriverName:     "postgres",
+			DriverName:     *f.driver,
 			DataSourceCred: *f.dsn,
 			Name:           "vdb",
+			MigrateOnStart: *f.migrate,
+		},
+		Logic: &usecase.Config{
+			PointsTTL:      time.Duration(*f.pointsTTL) * day,
+			ExpiringSoon:   time.Duration(*f.expiringSoon) * day,
+			ExpiryInterval: *f.expiryInterval,
+			Tiers:          tiers,
+			TierWindow:     time.Duration(*f.tierWindow) * day,
+
+			TransferMin:          *f.transferMin,
+			TransferMax:          *f.transferMax,
+			TransferDailyMax:     *f.transferDaily,
+			TransferConfirmation: *f.transferAccept,
+
+			ReferralBonus:      *f.referralBonus,
+			ReferralMax:        *f.referralMax,
+			ReferralDailyMax:   *f.referralDaily,
+			ReferralMinAccrual: *f.referralMin,
+
+			Withdrawal: withdrawal.Policy{
+				Min:        *f.withdrawMin,
+				MaxShare:   *f.withdrawShare,
+				DailyMax:   *f.withdrawDaily,
+				MonthlyMax: *f.withdrawMonth,
+				CoolingOff: *f.withdrawFresh,
+			},
+			Deletion: deletion,
+
+			TOTPIssuer:  *f.totpIssuer,
+			StepUpAbove: *f.stepUpAbove,
+
+			Notifier: notify.NewFile(*f.outbox),
+			ResetTTL: *f.resetTTL,
+
+			Accrual: accrual.New(accrual.Config{
+				Failures:  *f.accrualFails,
+				Cooldown:  *f.accrualCool,
+				Timeout:   *f.accrualTimeout,
+				CacheSize: *f.accrualCache,
+			}),
+			ReconcileInterval: *f.reconcileEvery,
+			ReconcileWindow:   time.Duration(*f.reconcileDays) * day,
+			ReconcileSample:   *f.reconcileCount,
+			ReconcileApply:    *f.reconcileApply,
+
+			Risk: risk.Policy{
+				Rules:      riskRules,
+				Window:     *f.riskWindow,
+				MinUploads: *f.riskMinUploads,
+				Throttle:   *f.riskThrottle,
+			},
+
+			PollWorkers: *f.pollWorkers,
+			PollQueue:   *f.pollQueue,
+			PollTimeout: *f.pollTimeout,
 		},
 		AccrualSystemAddress: *f.asa,
+		Admins:               parseAdmins(*f.adminTokens),
+		ServiceToken:         *f.serviceToken,
+		BruteForce: &bruteforce.Config{
+			MaxLoginFailures: *f.loginFailures,
+			MaxIPFailures:    *f.ipFailures,
+			Lockout:          *f.loginLockout,
+		},
+		LoginAttemptsStore: *f.loginStore,
+		GRPCHost:           *f.grpcHost,
+		Programs:           programs,
+	}
+}
+
+// parseAdmins parses admin tokens written as "name:token,...".
+func parseAdmins(s string) map[string]string {
+	admins := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" || token == "" {
+			if pair != "" {
+				log.Println("bad admin token format, want name:token")
+			}
+			continue
+		}
+
+		admins[token] = name
+	}
+
+	return admins
+}
+
+func lookupInt(key string) (int, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return 0, false
+	}
+
+	return n, true
+}
+
+func lookupDuration(key string) (time.Duration, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return 0, false
+	}
+
+	return d, true
+}
+
+func lookupFloat(key string) (float64, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return 0, false
 	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return 0, false
+	}
+
+	return n, true
+}
+
+func lookupBool(key string) (bool, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return false, false
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return false, false
+	}
+
+	return b, true
 }
@@ -5,7 +5,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"gomarket/internal/loyalty/program"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,11 +19,26 @@ func SetSecret(secret []byte) {
 }
 
 func NewCookie(username string) *http.Cookie {
+	return NewProgramCookie(program.DefaultID, username)
+}
+
+// NewProgramCookie returns the session of the user valid only in the program.
+func NewProgramCookie(programID, username string) *http.Cookie {
+	return NewSessionCookie(programID, username, 0)
+}
+
+// NewSessionCookie returns the session of the token generation of the user,
+// changing the password moves the user to the next generation and revokes the tokens of the previous ones.
+// The tokens of generation 0 have the format used before the revocation, so they stay valid until then.
+func NewSessionCookie(programID, username string, generation int64) *http.Cookie {
 	h := hmac.New(sha256.New, secretkey)
 	src := []byte(username)
-	h.Write(src)
+	h.Write(signedGeneration(programID, src, generation))
 
 	value := hex.EncodeToString(h.Sum(nil)) + "-" + hex.EncodeToString(src)
+	if generation != 0 {
+		value += "-" + strconv.FormatInt(generation, 10)
+	}
 	cookie := &http.Cookie{
 		Name:       "session",
 		Value:      value,
@@ -54,19 +71,32 @@ func Get(r *http.Request) (cookie string, err error) {
 	return "", errors.New("no cookies was provided")
 }
 
-func Set(w http.ResponseWriter, username string) {
-	cookie := NewCookie(username)
+func Set(w http.ResponseWriter, programID, username string) {
+	SetSession(w, programID, username, 0)
+}
+
+func SetSession(w http.ResponseWriter, programID, username string, generation int64) {
+	cookie := NewSessionCookie(programID, username, generation)
 	w.Header().Set("Authorization", cookie.Value)
 }
 
 func Check(cookie string) bool {
+	return CheckProgram(program.DefaultID, cookie)
+}
+
+// CheckProgram reports whether the cookie was issued by the program.
+func CheckProgram(programID, cookie string) bool {
 	arr := strings.Split(cookie, "-")
 
-	if len(arr) < 2 {
+	if len(arr) != 2 && len(arr) != 3 {
 		return false
 	}
 
 	k, v := arr[0], arr[1]
+	generation, ok := parseGeneration(arr)
+	if !ok {
+		return false
+	}
 
 	sign, err := hex.DecodeString(k)
 	if err != nil {
@@ -79,7 +109,62 @@ func Check(cookie string) bool {
 	}
 
 	h := hmac.New(sha256.New, secretkey)
-	h.Write(data)
+	h.Write(signedGeneration(programID, data, generation))
 
 	return hmac.Equal(sign, h.Sum(nil))
 }
+
+// Generation returns the token generation of the cookie, it doesn't check the signature.
+func Generation(cookie string) int64 {
+	generation, _ := parseGeneration(strings.Split(cookie, "-"))
+	return generation
+}
+
+// Username returns the user of the cookie, it doesn't check the signature.
+func Username(cookie string) string {
+	arr := strings.Split(cookie, "-")
+	if len(arr) < 2 {
+		return ""
+	}
+
+	username, err := hex.DecodeString(arr[1])
+	if err != nil {
+		return ""
+	}
+
+	return string(username)
+}
+
+func parseGeneration(parts []string) (int64, bool) {
+	if len(parts) != 3 {
+		return 0, true
+	}
+
+	generation, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || generation <= 0 {
+		return 0, false
+	}
+
+	return generation, true
+}
+
+// signed returns the data signed for the user of the program.
+// The default program signs the username alone, so the tokens issued before the programs stay valid.
+func signed(programID string, username []byte) []byte {
+	if programID == program.DefaultID {
+		return username
+	}
+
+	return append([]byte(programID+"\x00"), username...)
+}
+
+// signedGeneration returns the data signed for the token generation, generation 0 signs the data like before.
+func signedGeneration(programID string, username []byte, generation int64) []byte {
+	data := signed(programID, username)
+	if generation == 0 {
+		return data
+	}
+
+	suffix := "\x00" + strconv.FormatInt(generation, 10)
+	return append(append(make([]byte, 0, len(data)+len(suffix)), data...), suffix...)
+}
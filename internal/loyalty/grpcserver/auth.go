@@ -0,0 +1,95 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/pkg/loyaltypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type tokenKey struct{}
+
+type programKey struct{}
+
+// public methods don't require a token.
+var public = map[string]bool{
+	loyaltypb.Loyalty_Register_FullMethodName: true,
+	loyaltypb.Loyalty_Login_FullMethodName:    true,
+}
+
+// AuthInterceptor checks the token from the "authorization" metadata
+// the same way middleware.AuthRequired checks the Authorization header.
+func AuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if public[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "no token was provided")
+	}
+
+	if !cookies.CheckProgram(programID(ctx), values[0]) {
+		return nil, status.Error(codes.Unauthenticated, "bad token")
+	}
+
+	return handler(context.WithValue(ctx, tokenKey{}, values[0]), req)
+}
+
+// tokenInterceptor rejects the tokens revoked by a password change or reset, it goes after AuthInterceptor.
+func (s *Server) tokenInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if public[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	err := s.useCase(ctx).CheckToken(token(ctx))
+	if errors.Is(err, storage.ErrTokenRevoked) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	return handler(ctx, req)
+}
+
+// ProgramInterceptor resolves the "x-program-key" metadata to one of the programs
+// the same way middleware.ProgramRequired resolves the X-Program-Key header.
+func ProgramInterceptor(programs []program.Program) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("x-program-key")
+		if len(values) == 0 || values[0] == "" {
+			return handler(ctx, req)
+		}
+
+		p, ok := program.Find(programs, values[0])
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "bad program key")
+		}
+
+		return handler(context.WithValue(ctx, programKey{}, p.ID), req)
+	}
+}
+
+func programID(ctx context.Context) string {
+	id, ok := ctx.Value(programKey{}).(string)
+	if !ok {
+		return program.DefaultID
+	}
+
+	return id
+}
+
+func token(ctx context.Context) string {
+	t, _ := ctx.Value(tokenKey{}).(string)
+	return t
+}
@@ -0,0 +1,397 @@
+// Package grpcserver serves the loyalty gRPC API over the same usecase as the HTTP handlers.
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gomarket/internal/bruteforce"
+	"gomarket/internal/logger"
+	"gomarket/internal/loyalty/config"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/risk"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/usecase"
+	"gomarket/internal/loyalty/withdrawal"
+	"gomarket/pkg/loyaltypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"strconv"
+	"time"
+)
+
+type Server struct {
+	loyaltypb.UnimplementedLoyaltyServer
+	conf   *config.Config
+	logic  usecase.IUseCase
+	logger logger.ILogger
+	guard  *bruteforce.Guard
+	// programs maps a program ID to its use case, logic serves the default program.
+	programs map[string]usecase.IUseCase
+}
+
+// New returns the gRPC server with the loyalty service registered.
+func New(cfg *config.Config, logic usecase.IUseCase, loggerInstance logger.ILogger) *grpc.Server {
+	if cfg == nil {
+		panic("конфиг равен nil")
+	}
+
+	guard := cfg.Guard
+	if guard == nil && cfg.BruteForce != nil {
+		guard = bruteforce.New(*cfg.BruteForce)
+	} else if guard == nil {
+		guard = bruteforce.New(bruteforce.Config{})
+	}
+
+	programs := make(map[string]usecase.IUseCase, len(cfg.Programs))
+	for _, p := range cfg.Programs {
+		programs[p.ID] = logic.ForProgram(p)
+	}
+
+	s := &Server{
+		conf:     cfg,
+		logic:    logic,
+		logger:   loggerInstance,
+		guard:    guard,
+		programs: programs,
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(ProgramInterceptor(cfg.Programs), AuthInterceptor, s.tokenInterceptor))
+	loyaltypb.RegisterLoyaltyServer(server, s)
+	return server
+}
+
+// useCase returns the use case of the program the call belongs to.
+func (s *Server) useCase(ctx context.Context) usecase.IUseCase {
+	if uc, ok := s.programs[programID(ctx)]; ok {
+		return uc
+	}
+
+	return s.logic
+}
+
+func (s *Server) Register(ctx context.Context, req *loyaltypb.AuthRequest) (*loyaltypb.AuthResponse, error) {
+	err := s.useCase(ctx).CreateUser(req.Login, req.Password, req.ReferralCode)
+	s.securityEvent(ctx, storage.EventRegistration, req.Login, err, "")
+	if errors.Is(err, storage.ErrUsernameConflict) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrBadReferral) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrReferralLimit) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	return &loyaltypb.AuthResponse{Token: s.startSession(ctx, req.Login)}, nil
+}
+
+func (s *Server) Login(ctx context.Context, req *loyaltypb.AuthRequest) (*loyaltypb.AuthResponse, error) {
+	ip := peerIP(ctx)
+	key := program.Qualify(programID(ctx), req.Login)
+	_, err := s.guard.Allow(key, ip)
+	if errors.Is(err, bruteforce.ErrTooManyAttempts) {
+		s.securityEvent(ctx, storage.EventLogin, req.Login, err, "")
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	err = s.useCase(ctx).CheckPassword(req.Login, req.Password)
+	if err != nil {
+		s.securityEvent(ctx, storage.EventLogin, req.Login, err, "")
+	}
+
+	// the attempt is counted as failed by Allow, only the wrong guesses keep it
+	if err != nil && !errors.Is(err, storage.ErrWrongPassword) {
+		s.release(key, ip)
+	}
+
+	if errors.Is(err, storage.ErrWrongPassword) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrUserBlocked) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	err = s.useCase(ctx).VerifyTwoFactor(req.Login, req.Otp)
+	if err != nil {
+		s.securityEvent(ctx, storage.EventLogin, req.Login, err, "two-factor")
+	}
+
+	if err != nil && !errors.Is(err, storage.ErrWrongTwoFactorCode) {
+		s.release(key, ip)
+	}
+
+	if errors.Is(err, storage.ErrTwoFactorRequired) || errors.Is(err, storage.ErrWrongTwoFactorCode) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	if err := s.guard.Succeeded(key, ip); err != nil {
+		s.logger.Warn(err.Error())
+	}
+
+	s.securityEvent(ctx, storage.EventLogin, req.Login, nil, "")
+
+	return &loyaltypb.AuthResponse{Token: s.startSession(ctx, req.Login)}, nil
+}
+
+func (s *Server) UploadOrder(ctx context.Context, req *loyaltypb.UploadOrderRequest) (*loyaltypb.UploadOrderResponse, error) {
+	err := s.useCase(ctx).CheckID(s.conf.AccrualSystemAddress, token(ctx), req.Number)
+	if errors.Is(err, storage.ErrCreatedByThisUser) {
+		return &loyaltypb.UploadOrderResponse{AlreadyUploaded: true}, nil
+	}
+
+	if errors.Is(err, storage.ErrCreatedByAnotherUser) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrBadID) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrUserBlocked) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if errors.Is(err, risk.ErrThrottled) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrPollQueueFull) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	return &loyaltypb.UploadOrderResponse{}, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, _ *loyaltypb.ListOrdersRequest) (*loyaltypb.ListOrdersResponse, error) {
+	res, err := s.useCase(ctx).GetOrders(token(ctx))
+	if errors.Is(err, storage.ErrNoResult) {
+		return &loyaltypb.ListOrdersResponse{}, nil
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	var orders []schema.UserOrder
+	err = json.Unmarshal(res, &orders)
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	resp := &loyaltypb.ListOrdersResponse{Orders: make([]*loyaltypb.Order, 0, len(orders))}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, &loyaltypb.Order{
+			Number:     order.Number,
+			Status:     order.Status,
+			Accrual:    order.Accrual,
+			UploadedAt: order.UploadedAt,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *Server) GetBalance(ctx context.Context, _ *loyaltypb.GetBalanceRequest) (*loyaltypb.Balance, error) {
+	res, err := s.useCase(ctx).GetBalance(token(ctx))
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	var balance schema.Balance
+	err = json.Unmarshal(res, &balance)
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	return &loyaltypb.Balance{
+		Current:   float64(balance.Current),
+		Withdrawn: float64(balance.Withdrawn),
+		Tier:      balance.Tier,
+	}, nil
+}
+
+func (s *Server) Withdraw(ctx context.Context, req *loyaltypb.WithdrawRequest) (*loyaltypb.WithdrawResponse, error) {
+	// the step-up codes are limited by the token like the logins are limited by the login
+	ip := peerIP(ctx)
+	key := program.Qualify(programID(ctx), "2fa:"+token(ctx))
+	if req.Otp != "" {
+		_, err := s.guard.Allow(key, ip)
+		if errors.Is(err, bruteforce.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		if err != nil {
+			return nil, s.internal(err)
+		}
+	}
+
+	err := s.useCase(ctx).DrawBonuses(token(ctx), req.Sum, req.OrderTotal, req.Order, req.Otp)
+	s.securityEvent(ctx, storage.EventWithdrawal, cookies.Username(token(ctx)), err,
+		"sum "+strconv.FormatFloat(req.Sum, 'f', -1, 64)+", order "+req.Order)
+	if req.Otp != "" && !errors.Is(err, storage.ErrWrongTwoFactorCode) {
+		s.release(key, ip)
+	}
+
+	if errors.Is(err, storage.ErrTwoFactorRequired) || errors.Is(err, storage.ErrWrongTwoFactorCode) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrTwoFactorDisabled) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrNotEnoughMoney) || errors.Is(err, withdrawal.ErrCoolingOff) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if errors.Is(err, withdrawal.ErrBelowMin) || errors.Is(err, withdrawal.ErrOrderShare) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if errors.Is(err, withdrawal.ErrDailyLimit) || errors.Is(err, withdrawal.ErrMonthlyLimit) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrBadID) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrUserBlocked) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if errors.Is(err, risk.ErrThrottled) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	return &loyaltypb.WithdrawResponse{}, nil
+}
+
+func (s *Server) ListWithdrawals(ctx context.Context, _ *loyaltypb.ListWithdrawalsRequest) (*loyaltypb.ListWithdrawalsResponse, error) {
+	res, err := s.useCase(ctx).GetWithdrawals(token(ctx))
+	if errors.Is(err, storage.ErrNoWithdrawals) {
+		return &loyaltypb.ListWithdrawalsResponse{}, nil
+	}
+
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	var withdrawals []schema.Withdrawn
+	err = json.Unmarshal(res, &withdrawals)
+	if err != nil {
+		return nil, s.internal(err)
+	}
+
+	resp := &loyaltypb.ListWithdrawalsResponse{Withdrawals: make([]*loyaltypb.Withdrawal, 0, len(withdrawals))}
+	for _, withdrawal := range withdrawals {
+		resp.Withdrawals = append(resp.Withdrawals, &loyaltypb.Withdrawal{
+			Order:       withdrawal.Order,
+			Sum:         withdrawal.Sum,
+			ProcessedAt: withdrawal.ProcessedAt.Format(time.RFC3339),
+		})
+	}
+
+	return resp, nil
+}
+
+// startSession records the sign-in and issues the token like the HTTP handler does.
+func (s *Server) startSession(ctx context.Context, login string) string {
+	generation, err := s.useCase(ctx).AddSession(login, peerIP(ctx), metadataValue(ctx, "user-agent"))
+	if err != nil {
+		s.logger.Warn(err.Error())
+	}
+
+	s.securityEvent(ctx, storage.EventTokenIssued, login, nil, "generation "+strconv.FormatInt(generation, 10))
+	return cookies.NewSessionCookie(programID(ctx), login, generation).Value
+}
+
+// securityEvent appends the event to the security audit log like the HTTP handler does.
+func (s *Server) securityEvent(ctx context.Context, event, login string, err error, details string) {
+	e := schema.SecurityEvent{
+		Event:     event,
+		Login:     login,
+		Success:   err == nil,
+		IP:        peerIP(ctx),
+		UserAgent: metadataValue(ctx, "user-agent"),
+		RequestID: metadataValue(ctx, "x-request-id"),
+		Details:   details,
+	}
+	if err != nil && details != "" {
+		e.Details = details + ": " + err.Error()
+	} else if err != nil {
+		e.Details = err.Error()
+	}
+
+	if err := s.useCase(ctx).RecordSecurityEvent(e); err != nil {
+		s.logger.Warn(err.Error())
+	}
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// release forgets the attempt reserved by the guard, it was not a wrong guess.
+func (s *Server) release(key, ip string) {
+	if err := s.guard.Release(key, ip); err != nil {
+		s.logger.Warn(err.Error())
+	}
+}
+
+func (s *Server) internal(err error) error {
+	s.logger.Warn(err.Error())
+	return status.Error(codes.Internal, err.Error())
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
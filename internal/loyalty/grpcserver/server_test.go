@@ -0,0 +1,180 @@
+package grpcserver
+
+import (
+	"context"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gomarket/internal/logger"
+	"gomarket/internal/loyalty/config"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/storage"
+	servicemocks "gomarket/internal/loyalty/usecase/mocks"
+	"gomarket/pkg/loyaltypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+const adminToken = "8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e"
+
+func newClient(t *testing.T, logic *servicemocks.MockIUseCase) loyaltypb.LoyaltyClient {
+	cfg := config.New()
+	cfg.AccrualSystemAddress = "accrual"
+
+	loggerInstance := httplog.NewLogger("loyalty", httplog.Options{Concise: true})
+	server := New(cfg, logic, logger.New(loggerInstance))
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return loyaltypb.NewLoyaltyClient(conn)
+}
+
+func TestServer_Register(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	logic.EXPECT().CreateUser("admin", "admin", "").Return(nil)
+	logic.EXPECT().CreateUser("admin", "admin", "").Return(storage.ErrUsernameConflict)
+	logic.EXPECT().CreateUser("bob", "bob", "NOPE").Return(storage.ErrBadReferral)
+
+	client := newClient(t, logic)
+
+	resp, err := client.Register(context.Background(), &loyaltypb.AuthRequest{Login: "admin", Password: "admin"})
+	assert.NoError(t, err)
+	assert.True(t, cookies.Check(resp.Token))
+
+	_, err = client.Register(context.Background(), &loyaltypb.AuthRequest{Login: "admin", Password: "admin"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.Register(context.Background(), &loyaltypb.AuthRequest{Login: "bob", Password: "bob", ReferralCode: "NOPE"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_LoginTwoFactor(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	logic.EXPECT().CheckPassword("admin", "admin").Return(nil).Times(3)
+	logic.EXPECT().VerifyTwoFactor("admin", "").Return(storage.ErrTwoFactorRequired)
+	logic.EXPECT().VerifyTwoFactor("admin", "000000").Return(storage.ErrWrongTwoFactorCode)
+	logic.EXPECT().VerifyTwoFactor("admin", "123456").Return(nil)
+
+	client := newClient(t, logic)
+
+	_, err := client.Login(context.Background(), &loyaltypb.AuthRequest{Login: "admin", Password: "admin"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Login(context.Background(), &loyaltypb.AuthRequest{Login: "admin", Password: "admin", Otp: "000000"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	resp, err := client.Login(context.Background(), &loyaltypb.AuthRequest{Login: "admin", Password: "admin", Otp: "123456"})
+	assert.NoError(t, err)
+	assert.True(t, cookies.Check(resp.Token))
+}
+
+func TestServer_Auth(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	logic.EXPECT().GetBalance(adminToken).Return([]byte(`{"current":10.5,"withdrawn":2,"tier":"Silver"}`), nil)
+
+	client := newClient(t, logic)
+
+	_, err := client.GetBalance(context.Background(), &loyaltypb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "bad-token")
+	_, err = client.GetBalance(ctx, &loyaltypb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", adminToken)
+	balance, err := client.GetBalance(ctx, &loyaltypb.GetBalanceRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 10.5, balance.Current)
+	assert.Equal(t, 2.0, balance.Withdrawn)
+	assert.Equal(t, "Silver", balance.Tier)
+}
+
+func TestServer_RevokedToken(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := servicemocks.NewMockIUseCase(c)
+	logic.EXPECT().CheckToken(adminToken).Return(storage.ErrTokenRevoked)
+
+	client := newClient(t, logic)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", adminToken)
+	_, err := client.GetBalance(ctx, &loyaltypb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestServer_UploadOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		code     codes.Code
+		uploaded bool
+	}{
+		{name: "Ok", code: codes.OK},
+		{name: "Already Uploaded", err: storage.ErrCreatedByThisUser, code: codes.OK, uploaded: true},
+		{name: "Another User", err: storage.ErrCreatedByAnotherUser, code: codes.AlreadyExists},
+		{name: "Bad Number", err: storage.ErrBadID, code: codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			logic.EXPECT().CheckID("accrual", adminToken, "12345678903").Return(test.err)
+
+			client := newClient(t, logic)
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", adminToken)
+			resp, err := client.UploadOrder(ctx, &loyaltypb.UploadOrderRequest{Number: "12345678903"})
+			assert.Equal(t, test.code, status.Code(err))
+			if err == nil {
+				assert.Equal(t, test.uploaded, resp.AlreadyUploaded)
+			}
+		})
+	}
+}
+
+func TestServer_ListWithdrawals(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	logic.EXPECT().GetWithdrawals(adminToken).Return(nil, storage.ErrNoWithdrawals)
+
+	client := newClient(t, logic)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", adminToken)
+	resp, err := client.ListWithdrawals(ctx, &loyaltypb.ListWithdrawalsRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Withdrawals)
+}
+
+// newUseCaseMock accepts every token and security event, the revocation and the audit log have their own tests.
+func newUseCaseMock(c *gomock.Controller) *servicemocks.MockIUseCase {
+	logic := servicemocks.NewMockIUseCase(c)
+	logic.EXPECT().CheckToken(gomock.Any()).Return(nil).AnyTimes()
+	logic.EXPECT().RecordSecurityEvent(gomock.Any()).Return(nil).AnyTimes()
+	logic.EXPECT().AddSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), nil).AnyTimes()
+	return logic
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/pkg/bettererror"
+	"net/http"
+)
+
+func (h Handler) GetExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		export, err := h.useCase(r).Export(cookie)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="export.json"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(export)
+	}
+}
+
+func (h Handler) DeleteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		deletion, err := h.useCase(r).DeleteUser(cookie)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrBalanceRemaining) || errors.Is(err, storage.ErrPendingTransfers) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(deletion)
+	}
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/usecase"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+	"strconv"
+)
+
+func (h Handler) GetAdminUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+		limit, offset := pageParams(r)
+
+		users, err := h.useCase(r).FindUsers(middleware.Admin(r), query.Get("q"), limit, offset)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(users)
+	}
+}
+
+func (h Handler) GetAdminUser() http.HandlerFunc {
+	return h.adminUserView(usecase.IUseCase.GetUser)
+}
+
+func (h Handler) GetAdminUserOrders() http.HandlerFunc {
+	return h.adminUserView(usecase.IUseCase.GetUserOrders)
+}
+
+func (h Handler) GetAdminUserWithdrawals() http.HandlerFunc {
+	return h.adminUserView(usecase.IUseCase.GetUserWithdrawals)
+}
+
+func (h Handler) adminUserView(view func(uc usecase.IUseCase, admin, login string) ([]byte, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		res, err := view(h.useCase(r), middleware.Admin(r), chi.URLParam(r, "login"))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrNoResult) || errors.Is(err, storage.ErrNoWithdrawals) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(res)
+	}
+}
+
+func (h Handler) PostAdminAdjustment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req schema.AdjustmentRequest
+		err := BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		adjustment, err := h.useCase(r).AdjustBalance(middleware.Admin(r), chi.URLParam(r, "login"), req.Sum, req.Reason)
+		h.securityEvent(r, storage.EventAdjustment, chi.URLParam(r, "login"), err,
+			"admin "+middleware.Admin(r)+", sum "+strconv.FormatFloat(req.Sum, 'f', -1, 64)+", "+req.Reason)
+		if errors.Is(err, storage.ErrBadAdjustment) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrNotEnoughMoney) {
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(adjustment)
+	}
+}
+
+func (h Handler) PostAdminBlock() http.HandlerFunc {
+	return h.adminBlock(true)
+}
+
+func (h Handler) PostAdminUnblock() http.HandlerFunc {
+	return h.adminBlock(false)
+}
+
+func (h Handler) adminBlock(blocked bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req schema.BlockRequest
+		if r.ContentLength != 0 {
+			err := BindJSON(w, r, &req)
+			if err != nil {
+				return
+			}
+		}
+
+		err := h.useCase(r).BlockUser(middleware.Admin(r), chi.URLParam(r, "login"), req.Reason, blocked)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h Handler) GetAdminActions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		limit, offset := pageParams(r)
+
+		actions, err := h.useCase(r).GetAdminActions(middleware.Admin(r), r.URL.Query().Get("login"), limit, offset)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(actions)
+	}
+}
+
+// pageParams reads limit and offset query params, the usecase applies defaults for zeroes.
+func pageParams(r *http.Request) (limit, offset int) {
+	query := r.URL.Query()
+	limit, _ = strconv.Atoi(query.Get("limit"))
+	offset, _ = strconv.Atoi(query.Get("offset"))
+	return limit, offset
+}
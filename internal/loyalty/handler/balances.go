@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+)
+
+// PostAdminBalanceCheck recomputes the balances and reports the mismatches,
+// they are repaired only with "repair", otherwise the check is a dry run.
+func (h Handler) PostAdminBalanceCheck() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req schema.BalanceCheckRequest
+		err := BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		check, err := h.useCase(r).CheckBalances(middleware.Admin(r), req)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(check)
+	}
+}
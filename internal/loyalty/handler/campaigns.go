@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+	"strconv"
+)
+
+func (h Handler) GetAdminCampaigns() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		campaigns, err := h.useCase(r).GetCampaigns(middleware.Admin(r))
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(campaigns)
+	}
+}
+
+func (h Handler) PostAdminCampaign() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req schema.CampaignRequest
+		err := BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		campaign, err := h.useCase(r).CreateCampaign(middleware.Admin(r), req)
+		h.writeCampaign(w, campaign, err)
+	}
+}
+
+func (h Handler) PutAdminCampaign() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		var req schema.CampaignRequest
+		err = BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		campaign, err := h.useCase(r).UpdateCampaign(middleware.Admin(r), id, req)
+		h.writeCampaign(w, campaign, err)
+	}
+}
+
+func (h Handler) writeCampaign(w http.ResponseWriter, campaign []byte, err error) {
+	if errors.Is(err, storage.ErrBadCampaign) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+		return
+	}
+
+	if errors.Is(err, storage.ErrCampaignNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+		return
+	}
+
+	if err != nil {
+		h.logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(campaign)
+}
@@ -1,23 +1,36 @@
 package handler
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"github.com/go-chi/chi"
+	"gomarket/internal/bruteforce"
 	"gomarket/internal/logger"
+	"gomarket/internal/loyalty/accrual"
 	"gomarket/internal/loyalty/config"
 	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/risk"
 	"gomarket/internal/loyalty/schema"
 	"gomarket/internal/loyalty/storage"
 	"gomarket/internal/loyalty/usecase"
+	"gomarket/internal/loyalty/withdrawal"
+	"gomarket/internal/middleware"
 	"gomarket/pkg/bettererror"
+	"gomarket/pkg/loyaltyapi"
 	"io"
 	"net/http"
+	"strconv"
 )
 
 type Handler struct {
 	conf   *config.Config
 	logic  usecase.IUseCase
 	logger logger.ILogger
+	guard  *bruteforce.Guard
+	// programs maps a program ID to its use case, logic serves the default program.
+	programs map[string]usecase.IUseCase
 }
 
 func NewHandler(cfg *config.Config, logic usecase.IUseCase, loggerInstance logger.ILogger) *Handler {
@@ -25,7 +38,28 @@ func NewHandler(cfg *config.Config, logic usecase.IUseCase, loggerInstance logge
 		panic("конфиг равен nil")
 	}
 
-	return &Handler{conf: cfg, logic: logic, logger: loggerInstance}
+	guard := cfg.Guard
+	if guard == nil && cfg.BruteForce != nil {
+		guard = bruteforce.New(*cfg.BruteForce)
+	} else if guard == nil {
+		guard = bruteforce.New(bruteforce.Config{})
+	}
+
+	programs := make(map[string]usecase.IUseCase, len(cfg.Programs))
+	for _, p := range cfg.Programs {
+		programs[p.ID] = logic.ForProgram(p)
+	}
+
+	return &Handler{conf: cfg, logic: logic, logger: loggerInstance, guard: guard, programs: programs}
+}
+
+// useCase returns the use case of the program the request belongs to.
+func (h Handler) useCase(r *http.Request) usecase.IUseCase {
+	if uc, ok := h.programs[middleware.Program(r)]; ok {
+		return uc
+	}
+
+	return h.logic
 }
 
 func BindJSON(w http.ResponseWriter, r *http.Request, obj any) error {
@@ -50,7 +84,8 @@ func (h Handler) PostRegister() http.HandlerFunc {
 			return
 		}
 
-		err = h.logic.CreateUser(cred.Login, cred.Password)
+		err = h.useCase(r).CreateUser(cred.Login, cred.Password, cred.ReferralCode)
+		h.securityEvent(r, storage.EventRegistration, cred.Login, err, "")
 		if err != nil {
 			if err == storage.ErrUsernameConflict {
 				w.WriteHeader(http.StatusConflict)
@@ -58,13 +93,25 @@ func (h Handler) PostRegister() http.HandlerFunc {
 				return
 			}
 
+			if errors.Is(err, storage.ErrBadReferral) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+				return
+			}
+
+			if errors.Is(err, storage.ErrReferralLimit) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+				return
+			}
+
 			h.logger.Warn(err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
 			return
 		}
 
-		cookies.Set(w, cred.Login)
+		h.startSession(w, r, cred.Login)
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -78,11 +125,60 @@ func (h Handler) PostLogin() http.HandlerFunc {
 			return
 		}
 
-		err = h.logic.CheckPassword(cred.Login, cred.Password)
+		ip := clientIP(r)
+		key := program.Qualify(middleware.Program(r), cred.Login)
+		wait, err := h.guard.Allow(key, ip)
+		if errors.Is(err, bruteforce.ErrTooManyAttempts) {
+			h.securityEvent(r, storage.EventLogin, cred.Login, err, "")
+			w.Header().Set("Retry-After", retryAfter(wait))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		} else if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		err = h.useCase(r).CheckPassword(cred.Login, cred.Password)
+		if err != nil {
+			h.securityEvent(r, storage.EventLogin, cred.Login, err, "")
+		}
+
+		// the attempt is counted as failed by Allow, only the wrong guesses keep it
+		if err != nil && err != storage.ErrWrongPassword {
+			h.release(key, ip)
+		}
+
 		if err == storage.ErrWrongPassword {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
 			return
+		} else if errors.Is(err, storage.ErrUserBlocked) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		} else if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		err = h.useCase(r).VerifyTwoFactor(cred.Login, cred.OTP)
+		if err != nil {
+			h.securityEvent(r, storage.EventLogin, cred.Login, err, "two-factor")
+		}
+
+		if err != nil && !errors.Is(err, storage.ErrWrongTwoFactorCode) {
+			h.release(key, ip)
+		}
+
+		if status, ok := twoFactorStatus(err); ok {
+			w.WriteHeader(status)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
 		} else if err != nil {
 			h.logger.Warn(err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -90,11 +186,27 @@ func (h Handler) PostLogin() http.HandlerFunc {
 			return
 		}
 
-		cookies.Set(w, cred.Login)
+		if err := h.guard.Succeeded(key, ip); err != nil {
+			h.logger.Warn(err.Error())
+		}
+
+		h.securityEvent(r, storage.EventLogin, cred.Login, nil, "")
+		h.startSession(w, r, cred.Login)
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// startSession records the sign-in and issues the token, the token is issued even if the record fails.
+func (h Handler) startSession(w http.ResponseWriter, r *http.Request, login string) {
+	generation, err := h.useCase(r).AddSession(login, clientIP(r), r.UserAgent())
+	if err != nil {
+		h.logger.Warn(err.Error())
+	}
+
+	cookies.SetSession(w, middleware.Program(r), login, generation)
+	h.securityEvent(r, storage.EventTokenIssued, login, nil, "generation "+strconv.FormatInt(generation, 10))
+}
+
 func (h Handler) PostOrders() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := cookies.Get(r)
@@ -111,7 +223,19 @@ func (h Handler) PostOrders() http.HandlerFunc {
 			return
 		}
 
-		err = h.logic.CheckID(h.conf.AccrualSystemAddress, cookie, string(id))
+		err = h.useCase(r).CheckID(h.conf.AccrualSystemAddress, cookie, string(id))
+		if errors.Is(err, risk.ErrThrottled) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserBlocked) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
 		if errors.Is(err, storage.ErrCreatedByThisUser) {
 			w.WriteHeader(http.StatusOK)
 			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
@@ -130,6 +254,12 @@ func (h Handler) PostOrders() http.HandlerFunc {
 			return
 		}
 
+		if errors.Is(err, storage.ErrPollQueueFull) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
 		if err != nil {
 			h.logger.Warn(err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -152,7 +282,7 @@ func (h Handler) GetUserOrders() http.HandlerFunc {
 			return
 		}
 
-		orders, err := h.logic.GetOrders(cookie)
+		orders, err := h.useCase(r).GetOrders(cookie)
 		if errors.Is(err, storage.ErrNoResult) {
 			w.WriteHeader(http.StatusNoContent)
 			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
@@ -181,7 +311,7 @@ func (h Handler) GetBalance() http.HandlerFunc {
 			return
 		}
 
-		balance, err := h.logic.GetBalance(cookie)
+		balance, err := h.useCase(r).GetBalance(cookie)
 		if err != nil {
 			h.logger.Warn(err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -210,15 +340,40 @@ func (h Handler) PostWithdraw() http.HandlerFunc {
 			return
 		}
 
-		err = h.logic.DrawBonuses(cookie, withdrawn.Sum, withdrawn.Order)
-		if errors.Is(err, storage.ErrNotEnoughMoney) {
-			w.WriteHeader(http.StatusPaymentRequired)
+		if withdrawn.OTP != "" && !h.allowCode(w, r, cookie) {
+			return
+		}
+
+		if h.trustedService(r) {
+			err = h.useCase(r).DrawServiceBonuses(cookie, withdrawn.Sum, withdrawn.OrderTotal, withdrawn.Order)
+		} else {
+			err = h.useCase(r).DrawBonuses(cookie, withdrawn.Sum, withdrawn.OrderTotal, withdrawn.Order, withdrawn.OTP)
+		}
+		h.securityEvent(r, storage.EventWithdrawal, cookies.Username(cookie), err, withdrawalDetails(withdrawn.Sum, withdrawn.Order))
+		if withdrawn.OTP != "" {
+			h.codeChecked(r, cookie, err)
+		}
+
+		if status, ok := twoFactorStatus(err); ok {
+			w.WriteHeader(status)
 			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
 			return
 		}
 
-		if errors.Is(err, storage.ErrBadID) {
-			w.WriteHeader(http.StatusUnprocessableEntity)
+		if status, code, ok := withdrawalStatus(err); ok {
+			w.WriteHeader(status)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).SetCode(code).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserBlocked) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrNotEnoughMoney) {
+			w.WriteHeader(http.StatusPaymentRequired)
 			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
 			return
 		}
@@ -234,6 +389,51 @@ func (h Handler) PostWithdraw() http.HandlerFunc {
 	}
 }
 
+// twoFactorStatus maps the errors of the two-factor challenge to the response statuses.
+// A missing or wrong code is an authentication error, a user without two-factor authentication
+// is forbidden from the operations that require it.
+func twoFactorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, storage.ErrTwoFactorRequired), errors.Is(err, storage.ErrWrongTwoFactorCode):
+		return http.StatusUnauthorized, true
+	case errors.Is(err, storage.ErrTwoFactorDisabled):
+		return http.StatusForbidden, true
+	}
+
+	return 0, false
+}
+
+// trustedService reports whether the request is sent by the service with the configured token.
+func (h Handler) trustedService(r *http.Request) bool {
+	token := r.Header.Get("X-Service-Token")
+	return h.conf.ServiceToken != "" && subtle.ConstantTimeCompare([]byte(h.conf.ServiceToken), []byte(token)) == 1
+}
+
+// withdrawalStatus maps the rules that reject a withdrawal to the response statuses and to the codes
+// in the body, as some of the rules share a status.
+func withdrawalStatus(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, storage.ErrBadID):
+		return http.StatusUnprocessableEntity, "bad_order", true
+	case errors.Is(err, storage.ErrWithdrawalExists):
+		return http.StatusUnprocessableEntity, "withdrawal_exists", true
+	case errors.Is(err, withdrawal.ErrBelowMin):
+		return http.StatusUnprocessableEntity, "below_min", true
+	case errors.Is(err, withdrawal.ErrOrderShare):
+		return http.StatusConflict, "order_share", true
+	case errors.Is(err, withdrawal.ErrDailyLimit):
+		return http.StatusTooManyRequests, "daily_limit", true
+	case errors.Is(err, risk.ErrThrottled):
+		return http.StatusTooManyRequests, "throttled", true
+	case errors.Is(err, withdrawal.ErrMonthlyLimit):
+		return http.StatusLocked, "monthly_limit", true
+	case errors.Is(err, withdrawal.ErrCoolingOff):
+		return http.StatusTooEarly, "cooling_off", true
+	}
+
+	return 0, "", false
+}
+
 func (h Handler) GetWithdrawals() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -244,7 +444,7 @@ func (h Handler) GetWithdrawals() http.HandlerFunc {
 			return
 		}
 
-		withdrawals, err := h.logic.GetWithdrawals(cookie)
+		withdrawals, err := h.useCase(r).GetWithdrawals(cookie)
 		if errors.Is(err, storage.ErrNoWithdrawals) {
 			w.WriteHeader(http.StatusNoContent)
 			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
@@ -263,14 +463,182 @@ func (h Handler) GetWithdrawals() http.HandlerFunc {
 	}
 }
 
-// PingAccrual used for keep alive Accrual
+// GetOpenAPI serves the OpenAPI document of the API.
+func (h Handler) GetOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(loyaltyapi.Spec)
+	}
+}
+
+// PingAccrual used for keep alive Accrual, it responds with the state of the circuit breaker
+// of the accrual requests.
 func (h Handler) PingAccrual() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		resp, err := http.Get(h.conf.AccrualSystemAddress)
+		w.Header().Set("Content-Type", "application/json")
+		client := h.conf.Logic.Accrual
+		err := client.Ping(h.conf.AccrualSystemAddress)
+		if err != nil && !errors.Is(err, accrual.ErrOpen) {
+			h.logger.Warn(err.Error())
+		}
+
+		state, err := json.Marshal(client.State(h.conf.AccrualSystemAddress))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(state)
+	}
+}
+
+func (h Handler) PostTransfer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		var req schema.TransferRequest
+		err = BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		transfer, err := h.useCase(r).Transfer(cookie, req.Login, req.Sum)
+		if errors.Is(err, risk.ErrThrottled) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).SetCode("throttled").JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserBlocked) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrNotEnoughMoney) {
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrBadTransfer) || errors.Is(err, storage.ErrTransferLimit) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
 		if err != nil {
 			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		res, err := json.Marshal(transfer)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		if transfer.Status == storage.TransferPending {
+			w.WriteHeader(http.StatusAccepted)
+		} else {
+			w.WriteHeader(http.StatusOK)
 		}
-		defer resp.Body.Close()
+		w.Write(res)
+	}
+}
+
+func (h Handler) GetTransfers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		transfers, err := h.useCase(r).GetTransfers(cookie)
+		if errors.Is(err, storage.ErrNoTransfers) {
+			w.WriteHeader(http.StatusNoContent)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(transfers)
+	}
+}
+
+func (h Handler) PostAcceptTransfer() http.HandlerFunc {
+	return h.completeTransfer(usecase.IUseCase.AcceptTransfer)
+}
+
+func (h Handler) PostDeclineTransfer() http.HandlerFunc {
+	return h.completeTransfer(usecase.IUseCase.DeclineTransfer)
+}
+
+func (h Handler) completeTransfer(complete func(uc usecase.IUseCase, cookie string, id int64) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		err = complete(h.useCase(r), cookie, id)
+		if errors.Is(err, storage.ErrTransferNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserBlocked) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -1,21 +1,32 @@
 package handler
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/httplog"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"gomarket/internal/bruteforce"
 	"gomarket/internal/logger"
+	"gomarket/internal/loyalty/accrual"
 	"gomarket/internal/loyalty/config"
 	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/risk"
+	"gomarket/internal/loyalty/schema"
 	"gomarket/internal/loyalty/storage"
 	servicemocks "gomarket/internal/loyalty/usecase/mocks"
+	"gomarket/internal/loyalty/withdrawal"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"gomarket/pkg/loyaltyapi"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandler_Registration(t *testing.T) {
@@ -31,8 +42,10 @@ func TestHandler_Registration(t *testing.T) {
 		{
 			name: "Ok",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().CreateUser("admin", "admin").
+				r.EXPECT().CreateUser("admin", "admin", "").
 					Return(nil).AnyTimes()
+				r.EXPECT().AddSession("admin", "192.0.2.1", "").
+					Return(int64(0), nil).AnyTimes()
 			},
 			body:               `{"login": "admin", "password": "admin"}`,
 			expectedStatusCode: 200,
@@ -40,7 +53,7 @@ func TestHandler_Registration(t *testing.T) {
 		{
 			name: "Bad Request",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().CreateUser("admin", "admin").
+				r.EXPECT().CreateUser("admin", "admin", "").
 					Return(nil).AnyTimes()
 			},
 			body:               ``,
@@ -49,7 +62,7 @@ func TestHandler_Registration(t *testing.T) {
 		{
 			name: "Internal Server Error",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().CreateUser("admin", "admin").
+				r.EXPECT().CreateUser("admin", "admin", "").
 					Return(errors.New("DB error")).AnyTimes()
 			},
 			body:               `{"login": "admin", "password": "admin"}`,
@@ -58,19 +71,48 @@ func TestHandler_Registration(t *testing.T) {
 		{
 			name: "User Already Exists",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().CreateUser("admin", "admin").
+				r.EXPECT().CreateUser("admin", "admin", "").
 					Return(storage.ErrUsernameConflict).AnyTimes()
 			},
 			body:               `{"login": "admin", "password": "admin"}`,
 			expectedStatusCode: 409,
 		},
+		{
+			name: "Referred",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CreateUser("admin", "admin", "ABCD2345").
+					Return(nil).AnyTimes()
+				r.EXPECT().AddSession("admin", "192.0.2.1", "").
+					Return(int64(0), nil).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin", "referral_code": "ABCD2345"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Unknown Referral Code",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CreateUser("admin", "admin", "NOPE").
+					Return(storage.ErrBadReferral).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin", "referral_code": "NOPE"}`,
+			expectedStatusCode: 422,
+		},
+		{
+			name: "Referral Limit",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CreateUser("admin", "admin", "ABCD2345").
+					Return(storage.ErrReferralLimit).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin", "referral_code": "ABCD2345"}`,
+			expectedStatusCode: 429,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
-			logic := servicemocks.NewMockIUseCase(c)
+			logic := newUseCaseMock(c)
 			test.mockBehavior(logic)
 			cfg := config.New()
 
@@ -108,6 +150,23 @@ func TestHandler_Login(t *testing.T) {
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
 				r.EXPECT().CheckPassword("admin", "admin").
 					Return(nil).AnyTimes()
+				r.EXPECT().VerifyTwoFactor("admin", "").
+					Return(nil).AnyTimes()
+				r.EXPECT().AddSession("admin", "192.0.2.1", "").
+					Return(int64(0), nil).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Session Not Recorded",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckPassword("admin", "admin").
+					Return(nil).AnyTimes()
+				r.EXPECT().VerifyTwoFactor("admin", "").
+					Return(nil).AnyTimes()
+				r.EXPECT().AddSession("admin", "192.0.2.1", "").
+					Return(int64(0), errors.New("DB error")).AnyTimes()
 			},
 			body:               `{"login": "admin", "password": "admin"}`,
 			expectedStatusCode: 200,
@@ -139,13 +198,57 @@ func TestHandler_Login(t *testing.T) {
 			body:               `{"login": "admin", "password": "admin"}`,
 			expectedStatusCode: 401,
 		},
+		{
+			name: "Blocked",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckPassword("admin", "admin").
+					Return(storage.ErrUserBlocked).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin"}`,
+			expectedStatusCode: 403,
+		},
+		{
+			name: "Two-Factor Challenge",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckPassword("admin", "admin").
+					Return(nil).AnyTimes()
+				r.EXPECT().VerifyTwoFactor("admin", "").
+					Return(storage.ErrTwoFactorRequired).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin"}`,
+			expectedStatusCode: 401,
+		},
+		{
+			name: "Two-Factor Code",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckPassword("admin", "admin").
+					Return(nil).AnyTimes()
+				r.EXPECT().VerifyTwoFactor("admin", "123456").
+					Return(nil).AnyTimes()
+				r.EXPECT().AddSession("admin", "192.0.2.1", "").
+					Return(int64(0), nil).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin", "otp": "123456"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Wrong Two-Factor Code",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckPassword("admin", "admin").
+					Return(nil).AnyTimes()
+				r.EXPECT().VerifyTwoFactor("admin", "000000").
+					Return(storage.ErrWrongTwoFactorCode).AnyTimes()
+			},
+			body:               `{"login": "admin", "password": "admin", "otp": "000000"}`,
+			expectedStatusCode: 401,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
-			logic := servicemocks.NewMockIUseCase(c)
+			logic := newUseCaseMock(c)
 			test.mockBehavior(logic)
 			cfg := config.New()
 			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
@@ -168,6 +271,38 @@ func TestHandler_Login(t *testing.T) {
 	}
 }
 
+func TestHandler_LoginLockout(t *testing.T) {
+	url := "http://localhost:8080/api/user/login"
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	logic.EXPECT().CheckPassword("admin", "wrong").
+		Return(storage.ErrWrongPassword).Times(2)
+
+	cfg := config.New()
+	cfg.BruteForce = &bruteforce.Config{MaxLoginFailures: 2, FreeAttempts: 5}
+	loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+		Concise: true,
+	})
+
+	h := NewHandler(cfg, logic, logger.New(loggerInstance))
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+
+	for _, expectedStatusCode := range []int{401, 401, 429} {
+		r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(`{"login": "admin", "password": "wrong"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, expectedStatusCode, w.Code)
+		if expectedStatusCode == 429 {
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
 func TestHandler_PostOrders(t *testing.T) {
 	type mockBehavior func(r *servicemocks.MockIUseCase)
 	url := "http://localhost:8080/api/user/orders"
@@ -205,6 +340,24 @@ func TestHandler_PostOrders(t *testing.T) {
 			body:               `12345678903`,
 			expectedStatusCode: 409,
 		},
+		{
+			name: "Throttled",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckID("", "8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "12345678903").
+					Return(risk.ErrThrottled).AnyTimes()
+			},
+			body:               `12345678903`,
+			expectedStatusCode: 429,
+		},
+		{
+			name: "Queue Full",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckID("", "8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "12345678903").
+					Return(storage.ErrPollQueueFull).AnyTimes()
+			},
+			body:               `12345678903`,
+			expectedStatusCode: 503,
+		},
 		{
 			name: "Bad format",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
@@ -239,7 +392,7 @@ func TestHandler_PostOrders(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
-			logic := servicemocks.NewMockIUseCase(c)
+			logic := newUseCaseMock(c)
 			test.mockBehavior(logic)
 			cfg := config.New()
 			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
@@ -306,7 +459,7 @@ func TestHandler_GetUserOrders(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
-			logic := servicemocks.NewMockIUseCase(c)
+			logic := newUseCaseMock(c)
 			test.mockBehavior(logic)
 			cfg := config.New()
 			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
@@ -368,7 +521,7 @@ func TestHandler_GetBalance(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
-			logic := servicemocks.NewMockIUseCase(c)
+			logic := newUseCaseMock(c)
 			test.mockBehavior(logic)
 			cfg := config.New()
 			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
@@ -395,6 +548,83 @@ func TestHandler_GetBalance(t *testing.T) {
 	}
 }
 
+func TestHandler_Programs(t *testing.T) {
+	url := "http://localhost:8080/api/user/balance"
+	coffeeToken := cookies.NewProgramCookie("coffee", "admin").Value
+	defaultToken := cookies.NewCookie("admin").Value
+	tests := []struct {
+		name               string
+		programKey         string
+		token              string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Ok",
+			programKey:         "coffee-key",
+			token:              coffeeToken,
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Default",
+			token:              defaultToken,
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Token of the default program",
+			programKey:         "coffee-key",
+			token:              defaultToken,
+			expectedStatusCode: 401,
+		},
+		{
+			name:               "Token of another program",
+			token:              coffeeToken,
+			expectedStatusCode: 401,
+		},
+		{
+			name:               "Unknown program",
+			programKey:         "unknown",
+			token:              coffeeToken,
+			expectedStatusCode: 401,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			cfg := config.New()
+			cfg.Programs = []program.Program{{ID: "coffee", Rate: 2, APIKey: "coffee-key"}}
+
+			logic := newUseCaseMock(c)
+			coffee := newUseCaseMock(c)
+			logic.EXPECT().ForProgram(cfg.Programs[0]).Return(coffee)
+			logic.EXPECT().GetBalance(defaultToken).Return([]byte(""), nil).AnyTimes()
+			coffee.EXPECT().GetBalance(coffeeToken).Return([]byte(""), nil).AnyTimes()
+
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, url, nil)
+			r.Header.Set("Authorization", test.token)
+			if test.programKey != "" {
+				r.Header.Set("X-Program-Key", test.programKey)
+			}
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
 func TestHandler_PostWithdraw(t *testing.T) {
 	type mockBehavior func(r *servicemocks.MockIUseCase)
 	url := "http://localhost:8080/api/user/balance/withdraw"
@@ -402,12 +632,14 @@ func TestHandler_PostWithdraw(t *testing.T) {
 		name               string
 		mockBehavior       mockBehavior
 		body               string
+		serviceToken       string
 		expectedStatusCode int
+		expectedCode       string
 	}{
 		{
 			name: "Ok",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, "2377225624").
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
 					Return(nil).AnyTimes()
 			},
 			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
@@ -416,39 +648,166 @@ func TestHandler_PostWithdraw(t *testing.T) {
 		{
 			name: "Not Enough Funds",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, "2377225624").
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
 					Return(storage.ErrNotEnoughMoney).AnyTimes()
 			},
 			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
 			expectedStatusCode: 402,
 		},
+		{
+			name: "Throttled",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
+					Return(risk.ErrThrottled).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
+			expectedStatusCode: 429,
+			expectedCode:       "throttled",
+		},
 		{
 			name: "Wrong ID",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, "2377225624").
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
 					Return(storage.ErrBadID).AnyTimes()
 			},
 			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
 			expectedStatusCode: 422,
 		},
+		{
+			name: "Another Sum For Order",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
+					Return(storage.ErrWithdrawalExists).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
+			expectedStatusCode: 422,
+			expectedCode:       "withdrawal_exists",
+		},
 		{
 			name: "Internal Server Error",
 			mockBehavior: func(r *servicemocks.MockIUseCase) {
-				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, "2377225624").
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
 					Return(errors.New("DB Error")).AnyTimes()
 			},
 			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
 			expectedStatusCode: 500,
 		},
+		{
+			name: "Below Min",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 1000.0, "2377225624", "").
+					Return(withdrawal.ErrBelowMin).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751,\"order_total\": 1000} ",
+			expectedStatusCode: 422,
+			expectedCode:       "below_min",
+		},
+		{
+			name: "Over Order Share",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 1000.0, "2377225624", "").
+					Return(withdrawal.ErrOrderShare).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751,\"order_total\": 1000} ",
+			expectedStatusCode: 409,
+			expectedCode:       "order_share",
+		},
+		{
+			name: "Daily Limit",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 1000.0, "2377225624", "").
+					Return(withdrawal.ErrDailyLimit).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751,\"order_total\": 1000} ",
+			expectedStatusCode: 429,
+			expectedCode:       "daily_limit",
+		},
+		{
+			name: "Monthly Limit",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 1000.0, "2377225624", "").
+					Return(withdrawal.ErrMonthlyLimit).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751,\"order_total\": 1000} ",
+			expectedStatusCode: 423,
+			expectedCode:       "monthly_limit",
+		},
+		{
+			name: "Cooling Off",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 1000.0, "2377225624", "").
+					Return(withdrawal.ErrCoolingOff).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751,\"order_total\": 1000} ",
+			expectedStatusCode: 425,
+			expectedCode:       "cooling_off",
+		},
+		{
+			name: "Step-Up Required",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
+					Return(storage.ErrTwoFactorRequired).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
+			expectedStatusCode: 401,
+		},
+		{
+			name: "Step-Up Code",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "123456").
+					Return(nil).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751,\"otp\": \"123456\"} ",
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Wrong Step-Up Code",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "000000").
+					Return(storage.ErrWrongTwoFactorCode).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751,\"otp\": \"000000\"} ",
+			expectedStatusCode: 401,
+		},
+		{
+			name: "Two-Factor Not Enabled",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
+					Return(storage.ErrTwoFactorDisabled).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
+			expectedStatusCode: 403,
+		},
+		{
+			name: "Trusted Service",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawServiceBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624").
+					Return(nil).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
+			serviceToken:       "market",
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Wrong Service Token",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", 751.0, 0.0, "2377225624", "").
+					Return(storage.ErrTwoFactorRequired).AnyTimes()
+			},
+			body:               "{\"order\": \"2377225624\",\"sum\": 751} ",
+			serviceToken:       "guess",
+			expectedStatusCode: 401,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
-			logic := servicemocks.NewMockIUseCase(c)
+			logic := newUseCaseMock(c)
 			test.mockBehavior(logic)
 			cfg := config.New()
+			cfg.ServiceToken = "market"
 			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
 				Concise: true,
 			})
@@ -458,6 +817,9 @@ func TestHandler_PostWithdraw(t *testing.T) {
 			r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(test.body))
 			cookie := cookies.NewCookie("admin")
 			r.AddCookie(cookie)
+			if test.serviceToken != "" {
+				r.Header.Set("X-Service-Token", test.serviceToken)
+			}
 			w := httptest.NewRecorder()
 			router := chi.NewRouter()
 
@@ -468,6 +830,11 @@ func TestHandler_PostWithdraw(t *testing.T) {
 			// Assert
 			log.Println(w.Body)
 			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedCode != "" {
+				var body bettererror.BetterError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, test.expectedCode, body.Code)
+			}
 		})
 	}
 }
@@ -512,7 +879,7 @@ func TestHandler_GetWithdrawals(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
-			logic := servicemocks.NewMockIUseCase(c)
+			logic := newUseCaseMock(c)
 			test.mockBehavior(logic)
 			cfg := config.New()
 			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
@@ -538,3 +905,1935 @@ func TestHandler_GetWithdrawals(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_PostTransfer(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/user/balance/transfer"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name: "Ok",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{Status: storage.TransferCompleted}, nil).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Waits for confirmation",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{Status: storage.TransferPending}, nil).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 202,
+		},
+		{
+			name: "Bad Request",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(schema.Transfer{}, nil).AnyTimes()
+			},
+			body:               `{"login": `,
+			expectedStatusCode: 400,
+		},
+		{
+			name: "Not Enough Funds",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{}, storage.ErrNotEnoughMoney).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 402,
+		},
+		{
+			name: "Recipient Not Found",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{}, storage.ErrUserNotFound).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 404,
+		},
+		{
+			name: "Limit Exceeded",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{}, storage.ErrTransferLimit).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 422,
+		},
+		{
+			name: "Recipient Blocked",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{}, storage.ErrUserBlocked).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 403,
+		},
+		{
+			name: "Throttled",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{}, risk.ErrThrottled).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 429,
+		},
+		{
+			name: "Internal Server Error",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Transfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "admin2", 100.0).
+					Return(schema.Transfer{}, errors.New("DB Error")).AnyTimes()
+			},
+			body:               `{"login": "admin2", "sum": 100}`,
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(test.body))
+			cookie := cookies.NewCookie("admin")
+			r.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetTransfers(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/user/transfers"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Ok",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetTransfers("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(""), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "No content",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetTransfers("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(""), storage.ErrNoTransfers).AnyTimes()
+			},
+			expectedStatusCode: 204,
+		},
+		{
+			name: "Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetTransfers("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(""), errors.New("err with DB")).AnyTimes()
+			},
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, url, nil)
+			cookie := cookies.NewCookie("admin")
+			r.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetReferrals(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/user/referrals"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Ok",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetReferrals("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(`{"code":"ABCD2345","earned":0,"referrals":[]}`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetReferrals("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(""), errors.New("err with DB")).AnyTimes()
+			},
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, url, nil)
+			r.AddCookie(cookies.NewCookie("admin"))
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_CompleteTransfer(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		url                string
+		expectedStatusCode int
+	}{
+		{
+			name: "Accept",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().AcceptTransfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", int64(1)).
+					Return(nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/transfers/1/accept",
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Decline",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DeclineTransfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", int64(1)).
+					Return(nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/transfers/1/decline",
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Bad ID",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			url:                "http://localhost:8080/api/user/transfers/abc/accept",
+			expectedStatusCode: 400,
+		},
+		{
+			name: "Not Found",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().AcceptTransfer("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", int64(2)).
+					Return(storage.ErrTransferNotFound).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/transfers/2/accept",
+			expectedStatusCode: 404,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, test.url, nil)
+			cookie := cookies.NewCookie("admin")
+			r.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetAdminUsers(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/admin/users?q=adm&limit=10"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		token              string
+		expectedStatusCode int
+	}{
+		{
+			name: "Ok",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().FindUsers("support", "adm", 10, 0).
+					Return([]byte("[]"), nil).AnyTimes()
+			},
+			token:              "secret",
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Bad token",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			token:              "wrong",
+			expectedStatusCode: 401,
+		},
+		{
+			name:               "No token",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 401,
+		},
+		{
+			name: "Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().FindUsers("support", "adm", 10, 0).
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			token:              "secret",
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, url, nil)
+			if test.token != "" {
+				r.Header.Set("X-Admin-Token", test.token)
+			}
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_PostAdminAdjustment(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/admin/users/admin/adjustments"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name: "Ok",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().AdjustBalance("support", "admin", 100.0, "lost receipt").
+					Return([]byte("{}"), nil).AnyTimes()
+			},
+			body:               `{"sum": 100, "reason": "lost receipt"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "No reason",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().AdjustBalance("support", "admin", 100.0, "").
+					Return(nil, storage.ErrBadAdjustment).AnyTimes()
+			},
+			body:               `{"sum": 100}`,
+			expectedStatusCode: 422,
+		},
+		{
+			name: "Not enough money",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().AdjustBalance("support", "admin", -100.0, "fraud").
+					Return(nil, storage.ErrNotEnoughMoney).AnyTimes()
+			},
+			body:               `{"sum": -100, "reason": "fraud"}`,
+			expectedStatusCode: 402,
+		},
+		{
+			name: "User not found",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().AdjustBalance("support", "admin", 100.0, "lost receipt").
+					Return(nil, storage.ErrUserNotFound).AnyTimes()
+			},
+			body:               `{"sum": 100, "reason": "lost receipt"}`,
+			expectedStatusCode: 404,
+		},
+		{
+			name:               "Bad Request",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			body:               ``,
+			expectedStatusCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(test.body))
+			r.Header.Set("X-Admin-Token", "secret")
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_AdminCampaigns(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/admin/campaigns"
+	double := schema.CampaignRequest{Name: "Double weekend", Multiplier: 2}
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		mockBehavior       mockBehavior
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name:   "Create",
+			method: http.MethodPost,
+			url:    url,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CreateCampaign("support", double).
+					Return([]byte(`{"id": 1}`), nil).AnyTimes()
+			},
+			body:               `{"name": "Double weekend", "multiplier": 2}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name:   "Bad campaign",
+			method: http.MethodPost,
+			url:    url,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CreateCampaign("support", schema.CampaignRequest{Name: "Nothing"}).
+					Return(nil, storage.ErrBadCampaign).AnyTimes()
+			},
+			body:               `{"name": "Nothing"}`,
+			expectedStatusCode: 422,
+		},
+		{
+			name:   "Update",
+			method: http.MethodPut,
+			url:    url + "/1",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UpdateCampaign("support", int64(1), double).
+					Return([]byte(`{"id": 1}`), nil).AnyTimes()
+			},
+			body:               `{"name": "Double weekend", "multiplier": 2}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name:   "Not found",
+			method: http.MethodPut,
+			url:    url + "/7",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UpdateCampaign("support", int64(7), double).
+					Return(nil, storage.ErrCampaignNotFound).AnyTimes()
+			},
+			body:               `{"name": "Double weekend", "multiplier": 2}`,
+			expectedStatusCode: 404,
+		},
+		{
+			name:               "Bad id",
+			method:             http.MethodPut,
+			url:                url + "/abc",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			body:               `{"name": "Double weekend", "multiplier": 2}`,
+			expectedStatusCode: 400,
+		},
+		{
+			name:   "List",
+			method: http.MethodGet,
+			url:    url,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetCampaigns("support").
+					Return([]byte(`[]`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			r.Header.Set("X-Admin-Token", "secret")
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_AdminSecurityEvents(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	failed := false
+	tests := []struct {
+		name               string
+		url                string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "All",
+			url:  "http://localhost:8080/api/admin/security-events",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetSecurityEvents("support", storage.SecurityEventFilter{}, 0, 0).
+					Return([]byte(`[]`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Filtered",
+			url:  "http://localhost:8080/api/admin/security-events?login=admin&event=login&ip=192.0.2.1&success=false&from=2024-01-01&to=2024-01-31&limit=5",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetSecurityEvents("support", storage.SecurityEventFilter{
+					Login:   "admin",
+					Event:   storage.EventLogin,
+					IP:      "192.0.2.1",
+					Success: &failed,
+					From:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					To:      time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				}, 5, 0).Return([]byte(`[]`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Bad Success",
+			url:                "http://localhost:8080/api/admin/security-events?success=maybe",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "Bad Date",
+			url:                "http://localhost:8080/api/admin/security-events?from=yesterday",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 400,
+		},
+		{
+			name: "Err with db",
+			url:  "http://localhost:8080/api/admin/security-events",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetSecurityEvents("support", storage.SecurityEventFilter{}, 0, 0).
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			r.Header.Set("X-Admin-Token", "secret")
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_AdminRiskFlags(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	tests := []struct {
+		name               string
+		url                string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "All",
+			url:  "http://localhost:8080/api/admin/risk-flags",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetRiskFlags("support", "", 0, 0).
+					Return([]byte(`[]`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Of the user",
+			url:  "http://localhost:8080/api/admin/risk-flags?login=admin&limit=5&offset=10",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetRiskFlags("support", "admin", 5, 10).
+					Return([]byte(`[]`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Err with db",
+			url:  "http://localhost:8080/api/admin/risk-flags",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetRiskFlags("support", "", 0, 0).
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			r.Header.Set("X-Admin-Token", "secret")
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_AdminReconciliations(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/admin/reconciliations"
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		mockBehavior       mockBehavior
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name:   "Dry run",
+			method: http.MethodPost,
+			url:    url,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Reconcile("support", gomock.Any(), schema.ReconciliationRequest{Days: 7, Sample: 100}).
+					Return([]byte(`{"id": 1}`), nil).AnyTimes()
+			},
+			body:               `{"days": 7, "sample": 100}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name:   "Apply",
+			method: http.MethodPost,
+			url:    url,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Reconcile("support", gomock.Any(), schema.ReconciliationRequest{Apply: true}).
+					Return([]byte(`{"id": 2}`), nil).AnyTimes()
+			},
+			body:               `{"apply": true}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Bad request",
+			method:             http.MethodPost,
+			url:                url,
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			body:               `{"days": "week"}`,
+			expectedStatusCode: 400,
+		},
+		{
+			name:   "Err with db",
+			method: http.MethodPost,
+			url:    url,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Reconcile("support", gomock.Any(), schema.ReconciliationRequest{}).
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			body:               `{}`,
+			expectedStatusCode: 500,
+		},
+		{
+			name:   "List",
+			method: http.MethodGet,
+			url:    url + "?limit=5&offset=10",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetReconciliations("support", 5, 10).
+					Return([]byte(`[]`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:   "Report",
+			method: http.MethodGet,
+			url:    url + "/1",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetReconciliation("support", int64(1)).
+					Return([]byte(`{"id": 1}`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:   "Not found",
+			method: http.MethodGet,
+			url:    url + "/7",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().GetReconciliation("support", int64(7)).
+					Return(nil, storage.ErrReconciliationNotFound).AnyTimes()
+			},
+			expectedStatusCode: 404,
+		},
+		{
+			name:               "Bad id",
+			method:             http.MethodGet,
+			url:                url + "/abc",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			r.Header.Set("X-Admin-Token", "secret")
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_AdminBalanceCheck(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name: "Dry run",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckBalances("support", schema.BalanceCheckRequest{}).
+					Return([]byte(`{"checked": 2, "mismatched": 0}`), nil).AnyTimes()
+			},
+			body:               `{}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Repair",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckBalances("support", schema.BalanceCheckRequest{Repair: true}).
+					Return([]byte(`{"checked": 2, "mismatched": 1}`), nil).AnyTimes()
+			},
+			body:               `{"repair": true}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Bad request",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			body:               `{"repair": "yes"}`,
+			expectedStatusCode: 400,
+		},
+		{
+			name: "Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckBalances("support", schema.BalanceCheckRequest{}).
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			body:               `{}`,
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/admin/balance-checks", strings.NewReader(test.body))
+			r.Header.Set("X-Admin-Token", "secret")
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_LoginSecurityEvents(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := servicemocks.NewMockIUseCase(c)
+	logic.EXPECT().CheckPassword("admin", "nope").Return(storage.ErrWrongPassword)
+	logic.EXPECT().RecordSecurityEvent(schema.SecurityEvent{
+		Event:     storage.EventLogin,
+		Login:     "admin",
+		IP:        "192.0.2.1",
+		UserAgent: "curl/8.0",
+		RequestID: "req-1",
+		Details:   storage.ErrWrongPassword.Error(),
+	}).Return(nil)
+
+	cfg := config.New()
+	loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+		Concise: true,
+	})
+
+	h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/user/login", strings.NewReader(`{"login": "admin", "password": "nope"}`))
+	r.Header.Set("User-Agent", "curl/8.0")
+	r.Header.Set("X-Request-Id", "req-1")
+	w := httptest.NewRecorder()
+	router := chi.NewRouter()
+
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestHandler_PostAdminBlock(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	tests := []struct {
+		name               string
+		url                string
+		mockBehavior       mockBehavior
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name: "Block",
+			url:  "http://localhost:8080/api/admin/users/admin/block",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().BlockUser("support", "admin", "chargeback", true).
+					Return(nil).AnyTimes()
+			},
+			body:               `{"reason": "chargeback"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Unblock without body",
+			url:  "http://localhost:8080/api/admin/users/admin/unblock",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().BlockUser("support", "admin", "", false).
+					Return(nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "User not found",
+			url:  "http://localhost:8080/api/admin/users/admin/block",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().BlockUser("support", "admin", "chargeback", true).
+					Return(storage.ErrUserNotFound).AnyTimes()
+			},
+			body:               `{"reason": "chargeback"}`,
+			expectedStatusCode: 404,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			cfg.Admins = map[string]string{"secret": "support"}
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, test.url, strings.NewReader(test.body))
+			r.Header.Set("X-Admin-Token", "secret")
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.Group(h.AdminRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetStatement(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	cookie := "8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e"
+	tests := []struct {
+		name                string
+		url                 string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedContentType string
+	}{
+		{
+			name: "Ok json",
+			url:  "http://localhost:8080/api/user/statement?from=2023-01-01&to=2023-01-31",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+				to := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+				r.EXPECT().WriteStatement(cookie, from, to, "json", gomock.Any()).
+					Return(nil).AnyTimes()
+			},
+			expectedStatusCode:  200,
+			expectedContentType: "application/json",
+		},
+		{
+			name: "Ok csv",
+			url:  "http://localhost:8080/api/user/statement?format=csv",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().WriteStatement(cookie, gomock.Any(), gomock.Any(), "csv", gomock.Any()).
+					Return(nil).AnyTimes()
+			},
+			expectedStatusCode:  200,
+			expectedContentType: "text/csv",
+		},
+		{
+			name:                "Bad format",
+			url:                 "http://localhost:8080/api/user/statement?format=xml",
+			mockBehavior:        func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode:  400,
+			expectedContentType: "application/json",
+		},
+		{
+			name:                "Bad date",
+			url:                 "http://localhost:8080/api/user/statement?from=yesterday",
+			mockBehavior:        func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode:  400,
+			expectedContentType: "application/json",
+		},
+		{
+			name: "Bad period",
+			url:  "http://localhost:8080/api/user/statement?from=2023-02-01&to=2023-01-01",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().WriteStatement(cookie, gomock.Any(), gomock.Any(), "json", gomock.Any()).
+					Return(storage.ErrBadStatement).AnyTimes()
+			},
+			expectedStatusCode:  400,
+			expectedContentType: "application/json",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			cookie := cookies.NewCookie("admin")
+			r.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedContentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestHandler_PostOrdersBatch(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/user/orders/batch"
+	cookie := "8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e"
+	tests := []struct {
+		name               string
+		contentType        string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:        "Ok json",
+			contentType: "application/json",
+			body:        `["12345678903", 4561261212345467]`,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UploadOrders(gomock.Any(), cookie, []string{"12345678903", "4561261212345467"}).
+					Return([]byte("[]"), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:        "Ok csv",
+			contentType: "text/csv",
+			body:        "12345678903\n4561261212345467, 1234\n",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UploadOrders(gomock.Any(), cookie, []string{"12345678903", "4561261212345467", "1234"}).
+					Return([]byte("[]"), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Bad json",
+			contentType:        "application/json",
+			body:               `{"order": "12345678903"}`,
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 400,
+		},
+		{
+			name:        "Empty batch",
+			contentType: "text/csv",
+			body:        "",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UploadOrders(gomock.Any(), cookie, []string{}).
+					Return(nil, storage.ErrBadBatch).AnyTimes()
+			},
+			expectedStatusCode: 400,
+		},
+		{
+			name:        "Blocked",
+			contentType: "text/csv",
+			body:        "12345678903",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UploadOrders(gomock.Any(), cookie, []string{"12345678903"}).
+					Return(nil, storage.ErrUserBlocked).AnyTimes()
+			},
+			expectedStatusCode: 403,
+		},
+		{
+			name:        "Throttled",
+			contentType: "text/csv",
+			body:        "12345678903",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UploadOrders(gomock.Any(), cookie, []string{"12345678903"}).
+					Return(nil, risk.ErrThrottled).AnyTimes()
+			},
+			expectedStatusCode: 429,
+		},
+		{
+			name:        "Err with db",
+			contentType: "text/csv",
+			body:        "12345678903",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().UploadOrders(gomock.Any(), cookie, []string{"12345678903"}).
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+			cookie := cookies.NewCookie("admin")
+			r.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetExport(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/user/export"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Ok",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Export("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(`{"profile":{"login":"admin"},"orders":[]}`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Not Found",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Export("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return(nil, storage.ErrUserNotFound).AnyTimes()
+			},
+			expectedStatusCode: 404,
+		},
+		{
+			name: "Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().Export("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodGet, url, nil)
+			r.AddCookie(cookies.NewCookie("admin"))
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+			}
+		})
+	}
+}
+
+func TestHandler_DeleteUser(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	url := "http://localhost:8080/api/user"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Ok",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DeleteUser("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(`{"id":1,"alias":"deleted-0011223344556677","mode":"anonymize","forfeited":0}`), nil).AnyTimes()
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Not Found",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DeleteUser("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return(nil, storage.ErrUserNotFound).AnyTimes()
+			},
+			expectedStatusCode: 404,
+		},
+		{
+			name: "Balance Remaining",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DeleteUser("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return(nil, storage.ErrBalanceRemaining).AnyTimes()
+			},
+			expectedStatusCode: 409,
+		},
+		{
+			name: "Pending Transfers",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DeleteUser("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return(nil, storage.ErrPendingTransfers).AnyTimes()
+			},
+			expectedStatusCode: 409,
+		},
+		{
+			name: "Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DeleteUser("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return(nil, errors.New("err with DB")).AnyTimes()
+			},
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodDelete, url, nil)
+			r.AddCookie(cookies.NewCookie("admin"))
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_TwoFactor(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		url                string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name: "Enroll",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().EnrollTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return([]byte(`{"secret":"GEZDGNBV","uri":"otpauth://totp/Gophermart:admin?secret=GEZDGNBV","recovery_codes":[]}`), nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa",
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Enroll When Enabled",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().EnrollTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e").
+					Return(nil, storage.ErrTwoFactorEnabled).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa",
+			expectedStatusCode: 409,
+		},
+		{
+			name: "Confirm",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ConfirmTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "123456").
+					Return(nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa/confirm",
+			body:               `{"code": "123456"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Confirm Wrong Code",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ConfirmTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "000000").
+					Return(storage.ErrWrongTwoFactorCode).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa/confirm",
+			body:               `{"code": "000000"}`,
+			expectedStatusCode: 422,
+		},
+		{
+			name: "Confirm Not Enrolled",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ConfirmTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "123456").
+					Return(storage.ErrTwoFactorDisabled).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa/confirm",
+			body:               `{"code": "123456"}`,
+			expectedStatusCode: 409,
+		},
+		{
+			name: "Disable",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DisableTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "ABCDE-FGH23").
+					Return(nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa/disable",
+			body:               `{"code": "ABCDE-FGH23"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Disable Wrong Code",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DisableTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "000000").
+					Return(storage.ErrWrongTwoFactorCode).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa/disable",
+			body:               `{"code": "000000"}`,
+			expectedStatusCode: 401,
+		},
+		{
+			name: "Disable Not Enabled",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DisableTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "123456").
+					Return(storage.ErrTwoFactorDisabled).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa/disable",
+			body:               `{"code": "123456"}`,
+			expectedStatusCode: 409,
+		},
+		{
+			name: "Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DisableTwoFactor("8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e", "123456").
+					Return(errors.New("err with DB")).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/2fa/disable",
+			body:               `{"code": "123456"}`,
+			expectedStatusCode: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, test.url, strings.NewReader(test.body))
+			r.AddCookie(cookies.NewCookie("admin"))
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_Passwords(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	token := "8d5f8aeeb64e3ce20b537d04c486407eaf489646617cfcf493e76f5b794fa080-61646d696e"
+	tests := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		url                string
+		body               string
+		expectedStatusCode int
+		newToken           bool
+	}{
+		{
+			name: "Change",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ChangePassword(token, "admin", "secret").
+					Return(int64(1), nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password",
+			body:               `{"old_password": "admin", "new_password": "secret"}`,
+			expectedStatusCode: 200,
+			newToken:           true,
+		},
+		{
+			name: "Change Wrong Password",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ChangePassword(token, "nope", "secret").
+					Return(int64(0), storage.ErrWrongPassword).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password",
+			body:               `{"old_password": "nope", "new_password": "secret"}`,
+			expectedStatusCode: 401,
+		},
+		{
+			name: "Change Empty Password",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ChangePassword(token, "admin", "").
+					Return(int64(0), storage.ErrBadPassword).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password",
+			body:               `{"old_password": "admin", "new_password": ""}`,
+			expectedStatusCode: 422,
+		},
+		{
+			name: "Change Blocked",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ChangePassword(token, "admin", "secret").
+					Return(int64(0), storage.ErrUserBlocked).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password",
+			body:               `{"old_password": "admin", "new_password": "secret"}`,
+			expectedStatusCode: 403,
+		},
+		{
+			name: "Request Reset",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().RequestPasswordReset("admin").
+					Return(nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password/reset",
+			body:               `{"login": "admin"}`,
+			expectedStatusCode: 202,
+		},
+		{
+			name: "Request Reset Err with db",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().RequestPasswordReset("admin").
+					Return(errors.New("err with DB")).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password/reset",
+			body:               `{"login": "admin"}`,
+			expectedStatusCode: 500,
+		},
+		{
+			name: "Reset",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ResetPassword("abc", "secret").
+					Return("admin", nil).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password/reset/confirm",
+			body:               `{"token": "abc", "password": "secret"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			name: "Reset Bad Token",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().ResetPassword("abc", "secret").
+					Return("", storage.ErrBadResetToken).AnyTimes()
+			},
+			url:                "http://localhost:8080/api/user/password/reset/confirm",
+			body:               `{"token": "abc", "password": "secret"}`,
+			expectedStatusCode: 422,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(http.MethodPost, test.url, strings.NewReader(test.body))
+			r.AddCookie(cookies.NewCookie("admin"))
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.newToken {
+				assert.Equal(t, cookies.NewSessionCookie(program.DefaultID, "admin", 1).Value, w.Header().Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestHandler_RevokedToken(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := servicemocks.NewMockIUseCase(c)
+	logic.EXPECT().CheckToken(cookies.NewCookie("admin").Value).Return(storage.ErrTokenRevoked)
+
+	cfg := config.New()
+	loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+		Concise: true,
+	})
+
+	h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/user/balance", nil)
+	r.AddCookie(cookies.NewCookie("admin"))
+	w := httptest.NewRecorder()
+	router := chi.NewRouter()
+
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestHandler_ResetLockout(t *testing.T) {
+	url := "http://localhost:8080/api/user/password/reset"
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	logic.EXPECT().RequestPasswordReset("admin").
+		Return(nil).Times(2)
+
+	cfg := config.New()
+	cfg.BruteForce = &bruteforce.Config{MaxLoginFailures: 2, FreeAttempts: 5}
+	loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+		Concise: true,
+	})
+
+	h := NewHandler(cfg, logic, logger.New(loggerInstance))
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+
+	// every request counts, so the token of the user can't be revoked over and over
+	for _, expectedStatusCode := range []int{202, 202, 429} {
+		r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(`{"login": "admin"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, expectedStatusCode, w.Code)
+	}
+}
+
+func TestHandler_StepUpLockout(t *testing.T) {
+	url := "http://localhost:8080/api/user/balance/withdraw"
+	cookie := cookies.NewCookie("admin")
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	logic.EXPECT().DrawBonuses(cookie.Value, 751.0, 0.0, "2377225624", "000000").
+		Return(storage.ErrWrongTwoFactorCode).Times(2)
+
+	cfg := config.New()
+	cfg.BruteForce = &bruteforce.Config{MaxLoginFailures: 2, FreeAttempts: 5}
+	loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+		Concise: true,
+	})
+
+	h := NewHandler(cfg, logic, logger.New(loggerInstance))
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+
+	for _, expectedStatusCode := range []int{401, 401, 429} {
+		r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(`{"order": "2377225624", "sum": 751, "otp": "000000"}`))
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, expectedStatusCode, w.Code)
+	}
+}
+
+func TestHandler_PingAccrual(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+	cfg := config.New()
+	cfg.AccrualSystemAddress = server.URL
+	cfg.Logic.Accrual = accrual.New(accrual.Config{Failures: 1, Cooldown: time.Hour})
+	h := NewHandler(cfg, newUseCaseMock(c), logger.New(httplog.NewLogger("loyalty", httplog.Options{Concise: true})))
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping-accrual", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var state accrual.BreakerState
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+		assert.Equal(t, accrual.Open, state.State)
+		assert.NotNil(t, state.RetryAt)
+	}
+
+	// the open circuit keeps the second ping from the accrual system
+	assert.Equal(t, 1, requests)
+}
+
+func TestHandler_SharedGuard(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	cfg := config.New()
+	cfg.Guard = bruteforce.New(bruteforce.Config{MaxIPFailures: 1})
+
+	// an attempt over another API locks the IP for the handler too
+	if _, err := cfg.Guard.Allow("admin", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(cfg, newUseCaseMock(c), logger.New(httplog.NewLogger("loyalty", httplog.Options{Concise: true})))
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login": "admin", "password": "admin"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestHandler_OpenAPIMatchesRoutes(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := newUseCaseMock(c)
+	cfg := config.New()
+	loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+		Concise: true,
+	})
+
+	h := NewHandler(cfg, logic, logger.New(loggerInstance))
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+	router.Group(h.AdminRoutes)
+
+	routes := make(map[string]bool)
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes[method+" "+route] = true
+		return nil
+	})
+	assert.NoError(t, err)
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(loyaltyapi.Spec, &doc))
+
+	documented := make(map[string]bool)
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	assert.Equal(t, routes, documented)
+}
+
+func TestHandler_ValidateRequests(t *testing.T) {
+	type mockBehavior func(r *servicemocks.MockIUseCase)
+	cookie := cookies.NewCookie("admin").Value
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		contentType        string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:        "Order as text",
+			method:      http.MethodPost,
+			url:         "/api/user/orders",
+			contentType: "text/plain",
+			body:        "12345678903",
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().CheckID(gomock.Any(), cookie, "12345678903").Return(nil)
+			},
+			expectedStatusCode: 202,
+		},
+		{
+			name:               "Order as JSON",
+			method:             http.MethodPost,
+			url:                "/api/user/orders",
+			contentType:        "application/json",
+			body:               `"12345678903"`,
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 415,
+		},
+		{
+			name:               "Login as text",
+			method:             http.MethodPost,
+			url:                "/api/user/login",
+			contentType:        "text/plain",
+			body:               `{"login": "admin", "password": "admin"}`,
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 415,
+		},
+		{
+			name:               "Login without password",
+			method:             http.MethodPost,
+			url:                "/api/user/login",
+			contentType:        "application/json",
+			body:               `{"login": "admin"}`,
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 400,
+		},
+		{
+			name:        "Withdraw",
+			method:      http.MethodPost,
+			url:         "/api/user/balance/withdraw",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"order": "12345678903", "sum": 10}`,
+			mockBehavior: func(r *servicemocks.MockIUseCase) {
+				r.EXPECT().DrawBonuses(cookie, 10.0, 0.0, "12345678903", "").Return(nil)
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Withdraw with string sum",
+			method:             http.MethodPost,
+			url:                "/api/user/balance/withdraw",
+			contentType:        "application/json",
+			body:               `{"order": "12345678903", "sum": "10"}`,
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "Too large body",
+			method:             http.MethodPost,
+			url:                "/api/user/login",
+			contentType:        "application/json",
+			body:               `{"login": "admin", "password": "` + strings.Repeat("a", 1<<20) + `"}`,
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 413,
+		},
+		{
+			name:               "Bad statement format",
+			method:             http.MethodGet,
+			url:                "/api/user/statement?format=xml",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "Unknown path",
+			method:             http.MethodGet,
+			url:                "/api/user/unknown",
+			mockBehavior:       func(r *servicemocks.MockIUseCase) {},
+			expectedStatusCode: 404,
+		},
+	}
+
+	validator, err := middleware.ValidateRequests(loyaltyapi.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := newUseCaseMock(c)
+			test.mockBehavior(logic)
+			cfg := config.New()
+			loggerInstance := httplog.NewLogger("loyalty", httplog.Options{
+				Concise: true,
+			})
+
+			h := NewHandler(cfg, logic, logger.New(loggerInstance))
+
+			r := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			r.Header.Set("Authorization", cookie)
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+			router.Use(validator)
+
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+// newUseCaseMock accepts every token and security event, the revocation and the audit log have their own tests.
+func newUseCaseMock(c *gomock.Controller) *servicemocks.MockIUseCase {
+	logic := servicemocks.NewMockIUseCase(c)
+	logic.EXPECT().CheckToken(gomock.Any()).Return(nil).AnyTimes()
+	logic.EXPECT().RecordSecurityEvent(gomock.Any()).Return(nil).AnyTimes()
+	return logic
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/risk"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/pkg/bettererror"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const maxBatchBody = 1 << 20
+
+// PostOrdersBatch uploads many orders at once. The body is a JSON array
+// of numbers or strings, or CSV (text/csv, text/plain) with numbers in any cells.
+func (h Handler) PostOrdersBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		ids, err := readBatch(r, http.MaxBytesReader(w, r.Body, maxBatchBody))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		results, err := h.useCase(r).UploadOrders(h.conf.AccrualSystemAddress, cookie, ids)
+		if errors.Is(err, risk.ErrThrottled) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrBadBatch) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserBlocked) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrPollQueueFull) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(results)
+	}
+}
+
+func readBatch(r *http.Request, body io.Reader) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		return readBatchJSON(body)
+	}
+
+	return readBatchCSV(body)
+}
+
+func readBatchJSON(body io.Reader) ([]string, error) {
+	var raw []json.RawMessage
+	err := json.NewDecoder(body).Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(raw))
+	for _, value := range raw {
+		var id string
+		if err := json.Unmarshal(value, &id); err != nil {
+			// numbers are taken as they are written, without float conversion
+			id = string(value)
+		}
+
+		ids = append(ids, strings.TrimSpace(id))
+	}
+
+	return ids, nil
+}
+
+func readBatchCSV(body io.Reader) ([]string, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	ids := make([]string, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return ids, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cell := range record {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				ids = append(ids, cell)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+	"strconv"
+)
+
+// TokenRequired rejects the tokens revoked by a password change or reset, it goes after AuthRequired.
+func (h Handler) TokenRequired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		err = h.useCase(r).CheckToken(cookie)
+		if errors.Is(err, storage.ErrTokenRevoked) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PostPassword changes the password and issues a new token, the tokens of the other sessions stop working.
+func (h Handler) PostPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		var req schema.PasswordChangeRequest
+		err = BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		key := passwordKey(r, cookie)
+		if !h.allow(w, r, key) {
+			return
+		}
+
+		generation, err := h.useCase(r).ChangePassword(cookie, req.OldPassword, req.NewPassword)
+		h.securityEvent(r, storage.EventPasswordChange, cookies.Username(cookie), err, "")
+		if err != nil && !errors.Is(err, storage.ErrWrongPassword) {
+			h.release(key, clientIP(r))
+		}
+
+		if errors.Is(err, storage.ErrWrongPassword) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrBadPassword) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserBlocked) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		if err := h.guard.Succeeded(key, clientIP(r)); err != nil {
+			h.logger.Warn(err.Error())
+		}
+
+		cookies.SetSession(w, middleware.Program(r), cookies.Username(cookie), generation)
+		h.securityEvent(r, storage.EventTokenIssued, cookies.Username(cookie), nil, "generation "+strconv.FormatInt(generation, 10))
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// PostPasswordReset answers the same for the unknown logins, so it doesn't tell which logins exist.
+// Every request is counted by the guard, as a new token revokes the earlier one of the user.
+func (h Handler) PostPasswordReset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req schema.PasswordResetRequest
+		err := BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		key := resetKey(r, req.Login)
+		if !h.allow(w, r, key) {
+			return
+		}
+
+		err = h.useCase(r).RequestPasswordReset(req.Login)
+		if err != nil {
+			h.release(key, clientIP(r))
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (h Handler) PostPasswordResetConfirm() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req schema.PasswordResetConfirm
+		err := BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		login, err := h.useCase(r).ResetPassword(req.Token, req.Password)
+		h.securityEvent(r, storage.EventPasswordReset, login, err, "")
+		if errors.Is(err, storage.ErrBadResetToken) || errors.Is(err, storage.ErrBadPassword) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// passwordKey counts the wrong old passwords by the token, so a stolen token can't be used to guess the password.
+func passwordKey(r *http.Request, cookie string) string {
+	return program.Qualify(middleware.Program(r), "passwd:"+cookie)
+}
+
+// resetKey counts the reset requests by the login, so the token of the user can't be revoked over and over.
+func resetKey(r *http.Request, login string) string {
+	return program.Qualify(middleware.Program(r), "reset:"+login)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+	"strconv"
+)
+
+// PostAdminReconciliation compares the processed orders with the accrual system and returns the report,
+// the corrections are applied only with "apply".
+func (h Handler) PostAdminReconciliation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req schema.ReconciliationRequest
+		err := BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		report, err := h.useCase(r).Reconcile(middleware.Admin(r), h.conf.AccrualSystemAddress, req)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(report)
+	}
+}
+
+func (h Handler) GetAdminReconciliations() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		limit, offset := pageParams(r)
+
+		reports, err := h.useCase(r).GetReconciliations(middleware.Admin(r), limit, offset)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(reports)
+	}
+}
+
+func (h Handler) GetAdminReconciliation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		report, err := h.useCase(r).GetReconciliation(middleware.Admin(r), id)
+		if errors.Is(err, storage.ErrReconciliationNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(report)
+	}
+}
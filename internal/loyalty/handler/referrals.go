@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/pkg/bettererror"
+	"net/http"
+)
+
+func (h Handler) GetReferrals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		referrals, err := h.useCase(r).GetReferrals(cookie)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(referrals)
+	}
+}
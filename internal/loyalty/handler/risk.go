@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+)
+
+// GetAdminRiskFlags lists the flags of the risk rules, of the user with ?login= or of everyone.
+func (h Handler) GetAdminRiskFlags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		limit, offset := pageParams(r)
+
+		flags, err := h.useCase(r).GetRiskFlags(middleware.Admin(r), r.URL.Query().Get("login"), limit, offset)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(flags)
+	}
+}
@@ -6,19 +6,66 @@ import (
 )
 
 func (h Handler) PublicRoutes(r chi.Router) {
+	r.Use(middleware.ProgramRequired(h.conf.Programs))
 	r.Post("/api/user/register", h.PostRegister())
 	r.Post("/api/user/login", h.PostLogin())
+	r.Post("/api/user/password/reset", h.PostPasswordReset())
+	r.Post("/api/user/password/reset/confirm", h.PostPasswordResetConfirm())
 	r.Head("/ping-accrual", h.PingAccrual())
 	r.Get("/ping-accrual", h.PingAccrual())
+	r.Get("/openapi.json", h.GetOpenAPI())
 }
 
 func (h Handler) PrivateRoutes(r chi.Router) {
+	r.Use(middleware.ProgramRequired(h.conf.Programs))
 	r.Use(middleware.AuthRequired)
+	r.Use(h.TokenRequired)
 	r.Post("/api/user/orders", h.PostOrders())
 	r.Get("/api/user/orders", h.GetUserOrders())
+	r.Post("/api/user/orders/batch", h.PostOrdersBatch())
 
 	r.Get("/api/user/balance", h.GetBalance())
 
 	r.Post("/api/user/balance/withdraw", h.PostWithdraw())
 	r.Get("/api/user/withdrawals", h.GetWithdrawals())
+
+	r.Post("/api/user/balance/transfer", h.PostTransfer())
+	r.Get("/api/user/transfers", h.GetTransfers())
+	r.Post("/api/user/transfers/{id}/accept", h.PostAcceptTransfer())
+	r.Post("/api/user/transfers/{id}/decline", h.PostDeclineTransfer())
+
+	r.Get("/api/user/statement", h.GetStatement())
+
+	r.Get("/api/user/referrals", h.GetReferrals())
+
+	r.Get("/api/user/export", h.GetExport())
+	r.Delete("/api/user", h.DeleteUser())
+
+	r.Post("/api/user/2fa", h.PostTwoFactor())
+	r.Post("/api/user/2fa/confirm", h.PostConfirmTwoFactor())
+	r.Post("/api/user/2fa/disable", h.PostDisableTwoFactor())
+
+	r.Post("/api/user/password", h.PostPassword())
+}
+
+func (h Handler) AdminRoutes(r chi.Router) {
+	r.Use(middleware.AdminRequired(h.conf.Admins))
+	r.Use(middleware.ProgramRequired(h.conf.Programs))
+	r.Get("/api/admin/users", h.GetAdminUsers())
+	r.Get("/api/admin/users/{login}", h.GetAdminUser())
+	r.Get("/api/admin/users/{login}/orders", h.GetAdminUserOrders())
+	r.Get("/api/admin/users/{login}/withdrawals", h.GetAdminUserWithdrawals())
+	r.Post("/api/admin/users/{login}/adjustments", h.PostAdminAdjustment())
+	r.Post("/api/admin/users/{login}/block", h.PostAdminBlock())
+	r.Post("/api/admin/users/{login}/unblock", h.PostAdminUnblock())
+	r.Get("/api/admin/actions", h.GetAdminActions())
+	r.Get("/api/admin/security-events", h.GetAdminSecurityEvents())
+	r.Get("/api/admin/risk-flags", h.GetAdminRiskFlags())
+	r.Post("/api/admin/reconciliations", h.PostAdminReconciliation())
+	r.Get("/api/admin/reconciliations", h.GetAdminReconciliations())
+	r.Get("/api/admin/reconciliations/{id}", h.GetAdminReconciliation())
+	r.Post("/api/admin/balance-checks", h.PostAdminBalanceCheck())
+	r.Get("/api/admin/campaigns", h.GetAdminCampaigns())
+	r.Post("/api/admin/campaigns", h.PostAdminCampaign())
+	r.Put("/api/admin/campaigns/{id}", h.PutAdminCampaign())
 }
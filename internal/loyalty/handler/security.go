@@ -0,0 +1,109 @@
+package handler
+
+import (
+	chimiddleware "github.com/go-chi/chi/middleware"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// securityEvent appends the event to the security audit log, a failed operation is recorded with its error.
+// The log must not break the request, so a failed record is only logged.
+func (h Handler) securityEvent(r *http.Request, event, login string, err error, details string) {
+	e := schema.SecurityEvent{
+		Event:     event,
+		Login:     login,
+		Success:   err == nil,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		RequestID: requestID(r),
+		Details:   details,
+	}
+	if err != nil {
+		e.Details = joinDetails(details, err.Error())
+	}
+
+	if err := h.useCase(r).RecordSecurityEvent(e); err != nil {
+		h.logger.Warn(err.Error())
+	}
+}
+
+// GetAdminSecurityEvents lists the security events for ?login=&event=&ip=&success=&from=&to=,
+// the dates are parsed like the ones of the statement.
+func (h Handler) GetAdminSecurityEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+		limit, offset := pageParams(r)
+
+		f := storage.SecurityEventFilter{
+			Login: query.Get("login"),
+			Event: query.Get("event"),
+			IP:    query.Get("ip"),
+		}
+
+		var err error
+		if value := query.Get("success"); value != "" {
+			success, err := strconv.ParseBool(value)
+			if err != nil {
+				badRequest(w, err)
+				return
+			}
+
+			f.Success = &success
+		}
+
+		f.From, err = parseStatementDate(query.Get("from"), time.Time{}, false)
+		if err != nil {
+			badRequest(w, err)
+			return
+		}
+
+		f.To, err = parseStatementDate(query.Get("to"), time.Time{}, true)
+		if err != nil {
+			badRequest(w, err)
+			return
+		}
+
+		events, err := h.useCase(r).GetSecurityEvents(middleware.Admin(r), f, limit, offset)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(events)
+	}
+}
+
+func withdrawalDetails(sum float64, order string) string {
+	return "sum " + strconv.FormatFloat(sum, 'f', -1, 64) + ", order " + order
+}
+
+func badRequest(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+}
+
+// requestID returns the ID set by the RequestID middleware or the one sent by the client.
+func requestID(r *http.Request) string {
+	if id := chimiddleware.GetReqID(r.Context()); id != "" {
+		return id
+	}
+
+	return r.Header.Get(chimiddleware.RequestIDHeader)
+}
+
+func joinDetails(details, reason string) string {
+	if details == "" {
+		return reason
+	}
+
+	return details + ": " + reason
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/usecase"
+	"gomarket/pkg/bettererror"
+	"net/http"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// GetStatement streams the statement for ?from=&to=&format=csv|json.
+// Dates are RFC 3339 timestamps or plain dates, a plain "to" date is inclusive.
+// By default the statement covers the last month in JSON.
+func (h Handler) GetStatement() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		query := r.URL.Query()
+		to, err := parseStatementDate(query.Get("to"), time.Now(), true)
+		if err != nil {
+			badStatement(w, err)
+			return
+		}
+
+		from, err := parseStatementDate(query.Get("from"), to.AddDate(0, -1, 0), false)
+		if err != nil {
+			badStatement(w, err)
+			return
+		}
+
+		format := query.Get("format")
+		switch format {
+		case "", usecase.StatementJSON:
+			format = usecase.StatementJSON
+			w.Header().Set("Content-Type", "application/json")
+		case usecase.StatementCSV:
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="statement.csv"`)
+		default:
+			badStatement(w, storage.ErrBadStatement)
+			return
+		}
+
+		err = h.useCase(r).WriteStatement(cookie, from, to, format, w)
+		if errors.Is(err, storage.ErrBadStatement) {
+			badStatement(w, err)
+			return
+		}
+
+		// the status is already sent once the statement is being written
+		if err != nil {
+			h.logger.Warn(err.Error())
+		}
+	}
+}
+
+func parseStatementDate(value string, def time.Time, inclusive bool) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	if date, err := time.Parse(dateLayout, value); err == nil {
+		if inclusive {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func badStatement(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+}
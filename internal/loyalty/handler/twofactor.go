@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"errors"
+	"gomarket/internal/bruteforce"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/middleware"
+	"gomarket/pkg/bettererror"
+	"net/http"
+)
+
+func (h Handler) PostTwoFactor() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		enrollment, err := h.useCase(r).EnrollTwoFactor(cookie)
+		if errors.Is(err, storage.ErrTwoFactorEnabled) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(enrollment)
+	}
+}
+
+func (h Handler) PostConfirmTwoFactor() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		var req schema.TwoFactorCode
+		err = BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		err = h.useCase(r).ConfirmTwoFactor(cookie, req.Code)
+		if errors.Is(err, storage.ErrWrongTwoFactorCode) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if errors.Is(err, storage.ErrTwoFactorEnabled) || errors.Is(err, storage.ErrTwoFactorDisabled) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h Handler) PostDisableTwoFactor() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cookie, err := cookies.Get(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+			return
+		}
+
+		var req schema.TwoFactorCode
+		err = BindJSON(w, r, &req)
+		if err != nil {
+			return
+		}
+
+		if !h.allowCode(w, r, cookie) {
+			return
+		}
+
+		err = h.useCase(r).DisableTwoFactor(cookie, req.Code)
+		h.codeChecked(r, cookie, err)
+
+		if errors.Is(err, storage.ErrTwoFactorDisabled) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if status, ok := twoFactorStatus(err); ok {
+			w.WriteHeader(status)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Logic).JSON())
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// allowCode limits the guesses of the two-factor codes sent with a token like the logins are limited,
+// it writes the response when the caller has to wait.
+func (h Handler) allowCode(w http.ResponseWriter, r *http.Request, cookie string) bool {
+	return h.allow(w, r, codeKey(r, cookie))
+}
+
+// allow writes the response when the caller has to wait before the next attempt with the key.
+func (h Handler) allow(w http.ResponseWriter, r *http.Request, key string) bool {
+	wait, err := h.guard.Allow(key, clientIP(r))
+	if errors.Is(err, bruteforce.ErrTooManyAttempts) {
+		w.Header().Set("Retry-After", retryAfter(wait))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).SetCode("too_many_attempts").JSON())
+		return false
+	}
+
+	if err != nil {
+		h.logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSON())
+		return false
+	}
+
+	return true
+}
+
+// codeChecked keeps the attempt reserved by allowCode counted only if the code was wrong.
+func (h Handler) codeChecked(r *http.Request, cookie string, err error) {
+	if !errors.Is(err, storage.ErrWrongTwoFactorCode) {
+		h.release(codeKey(r, cookie), clientIP(r))
+	}
+}
+
+// release forgets the attempt reserved by allow, it was not a wrong guess.
+func (h Handler) release(key, ip string) {
+	if err := h.guard.Release(key, ip); err != nil {
+		h.logger.Warn(err.Error())
+	}
+}
+
+// codeKey counts the failures by the token, it is bound to the user of the program.
+func codeKey(r *http.Request, cookie string) string {
+	return program.Qualify(middleware.Program(r), "2fa:"+cookie)
+}
@@ -0,0 +1,102 @@
+package program
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultID is the program of the requests without a program key,
+// it holds the users registered before the programs were introduced.
+const DefaultID = "default"
+
+// Program is a loyalty program of a merchant hosted in the service.
+type Program struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Rate is the number of points credited per ruble of accrual.
+	Rate float64 `json:"rate"`
+	// AccrualAddress overrides the accrual system address of the service if set.
+	AccrualAddress string `json:"accrual_address"`
+	// APIKey is sent by the merchant in the X-Program-Key header.
+	APIKey string `json:"api_key"`
+}
+
+var ErrBadProgram = errors.New("bad program")
+
+// Default returns the program of the requests without a program key.
+func Default(accrualAddress string) Program {
+	return Program{ID: DefaultID, Rate: 1, AccrualAddress: accrualAddress}
+}
+
+// Qualify prefixes the name by the program for the keys shared by all the programs,
+// names of the default program are left as they are.
+func Qualify(id, name string) string {
+	if id == DefaultID {
+		return name
+	}
+
+	return id + "/" + name
+}
+
+// Find returns the program with the API key, keys are compared in constant time.
+func Find(programs []Program, apiKey string) (Program, bool) {
+	if apiKey == "" {
+		return Program{}, false
+	}
+
+	var found Program
+	var ok bool
+	for _, p := range programs {
+		if subtle.ConstantTimeCompare([]byte(p.APIKey), []byte(apiKey)) == 1 {
+			found, ok = p, true
+		}
+	}
+
+	return found, ok
+}
+
+// Load reads the programs from the JSON file, an empty path means no programs besides the default one.
+func Load(path string) ([]Program, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var programs []Program
+	err = json.Unmarshal(data, &programs)
+	if err != nil {
+		return nil, err
+	}
+
+	return programs, Validate(programs)
+}
+
+// Validate checks that the programs have unique IDs and API keys and positive rates.
+func Validate(programs []Program) error {
+	ids := make(map[string]bool, len(programs))
+	keys := make(map[string]bool, len(programs))
+	for _, p := range programs {
+		switch {
+		case p.ID == "" || p.ID == DefaultID:
+			return fmt.Errorf("%w: id %q is reserved", ErrBadProgram, p.ID)
+		case ids[p.ID]:
+			return fmt.Errorf("%w: id %q is not unique", ErrBadProgram, p.ID)
+		case p.APIKey == "" || keys[p.APIKey]:
+			return fmt.Errorf("%w: %s needs a unique api key", ErrBadProgram, p.ID)
+		case p.Rate <= 0:
+			return fmt.Errorf("%w: %s needs a positive rate", ErrBadProgram, p.ID)
+		}
+
+		ids[p.ID] = true
+		keys[p.APIKey] = true
+	}
+
+	return nil
+}
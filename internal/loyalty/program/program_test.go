@@ -0,0 +1,115 @@
+package program
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		programs []Program
+		wantErr  error
+	}{
+		{
+			name: "ok",
+			programs: []Program{
+				{ID: "coffee", Rate: 1, APIKey: "k1"},
+				{ID: "books", Rate: 0.5, APIKey: "k2"},
+			},
+		},
+		{
+			name:     "none",
+			programs: nil,
+		},
+		{
+			name:     "default id",
+			programs: []Program{{ID: DefaultID, Rate: 1, APIKey: "k1"}},
+			wantErr:  ErrBadProgram,
+		},
+		{
+			name: "duplicate id",
+			programs: []Program{
+				{ID: "coffee", Rate: 1, APIKey: "k1"},
+				{ID: "coffee", Rate: 1, APIKey: "k2"},
+			},
+			wantErr: ErrBadProgram,
+		},
+		{
+			name: "duplicate key",
+			programs: []Program{
+				{ID: "coffee", Rate: 1, APIKey: "k1"},
+				{ID: "books", Rate: 1, APIKey: "k1"},
+			},
+			wantErr: ErrBadProgram,
+		},
+		{
+			name:     "zero rate",
+			programs: []Program{{ID: "coffee", APIKey: "k1"}},
+			wantErr:  ErrBadProgram,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.programs)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "programs.json")
+	data := `[{"id": "coffee", "name": "Coffee", "rate": 2, "accrual_address": "http://accrual:8080", "api_key": "k1"}]`
+	err := os.WriteFile(path, []byte(data), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	programs, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Program{{ID: "coffee", Name: "Coffee", Rate: 2, AccrualAddress: "http://accrual:8080", APIKey: "k1"}}
+	if !reflect.DeepEqual(programs, want) {
+		t.Errorf("Load() = %v, want %v", programs, want)
+	}
+
+	programs, err = Load("")
+	if err != nil || programs != nil {
+		t.Errorf("Load(\"\") = %v, %v, want no programs", programs, err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	programs := []Program{
+		{ID: "coffee", Rate: 1, APIKey: "k1"},
+		{ID: "books", Rate: 1, APIKey: "k2"},
+	}
+
+	p, ok := Find(programs, "k2")
+	if !ok || p.ID != "books" {
+		t.Errorf("Find(k2) = %v, %v, want books", p, ok)
+	}
+
+	for _, key := range []string{"", "k3"} {
+		if _, ok = Find(programs, key); ok {
+			t.Errorf("Find(%q) found a program", key)
+		}
+	}
+}
+
+func TestQualify(t *testing.T) {
+	if got := Qualify(DefaultID, "alice"); got != "alice" {
+		t.Errorf("Qualify(default) = %q, want alice", got)
+	}
+
+	if got := Qualify("coffee", "alice"); got != "coffee/alice" {
+		t.Errorf("Qualify(coffee) = %q, want coffee/alice", got)
+	}
+}
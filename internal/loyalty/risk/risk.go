@@ -0,0 +1,158 @@
+package risk
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signals the rules are set on. The rates are the shares of the uploaded order numbers
+// within the window that fail the Luhn check or belong to other users,
+// the withdrawal signals are the number and the sum of the withdrawals within it.
+const (
+	InvalidRate = "invalid_rate"
+	ForeignRate = "foreign_rate"
+	Withdrawals = "withdrawals"
+	Withdrawn   = "withdrawn"
+)
+
+// Actions of the rules in the order of severity. A flag is only recorded for the admins,
+// a throttled user can't upload orders and withdraw points for a while, a blocked one until unblocked.
+const (
+	Flag     = "flag"
+	Throttle = "throttle"
+	Block    = "block"
+)
+
+// Rule takes the action when the signal reaches the threshold.
+type Rule struct {
+	Signal    string
+	Threshold float64
+	Action    string
+}
+
+type Rules []Rule
+
+var ErrBadFormat = errors.New("risk rules must be set as signal:threshold:action,...")
+var ErrThrottled = errors.New("too many suspicious requests, try again later")
+
+// Parse parses rules written as "invalid_rate:0.5:flag,foreign_rate:0.3:throttle,withdrawals:10:block".
+func Parse(s string) (Rules, error) {
+	rules := make(Rules, 0)
+	if strings.TrimSpace(s) == "" {
+		return rules, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+		if len(fields) != 3 || !validSignal(fields[0]) || severity(fields[2]) == 0 {
+			return nil, ErrBadFormat
+		}
+
+		threshold, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || threshold <= 0 {
+			return nil, ErrBadFormat
+		}
+
+		rules = append(rules, Rule{Signal: fields[0], Threshold: threshold, Action: fields[2]})
+	}
+
+	return rules, nil
+}
+
+func validSignal(signal string) bool {
+	switch signal {
+	case InvalidRate, ForeignRate, Withdrawals, Withdrawn:
+		return true
+	}
+
+	return false
+}
+
+// severity orders the actions, unknown ones are 0.
+func severity(action string) int {
+	switch action {
+	case Flag:
+		return 1
+	case Throttle:
+		return 2
+	case Block:
+		return 3
+	}
+
+	return 0
+}
+
+// Policy judges the activity of a user, it is disabled without rules.
+type Policy struct {
+	Rules Rules
+	// Window is the period the activity is counted within.
+	Window time.Duration
+	// MinUploads is the least number of uploads within the window the rates are judged on,
+	// so a couple of mistyped numbers don't trigger the rules.
+	MinUploads int
+	// Throttle is how long a throttled user has to wait.
+	Throttle time.Duration
+}
+
+// Activity is what the user did within the window. Uploads counts every uploaded number,
+// Invalid and Foreign are the parts of them that fail the Luhn check or belong to other users.
+type Activity struct {
+	Uploads     int
+	Invalid     int
+	Foreign     int
+	Withdrawals int
+	Withdrawn   float64
+}
+
+// Hit is a rule the activity triggered with the value of its signal.
+type Hit struct {
+	Rule
+	Value float64
+}
+
+func (p Policy) Enabled() bool {
+	return len(p.Rules) > 0
+}
+
+// Evaluate returns the rules triggered by the activity in the order they are set.
+func (p Policy) Evaluate(a Activity) []Hit {
+	hits := make([]Hit, 0)
+	for _, rule := range p.Rules {
+		value, ok := p.value(rule.Signal, a)
+		if ok && value >= rule.Threshold {
+			hits = append(hits, Hit{Rule: rule, Value: value})
+		}
+	}
+
+	return hits
+}
+
+func (p Policy) value(signal string, a Activity) (float64, bool) {
+	rated := a.Uploads > 0 && a.Uploads >= p.MinUploads
+	switch signal {
+	case InvalidRate:
+		return float64(a.Invalid) / float64(a.Uploads), rated
+	case ForeignRate:
+		return float64(a.Foreign) / float64(a.Uploads), rated
+	case Withdrawals:
+		return float64(a.Withdrawals), true
+	case Withdrawn:
+		return a.Withdrawn, true
+	}
+
+	return 0, false
+}
+
+// Strongest returns the most severe action of the hits, an empty string without hits.
+func Strongest(hits []Hit) string {
+	action := ""
+	for _, hit := range hits {
+		if severity(hit.Action) > severity(action) {
+			action = hit.Action
+		}
+	}
+
+	return action
+}
@@ -0,0 +1,127 @@
+package risk
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Rules
+		wantErr error
+	}{
+		{
+			name:  "ok",
+			input: "invalid_rate:0.5:flag, foreign_rate:0.3:throttle,withdrawn:1000:block",
+			want: Rules{
+				{Signal: InvalidRate, Threshold: 0.5, Action: Flag},
+				{Signal: ForeignRate, Threshold: 0.3, Action: Throttle},
+				{Signal: Withdrawn, Threshold: 1000, Action: Block},
+			},
+		},
+		{
+			name:  "empty",
+			input: "",
+			want:  Rules{},
+		},
+		{
+			name:    "unknown signal",
+			input:   "orders:5:flag",
+			wantErr: ErrBadFormat,
+		},
+		{
+			name:    "unknown action",
+			input:   "withdrawals:5:ban",
+			wantErr: ErrBadFormat,
+		},
+		{
+			name:    "zero threshold",
+			input:   "withdrawals:0:flag",
+			wantErr: ErrBadFormat,
+		},
+		{
+			name:    "no action",
+			input:   "withdrawals:5",
+			wantErr: ErrBadFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy := Policy{
+		Rules: Rules{
+			{Signal: InvalidRate, Threshold: 0.5, Action: Flag},
+			{Signal: ForeignRate, Threshold: 0.3, Action: Throttle},
+			{Signal: Withdrawals, Threshold: 5, Action: Block},
+		},
+		MinUploads: 10,
+	}
+	tests := []struct {
+		name     string
+		activity Activity
+		want     []Hit
+	}{
+		{
+			name:     "clean",
+			activity: Activity{Uploads: 20, Invalid: 2, Foreign: 1, Withdrawals: 1},
+			want:     []Hit{},
+		},
+		{
+			name:     "too few uploads",
+			activity: Activity{Uploads: 5, Invalid: 5, Foreign: 5},
+			want:     []Hit{},
+		},
+		{
+			name:     "invalid and foreign",
+			activity: Activity{Uploads: 10, Invalid: 5, Foreign: 4},
+			want: []Hit{
+				{Rule: policy.Rules[0], Value: 0.5},
+				{Rule: policy.Rules[1], Value: 0.4},
+			},
+		},
+		{
+			name:     "withdrawals without uploads",
+			activity: Activity{Withdrawals: 6, Withdrawn: 600},
+			want:     []Hit{{Rule: policy.Rules[2], Value: 6}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Evaluate(tt.activity)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrongest(t *testing.T) {
+	hits := []Hit{
+		{Rule: Rule{Action: Throttle}},
+		{Rule: Rule{Action: Block}},
+		{Rule: Rule{Action: Flag}},
+	}
+	if got := Strongest(hits); got != Block {
+		t.Errorf("Strongest() = %v, want %v", got, Block)
+	}
+
+	if got := Strongest(nil); got != "" {
+		t.Errorf("Strongest(nil) = %v, want none", got)
+	}
+}
@@ -5,18 +5,44 @@ import "time"
 type AuthRequestJSON struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	// ReferralCode is the code of the user who invited the new one, it is only read at registration.
+	ReferralCode string `json:"referral_code,omitempty"`
+	// OTP is the code of the authenticator or a recovery code, it is only read at login
+	// and required when the user has enabled two-factor authentication.
+	OTP string `json:"otp,omitempty"`
 }
 
 type UserOrder struct {
-	Number     string  `json:"number"`
-	Status     string  `json:"status"`
-	Accrual    float64 `json:"accrual,omitempty"`
-	UploadedAt string  `json:"uploaded_at"`
+	Number     string            `json:"number"`
+	Status     string            `json:"status"`
+	Accrual    float64           `json:"accrual,omitempty"`
+	UploadedAt string            `json:"uploaded_at"`
+	Campaigns  []AppliedCampaign `json:"campaigns,omitempty"`
+}
+
+type BatchOrderResult struct {
+	Number string `json:"number"`
+	Status string `json:"status"`
 }
 
 type Balance struct {
-	Current   float32 `json:"current"`
-	Withdrawn float32 `json:"withdrawn"`
+	Current      float32          `json:"current"`
+	Withdrawn    float32          `json:"withdrawn"`
+	Tier         string           `json:"tier,omitempty"`
+	ExpiringSoon []ExpiringPoints `json:"expiring_soon,omitempty"`
+}
+
+type ExpiringPoints struct {
+	Sum       float64   `json:"sum"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Credit is a portion of points credited to the user at once.
+// Withdrawals spend credits in FIFO order, the unspent rest expires.
+type Credit struct {
+	ID        int64
+	Remaining float64
+	Date      time.Time
 }
 
 type ResponseFromTheCalculationSystem struct {
@@ -28,6 +54,10 @@ type ResponseFromTheCalculationSystem struct {
 type WithdrawnRequest struct {
 	Order string  `json:"order"`
 	Sum   float64 `json:"sum"`
+	// OrderTotal is the value of the order, it is required when the points can pay only a share of it.
+	OrderTotal float64 `json:"order_total,omitempty"`
+	// OTP confirms the withdrawals above the step-up threshold.
+	OTP string `json:"otp,omitempty"`
 }
 
 type Withdrawn struct {
@@ -35,3 +65,255 @@ type Withdrawn struct {
 	Sum         float64   `json:"sum"`
 	ProcessedAt time.Time `json:"processed_at"`
 }
+
+// StatementEntry is a balance movement, Balance is the balance after it.
+type StatementEntry struct {
+	Date      time.Time `json:"date"`
+	Type      string    `json:"type"`
+	Reference string    `json:"reference"`
+	Amount    float64   `json:"amount"`
+	Balance   float64   `json:"balance"`
+}
+
+type TransferRequest struct {
+	Login string  `json:"login"`
+	Sum   float64 `json:"sum"`
+}
+
+type Transfer struct {
+	ID          int64      `json:"id"`
+	Sender      string     `json:"sender"`
+	Recipient   string     `json:"recipient"`
+	Sum         float64    `json:"sum"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type User struct {
+	Login     string  `json:"login"`
+	Balance   float64 `json:"balance"`
+	Withdrawn float64 `json:"withdrawn"`
+	Tier      string  `json:"tier,omitempty"`
+	Blocked   bool    `json:"blocked"`
+}
+
+type AdjustmentRequest struct {
+	Sum    float64 `json:"sum"`
+	Reason string  `json:"reason"`
+}
+
+// Adjustment is a manual change of the balance, positive Sum credits points and negative debits them.
+type Adjustment struct {
+	ID        int64     `json:"id"`
+	Login     string    `json:"login"`
+	Sum       float64   `json:"sum"`
+	Reason    string    `json:"reason"`
+	Admin     string    `json:"admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BlockRequest struct {
+	Reason string `json:"reason"`
+}
+
+type AdminAction struct {
+	ID        int64     `json:"id"`
+	Admin     string    `json:"admin"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CampaignRequest struct {
+	Name        string     `json:"name"`
+	Multiplier  float64    `json:"multiplier"`
+	Bonus       float64    `json:"bonus"`
+	FirstOrders int        `json:"first_orders"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	Disabled    bool       `json:"disabled"`
+}
+
+// Campaign boosts the accrual of the orders processed within [StartsAt, EndsAt).
+type Campaign struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Multiplier multiplies the accrual, 1 leaves it as is.
+	Multiplier float64 `json:"multiplier"`
+	// Bonus is added to the accrual after the multipliers.
+	Bonus float64 `json:"bonus"`
+	// FirstOrders limits the campaign to the first processed orders of the user, zero means every order.
+	FirstOrders int        `json:"first_orders"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Disabled    bool       `json:"disabled"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AppliedCampaign is a campaign applied to an order, Bonus is the number of points it added.
+type AppliedCampaign struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Bonus float64 `json:"bonus"`
+}
+
+// Referral is a user invited by the referral code, Bonus is credited to both users once it is rewarded.
+type Referral struct {
+	Login       string     `json:"login"`
+	Status      string     `json:"status"`
+	Bonus       float64    `json:"bonus"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type Referrals struct {
+	Code string `json:"code"`
+	// Earned is the sum of the bonuses of the rewarded referrals.
+	Earned    float64    `json:"earned"`
+	Referrals []Referral `json:"referrals"`
+}
+
+// Session is a sign-in of the user.
+type Session struct {
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Export is everything stored about the user.
+type Export struct {
+	Profile      User         `json:"profile"`
+	ReferralCode string       `json:"referral_code"`
+	Orders       []UserOrder  `json:"orders"`
+	Withdrawals  []Withdrawn  `json:"withdrawals"`
+	Transfers    []Transfer   `json:"transfers"`
+	Adjustments  []Adjustment `json:"adjustments"`
+	Referrals    []Referral   `json:"referrals"`
+	Sessions     []Session    `json:"sessions"`
+	ExportedAt   time.Time    `json:"exported_at"`
+}
+
+// Deletion is the retained record of a deleted account, the rows kept for accounting belong to Alias.
+type Deletion struct {
+	ID    int64  `json:"id"`
+	Alias string `json:"alias"`
+	Mode  string `json:"mode"`
+	// Forfeited is the balance written off with the account.
+	Forfeited float64   `json:"forfeited"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// TwoFactorEnrollment is shown once, the recovery codes are only stored hashed.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth URI for the QR code read by the authenticator apps.
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code"`
+}
+
+type PasswordChangeRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type PasswordResetRequest struct {
+	Login string `json:"login"`
+}
+
+// PasswordResetConfirm sets the password with the token sent by the reset request.
+type PasswordResetConfirm struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// SecurityEvent is a record of the append-only security audit log.
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	Event     string    `json:"event"`
+	Login     string    `json:"login"`
+	Success   bool      `json:"success"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	RequestID string    `json:"request_id"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RiskFlag records a risk rule triggered by the activity of the user, Until is the end of a throttle.
+type RiskFlag struct {
+	ID        int64      `json:"id"`
+	Login     string     `json:"login"`
+	Signal    string     `json:"signal"`
+	Value     float64    `json:"value"`
+	Threshold float64    `json:"threshold"`
+	Action    string     `json:"action"`
+	Until     *time.Time `json:"until,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ReconciliationRequest struct {
+	// Days is how far back the processed orders are checked, zero uses the default of the job.
+	Days int `json:"days"`
+	// Sample is the number of random orders to check, zero checks all of them.
+	Sample int `json:"sample"`
+	// Apply corrects the balances through adjustments, otherwise the report is a dry run.
+	Apply bool `json:"apply"`
+}
+
+// Reconciliation is the report of comparing the processed orders with the accrual system.
+// Failed counts the orders the accrual system didn't answer for.
+type Reconciliation struct {
+	ID            int64         `json:"id"`
+	Since         time.Time     `json:"since"`
+	Checked       int           `json:"checked"`
+	Failed        int           `json:"failed"`
+	Mismatched    int           `json:"mismatched"`
+	Applied       bool          `json:"applied"`
+	Admin         string        `json:"admin"`
+	Discrepancies []Discrepancy `json:"discrepancies,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// Discrepancy is an order whose accrual differs from the one the accrual system reports now.
+// Accrual is the credited points, Reported is what the accrual system reported when the order was processed,
+// nil for the orders processed before it was stored. Correction is in points.
+type Discrepancy struct {
+	Number       string   `json:"number"`
+	Login        string   `json:"login"`
+	Accrual      float64  `json:"accrual"`
+	Reported     *float64 `json:"reported,omitempty"`
+	Status       string   `json:"status"`
+	Fresh        float64  `json:"fresh"`
+	Correction   float64  `json:"correction"`
+	AdjustmentID *int64   `json:"adjustment_id,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+type BalanceCheckRequest struct {
+	// Repair replaces the mismatched balances with the recomputed ones, otherwise the check is a dry run.
+	Repair bool `json:"repair"`
+}
+
+// BalanceCheck is the report of recomputing the balances from the orders, withdrawals, transfers,
+// adjustments and expirations. Repaired is set when the mismatches were replaced.
+type BalanceCheck struct {
+	Checked    int               `json:"checked"`
+	Mismatched int               `json:"mismatched"`
+	Repaired   bool              `json:"repaired"`
+	Mismatches []BalanceMismatch `json:"mismatches"`
+}
+
+// BalanceMismatch is a user whose stored balance or withdrawn sum differs from the recomputed one.
+type BalanceMismatch struct {
+	Login             string  `json:"login"`
+	Balance           float64 `json:"balance"`
+	Expected          float64 `json:"expected"`
+	Withdrawn         float64 `json:"withdrawn"`
+	ExpectedWithdrawn float64 `json:"expected_withdrawn"`
+}
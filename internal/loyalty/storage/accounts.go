@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"gomarket/internal/loyalty/schema"
+	"time"
+)
+
+func (s Storage) AddSession(username string, session schema.Session) error {
+	_, err := s.DB.Exec(addSession, username, session.IP, session.UserAgent, s.Program)
+	return err
+}
+
+func (s Storage) Export(username string) (schema.Export, error) {
+	export := schema.Export{ExportedAt: time.Now()}
+	var err error
+	export.Profile, err = s.GetUser(username)
+	if err != nil {
+		return schema.Export{}, err
+	}
+
+	referrals, err := s.GetReferrals(username)
+	if err != nil {
+		return schema.Export{}, err
+	}
+	export.ReferralCode, export.Referrals = referrals.Code, referrals.Referrals
+
+	export.Orders, err = s.GetOrders(username)
+	if err != nil && !errors.Is(err, ErrNoResult) {
+		return schema.Export{}, err
+	}
+
+	export.Withdrawals, err = s.GetWithdrawals(username)
+	if err != nil && !errors.Is(err, ErrNoWithdrawals) {
+		return schema.Export{}, err
+	}
+
+	export.Transfers, err = s.GetTransfers(username)
+	if err != nil && !errors.Is(err, ErrNoTransfers) {
+		return schema.Export{}, err
+	}
+
+	export.Adjustments, err = s.getUserAdjustments(username)
+	if err != nil {
+		return schema.Export{}, err
+	}
+
+	export.Sessions, err = s.getSessions(username)
+	if err != nil {
+		return schema.Export{}, err
+	}
+
+	return withEmptyLists(export), nil
+}
+
+// withEmptyLists replaces the missing lists of the export by empty ones, so they are encoded as [].
+func withEmptyLists(export schema.Export) schema.Export {
+	if export.Orders == nil {
+		export.Orders = make([]schema.UserOrder, 0)
+	}
+
+	if export.Withdrawals == nil {
+		export.Withdrawals = make([]schema.Withdrawn, 0)
+	}
+
+	if export.Transfers == nil {
+		export.Transfers = make([]schema.Transfer, 0)
+	}
+
+	if export.Adjustments == nil {
+		export.Adjustments = make([]schema.Adjustment, 0)
+	}
+
+	if export.Referrals == nil {
+		export.Referrals = make([]schema.Referral, 0)
+	}
+
+	if export.Sessions == nil {
+		export.Sessions = make([]schema.Session, 0)
+	}
+
+	return export
+}
+
+func (s Storage) getUserAdjustments(username string) ([]schema.Adjustment, error) {
+	rows, err := s.DB.Query(getUserAdjustments, username, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := make([]schema.Adjustment, 0)
+	for rows.Next() {
+		adjustment := schema.Adjustment{Login: username}
+		err = rows.Scan(&adjustment.ID, &adjustment.Sum, &adjustment.Reason, &adjustment.Admin, &adjustment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		adjustments = append(adjustments, adjustment)
+	}
+
+	return adjustments, rows.Err()
+}
+
+func (s Storage) getSessions(username string) ([]schema.Session, error) {
+	rows, err := s.DB.Query(getSessions, username, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]schema.Session, 0)
+	for rows.Next() {
+		var session schema.Session
+		err = rows.Scan(&session.IP, &session.UserAgent, &session.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// DeleteUser removes the account by the policy and records the deletion.
+// The rows kept for accounting and the history of other users move to a blocked alias,
+// so the foreign keys to the user stay valid and the login can be registered again.
+func (s Storage) DeleteUser(username string, policy DeletionPolicy) (schema.Deletion, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+	defer tx.Rollback()
+
+	var balance float64
+	var blocked bool
+	err = tx.QueryRow(lockUser, username, s.Program).Scan(&balance, &blocked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema.Deletion{}, ErrUserNotFound
+	}
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	var pending int
+	err = tx.QueryRow(countPendingTransfers, username, s.Program).Scan(&pending)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	if pending > 0 {
+		return schema.Deletion{}, ErrPendingTransfers
+	}
+
+	if balance > 0 && policy.Balance != BalanceForfeit {
+		return schema.Deletion{}, ErrBalanceRemaining
+	}
+
+	var generation int64
+	err = tx.QueryRow(getTokenGeneration, username, s.Program).Scan(&generation)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	alias, err := newAlias()
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	code, err := newReferralCode()
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	_, err = tx.Exec(addAlias, alias, code, username, s.Program)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	// the alias must not expire or spend the points written off
+	_, err = tx.Exec(freezeCredits, username, s.Program)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	_, err = tx.Exec(deleteSessions, username, s.Program)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	_, err = tx.Exec(deleteRecoveryCodes, username, s.Program)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	_, err = tx.Exec(deletePasswordResets, username, s.Program)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	_, err = tx.Exec(deleteRiskActivity, username, s.Program)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	if policy.Mode == DeletionDelete {
+		for _, query := range deleteUserRows {
+			_, err = tx.Exec(query, username, s.Program)
+			if err != nil {
+				return schema.Deletion{}, err
+			}
+		}
+
+		_, err = tx.Exec(tombstoneOrders, alias, username, s.Program)
+		if err != nil {
+			return schema.Deletion{}, err
+		}
+	} else {
+		for _, query := range moveUserRows {
+			_, err = tx.Exec(query, alias, username, s.Program)
+			if err != nil {
+				return schema.Deletion{}, err
+			}
+		}
+	}
+
+	for _, query := range moveSharedRows {
+		_, err = tx.Exec(query, alias, username, s.Program)
+		if err != nil {
+			return schema.Deletion{}, err
+		}
+	}
+
+	_, err = tx.Exec(deleteUser, username, s.Program)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	deletion := schema.Deletion{Alias: alias, Mode: policy.Mode, Forfeited: balance}
+	err = tx.QueryRow(addDeletion, userHash(s.Program, username), alias, policy.Mode, balance, generation, s.Program).
+		Scan(&deletion.ID, &deletion.DeletedAt)
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	return deletion, tx.Commit()
+}
+
+// newAlias returns the name of the anonymous owner of the rows left by a deleted user.
+func newAlias() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "deleted-" + hex.EncodeToString(b), nil
+}
+
+// userHash identifies the deleted user in the retained records without keeping the login.
+func userHash(programID, username string) string {
+	sum := sha256.Sum256([]byte(programID + "\x00" + username))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"gomarket/internal/loyalty/schema"
+	"strconv"
+	"strings"
+)
+
+func (s Storage) IsBlocked(username string) (bool, error) {
+	prepare, err := s.DB.Prepare(isBlocked)
+	if err != nil {
+		return false, err
+	}
+
+	var blocked bool
+	err = prepare.QueryRow(username, s.Program).Scan(&blocked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrUserNotFound
+	}
+
+	return blocked, err
+}
+
+func (s Storage) FindUsers(query string, limit, offset int) ([]schema.User, error) {
+	prepare, err := s.DB.Prepare(findUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := "%" + escapeLike(query) + "%"
+	rows, err := prepare.Query(pattern, limit, offset, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]schema.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (s Storage) GetUser(username string) (schema.User, error) {
+	prepare, err := s.DB.Prepare(getUser)
+	if err != nil {
+		return schema.User{}, err
+	}
+
+	user, err := scanUser(prepare.QueryRow(username, s.Program))
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema.User{}, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+func scanUser(row scanner) (schema.User, error) {
+	var user schema.User
+	err := row.Scan(&user.Login, &user.Balance, &user.Withdrawn, &user.Tier, &user.Blocked)
+	return user, err
+}
+
+func (s Storage) Adjust(username string, sum float64, reason, admin string) (schema.Adjustment, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+	defer tx.Rollback()
+
+	adjustment, err := s.adjust(tx, username, sum, reason, admin)
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	return adjustment, tx.Commit()
+}
+
+func (s Storage) adjust(tx *sql.Tx, username string, sum float64, reason, admin string) (schema.Adjustment, error) {
+	var balance float64
+	var blocked bool
+	err := tx.QueryRow(lockUser, username, s.Program).Scan(&balance, &blocked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema.Adjustment{}, ErrUserNotFound
+	}
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	adjustment := schema.Adjustment{
+		Login:  username,
+		Sum:    sum,
+		Reason: reason,
+		Admin:  admin,
+	}
+
+	err = tx.QueryRow(addAdjustment, username, sum, reason, admin, s.Program).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	if sum > 0 {
+		reference := strconv.FormatInt(adjustment.ID, 10)
+		_, err = tx.Exec(addCredit, username, CreditSourceAdjustment, reference, sum, s.Program)
+		if err != nil {
+			return schema.Adjustment{}, err
+		}
+
+		_, err = tx.Exec(updateBalance, sum, username, s.Program)
+	} else {
+		if balance < -sum {
+			return schema.Adjustment{}, ErrNotEnoughMoney
+		}
+
+		_, err = s.spendCredits(tx, username, -sum)
+		if err != nil {
+			return schema.Adjustment{}, err
+		}
+
+		_, err = tx.Exec(writeOffBalance, -sum, username, s.Program)
+	}
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	details := strconv.FormatFloat(sum, 'f', -1, 64) + ": " + reason
+	_, err = tx.Exec(addAdminAction, admin, "adjust", username, details, s.Program)
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	return adjustment, nil
+}
+
+func (s Storage) SetBlocked(username string, blocked bool, reason, admin string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(setBlocked, blocked, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+
+	action := "unblock"
+	if blocked {
+		action = "block"
+	}
+
+	_, err = tx.Exec(addAdminAction, admin, action, username, reason, s.Program)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s Storage) AddAdminAction(action schema.AdminAction) error {
+	prepare, err := s.DB.Prepare(addAdminAction)
+	if err != nil {
+		return err
+	}
+
+	_, err = prepare.Exec(action.Admin, action.Action, action.Target, action.Details, s.Program)
+	return err
+}
+
+func (s Storage) GetAdminActions(target string, limit, offset int) ([]schema.AdminAction, error) {
+	prepare, err := s.DB.Prepare(getAdminActions)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := prepare.Query(target, limit, offset, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := make([]schema.AdminAction, 0)
+	for rows.Next() {
+		var action schema.AdminAction
+		err = rows.Scan(&action.ID, &action.Admin, &action.Action, &action.Target, &action.Details, &action.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		actions = append(actions, action)
+	}
+
+	return actions, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"gomarket/internal/loyalty/schema"
+	"math"
+)
+
+// balanceTolerance is the least difference of the balances that is a mismatch, the points have kopecks.
+const balanceTolerance = 0.005
+
+// CheckBalances recomputes the balances of the users and reports the ones that differ from the stored balances.
+// With repair the users are locked and the mismatched balances are replaced in one transaction,
+// every repair is recorded as an admin action of admin.
+func (s Storage) CheckBalances(repair bool, admin string) (schema.BalanceCheck, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.BalanceCheck{}, err
+	}
+	defer tx.Rollback()
+
+	if repair {
+		// the locks keep the balances from changing between the check and the repair
+		_, err = tx.Exec(lockProgramUsers, s.Program)
+		if err != nil {
+			return schema.BalanceCheck{}, err
+		}
+	}
+
+	rows, err := tx.Query(checkBalances, s.Program)
+	if err != nil {
+		return schema.BalanceCheck{}, err
+	}
+	defer rows.Close()
+
+	check := schema.BalanceCheck{Repaired: repair, Mismatches: make([]schema.BalanceMismatch, 0)}
+	for rows.Next() {
+		var m schema.BalanceMismatch
+		err = rows.Scan(&m.Login, &m.Balance, &m.Withdrawn, &m.Expected, &m.ExpectedWithdrawn)
+		if err != nil {
+			return schema.BalanceCheck{}, err
+		}
+
+		check.Checked++
+		if mismatched(m) {
+			check.Mismatches = append(check.Mismatches, m)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return schema.BalanceCheck{}, err
+	}
+	rows.Close()
+
+	check.Mismatched = len(check.Mismatches)
+	if !repair {
+		return check, nil
+	}
+
+	for _, m := range check.Mismatches {
+		_, err = tx.Exec(setBalance, m.Expected, m.ExpectedWithdrawn, m.Login, s.Program)
+		if err != nil {
+			return schema.BalanceCheck{}, err
+		}
+
+		_, err = tx.Exec(addAdminAction, admin, "repair_balance", m.Login, repairDetails(m), s.Program)
+		if err != nil {
+			return schema.BalanceCheck{}, err
+		}
+	}
+
+	return check, tx.Commit()
+}
+
+func mismatched(m schema.BalanceMismatch) bool {
+	return math.Abs(m.Balance-m.Expected) >= balanceTolerance ||
+		math.Abs(m.Withdrawn-m.ExpectedWithdrawn) >= balanceTolerance
+}
+
+func repairDetails(m schema.BalanceMismatch) string {
+	return fmt.Sprintf("balance %g -> %g, withdrawn %g -> %g", m.Balance, m.Expected, m.Withdrawn, m.ExpectedWithdrawn)
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"gomarket/internal/loyalty/schema"
+	"strconv"
+)
+
+func (s Storage) CreateCampaign(c schema.Campaign, admin string) (schema.Campaign, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Campaign{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(addCampaign, c.Name, c.Multiplier, c.Bonus, c.FirstOrders, c.StartsAt, c.EndsAt, c.Disabled, s.Program).
+		Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		return schema.Campaign{}, err
+	}
+
+	_, err = tx.Exec(addAdminAction, admin, "create_campaign", campaignTarget(c.ID), c.Name, s.Program)
+	if err != nil {
+		return schema.Campaign{}, err
+	}
+
+	return c, tx.Commit()
+}
+
+func (s Storage) UpdateCampaign(c schema.Campaign, admin string) (schema.Campaign, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Campaign{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(updateCampaign, c.Name, c.Multiplier, c.Bonus, c.FirstOrders, c.StartsAt, c.EndsAt, c.Disabled, c.ID, s.Program).
+		Scan(&c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema.Campaign{}, ErrCampaignNotFound
+	}
+	if err != nil {
+		return schema.Campaign{}, err
+	}
+
+	_, err = tx.Exec(addAdminAction, admin, "update_campaign", campaignTarget(c.ID), c.Name, s.Program)
+	if err != nil {
+		return schema.Campaign{}, err
+	}
+
+	return c, tx.Commit()
+}
+
+func (s Storage) GetCampaigns() ([]schema.Campaign, error) {
+	prepare, err := s.DB.Prepare(getCampaigns)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := prepare.Query(s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns := make([]schema.Campaign, 0)
+	for rows.Next() {
+		var c schema.Campaign
+		var endsAt sql.NullTime
+		err = rows.Scan(&c.ID, &c.Name, &c.Multiplier, &c.Bonus, &c.FirstOrders, &c.StartsAt, &endsAt, &c.Disabled, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if endsAt.Valid {
+			c.EndsAt = &endsAt.Time
+		}
+
+		campaigns = append(campaigns, c)
+	}
+
+	return campaigns, rows.Err()
+}
+
+// getOrderCampaigns returns the campaigns applied to the orders of the user by the order number.
+func (s Storage) getOrderCampaigns(username string) (map[string][]schema.AppliedCampaign, error) {
+	rows, err := s.DB.Query(getOrderCampaigns, username, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns := make(map[string][]schema.AppliedCampaign)
+	for rows.Next() {
+		var order string
+		var c schema.AppliedCampaign
+		err = rows.Scan(&order, &c.ID, &c.Name, &c.Bonus)
+		if err != nil {
+			return nil, err
+		}
+
+		campaigns[order] = append(campaigns[order], c)
+	}
+
+	return campaigns, rows.Err()
+}
+
+// campaignTarget is the target of the admin actions on the campaign.
+func campaignTarget(id int64) string {
+	return "campaign/" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,19 @@
+package storage_test
+
+import (
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/storage/storagetest"
+	"testing"
+)
+
+// TestStorage_Conformance runs after the tests of the storage package, so it may empty the tables.
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.IStorage {
+		_, err := storage.TestDB.DB.Exec(`TRUNCATE "Users", "AdminActions", "RiskActivity", "RiskFlags", "Reconciliations", "Discrepancies" RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return storage.TestDB
+	})
+}
@@ -0,0 +1,1801 @@
+package storage
+
+import (
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/withdrawal"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps the data in memory with the same semantics as Storage.
+// It is meant for local development and tests, the data is lost on restart.
+type Memory struct {
+	mu sync.Mutex
+
+	// programs is shared by the storages of all the programs
+	programs *memoryPrograms
+
+	users       map[string]*memoryUser
+	orders      map[string]*memoryOrder
+	orderIDs    []string
+	credits     []*memoryCredit
+	withdrawals []memoryWithdrawal
+	expirations []memoryExpiration
+	transfers   []*memoryTransfer
+	adjustments []schema.Adjustment
+	actions     []schema.AdminAction
+	campaigns   []schema.Campaign
+	referrals   []*memoryReferral
+	sessions    []memorySession
+	deletions   []schema.Deletion
+	resets      []*memoryReset
+	events      []schema.SecurityEvent
+	activity    []memoryActivity
+	flags       []schema.RiskFlag
+	reports     []schema.Reconciliation
+	// generations are the token generations the deleted logins start at when they are registered again
+	generations map[string]int64
+
+	// the IDs of credits and adjustments are not reused after the rows of a deleted user are removed
+	lastCreditID     int64
+	lastAdjustmentID int64
+}
+
+type memoryUser struct {
+	password  string
+	balance   float64
+	withdrawn float64
+	tier      string
+	blocked   bool
+	code      string
+	twoFactor TwoFactor
+	// recovery maps the hashes of the recovery codes to whether they were used
+	recovery   map[string]bool
+	generation int64
+}
+
+type memoryActivity struct {
+	owner string
+	date  time.Time
+	RiskActivity
+}
+
+type memoryReset struct {
+	owner     string
+	hash      string
+	expiresAt time.Time
+	used      bool
+}
+
+type memoryOrder struct {
+	owner      string
+	status     string
+	accrual    float64
+	date       time.Time
+	campaigns  []schema.AppliedCampaign
+	reported   *float64
+	multiplier *float64
+}
+
+type memoryCredit struct {
+	id        int64
+	owner     string
+	source    string
+	reference string
+	amount    float64
+	remaining float64
+	date      time.Time
+}
+
+type memoryWithdrawal struct {
+	client string
+	order  string
+	sum    float64
+	date   time.Time
+}
+
+type memoryExpiration struct {
+	owner  string
+	credit int64
+	sum    float64
+	date   time.Time
+}
+
+type memoryTransfer struct {
+	schema.Transfer
+	parts []schema.Credit
+}
+
+type memorySession struct {
+	owner string
+	schema.Session
+}
+
+type memoryReferral struct {
+	id       int64
+	referrer string
+	schema.Referral
+}
+
+type memoryPrograms struct {
+	mu   sync.Mutex
+	byID map[string]*Memory
+}
+
+// NewMemory returns the storage of the default program.
+func NewMemory() IStorage {
+	programs := &memoryPrograms{byID: make(map[string]*Memory)}
+	return programs.get(program.DefaultID)
+}
+
+func (p *memoryPrograms) get(id string) *Memory {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.byID[id]
+	if !ok {
+		m = &Memory{
+			programs:    p,
+			users:       make(map[string]*memoryUser),
+			orders:      make(map[string]*memoryOrder),
+			generations: make(map[string]int64),
+		}
+		p.byID[id] = m
+	}
+
+	return m
+}
+
+func (p *memoryPrograms) all() []*Memory {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all := make([]*Memory, 0, len(p.byID))
+	for _, m := range p.byID {
+		all = append(all, m)
+	}
+
+	return all
+}
+
+// ForProgram returns the storage of the program, every program keeps its data apart.
+func (m *Memory) ForProgram(id string) IStorage {
+	return m.programs.get(id)
+}
+
+func (m *Memory) CreateUser(login, passwd string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createUser(login, passwd)
+}
+
+func (m *Memory) createUser(login, passwd string) error {
+	if _, ok := m.users[login]; ok {
+		return ErrUsernameConflict
+	}
+
+	code, err := newReferralCode()
+	if err != nil {
+		return err
+	}
+
+	m.users[login] = &memoryUser{password: passwd, code: code, generation: m.generations[login]}
+	return nil
+}
+
+func (m *Memory) CheckPassword(login, passwd string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[login]
+	if !ok || user.password != passwd {
+		return ErrWrongPassword
+	}
+
+	if user.blocked {
+		return ErrUserBlocked
+	}
+
+	return nil
+}
+
+func (m *Memory) CheckID(username, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[username]; !ok {
+		return ErrUserNotFound
+	}
+
+	if order, ok := m.orders[id]; ok {
+		if order.owner != username {
+			return ErrCreatedByAnotherUser
+		}
+
+		return ErrCreatedByThisUser
+	}
+
+	m.addOrder(username, id)
+	return nil
+}
+
+func (m *Memory) AddOrders(username string, ids []string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[username]; !ok {
+		return nil, ErrUserNotFound
+	}
+
+	results := make(map[string]string, len(ids))
+	for _, id := range ids {
+		order, ok := m.orders[id]
+		switch {
+		case !ok:
+			m.addOrder(username, id)
+			results[id] = OrderAccepted
+		case order.owner == username:
+			results[id] = OrderDuplicate
+		default:
+			results[id] = OrderOwnedByAnotherUser
+		}
+	}
+
+	return results, nil
+}
+
+func (m *Memory) addOrder(username, id string) {
+	m.orders[id] = &memoryOrder{owner: username, status: "NEW", date: time.Now()}
+	m.orderIDs = append(m.orderIDs, id)
+}
+
+func (m *Memory) GetOrders(username string) (Orders, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orders := make(Orders, 0)
+	for _, id := range m.orderIDs {
+		order := m.orders[id]
+		if order.owner != username {
+			continue
+		}
+
+		orders = append(orders, schema.UserOrder{
+			Number:     id,
+			Status:     order.status,
+			Accrual:    order.accrual,
+			UploadedAt: order.date.Format(time.RFC3339),
+			Campaigns:  m.orderCampaigns(order),
+		})
+	}
+
+	if len(orders) == 0 {
+		return nil, ErrNoResult
+	}
+
+	return orders, nil
+}
+
+func (m *Memory) GetBalance(username string) (schema.Balance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return schema.Balance{}, ErrUserNotFound
+	}
+
+	return schema.Balance{Current: float32(user.balance), Withdrawn: float32(user.withdrawn), Tier: user.tier}, nil
+}
+
+func (m *Memory) UpdateOrder(username, id, status string, accrual float64, campaigns []schema.AppliedCampaign) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.orders[id]
+	if accrual == 0 {
+		if ok {
+			order.status = status
+		}
+
+		return nil
+	}
+
+	user, found := m.users[username]
+	if !found {
+		return ErrUserNotFound
+	}
+
+	if ok {
+		order.status = status
+		order.accrual = accrual
+		order.campaigns = append(order.campaigns, campaigns...)
+	}
+
+	user.balance += accrual
+	m.addCredit(username, CreditSourceAccrual, id, accrual, time.Now())
+	return nil
+}
+
+func (m *Memory) addCredit(owner, source, reference string, amount float64, date time.Time) {
+	m.lastCreditID++
+	m.credits = append(m.credits, &memoryCredit{
+		id:        m.lastCreditID,
+		owner:     owner,
+		source:    source,
+		reference: reference,
+		amount:    amount,
+		remaining: amount,
+		date:      date,
+	})
+}
+
+func (m *Memory) Withdraw(username string, amount float64, orderID string, limit WithdrawalLimit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if user.blocked {
+		return ErrUserBlocked
+	}
+
+	for _, w := range m.withdrawals {
+		if w.client == username && w.order == orderID {
+			return sameWithdrawal(w.sum, amount)
+		}
+	}
+
+	now := time.Now()
+	usage := withdrawal.Usage{
+		Balance: user.balance,
+		Day:     m.withdrawnSince(username, now.Add(-24*time.Hour)),
+		Month:   m.withdrawnSince(username, withdrawal.MonthStart(now)),
+		Fresh:   m.freshPoints(username, now.Add(-limit.Policy.CoolingOff)),
+	}
+
+	err := limit.Policy.Check(amount, limit.OrderTotal, usage)
+	if err != nil {
+		return err
+	}
+
+	if user.balance < amount {
+		return ErrNotEnoughMoney
+	}
+
+	m.spendCredits(username, amount)
+	user.balance -= amount
+	user.withdrawn += amount
+	m.withdrawals = append(m.withdrawals, memoryWithdrawal{
+		client: username,
+		order:  orderID,
+		sum:    amount,
+		date:   time.Now(),
+	})
+
+	return nil
+}
+
+// spendCredits consumes user's credits in FIFO order like spendCredits of Storage.
+func (m *Memory) spendCredits(username string, amount float64) []schema.Credit {
+	spent := make([]schema.Credit, 0)
+	for _, credit := range m.userCredits(username, time.Time{}) {
+		if amount <= 0 {
+			break
+		}
+
+		part := math.Min(credit.remaining, amount)
+		credit.remaining -= part
+		spent = append(spent, schema.Credit{ID: credit.id, Remaining: part, Date: credit.date})
+		amount -= part
+	}
+
+	return spent
+}
+
+// userCredits returns the unspent credits of the user ordered by date,
+// only the ones dated before the given moment unless it is zero.
+func (m *Memory) userCredits(username string, before time.Time) []*memoryCredit {
+	credits := make([]*memoryCredit, 0)
+	for _, credit := range m.credits {
+		if credit.owner != username || credit.remaining <= 0 {
+			continue
+		}
+
+		if !before.IsZero() && !credit.date.Before(before) {
+			continue
+		}
+
+		credits = append(credits, credit)
+	}
+
+	sort.SliceStable(credits, func(i, j int) bool {
+		return credits[i].date.Before(credits[j].date)
+	})
+
+	return credits
+}
+
+func (m *Memory) GetWithdrawals(username string) ([]schema.Withdrawn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	withdrawals := make([]schema.Withdrawn, 0)
+	for _, withdrawal := range m.withdrawals {
+		if withdrawal.client != username {
+			continue
+		}
+
+		withdrawals = append(withdrawals, schema.Withdrawn{
+			Order:       withdrawal.order,
+			Sum:         withdrawal.sum,
+			ProcessedAt: withdrawal.date,
+		})
+	}
+
+	if len(withdrawals) == 0 {
+		return nil, ErrNoWithdrawals
+	}
+
+	return withdrawals, nil
+}
+
+func (m *Memory) GetExpiringCredits(username string, before time.Time) ([]schema.Credit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	credits := make([]schema.Credit, 0)
+	for _, credit := range m.userCredits(username, before) {
+		credits = append(credits, schema.Credit{ID: credit.id, Remaining: credit.remaining, Date: credit.date})
+	}
+
+	return credits, nil
+}
+
+// ExpirePoints expires the points in every program.
+func (m *Memory) ExpirePoints(before time.Time) (float64, error) {
+	var total float64
+	for _, p := range m.programs.all() {
+		total += p.expirePoints(before)
+	}
+
+	return total, nil
+}
+
+func (m *Memory) expirePoints(before time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var total float64
+	for _, credit := range m.credits {
+		if credit.remaining <= 0 || !credit.date.Before(before) {
+			continue
+		}
+
+		m.expirations = append(m.expirations, memoryExpiration{
+			owner:  credit.owner,
+			credit: credit.id,
+			sum:    credit.remaining,
+			date:   time.Now(),
+		})
+
+		m.users[credit.owner].balance -= credit.remaining
+		total += credit.remaining
+		credit.remaining = 0
+	}
+
+	return total
+}
+
+func (m *Memory) GetAccrued(username string, since time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var accrued float64
+	for _, credit := range m.credits {
+		if credit.owner != username || credit.source != CreditSourceAccrual || !credit.date.After(since) {
+			continue
+		}
+
+		if order, ok := m.orders[credit.reference]; ok && order.reported != nil {
+			accrued += *order.reported
+		} else {
+			accrued += credit.amount
+		}
+	}
+
+	return accrued, nil
+}
+
+func (m *Memory) GetWithdrawnSince(username string, since time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.withdrawnSince(username, since), nil
+}
+
+func (m *Memory) withdrawnSince(username string, since time.Time) float64 {
+	var withdrawn float64
+	for _, w := range m.withdrawals {
+		if w.client == username && w.date.After(since) {
+			withdrawn += w.sum
+		}
+	}
+
+	return withdrawn
+}
+
+func (m *Memory) GetFreshPoints(username string, since time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.freshPoints(username, since), nil
+}
+
+func (m *Memory) freshPoints(username string, since time.Time) float64 {
+	var fresh float64
+	for _, credit := range m.credits {
+		if credit.owner == username && credit.date.After(since) {
+			fresh += credit.remaining
+		}
+	}
+
+	return fresh
+}
+
+func (m *Memory) ChangeTier(username, tier string, accrued float64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return false, ErrUserNotFound
+	}
+
+	if user.tier == tier {
+		return false, nil
+	}
+
+	user.tier = tier
+	return true, nil
+}
+
+func (m *Memory) CreateTransfer(sender, recipient string, sum float64, pending bool, limit TransferLimit) (schema.Transfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[sender]
+	if !ok {
+		return schema.Transfer{}, ErrUserNotFound
+	}
+
+	target, ok := m.users[recipient]
+	if !ok {
+		return schema.Transfer{}, ErrUserNotFound
+	}
+
+	if user.blocked || target.blocked {
+		return schema.Transfer{}, ErrUserBlocked
+	}
+
+	if user.balance < sum {
+		return schema.Transfer{}, ErrNotEnoughMoney
+	}
+
+	if limit.Max > 0 {
+		var transferred float64
+		for _, transfer := range m.transfers {
+			if transfer.Sender == sender && transfer.Status != TransferDeclined && transfer.CreatedAt.After(limit.Since) {
+				transferred += transfer.Sum
+			}
+		}
+
+		if transferred+sum > limit.Max {
+			return schema.Transfer{}, ErrTransferLimit
+		}
+	}
+
+	transfer := &memoryTransfer{Transfer: schema.Transfer{
+		ID:        int64(len(m.transfers) + 1),
+		Sender:    sender,
+		Recipient: recipient,
+		Sum:       sum,
+		Status:    TransferCompleted,
+		CreatedAt: time.Now(),
+	}}
+	if pending {
+		transfer.Status = TransferPending
+	}
+
+	transfer.parts = m.spendCredits(sender, sum)
+	user.balance -= sum
+	m.transfers = append(m.transfers, transfer)
+
+	if !pending {
+		completedAt := transfer.CreatedAt
+		transfer.CompletedAt = &completedAt
+		m.creditTransfer(recipient, transfer)
+	}
+
+	return transfer.Transfer, nil
+}
+
+// CompleteTransfer accepts or declines the pending transfer like CompleteTransfer of Storage.
+func (m *Memory) CompleteTransfer(id int64, username string, accept bool) (schema.Transfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id <= 0 || id > int64(len(m.transfers)) {
+		return schema.Transfer{}, ErrTransferNotFound
+	}
+
+	transfer := m.transfers[id-1]
+	if transfer.Recipient != username && (accept || transfer.Sender != username) {
+		return schema.Transfer{}, ErrTransferNotFound
+	}
+
+	if transfer.Status != TransferPending {
+		return schema.Transfer{}, ErrTransferNotFound
+	}
+
+	if recipient, ok := m.users[transfer.Recipient]; accept && ok && recipient.blocked {
+		return schema.Transfer{}, ErrUserBlocked
+	}
+
+	owner := transfer.Sender
+	transfer.Status = TransferDeclined
+	if accept {
+		owner = transfer.Recipient
+		transfer.Status = TransferCompleted
+	}
+
+	completedAt := time.Now()
+	transfer.CompletedAt = &completedAt
+	m.creditTransfer(owner, transfer)
+
+	return transfer.Transfer, nil
+}
+
+// creditTransfer credits the transferred points to the owner keeping the dates of the original credits.
+func (m *Memory) creditTransfer(owner string, transfer *memoryTransfer) {
+	reference := strconv.FormatInt(transfer.ID, 10)
+	for _, part := range transfer.parts {
+		m.addCredit(owner, CreditSourceTransfer, reference, part.Remaining, part.Date)
+	}
+
+	m.users[owner].balance += transfer.Sum
+}
+
+func (m *Memory) GetTransfers(username string) ([]schema.Transfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transfers := make([]schema.Transfer, 0)
+	for i := len(m.transfers) - 1; i >= 0; i-- {
+		transfer := m.transfers[i]
+		if transfer.Sender == username || transfer.Recipient == username {
+			transfers = append(transfers, transfer.Transfer)
+		}
+	}
+
+	if len(transfers) == 0 {
+		return nil, ErrNoTransfers
+	}
+
+	return transfers, nil
+}
+
+func (m *Memory) IsBlocked(username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return false, ErrUserNotFound
+	}
+
+	return user.blocked, nil
+}
+
+func (m *Memory) FindUsers(query string, limit, offset int) ([]schema.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query = strings.ToLower(query)
+	names := make([]string, 0)
+	for name := range m.users {
+		if strings.Contains(strings.ToLower(name), query) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, end := pageBounds(len(names), limit, offset)
+	users := make([]schema.User, 0, end-start)
+	for _, name := range names[start:end] {
+		users = append(users, m.user(name))
+	}
+
+	return users, nil
+}
+
+func (m *Memory) GetUser(username string) (schema.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[username]; !ok {
+		return schema.User{}, ErrUserNotFound
+	}
+
+	return m.user(username), nil
+}
+
+func (m *Memory) user(name string) schema.User {
+	user := m.users[name]
+	return schema.User{
+		Login:     name,
+		Balance:   user.balance,
+		Withdrawn: user.withdrawn,
+		Tier:      user.tier,
+		Blocked:   user.blocked,
+	}
+}
+
+func (m *Memory) Adjust(username string, sum float64, reason, admin string) (schema.Adjustment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.adjust(username, sum, reason, admin)
+}
+
+func (m *Memory) adjust(username string, sum float64, reason, admin string) (schema.Adjustment, error) {
+	user, ok := m.users[username]
+	if !ok {
+		return schema.Adjustment{}, ErrUserNotFound
+	}
+
+	if sum < 0 && user.balance < -sum {
+		return schema.Adjustment{}, ErrNotEnoughMoney
+	}
+
+	m.lastAdjustmentID++
+	adjustment := schema.Adjustment{
+		ID:        m.lastAdjustmentID,
+		Login:     username,
+		Sum:       sum,
+		Reason:    reason,
+		Admin:     admin,
+		CreatedAt: time.Now(),
+	}
+
+	if sum > 0 {
+		m.addCredit(username, CreditSourceAdjustment, strconv.FormatInt(adjustment.ID, 10), sum, adjustment.CreatedAt)
+	} else {
+		m.spendCredits(username, -sum)
+	}
+
+	user.balance += sum
+	m.adjustments = append(m.adjustments, adjustment)
+	m.addAdminAction(admin, "adjust", username, strconv.FormatFloat(sum, 'f', -1, 64)+": "+reason)
+
+	return adjustment, nil
+}
+
+func (m *Memory) SetBlocked(username string, blocked bool, reason, admin string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	user.blocked = blocked
+
+	action := "unblock"
+	if blocked {
+		action = "block"
+	}
+
+	m.addAdminAction(admin, action, username, reason)
+	return nil
+}
+
+func (m *Memory) AddAdminAction(action schema.AdminAction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.addAdminAction(action.Admin, action.Action, action.Target, action.Details)
+	return nil
+}
+
+func (m *Memory) addAdminAction(admin, action, target, details string) {
+	m.actions = append(m.actions, schema.AdminAction{
+		ID:        int64(len(m.actions) + 1),
+		Admin:     admin,
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (m *Memory) GetAdminActions(target string, limit, offset int) ([]schema.AdminAction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	matched := make([]schema.AdminAction, 0)
+	for i := len(m.actions) - 1; i >= 0; i-- {
+		if target == "" || m.actions[i].Target == target {
+			matched = append(matched, m.actions[i])
+		}
+	}
+
+	start, end := pageBounds(len(matched), limit, offset)
+	return matched[start:end], nil
+}
+
+// pageBounds returns the bounds of the page in a slice of n elements, like LIMIT and OFFSET.
+func pageBounds(n, limit, offset int) (int, int) {
+	start := offset
+	if start > n {
+		start = n
+	}
+
+	end := start + limit
+	if end > n {
+		end = n
+	}
+
+	return start, end
+}
+
+func (m *Memory) GetOpeningBalance(username string, before time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var balance float64
+	for _, entry := range m.ledger(username) {
+		if entry.Date.Before(before) {
+			balance += entry.Amount
+		}
+	}
+
+	return balance, nil
+}
+
+// GetStatement calls fn for every balance movement in [from, to) in chronological order.
+func (m *Memory) GetStatement(username string, from, to time.Time, fn func(schema.StatementEntry) error) error {
+	m.mu.Lock()
+	ledger := m.ledger(username)
+	m.mu.Unlock()
+
+	for _, entry := range ledger {
+		if entry.Date.Before(from) || !entry.Date.Before(to) {
+			continue
+		}
+
+		err := fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ledger lists every balance movement of the user like the ledger query of Storage.
+func (m *Memory) ledger(username string) []schema.StatementEntry {
+	entries := make([]schema.StatementEntry, 0)
+	add := func(date time.Time, kind, reference string, amount float64) {
+		entries = append(entries, schema.StatementEntry{Date: date, Type: kind, Reference: reference, Amount: amount})
+	}
+
+	for _, credit := range m.credits {
+		if credit.owner == username && credit.source != CreditSourceTransfer {
+			add(credit.date, credit.source, credit.reference, credit.amount)
+		}
+	}
+
+	for _, withdrawal := range m.withdrawals {
+		if withdrawal.client == username {
+			add(withdrawal.date, "withdrawal", withdrawal.order, -withdrawal.sum)
+		}
+	}
+
+	for _, transfer := range m.transfers {
+		reference := strconv.FormatInt(transfer.ID, 10)
+		if transfer.Sender == username {
+			add(transfer.CreatedAt, "transfer", reference, -transfer.Sum)
+		}
+
+		if transfer.Recipient == username && transfer.Status == TransferCompleted {
+			add(*transfer.CompletedAt, "transfer", reference, transfer.Sum)
+		}
+
+		if transfer.Sender == username && transfer.Status == TransferDeclined {
+			add(*transfer.CompletedAt, "transfer", reference, transfer.Sum)
+		}
+	}
+
+	for _, adjustment := range m.adjustments {
+		if adjustment.Login == username && adjustment.Sum < 0 {
+			add(adjustment.CreatedAt, "adjustment", strconv.FormatInt(adjustment.ID, 10), adjustment.Sum)
+		}
+	}
+
+	for _, expiration := range m.expirations {
+		if expiration.owner == username {
+			add(expiration.date, "expiration", strconv.FormatInt(expiration.credit, 10), -expiration.sum)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+
+	return entries
+}
+
+func (m *Memory) CreateCampaign(c schema.Campaign, admin string) (schema.Campaign, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.ID = int64(len(m.campaigns) + 1)
+	c.CreatedAt = time.Now()
+	m.campaigns = append(m.campaigns, c)
+	m.addAdminAction(admin, "create_campaign", campaignTarget(c.ID), c.Name)
+	return c, nil
+}
+
+func (m *Memory) UpdateCampaign(c schema.Campaign, admin string) (schema.Campaign, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c.ID < 1 || c.ID > int64(len(m.campaigns)) {
+		return schema.Campaign{}, ErrCampaignNotFound
+	}
+
+	c.CreatedAt = m.campaigns[c.ID-1].CreatedAt
+	m.campaigns[c.ID-1] = c
+	m.addAdminAction(admin, "update_campaign", campaignTarget(c.ID), c.Name)
+	return c, nil
+}
+
+func (m *Memory) GetCampaigns() ([]schema.Campaign, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append(make([]schema.Campaign, 0, len(m.campaigns)), m.campaigns...), nil
+}
+
+// orderCampaigns returns the campaigns applied to the order with their current names like the join in Storage.
+func (m *Memory) orderCampaigns(order *memoryOrder) []schema.AppliedCampaign {
+	if len(order.campaigns) == 0 {
+		return nil
+	}
+
+	applied := make([]schema.AppliedCampaign, 0, len(order.campaigns))
+	for _, c := range order.campaigns {
+		if c.ID >= 1 && c.ID <= int64(len(m.campaigns)) {
+			c.Name = m.campaigns[c.ID-1].Name
+		}
+		applied = append(applied, c)
+	}
+
+	return applied
+}
+
+func (m *Memory) CreateReferredUser(login, passwd, code string, limit ReferralLimit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	referrer := ""
+	for name, user := range m.users {
+		if user.code == code && !user.blocked {
+			referrer = name
+		}
+	}
+
+	if referrer == "" {
+		return ErrBadReferral
+	}
+
+	if limit.Max > 0 {
+		referred := 0
+		for _, referral := range m.referrals {
+			if referral.referrer == referrer && referral.CreatedAt.After(limit.Since) {
+				referred++
+			}
+		}
+
+		if referred >= limit.Max {
+			return ErrReferralLimit
+		}
+	}
+
+	err := m.createUser(login, passwd)
+	if err != nil {
+		return err
+	}
+
+	m.referrals = append(m.referrals, &memoryReferral{
+		id:       int64(len(m.referrals) + 1),
+		referrer: referrer,
+		Referral: schema.Referral{Login: login, Status: ReferralPending, CreatedAt: time.Now()},
+	})
+	return nil
+}
+
+func (m *Memory) RewardReferral(referred string, bonus float64, maxRewarded int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	referral := m.pendingReferral(referred)
+	if referral == nil {
+		return nil
+	}
+
+	rewarded := 0
+	for _, r := range m.referrals {
+		if r.referrer == referral.referrer && r.Status == ReferralRewarded {
+			rewarded++
+		}
+	}
+
+	now := time.Now()
+	referral.CompletedAt = &now
+	if m.users[referral.referrer].blocked || m.users[referred].blocked || (maxRewarded > 0 && rewarded >= maxRewarded) {
+		referral.Status = ReferralRejected
+		return nil
+	}
+
+	referral.Status = ReferralRewarded
+	referral.Bonus = bonus
+	if bonus <= 0 {
+		return nil
+	}
+
+	reference := strconv.FormatInt(referral.id, 10)
+	for _, username := range []string{referral.referrer, referred} {
+		m.users[username].balance += bonus
+		m.addCredit(username, CreditSourceReferral, reference, bonus, now)
+	}
+
+	return nil
+}
+
+func (m *Memory) RejectReferral(referred string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if referral := m.pendingReferral(referred); referral != nil {
+		now := time.Now()
+		referral.Status = ReferralRejected
+		referral.CompletedAt = &now
+	}
+
+	return nil
+}
+
+func (m *Memory) pendingReferral(referred string) *memoryReferral {
+	for _, referral := range m.referrals {
+		if referral.Login == referred && referral.Status == ReferralPending {
+			return referral
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) GetReferrals(username string) (schema.Referrals, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return schema.Referrals{}, ErrUserNotFound
+	}
+
+	referrals := schema.Referrals{Code: user.code, Referrals: make([]schema.Referral, 0)}
+	for i := len(m.referrals) - 1; i >= 0; i-- {
+		referral := m.referrals[i]
+		if referral.referrer != username {
+			continue
+		}
+
+		if referral.Status == ReferralRewarded {
+			referrals.Earned += referral.Bonus
+		}
+
+		referrals.Referrals = append(referrals.Referrals, referral.Referral)
+	}
+
+	return referrals, nil
+}
+
+func (m *Memory) AddSession(username string, session schema.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[username]; !ok {
+		return ErrUserNotFound
+	}
+
+	session.CreatedAt = time.Now()
+	m.sessions = append(m.sessions, memorySession{owner: username, Session: session})
+	return nil
+}
+
+// Export reads the parts of the export one by one like Storage does.
+func (m *Memory) Export(username string) (schema.Export, error) {
+	export := schema.Export{ExportedAt: time.Now()}
+	var err error
+	export.Profile, err = m.GetUser(username)
+	if err != nil {
+		return schema.Export{}, err
+	}
+
+	referrals, err := m.GetReferrals(username)
+	if err != nil {
+		return schema.Export{}, err
+	}
+	export.ReferralCode, export.Referrals = referrals.Code, referrals.Referrals
+
+	export.Orders, _ = m.GetOrders(username)
+	export.Withdrawals, _ = m.GetWithdrawals(username)
+	export.Transfers, _ = m.GetTransfers(username)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, adjustment := range m.adjustments {
+		if adjustment.Login == username {
+			export.Adjustments = append(export.Adjustments, adjustment)
+		}
+	}
+
+	for i := len(m.sessions) - 1; i >= 0; i-- {
+		if m.sessions[i].owner == username {
+			export.Sessions = append(export.Sessions, m.sessions[i].Session)
+		}
+	}
+
+	return withEmptyLists(export), nil
+}
+
+func (m *Memory) DeleteUser(username string, policy DeletionPolicy) (schema.Deletion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return schema.Deletion{}, ErrUserNotFound
+	}
+
+	for _, transfer := range m.transfers {
+		if transfer.Status == TransferPending && (transfer.Sender == username || transfer.Recipient == username) {
+			return schema.Deletion{}, ErrPendingTransfers
+		}
+	}
+
+	if user.balance > 0 && policy.Balance != BalanceForfeit {
+		return schema.Deletion{}, ErrBalanceRemaining
+	}
+
+	alias, err := newAlias()
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	code, err := newReferralCode()
+	if err != nil {
+		return schema.Deletion{}, err
+	}
+
+	m.users[alias] = &memoryUser{withdrawn: user.withdrawn, blocked: true, code: code}
+	for _, credit := range m.credits {
+		if credit.owner == username {
+			credit.remaining = 0
+		}
+	}
+
+	sessions := m.sessions[:0]
+	for _, session := range m.sessions {
+		if session.owner != username {
+			sessions = append(sessions, session)
+		}
+	}
+	m.sessions = sessions
+
+	resets := m.resets[:0]
+	for _, reset := range m.resets {
+		if reset.owner != username {
+			resets = append(resets, reset)
+		}
+	}
+	m.resets = resets
+
+	activity := m.activity[:0]
+	for _, a := range m.activity {
+		if a.owner != username {
+			activity = append(activity, a)
+		}
+	}
+	m.activity = activity
+
+	if policy.Mode == DeletionDelete {
+		m.deleteUserRows(alias, username)
+	} else {
+		m.moveUserRows(alias, username)
+	}
+
+	for _, transfer := range m.transfers {
+		transfer.Sender = rename(transfer.Sender, username, alias)
+		transfer.Recipient = rename(transfer.Recipient, username, alias)
+	}
+
+	for _, referral := range m.referrals {
+		referral.referrer = rename(referral.referrer, username, alias)
+		referral.Login = rename(referral.Login, username, alias)
+	}
+
+	for i := range m.actions {
+		m.actions[i].Target = rename(m.actions[i].Target, username, alias)
+	}
+
+	for i := range m.flags {
+		m.flags[i].Login = rename(m.flags[i].Login, username, alias)
+	}
+
+	for _, r := range m.reports {
+		for i := range r.Discrepancies {
+			r.Discrepancies[i].Login = rename(r.Discrepancies[i].Login, username, alias)
+		}
+	}
+
+	delete(m.users, username)
+	m.generations[username] = user.generation + 1
+
+	deletion := schema.Deletion{
+		ID:        int64(len(m.deletions) + 1),
+		Alias:     alias,
+		Mode:      policy.Mode,
+		Forfeited: user.balance,
+		DeletedAt: time.Now(),
+	}
+	m.deletions = append(m.deletions, deletion)
+	return deletion, nil
+}
+
+func (m *Memory) moveUserRows(alias, username string) {
+	for _, order := range m.orders {
+		order.owner = rename(order.owner, username, alias)
+	}
+
+	for i := range m.withdrawals {
+		m.withdrawals[i].client = rename(m.withdrawals[i].client, username, alias)
+	}
+
+	for _, credit := range m.credits {
+		credit.owner = rename(credit.owner, username, alias)
+	}
+
+	for i := range m.expirations {
+		m.expirations[i].owner = rename(m.expirations[i].owner, username, alias)
+	}
+
+	for i := range m.adjustments {
+		m.adjustments[i].Login = rename(m.adjustments[i].Login, username, alias)
+	}
+}
+
+func (m *Memory) deleteUserRows(alias, username string) {
+	// the numbers stay as tombstones, so they can't be uploaded and credited again
+	for _, order := range m.orders {
+		if order.owner == username {
+			*order = memoryOrder{owner: alias, status: "INVALID", date: order.date}
+		}
+	}
+
+	withdrawals := m.withdrawals[:0]
+	for _, withdrawal := range m.withdrawals {
+		if withdrawal.client != username {
+			withdrawals = append(withdrawals, withdrawal)
+		}
+	}
+	m.withdrawals = withdrawals
+
+	credits := m.credits[:0]
+	for _, credit := range m.credits {
+		if credit.owner != username {
+			credits = append(credits, credit)
+		}
+	}
+	m.credits = credits
+
+	expirations := m.expirations[:0]
+	for _, expiration := range m.expirations {
+		if expiration.owner != username {
+			expirations = append(expirations, expiration)
+		}
+	}
+	m.expirations = expirations
+
+	adjustments := m.adjustments[:0]
+	for _, adjustment := range m.adjustments {
+		if adjustment.Login != username {
+			adjustments = append(adjustments, adjustment)
+		}
+	}
+	m.adjustments = adjustments
+}
+
+func rename(name, from, to string) string {
+	if name == from {
+		return to
+	}
+
+	return name
+}
+
+func (m *Memory) GetTwoFactor(username string) (TwoFactor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return TwoFactor{}, ErrUserNotFound
+	}
+
+	return user.twoFactor, nil
+}
+
+func (m *Memory) SetTwoFactor(username, secret string, recoveryHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if user.twoFactor.Enabled {
+		return ErrTwoFactorEnabled
+	}
+
+	user.twoFactor = TwoFactor{Secret: secret}
+	user.recovery = make(map[string]bool, len(recoveryHashes))
+	for _, hash := range recoveryHashes {
+		user.recovery[hash] = false
+	}
+
+	return nil
+}
+
+func (m *Memory) EnableTwoFactor(username string, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if user.twoFactor.Enabled {
+		return ErrTwoFactorEnabled
+	}
+
+	if user.twoFactor.Secret == "" {
+		return ErrTwoFactorDisabled
+	}
+
+	user.twoFactor.Enabled, user.twoFactor.Step = true, step
+	return nil
+}
+
+func (m *Memory) DisableTwoFactor(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	user.twoFactor, user.recovery = TwoFactor{}, nil
+	return nil
+}
+
+func (m *Memory) UseTOTPStep(username string, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok || !user.twoFactor.Enabled || user.twoFactor.Step >= step {
+		return ErrWrongTwoFactorCode
+	}
+
+	user.twoFactor.Step = step
+	return nil
+}
+
+func (m *Memory) UseRecoveryCode(username, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return ErrWrongTwoFactorCode
+	}
+
+	if used, ok := user.recovery[hash]; !ok || used {
+		return ErrWrongTwoFactorCode
+	}
+
+	user.recovery[hash] = true
+	return nil
+}
+
+func (m *Memory) GetTokenGeneration(username string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+
+	return user.generation, nil
+}
+
+func (m *Memory) ChangePassword(username, passwd string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+
+	user.password = passwd
+	user.generation++
+	return user.generation, nil
+}
+
+func (m *Memory) CreatePasswordReset(username, hash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[username]; !ok {
+		return ErrUserNotFound
+	}
+
+	resets := m.resets[:0]
+	for _, reset := range m.resets {
+		if reset.owner != username || reset.used {
+			resets = append(resets, reset)
+		}
+	}
+
+	m.resets = append(resets, &memoryReset{owner: username, hash: hash, expiresAt: expiresAt})
+	return nil
+}
+
+func (m *Memory) ResetPassword(hash, passwd string, now time.Time) (string, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, reset := range m.resets {
+		if reset.hash != hash || reset.used || !reset.expiresAt.After(now) {
+			continue
+		}
+
+		user, ok := m.users[reset.owner]
+		if !ok {
+			break
+		}
+
+		reset.used = true
+		user.password = passwd
+		user.generation++
+		return reset.owner, user.generation, nil
+	}
+
+	return "", 0, ErrBadResetToken
+}
+
+func (m *Memory) AddSecurityEvent(e schema.SecurityEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.ID = int64(len(m.events) + 1)
+	e.CreatedAt = time.Now()
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *Memory) GetSecurityEvents(f SecurityEventFilter, limit, offset int) ([]schema.SecurityEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	matched := make([]schema.SecurityEvent, 0)
+	for i := len(m.events) - 1; i >= 0; i-- {
+		e := m.events[i]
+		if (f.Login != "" && e.Login != f.Login) || (f.Event != "" && e.Event != f.Event) || (f.IP != "" && e.IP != f.IP) {
+			continue
+		}
+
+		if (f.Success != nil && e.Success != *f.Success) || (!f.From.IsZero() && e.CreatedAt.Before(f.From)) ||
+			(!f.To.IsZero() && !e.CreatedAt.Before(f.To)) {
+			continue
+		}
+
+		matched = append(matched, e)
+	}
+
+	start, end := pageBounds(len(matched), limit, offset)
+	return matched[start:end], nil
+}
+
+func (m *Memory) AddRiskActivity(username string, a RiskActivity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.activity = append(m.activity, memoryActivity{owner: username, date: time.Now(), RiskActivity: a})
+	return nil
+}
+
+func (m *Memory) GetRiskActivity(username string, since time.Time) (RiskActivity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sum RiskActivity
+	for _, a := range m.activity {
+		if a.owner != username || !a.date.After(since) {
+			continue
+		}
+
+		sum.Uploads += a.Uploads
+		sum.Invalid += a.Invalid
+		sum.Foreign += a.Foreign
+		sum.Withdrawals += a.Withdrawals
+		sum.Withdrawn += a.Withdrawn
+	}
+
+	return sum, nil
+}
+
+func (m *Memory) AddRiskFlag(flag schema.RiskFlag, since time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range m.flags {
+		if f.Login == flag.Login && f.Signal == flag.Signal && f.Action == flag.Action && f.CreatedAt.After(since) {
+			return false, nil
+		}
+	}
+
+	flag.ID = int64(len(m.flags) + 1)
+	flag.CreatedAt = time.Now()
+	m.flags = append(m.flags, flag)
+	return true, nil
+}
+
+func (m *Memory) GetRiskFlags(login string, limit, offset int) ([]schema.RiskFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	matched := make([]schema.RiskFlag, 0)
+	for i := len(m.flags) - 1; i >= 0; i-- {
+		if login == "" || m.flags[i].Login == login {
+			matched = append(matched, m.flags[i])
+		}
+	}
+
+	start, end := pageBounds(len(matched), limit, offset)
+	return matched[start:end], nil
+}
+
+func (m *Memory) GetThrottledUntil(username string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var until time.Time
+	for _, f := range m.flags {
+		if f.Login == username && f.Until != nil && f.Until.After(until) {
+			until = *f.Until
+		}
+	}
+
+	return until, nil
+}
+
+func (m *Memory) GetProcessedOrders(since time.Time) ([]ProcessedOrder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orders := make([]ProcessedOrder, 0)
+	for _, id := range m.orderIDs {
+		order := m.orders[id]
+		if order.status != "PROCESSED" || !order.date.After(since) {
+			continue
+		}
+
+		orders = append(orders, ProcessedOrder{
+			Number:     id,
+			Owner:      order.owner,
+			Accrual:    order.accrual,
+			Reported:   order.reported,
+			Multiplier: order.multiplier,
+		})
+	}
+
+	return orders, nil
+}
+
+func (m *Memory) SetReported(id string, reported float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if order, ok := m.orders[id]; ok {
+		order.reported = &reported
+	}
+
+	return nil
+}
+
+func (m *Memory) SetMultiplier(id string, multiplier float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if order, ok := m.orders[id]; ok {
+		order.multiplier = &multiplier
+	}
+
+	return nil
+}
+
+func (m *Memory) CorrectAccrual(username, id string, reported, sum float64, reason, admin string) (schema.Adjustment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	adjustment, err := m.adjust(username, sum, reason, admin)
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	if order, ok := m.orders[id]; ok {
+		order.reported = &reported
+	}
+
+	return adjustment, nil
+}
+
+func (m *Memory) AddReconciliation(r schema.Reconciliation) (schema.Reconciliation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r.ID = int64(len(m.reports) + 1)
+	r.CreatedAt = time.Now()
+	r.Discrepancies = append([]schema.Discrepancy{}, r.Discrepancies...)
+	m.reports = append(m.reports, r)
+	return r, nil
+}
+
+func (m *Memory) GetReconciliations(limit, offset int) ([]schema.Reconciliation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reports := make([]schema.Reconciliation, 0, len(m.reports))
+	for i := len(m.reports) - 1; i >= 0; i-- {
+		r := m.reports[i]
+		r.Discrepancies = nil
+		reports = append(reports, r)
+	}
+
+	start, end := pageBounds(len(reports), limit, offset)
+	return reports[start:end], nil
+}
+
+func (m *Memory) GetReconciliation(id int64) (schema.Reconciliation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 1 || id > int64(len(m.reports)) {
+		return schema.Reconciliation{}, ErrReconciliationNotFound
+	}
+
+	r := m.reports[id-1]
+	r.Discrepancies = append([]schema.Discrepancy{}, r.Discrepancies...)
+	return r, nil
+}
+
+func (m *Memory) CheckBalances(repair bool, admin string) (schema.BalanceCheck, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	aliases := make(map[string]bool, len(m.deletions))
+	for _, d := range m.deletions {
+		aliases[d.Alias] = true
+	}
+
+	expected := make(map[string]float64)
+	withdrawn := make(map[string]float64)
+	for _, order := range m.orders {
+		if order.status == "PROCESSED" {
+			expected[order.owner] += order.accrual
+		}
+	}
+
+	for _, c := range m.credits {
+		if c.source == CreditSourceReferral {
+			expected[c.owner] += c.amount
+		}
+	}
+
+	for _, a := range m.adjustments {
+		expected[a.Login] += a.Sum
+	}
+
+	for _, t := range m.transfers {
+		if t.Status == TransferCompleted {
+			expected[t.Recipient] += t.Sum
+		}
+
+		if t.Status != TransferDeclined {
+			expected[t.Sender] -= t.Sum
+		}
+	}
+
+	for _, w := range m.withdrawals {
+		expected[w.client] -= w.sum
+		withdrawn[w.client] += w.sum
+	}
+
+	for _, e := range m.expirations {
+		expected[e.owner] -= e.sum
+	}
+
+	logins := make([]string, 0, len(m.users))
+	for login := range m.users {
+		if !aliases[login] {
+			logins = append(logins, login)
+		}
+	}
+	sort.Strings(logins)
+
+	check := schema.BalanceCheck{Checked: len(logins), Repaired: repair, Mismatches: make([]schema.BalanceMismatch, 0)}
+	for _, login := range logins {
+		user := m.users[login]
+		mismatch := schema.BalanceMismatch{
+			Login:             login,
+			Balance:           user.balance,
+			Expected:          expected[login],
+			Withdrawn:         user.withdrawn,
+			ExpectedWithdrawn: withdrawn[login],
+		}
+		if !mismatched(mismatch) {
+			continue
+		}
+
+		check.Mismatches = append(check.Mismatches, mismatch)
+		if repair {
+			user.balance = mismatch.Expected
+			user.withdrawn = mismatch.ExpectedWithdrawn
+			m.addAdminAction(admin, "repair_balance", login, repairDetails(mismatch))
+		}
+	}
+
+	check.Mismatched = len(check.Mismatches)
+	return check, nil
+}
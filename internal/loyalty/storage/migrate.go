@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"os"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+var ErrSchemaDirty = errors.New("database schema is dirty")
+
+// Migrator applies the migrations embedded in the binary.
+type Migrator struct {
+	m      *migrate.Migrate
+	latest uint
+}
+
+// NewMigrator takes a connection of db for the migrations, Close releases it and leaves db open.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	src, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := latestVersion(src)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := postgres.WithConnection(context.Background(), conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "gomarket", driver)
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+
+	return &Migrator{m: m, latest: latest}, nil
+}
+
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		version = next
+	}
+}
+
+// Up applies all the migrations that are not applied yet.
+func (m *Migrator) Up() error {
+	err := m.m.Up()
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
+	return err
+}
+
+// Down rolls back the given number of the last applied migrations.
+func (m *Migrator) Down(steps int) error {
+	err := m.m.Steps(-steps)
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
+	return err
+}
+
+// Force sets the schema version without running migrations and clears the dirty flag.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// Status returns the applied and the latest embedded versions,
+// version is zero if no migrations are applied.
+func (m *Migrator) Status() (version, latest uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, m.latest, false, nil
+	}
+
+	return version, m.latest, dirty, err
+}
+
+// Check returns ErrSchemaBehind or ErrSchemaDirty unless the schema is at the latest version.
+func (m *Migrator) Check() error {
+	version, latest, dirty, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("%w at version %d, fix it and run migrate force", ErrSchemaDirty, version)
+	}
+
+	if version < latest {
+		return fmt.Errorf("%w: version %d, want %d, run migrate up", ErrSchemaBehind, version, latest)
+	}
+
+	return nil
+}
+
+func (m *Migrator) Close() error {
+	_, err := m.m.Close()
+	return err
+}
@@ -0,0 +1,2 @@
+DROP TABLE "Expirations";
+DROP TABLE "Credits";
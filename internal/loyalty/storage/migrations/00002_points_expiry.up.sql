@@ -0,0 +1,20 @@
+CREATE TABLE "Credits" (
+    "ID" SERIAL PRIMARY KEY,
+    "Owner" VARCHAR(255) NOT NULL REFERENCES "Users"("Name"),
+    "Source" VARCHAR(255) NOT NULL,
+    "Reference" VARCHAR(255) NOT NULL,
+    "Amount" DECIMAL NOT NULL,
+    "Remaining" DECIMAL NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE INDEX "Credits_Owner_Date" ON "Credits" ("Owner", "Date");
+CREATE TABLE "Expirations" (
+    "Owner" VARCHAR(255) NOT NULL REFERENCES "Users"("Name"),
+    "Credit" INTEGER NOT NULL REFERENCES "Credits"("ID"),
+    "Sum" DECIMAL NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+INSERT INTO "Credits" ("Owner", "Source", "Reference", "Amount", "Remaining", "Date")
+SELECT "Name", 'migration', '', "Balance", "Balance", now()::timestamp
+FROM "Users"
+WHERE "Balance" > 0;
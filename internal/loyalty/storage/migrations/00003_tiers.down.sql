@@ -0,0 +1,2 @@
+DROP TABLE "TierHistory";
+ALTER TABLE "Users" DROP COLUMN "Tier";
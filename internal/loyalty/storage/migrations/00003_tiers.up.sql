@@ -0,0 +1,8 @@
+ALTER TABLE "Users" ADD COLUMN "Tier" VARCHAR(255) NOT NULL DEFAULT '';
+CREATE TABLE "TierHistory" (
+    "Owner" VARCHAR(255) NOT NULL REFERENCES "Users"("Name"),
+    "Tier" VARCHAR(255) NOT NULL,
+    "Previous" VARCHAR(255) NOT NULL,
+    "Accrued" DECIMAL NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TABLE "TransferParts";
+DROP TABLE "Transfers";
@@ -0,0 +1,18 @@
+CREATE TABLE "Transfers" (
+    "ID" SERIAL PRIMARY KEY,
+    "Sender" VARCHAR(255) NOT NULL REFERENCES "Users"("Name"),
+    "Recipient" VARCHAR(255) NOT NULL REFERENCES "Users"("Name"),
+    "Sum" DECIMAL NOT NULL,
+    "Status" VARCHAR(255) CHECK (
+        "Status" IN ('PENDING', 'COMPLETED', 'DECLINED')
+    ),
+    "Date" TIMESTAMP NOT NULL,
+    "CompletedAt" TIMESTAMP
+);
+CREATE INDEX "Transfers_Sender" ON "Transfers" ("Sender", "Date");
+CREATE INDEX "Transfers_Recipient" ON "Transfers" ("Recipient", "Date");
+CREATE TABLE "TransferParts" (
+    "Transfer" INTEGER NOT NULL REFERENCES "Transfers"("ID"),
+    "Sum" DECIMAL NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
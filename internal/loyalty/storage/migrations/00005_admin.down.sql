@@ -0,0 +1,3 @@
+DROP TABLE "AdminActions";
+DROP TABLE "Adjustments";
+ALTER TABLE "Users" DROP COLUMN "Blocked";
@@ -0,0 +1,18 @@
+ALTER TABLE "Users" ADD COLUMN "Blocked" BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE "Adjustments" (
+    "ID" SERIAL PRIMARY KEY,
+    "Owner" VARCHAR(255) NOT NULL REFERENCES "Users"("Name"),
+    "Sum" DECIMAL NOT NULL,
+    "Reason" TEXT NOT NULL,
+    "Admin" VARCHAR(255) NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE TABLE "AdminActions" (
+    "ID" SERIAL PRIMARY KEY,
+    "Admin" VARCHAR(255) NOT NULL,
+    "Action" VARCHAR(255) NOT NULL,
+    "Target" VARCHAR(255) NOT NULL,
+    "Details" TEXT NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE INDEX "AdminActions_Target" ON "AdminActions" ("Target", "Date");
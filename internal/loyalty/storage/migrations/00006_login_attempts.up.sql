@@ -0,0 +1,5 @@
+CREATE TABLE "LoginAttempts" (
+    "Key" VARCHAR(300) PRIMARY KEY,
+    "Failures" INT NOT NULL,
+    "Last" TIMESTAMP NOT NULL
+);
@@ -0,0 +1,44 @@
+DELETE FROM "TransferParts" WHERE "Transfer" IN (SELECT "ID" FROM "Transfers" WHERE "Program" <> 'default');
+DELETE FROM "Transfers" WHERE "Program" <> 'default';
+DELETE FROM "Expirations" WHERE "Program" <> 'default';
+DELETE FROM "Credits" WHERE "Program" <> 'default';
+DELETE FROM "TierHistory" WHERE "Program" <> 'default';
+DELETE FROM "Adjustments" WHERE "Program" <> 'default';
+DELETE FROM "AdminActions" WHERE "Program" <> 'default';
+DELETE FROM Withdrawals WHERE "Program" <> 'default';
+DELETE FROM "Orders" WHERE "Program" <> 'default';
+DELETE FROM "Users" WHERE "Program" <> 'default';
+
+DROP INDEX "Credits_Owner_Date";
+CREATE INDEX "Credits_Owner_Date" ON "Credits" ("Owner", "Date");
+DROP INDEX "Transfers_Sender";
+CREATE INDEX "Transfers_Sender" ON "Transfers" ("Sender", "Date");
+DROP INDEX "Transfers_Recipient";
+CREATE INDEX "Transfers_Recipient" ON "Transfers" ("Recipient", "Date");
+DROP INDEX "AdminActions_Target";
+CREATE INDEX "AdminActions_Target" ON "AdminActions" ("Target", "Date");
+
+-- CASCADE drops the foreign keys referencing "Users"("Program", "Name")
+ALTER TABLE "Users" DROP CONSTRAINT "Users_pkey" CASCADE;
+ALTER TABLE "Users" ADD PRIMARY KEY ("Name");
+ALTER TABLE "Orders" DROP CONSTRAINT "Orders_pkey";
+ALTER TABLE "Orders" ADD PRIMARY KEY ("UID");
+
+ALTER TABLE "Users" DROP COLUMN "Program";
+ALTER TABLE "Orders" DROP COLUMN "Program";
+ALTER TABLE Withdrawals DROP COLUMN "Program";
+ALTER TABLE "Credits" DROP COLUMN "Program";
+ALTER TABLE "Expirations" DROP COLUMN "Program";
+ALTER TABLE "TierHistory" DROP COLUMN "Program";
+ALTER TABLE "Transfers" DROP COLUMN "Program";
+ALTER TABLE "Adjustments" DROP COLUMN "Program";
+ALTER TABLE "AdminActions" DROP COLUMN "Program";
+
+ALTER TABLE "Orders" ADD FOREIGN KEY ("Owner") REFERENCES "Users"("Name");
+ALTER TABLE Withdrawals ADD FOREIGN KEY ("Client") REFERENCES "Users"("Name");
+ALTER TABLE "Credits" ADD FOREIGN KEY ("Owner") REFERENCES "Users"("Name");
+ALTER TABLE "Expirations" ADD FOREIGN KEY ("Owner") REFERENCES "Users"("Name");
+ALTER TABLE "TierHistory" ADD FOREIGN KEY ("Owner") REFERENCES "Users"("Name");
+ALTER TABLE "Transfers" ADD FOREIGN KEY ("Sender") REFERENCES "Users"("Name");
+ALTER TABLE "Transfers" ADD FOREIGN KEY ("Recipient") REFERENCES "Users"("Name");
+ALTER TABLE "Adjustments" ADD FOREIGN KEY ("Owner") REFERENCES "Users"("Name");
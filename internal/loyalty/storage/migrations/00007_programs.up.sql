@@ -0,0 +1,33 @@
+ALTER TABLE "Users" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE "Orders" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE Withdrawals ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE "Credits" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE "Expirations" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE "TierHistory" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE "Transfers" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE "Adjustments" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE "AdminActions" ADD COLUMN "Program" VARCHAR(255) NOT NULL DEFAULT 'default';
+
+-- CASCADE drops the foreign keys referencing "Users"("Name")
+ALTER TABLE "Users" DROP CONSTRAINT "Users_pkey" CASCADE;
+ALTER TABLE "Users" ADD PRIMARY KEY ("Program", "Name");
+ALTER TABLE "Orders" DROP CONSTRAINT "Orders_pkey";
+ALTER TABLE "Orders" ADD PRIMARY KEY ("Program", "UID");
+
+ALTER TABLE "Orders" ADD FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name");
+ALTER TABLE Withdrawals ADD FOREIGN KEY ("Program", "Client") REFERENCES "Users"("Program", "Name");
+ALTER TABLE "Credits" ADD FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name");
+ALTER TABLE "Expirations" ADD FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name");
+ALTER TABLE "TierHistory" ADD FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name");
+ALTER TABLE "Transfers" ADD FOREIGN KEY ("Program", "Sender") REFERENCES "Users"("Program", "Name");
+ALTER TABLE "Transfers" ADD FOREIGN KEY ("Program", "Recipient") REFERENCES "Users"("Program", "Name");
+ALTER TABLE "Adjustments" ADD FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name");
+
+DROP INDEX "Credits_Owner_Date";
+CREATE INDEX "Credits_Owner_Date" ON "Credits" ("Program", "Owner", "Date");
+DROP INDEX "Transfers_Sender";
+CREATE INDEX "Transfers_Sender" ON "Transfers" ("Program", "Sender", "Date");
+DROP INDEX "Transfers_Recipient";
+CREATE INDEX "Transfers_Recipient" ON "Transfers" ("Program", "Recipient", "Date");
+DROP INDEX "AdminActions_Target";
+CREATE INDEX "AdminActions_Target" ON "AdminActions" ("Program", "Target", "Date");
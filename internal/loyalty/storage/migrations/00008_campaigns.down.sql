@@ -0,0 +1,2 @@
+DROP TABLE "OrderCampaigns";
+DROP TABLE "Campaigns";
@@ -0,0 +1,20 @@
+CREATE TABLE "Campaigns" (
+    "ID" SERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Name" VARCHAR(255) NOT NULL,
+    "Multiplier" DECIMAL NOT NULL,
+    "Bonus" DECIMAL NOT NULL,
+    "FirstOrders" INTEGER NOT NULL,
+    "StartsAt" TIMESTAMP NOT NULL,
+    "EndsAt" TIMESTAMP,
+    "Disabled" BOOLEAN NOT NULL DEFAULT FALSE,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE TABLE "OrderCampaigns" (
+    "Program" VARCHAR(255) NOT NULL,
+    "Order" VARCHAR(255) NOT NULL,
+    "Campaign" INTEGER NOT NULL REFERENCES "Campaigns"("ID"),
+    "Bonus" DECIMAL NOT NULL,
+    FOREIGN KEY ("Program", "Order") REFERENCES "Orders"("Program", "UID")
+);
+CREATE INDEX "OrderCampaigns_Order" ON "OrderCampaigns" ("Program", "Order");
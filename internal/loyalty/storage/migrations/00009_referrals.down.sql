@@ -0,0 +1,3 @@
+DROP TABLE "Referrals";
+DROP INDEX "Users_ReferralCode";
+ALTER TABLE "Users" DROP COLUMN "ReferralCode";
@@ -0,0 +1,20 @@
+ALTER TABLE "Users" ADD COLUMN "ReferralCode" VARCHAR(32);
+UPDATE "Users" SET "ReferralCode" = upper(substr(md5(random()::text || "Program" || "Name"), 1, 10));
+ALTER TABLE "Users" ALTER COLUMN "ReferralCode" SET NOT NULL;
+CREATE UNIQUE INDEX "Users_ReferralCode" ON "Users" ("Program", "ReferralCode");
+CREATE TABLE "Referrals" (
+    "ID" SERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Referrer" VARCHAR(255) NOT NULL,
+    "Referred" VARCHAR(255) NOT NULL,
+    "Status" VARCHAR(255) CHECK (
+        "Status" IN ('PENDING', 'REWARDED', 'REJECTED')
+    ),
+    "Bonus" DECIMAL NOT NULL,
+    "Date" TIMESTAMP NOT NULL,
+    "CompletedAt" TIMESTAMP,
+    UNIQUE ("Program", "Referred"),
+    FOREIGN KEY ("Program", "Referrer") REFERENCES "Users"("Program", "Name"),
+    FOREIGN KEY ("Program", "Referred") REFERENCES "Users"("Program", "Name")
+);
+CREATE INDEX "Referrals_Referrer" ON "Referrals" ("Program", "Referrer", "Date");
@@ -0,0 +1,2 @@
+DROP TABLE "Deletions";
+DROP TABLE "Sessions";
@@ -0,0 +1,23 @@
+CREATE TABLE "Sessions" (
+    "ID" SERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Owner" VARCHAR(255) NOT NULL,
+    "IP" VARCHAR(255) NOT NULL,
+    "UserAgent" TEXT NOT NULL,
+    "Date" TIMESTAMP NOT NULL,
+    FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name")
+);
+CREATE INDEX "Sessions_Owner" ON "Sessions" ("Program", "Owner", "Date");
+-- Deletions outlive the users, so they keep a hash of the login instead of the login itself
+CREATE TABLE "Deletions" (
+    "ID" SERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "UserHash" VARCHAR(64) NOT NULL,
+    "Alias" VARCHAR(255) NOT NULL,
+    "Mode" VARCHAR(255) CHECK (
+        "Mode" IN ('anonymize', 'delete')
+    ),
+    "Forfeited" DECIMAL NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE INDEX "Deletions_UserHash" ON "Deletions" ("Program", "UserHash");
@@ -0,0 +1,4 @@
+DROP TABLE "RecoveryCodes";
+ALTER TABLE "Users" DROP COLUMN "TOTPStep";
+ALTER TABLE "Users" DROP COLUMN "TOTPEnabled";
+ALTER TABLE "Users" DROP COLUMN "TOTPSecret";
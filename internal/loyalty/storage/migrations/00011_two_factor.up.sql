@@ -0,0 +1,14 @@
+-- the secret is pending until the user confirms a code from the authenticator,
+-- "TOTPStep" is the last accepted period, so a code can't be used twice
+ALTER TABLE "Users" ADD COLUMN "TOTPSecret" VARCHAR(255);
+ALTER TABLE "Users" ADD COLUMN "TOTPEnabled" BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE "Users" ADD COLUMN "TOTPStep" BIGINT NOT NULL DEFAULT 0;
+CREATE TABLE "RecoveryCodes" (
+    "ID" SERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Owner" VARCHAR(255) NOT NULL,
+    "Hash" VARCHAR(64) NOT NULL,
+    "UsedAt" TIMESTAMP,
+    FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name")
+);
+CREATE INDEX "RecoveryCodes_Owner" ON "RecoveryCodes" ("Program", "Owner");
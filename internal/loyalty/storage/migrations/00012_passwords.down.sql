@@ -0,0 +1,2 @@
+DROP TABLE "PasswordResets";
+ALTER TABLE "Users" DROP COLUMN "TokenGeneration";
@@ -0,0 +1,14 @@
+-- changing the password moves the user to the next token generation, the tokens of the previous ones are rejected
+ALTER TABLE "Users" ADD COLUMN "TokenGeneration" BIGINT NOT NULL DEFAULT 0;
+-- the reset tokens are only stored hashed
+CREATE TABLE "PasswordResets" (
+    "ID" SERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Owner" VARCHAR(255) NOT NULL,
+    "Hash" VARCHAR(64) NOT NULL,
+    "Date" TIMESTAMP NOT NULL,
+    "ExpiresAt" TIMESTAMP NOT NULL,
+    "UsedAt" TIMESTAMP,
+    FOREIGN KEY ("Program", "Owner") REFERENCES "Users"("Program", "Name")
+);
+CREATE UNIQUE INDEX "PasswordResets_Hash" ON "PasswordResets" ("Program", "Hash");
@@ -0,0 +1,2 @@
+DROP TABLE "SecurityEvents";
+DROP FUNCTION "SecurityEvents_AppendOnly";
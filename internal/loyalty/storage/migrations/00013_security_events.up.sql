@@ -0,0 +1,22 @@
+-- the security events are append-only, they outlive the accounts and can't be changed
+CREATE TABLE "SecurityEvents" (
+    "ID" BIGSERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Event" VARCHAR(64) NOT NULL,
+    "Login" VARCHAR(255) NOT NULL,
+    "Success" BOOLEAN NOT NULL,
+    "IP" VARCHAR(64) NOT NULL,
+    "UserAgent" TEXT NOT NULL,
+    "RequestID" VARCHAR(255) NOT NULL,
+    "Details" TEXT NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE INDEX "SecurityEvents_Login" ON "SecurityEvents" ("Program", "Login", "Date");
+CREATE INDEX "SecurityEvents_Date" ON "SecurityEvents" ("Program", "Date");
+CREATE FUNCTION "SecurityEvents_AppendOnly"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'security events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER "SecurityEvents_AppendOnly" BEFORE UPDATE OR DELETE OR TRUNCATE ON "SecurityEvents"
+    FOR EACH STATEMENT EXECUTE FUNCTION "SecurityEvents_AppendOnly"();
@@ -0,0 +1,2 @@
+DROP TABLE "RiskFlags";
+DROP TABLE "RiskActivity";
@@ -0,0 +1,26 @@
+-- every upload and withdrawal adds a row with its counts, the rules sum them over the window
+CREATE TABLE "RiskActivity" (
+    "ID" BIGSERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Owner" VARCHAR(255) NOT NULL,
+    "Uploads" INTEGER NOT NULL,
+    "Invalid" INTEGER NOT NULL,
+    "Foreign" INTEGER NOT NULL,
+    "Withdrawals" INTEGER NOT NULL,
+    "Withdrawn" DECIMAL NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE INDEX "RiskActivity_Owner" ON "RiskActivity" ("Program", "Owner", "Date");
+CREATE TABLE "RiskFlags" (
+    "ID" BIGSERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Owner" VARCHAR(255) NOT NULL,
+    "Signal" VARCHAR(64) NOT NULL,
+    "Value" DECIMAL NOT NULL,
+    "Threshold" DECIMAL NOT NULL,
+    "Action" VARCHAR(64) NOT NULL,
+    "Until" TIMESTAMP,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE INDEX "RiskFlags_Owner" ON "RiskFlags" ("Program", "Owner", "Date");
+CREATE INDEX "RiskFlags_Date" ON "RiskFlags" ("Program", "Date");
@@ -0,0 +1,3 @@
+DROP TABLE "Discrepancies";
+DROP TABLE "Reconciliations";
+ALTER TABLE "Orders" DROP COLUMN "Reported";
@@ -0,0 +1,30 @@
+-- the accrual the accrual system reported for the order before the rate and the multipliers,
+-- NULL for the orders processed before the column was added
+ALTER TABLE "Orders" ADD COLUMN "Reported" DECIMAL;
+CREATE TABLE "Reconciliations" (
+    "ID" BIGSERIAL PRIMARY KEY,
+    "Program" VARCHAR(255) NOT NULL,
+    "Since" TIMESTAMP NOT NULL,
+    "Checked" INTEGER NOT NULL,
+    "Failed" INTEGER NOT NULL,
+    "Mismatched" INTEGER NOT NULL,
+    "Applied" BOOLEAN NOT NULL,
+    "Admin" VARCHAR(255) NOT NULL,
+    "Date" TIMESTAMP NOT NULL
+);
+CREATE INDEX "Reconciliations_Date" ON "Reconciliations" ("Program", "Date");
+CREATE TABLE "Discrepancies" (
+    "ID" BIGSERIAL PRIMARY KEY,
+    "Reconciliation" BIGINT NOT NULL REFERENCES "Reconciliations"("ID"),
+    "Number" VARCHAR(255) NOT NULL,
+    "Owner" VARCHAR(255) NOT NULL,
+    "Accrual" DECIMAL NOT NULL,
+    "Reported" DECIMAL,
+    "Status" VARCHAR(255) NOT NULL,
+    "Fresh" DECIMAL NOT NULL,
+    "Correction" DECIMAL NOT NULL,
+    "Adjustment" BIGINT,
+    "Error" TEXT NOT NULL,
+    "Program" VARCHAR(255) NOT NULL
+);
+CREATE INDEX "Discrepancies_Reconciliation" ON "Discrepancies" ("Reconciliation");
@@ -0,0 +1 @@
+ALTER TABLE "Deletions" DROP COLUMN "TokenGeneration";
@@ -0,0 +1,3 @@
+-- the last token generation of the deleted user, a login registered again starts above it,
+-- so the tokens of the deleted account don't sign in the new one
+ALTER TABLE "Deletions" ADD COLUMN "TokenGeneration" BIGINT NOT NULL DEFAULT 0;
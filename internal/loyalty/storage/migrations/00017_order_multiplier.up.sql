@@ -0,0 +1,3 @@
+-- the tier multiplier applied to the accrual of the order, the reconciliation corrects the order by it,
+-- NULL for the orders processed before the column was added
+ALTER TABLE "Orders" ADD COLUMN "Multiplier" DECIMAL;
@@ -8,6 +8,7 @@ import (
 	schema "gomarket/internal/loyalty/schema"
 	storage "gomarket/internal/loyalty/storage"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,6 +36,167 @@ func (m *MockIStorage) EXPECT() *MockIStorageMockRecorder {
 	return m.recorder
 }
 
+// AddAdminAction mocks base method.
+func (m *MockIStorage) AddAdminAction(action schema.AdminAction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAdminAction", action)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAdminAction indicates an expected call of AddAdminAction.
+func (mr *MockIStorageMockRecorder) AddAdminAction(action interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAdminAction", reflect.TypeOf((*MockIStorage)(nil).AddAdminAction), action)
+}
+
+// AddOrders mocks base method.
+func (m *MockIStorage) AddOrders(username string, ids []string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrders", username, ids)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddOrders indicates an expected call of AddOrders.
+func (mr *MockIStorageMockRecorder) AddOrders(username, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrders", reflect.TypeOf((*MockIStorage)(nil).AddOrders), username, ids)
+}
+
+// AddReconciliation mocks base method.
+func (m *MockIStorage) AddReconciliation(r schema.Reconciliation) (schema.Reconciliation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReconciliation", r)
+	ret0, _ := ret[0].(schema.Reconciliation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddReconciliation indicates an expected call of AddReconciliation.
+func (mr *MockIStorageMockRecorder) AddReconciliation(r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReconciliation", reflect.TypeOf((*MockIStorage)(nil).AddReconciliation), r)
+}
+
+// AddRiskActivity mocks base method.
+func (m *MockIStorage) AddRiskActivity(username string, a storage.RiskActivity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRiskActivity", username, a)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRiskActivity indicates an expected call of AddRiskActivity.
+func (mr *MockIStorageMockRecorder) AddRiskActivity(username, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRiskActivity", reflect.TypeOf((*MockIStorage)(nil).AddRiskActivity), username, a)
+}
+
+// AddRiskFlag mocks base method.
+func (m *MockIStorage) AddRiskFlag(flag schema.RiskFlag, since time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRiskFlag", flag, since)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddRiskFlag indicates an expected call of AddRiskFlag.
+func (mr *MockIStorageMockRecorder) AddRiskFlag(flag, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRiskFlag", reflect.TypeOf((*MockIStorage)(nil).AddRiskFlag), flag, since)
+}
+
+// AddSecurityEvent mocks base method.
+func (m *MockIStorage) AddSecurityEvent(e schema.SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSecurityEvent", e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddSecurityEvent indicates an expected call of AddSecurityEvent.
+func (mr *MockIStorageMockRecorder) AddSecurityEvent(e interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSecurityEvent", reflect.TypeOf((*MockIStorage)(nil).AddSecurityEvent), e)
+}
+
+// AddSession mocks base method.
+func (m *MockIStorage) AddSession(username string, session schema.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSession", username, session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddSession indicates an expected call of AddSession.
+func (mr *MockIStorageMockRecorder) AddSession(username, session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSession", reflect.TypeOf((*MockIStorage)(nil).AddSession), username, session)
+}
+
+// Adjust mocks base method.
+func (m *MockIStorage) Adjust(username string, sum float64, reason, admin string) (schema.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Adjust", username, sum, reason, admin)
+	ret0, _ := ret[0].(schema.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Adjust indicates an expected call of Adjust.
+func (mr *MockIStorageMockRecorder) Adjust(username, sum, reason, admin interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Adjust", reflect.TypeOf((*MockIStorage)(nil).Adjust), username, sum, reason, admin)
+}
+
+// ChangePassword mocks base method.
+func (m *MockIStorage) ChangePassword(username, passwd string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", username, passwd)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockIStorageMockRecorder) ChangePassword(username, passwd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockIStorage)(nil).ChangePassword), username, passwd)
+}
+
+// ChangeTier mocks base method.
+func (m *MockIStorage) ChangeTier(username, tier string, accrued float64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeTier", username, tier, accrued)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeTier indicates an expected call of ChangeTier.
+func (mr *MockIStorageMockRecorder) ChangeTier(username, tier, accrued interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeTier", reflect.TypeOf((*MockIStorage)(nil).ChangeTier), username, tier, accrued)
+}
+
+// CheckBalances mocks base method.
+func (m *MockIStorage) CheckBalances(repair bool, admin string) (schema.BalanceCheck, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckBalances", repair, admin)
+	ret0, _ := ret[0].(schema.BalanceCheck)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckBalances indicates an expected call of CheckBalances.
+func (mr *MockIStorageMockRecorder) CheckBalances(repair, admin interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckBalances", reflect.TypeOf((*MockIStorage)(nil).CheckBalances), repair, admin)
+}
+
 // CheckID mocks base method.
 func (m *MockIStorage) CheckID(username, id string) error {
 	m.ctrl.T.Helper()
@@ -63,6 +225,94 @@ func (mr *MockIStorageMockRecorder) CheckPassword(login, passwd interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPassword", reflect.TypeOf((*MockIStorage)(nil).CheckPassword), login, passwd)
 }
 
+// CompleteTransfer mocks base method.
+func (m *MockIStorage) CompleteTransfer(id int64, username string, accept bool) (schema.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteTransfer", id, username, accept)
+	ret0, _ := ret[0].(schema.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteTransfer indicates an expected call of CompleteTransfer.
+func (mr *MockIStorageMockRecorder) CompleteTransfer(id, username, accept interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteTransfer", reflect.TypeOf((*MockIStorage)(nil).CompleteTransfer), id, username, accept)
+}
+
+// CorrectAccrual mocks base method.
+func (m *MockIStorage) CorrectAccrual(username, id string, reported, sum float64, reason, admin string) (schema.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CorrectAccrual", username, id, reported, sum, reason, admin)
+	ret0, _ := ret[0].(schema.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CorrectAccrual indicates an expected call of CorrectAccrual.
+func (mr *MockIStorageMockRecorder) CorrectAccrual(username, id, reported, sum, reason, admin interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CorrectAccrual", reflect.TypeOf((*MockIStorage)(nil).CorrectAccrual), username, id, reported, sum, reason, admin)
+}
+
+// CreateCampaign mocks base method.
+func (m *MockIStorage) CreateCampaign(c schema.Campaign, admin string) (schema.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCampaign", c, admin)
+	ret0, _ := ret[0].(schema.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCampaign indicates an expected call of CreateCampaign.
+func (mr *MockIStorageMockRecorder) CreateCampaign(c, admin interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCampaign", reflect.TypeOf((*MockIStorage)(nil).CreateCampaign), c, admin)
+}
+
+// CreatePasswordReset mocks base method.
+func (m *MockIStorage) CreatePasswordReset(username, hash string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordReset", username, hash, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockIStorageMockRecorder) CreatePasswordReset(username, hash, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockIStorage)(nil).CreatePasswordReset), username, hash, expiresAt)
+}
+
+// CreateReferredUser mocks base method.
+func (m *MockIStorage) CreateReferredUser(login, passwd, code string, limit storage.ReferralLimit) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateReferredUser", login, passwd, code, limit)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateReferredUser indicates an expected call of CreateReferredUser.
+func (mr *MockIStorageMockRecorder) CreateReferredUser(login, passwd, code, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateReferredUser", reflect.TypeOf((*MockIStorage)(nil).CreateReferredUser), login, passwd, code, limit)
+}
+
+// CreateTransfer mocks base method.
+func (m *MockIStorage) CreateTransfer(sender, recipient string, sum float64, pending bool, limit storage.TransferLimit) (schema.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransfer", sender, recipient, sum, pending, limit)
+	ret0, _ := ret[0].(schema.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTransfer indicates an expected call of CreateTransfer.
+func (mr *MockIStorageMockRecorder) CreateTransfer(sender, recipient, sum, pending, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockIStorage)(nil).CreateTransfer), sender, recipient, sum, pending, limit)
+}
+
 // CreateUser mocks base method.
 func (m *MockIStorage) CreateUser(login, passwd string) error {
 	m.ctrl.T.Helper()
@@ -77,6 +327,138 @@ func (mr *MockIStorageMockRecorder) CreateUser(login, passwd interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockIStorage)(nil).CreateUser), login, passwd)
 }
 
+// DeleteUser mocks base method.
+func (m *MockIStorage) DeleteUser(username string, policy storage.DeletionPolicy) (schema.Deletion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", username, policy)
+	ret0, _ := ret[0].(schema.Deletion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockIStorageMockRecorder) DeleteUser(username, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockIStorage)(nil).DeleteUser), username, policy)
+}
+
+// DisableTwoFactor mocks base method.
+func (m *MockIStorage) DisableTwoFactor(username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTwoFactor", username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTwoFactor indicates an expected call of DisableTwoFactor.
+func (mr *MockIStorageMockRecorder) DisableTwoFactor(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTwoFactor", reflect.TypeOf((*MockIStorage)(nil).DisableTwoFactor), username)
+}
+
+// EnableTwoFactor mocks base method.
+func (m *MockIStorage) EnableTwoFactor(username string, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTwoFactor", username, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTwoFactor indicates an expected call of EnableTwoFactor.
+func (mr *MockIStorageMockRecorder) EnableTwoFactor(username, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTwoFactor", reflect.TypeOf((*MockIStorage)(nil).EnableTwoFactor), username, step)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockIStorage) ExpirePoints(before time.Time) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", before)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockIStorageMockRecorder) ExpirePoints(before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockIStorage)(nil).ExpirePoints), before)
+}
+
+// Export mocks base method.
+func (m *MockIStorage) Export(username string) (schema.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", username)
+	ret0, _ := ret[0].(schema.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockIStorageMockRecorder) Export(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockIStorage)(nil).Export), username)
+}
+
+// FindUsers mocks base method.
+func (m *MockIStorage) FindUsers(query string, limit, offset int) ([]schema.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUsers", query, limit, offset)
+	ret0, _ := ret[0].([]schema.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUsers indicates an expected call of FindUsers.
+func (mr *MockIStorageMockRecorder) FindUsers(query, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUsers", reflect.TypeOf((*MockIStorage)(nil).FindUsers), query, limit, offset)
+}
+
+// ForProgram mocks base method.
+func (m *MockIStorage) ForProgram(id string) storage.IStorage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForProgram", id)
+	ret0, _ := ret[0].(storage.IStorage)
+	return ret0
+}
+
+// ForProgram indicates an expected call of ForProgram.
+func (mr *MockIStorageMockRecorder) ForProgram(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForProgram", reflect.TypeOf((*MockIStorage)(nil).ForProgram), id)
+}
+
+// GetAccrued mocks base method.
+func (m *MockIStorage) GetAccrued(username string, since time.Time) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccrued", username, since)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccrued indicates an expected call of GetAccrued.
+func (mr *MockIStorageMockRecorder) GetAccrued(username, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccrued", reflect.TypeOf((*MockIStorage)(nil).GetAccrued), username, since)
+}
+
+// GetAdminActions mocks base method.
+func (m *MockIStorage) GetAdminActions(target string, limit, offset int) ([]schema.AdminAction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAdminActions", target, limit, offset)
+	ret0, _ := ret[0].([]schema.AdminAction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAdminActions indicates an expected call of GetAdminActions.
+func (mr *MockIStorageMockRecorder) GetAdminActions(target, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAdminActions", reflect.TypeOf((*MockIStorage)(nil).GetAdminActions), target, limit, offset)
+}
+
 // GetBalance mocks base method.
 func (m *MockIStorage) GetBalance(username string) (schema.Balance, error) {
 	m.ctrl.T.Helper()
@@ -92,6 +474,66 @@ func (mr *MockIStorageMockRecorder) GetBalance(username interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockIStorage)(nil).GetBalance), username)
 }
 
+// GetCampaigns mocks base method.
+func (m *MockIStorage) GetCampaigns() ([]schema.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCampaigns")
+	ret0, _ := ret[0].([]schema.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCampaigns indicates an expected call of GetCampaigns.
+func (mr *MockIStorageMockRecorder) GetCampaigns() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaigns", reflect.TypeOf((*MockIStorage)(nil).GetCampaigns))
+}
+
+// GetExpiringCredits mocks base method.
+func (m *MockIStorage) GetExpiringCredits(username string, before time.Time) ([]schema.Credit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpiringCredits", username, before)
+	ret0, _ := ret[0].([]schema.Credit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpiringCredits indicates an expected call of GetExpiringCredits.
+func (mr *MockIStorageMockRecorder) GetExpiringCredits(username, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpiringCredits", reflect.TypeOf((*MockIStorage)(nil).GetExpiringCredits), username, before)
+}
+
+// GetFreshPoints mocks base method.
+func (m *MockIStorage) GetFreshPoints(username string, since time.Time) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFreshPoints", username, since)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFreshPoints indicates an expected call of GetFreshPoints.
+func (mr *MockIStorageMockRecorder) GetFreshPoints(username, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFreshPoints", reflect.TypeOf((*MockIStorage)(nil).GetFreshPoints), username, since)
+}
+
+// GetOpeningBalance mocks base method.
+func (m *MockIStorage) GetOpeningBalance(username string, before time.Time) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOpeningBalance", username, before)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOpeningBalance indicates an expected call of GetOpeningBalance.
+func (mr *MockIStorageMockRecorder) GetOpeningBalance(username, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpeningBalance", reflect.TypeOf((*MockIStorage)(nil).GetOpeningBalance), username, before)
+}
+
 // GetOrders mocks base method.
 func (m *MockIStorage) GetOrders(username string) (storage.Orders, error) {
 	m.ctrl.T.Helper()
@@ -107,6 +549,200 @@ func (mr *MockIStorageMockRecorder) GetOrders(username interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockIStorage)(nil).GetOrders), username)
 }
 
+// GetProcessedOrders mocks base method.
+func (m *MockIStorage) GetProcessedOrders(since time.Time) ([]storage.ProcessedOrder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProcessedOrders", since)
+	ret0, _ := ret[0].([]storage.ProcessedOrder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProcessedOrders indicates an expected call of GetProcessedOrders.
+func (mr *MockIStorageMockRecorder) GetProcessedOrders(since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProcessedOrders", reflect.TypeOf((*MockIStorage)(nil).GetProcessedOrders), since)
+}
+
+// GetReconciliation mocks base method.
+func (m *MockIStorage) GetReconciliation(id int64) (schema.Reconciliation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReconciliation", id)
+	ret0, _ := ret[0].(schema.Reconciliation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReconciliation indicates an expected call of GetReconciliation.
+func (mr *MockIStorageMockRecorder) GetReconciliation(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliation", reflect.TypeOf((*MockIStorage)(nil).GetReconciliation), id)
+}
+
+// GetReconciliations mocks base method.
+func (m *MockIStorage) GetReconciliations(limit, offset int) ([]schema.Reconciliation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReconciliations", limit, offset)
+	ret0, _ := ret[0].([]schema.Reconciliation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReconciliations indicates an expected call of GetReconciliations.
+func (mr *MockIStorageMockRecorder) GetReconciliations(limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliations", reflect.TypeOf((*MockIStorage)(nil).GetReconciliations), limit, offset)
+}
+
+// GetReferrals mocks base method.
+func (m *MockIStorage) GetReferrals(username string) (schema.Referrals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrals", username)
+	ret0, _ := ret[0].(schema.Referrals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrals indicates an expected call of GetReferrals.
+func (mr *MockIStorageMockRecorder) GetReferrals(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrals", reflect.TypeOf((*MockIStorage)(nil).GetReferrals), username)
+}
+
+// GetRiskActivity mocks base method.
+func (m *MockIStorage) GetRiskActivity(username string, since time.Time) (storage.RiskActivity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRiskActivity", username, since)
+	ret0, _ := ret[0].(storage.RiskActivity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRiskActivity indicates an expected call of GetRiskActivity.
+func (mr *MockIStorageMockRecorder) GetRiskActivity(username, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRiskActivity", reflect.TypeOf((*MockIStorage)(nil).GetRiskActivity), username, since)
+}
+
+// GetRiskFlags mocks base method.
+func (m *MockIStorage) GetRiskFlags(login string, limit, offset int) ([]schema.RiskFlag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRiskFlags", login, limit, offset)
+	ret0, _ := ret[0].([]schema.RiskFlag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRiskFlags indicates an expected call of GetRiskFlags.
+func (mr *MockIStorageMockRecorder) GetRiskFlags(login, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRiskFlags", reflect.TypeOf((*MockIStorage)(nil).GetRiskFlags), login, limit, offset)
+}
+
+// GetSecurityEvents mocks base method.
+func (m *MockIStorage) GetSecurityEvents(f storage.SecurityEventFilter, limit, offset int) ([]schema.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecurityEvents", f, limit, offset)
+	ret0, _ := ret[0].([]schema.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecurityEvents indicates an expected call of GetSecurityEvents.
+func (mr *MockIStorageMockRecorder) GetSecurityEvents(f, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecurityEvents", reflect.TypeOf((*MockIStorage)(nil).GetSecurityEvents), f, limit, offset)
+}
+
+// GetStatement mocks base method.
+func (m *MockIStorage) GetStatement(username string, from, to time.Time, fn func(schema.StatementEntry) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatement", username, from, to, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetStatement indicates an expected call of GetStatement.
+func (mr *MockIStorageMockRecorder) GetStatement(username, from, to, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatement", reflect.TypeOf((*MockIStorage)(nil).GetStatement), username, from, to, fn)
+}
+
+// GetThrottledUntil mocks base method.
+func (m *MockIStorage) GetThrottledUntil(username string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThrottledUntil", username)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThrottledUntil indicates an expected call of GetThrottledUntil.
+func (mr *MockIStorageMockRecorder) GetThrottledUntil(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThrottledUntil", reflect.TypeOf((*MockIStorage)(nil).GetThrottledUntil), username)
+}
+
+// GetTokenGeneration mocks base method.
+func (m *MockIStorage) GetTokenGeneration(username string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTokenGeneration", username)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTokenGeneration indicates an expected call of GetTokenGeneration.
+func (mr *MockIStorageMockRecorder) GetTokenGeneration(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTokenGeneration", reflect.TypeOf((*MockIStorage)(nil).GetTokenGeneration), username)
+}
+
+// GetTransfers mocks base method.
+func (m *MockIStorage) GetTransfers(username string) ([]schema.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", username)
+	ret0, _ := ret[0].([]schema.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockIStorageMockRecorder) GetTransfers(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockIStorage)(nil).GetTransfers), username)
+}
+
+// GetTwoFactor mocks base method.
+func (m *MockIStorage) GetTwoFactor(username string) (storage.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTwoFactor", username)
+	ret0, _ := ret[0].(storage.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTwoFactor indicates an expected call of GetTwoFactor.
+func (mr *MockIStorageMockRecorder) GetTwoFactor(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTwoFactor", reflect.TypeOf((*MockIStorage)(nil).GetTwoFactor), username)
+}
+
+// GetUser mocks base method.
+func (m *MockIStorage) GetUser(username string) (schema.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", username)
+	ret0, _ := ret[0].(schema.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockIStorageMockRecorder) GetUser(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockIStorage)(nil).GetUser), username)
+}
+
 // GetWithdrawals mocks base method.
 func (m *MockIStorage) GetWithdrawals(username string) ([]schema.Withdrawn, error) {
 	m.ctrl.T.Helper()
@@ -122,30 +758,203 @@ func (mr *MockIStorageMockRecorder) GetWithdrawals(username interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockIStorage)(nil).GetWithdrawals), username)
 }
 
+// GetWithdrawnSince mocks base method.
+func (m *MockIStorage) GetWithdrawnSince(username string, since time.Time) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawnSince", username, since)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawnSince indicates an expected call of GetWithdrawnSince.
+func (mr *MockIStorageMockRecorder) GetWithdrawnSince(username, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawnSince", reflect.TypeOf((*MockIStorage)(nil).GetWithdrawnSince), username, since)
+}
+
+// IsBlocked mocks base method.
+func (m *MockIStorage) IsBlocked(username string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsBlocked", username)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsBlocked indicates an expected call of IsBlocked.
+func (mr *MockIStorageMockRecorder) IsBlocked(username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlocked", reflect.TypeOf((*MockIStorage)(nil).IsBlocked), username)
+}
+
+// RejectReferral mocks base method.
+func (m *MockIStorage) RejectReferral(referred string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectReferral", referred)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RejectReferral indicates an expected call of RejectReferral.
+func (mr *MockIStorageMockRecorder) RejectReferral(referred interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectReferral", reflect.TypeOf((*MockIStorage)(nil).RejectReferral), referred)
+}
+
+// ResetPassword mocks base method.
+func (m *MockIStorage) ResetPassword(hash, passwd string, now time.Time) (string, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", hash, passwd, now)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockIStorageMockRecorder) ResetPassword(hash, passwd, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockIStorage)(nil).ResetPassword), hash, passwd, now)
+}
+
+// RewardReferral mocks base method.
+func (m *MockIStorage) RewardReferral(referred string, bonus float64, maxRewarded int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewardReferral", referred, bonus, maxRewarded)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RewardReferral indicates an expected call of RewardReferral.
+func (mr *MockIStorageMockRecorder) RewardReferral(referred, bonus, maxRewarded interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewardReferral", reflect.TypeOf((*MockIStorage)(nil).RewardReferral), referred, bonus, maxRewarded)
+}
+
+// SetBlocked mocks base method.
+func (m *MockIStorage) SetBlocked(username string, blocked bool, reason, admin string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBlocked", username, blocked, reason, admin)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBlocked indicates an expected call of SetBlocked.
+func (mr *MockIStorageMockRecorder) SetBlocked(username, blocked, reason, admin interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBlocked", reflect.TypeOf((*MockIStorage)(nil).SetBlocked), username, blocked, reason, admin)
+}
+
+// SetMultiplier mocks base method.
+func (m *MockIStorage) SetMultiplier(id string, multiplier float64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMultiplier", id, multiplier)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMultiplier indicates an expected call of SetMultiplier.
+func (mr *MockIStorageMockRecorder) SetMultiplier(id, multiplier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMultiplier", reflect.TypeOf((*MockIStorage)(nil).SetMultiplier), id, multiplier)
+}
+
+// SetReported mocks base method.
+func (m *MockIStorage) SetReported(id string, reported float64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetReported", id, reported)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetReported indicates an expected call of SetReported.
+func (mr *MockIStorageMockRecorder) SetReported(id, reported interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReported", reflect.TypeOf((*MockIStorage)(nil).SetReported), id, reported)
+}
+
+// SetTwoFactor mocks base method.
+func (m *MockIStorage) SetTwoFactor(username, secret string, recoveryHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTwoFactor", username, secret, recoveryHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTwoFactor indicates an expected call of SetTwoFactor.
+func (mr *MockIStorageMockRecorder) SetTwoFactor(username, secret, recoveryHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTwoFactor", reflect.TypeOf((*MockIStorage)(nil).SetTwoFactor), username, secret, recoveryHashes)
+}
+
+// UpdateCampaign mocks base method.
+func (m *MockIStorage) UpdateCampaign(c schema.Campaign, admin string) (schema.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCampaign", c, admin)
+	ret0, _ := ret[0].(schema.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCampaign indicates an expected call of UpdateCampaign.
+func (mr *MockIStorageMockRecorder) UpdateCampaign(c, admin interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCampaign", reflect.TypeOf((*MockIStorage)(nil).UpdateCampaign), c, admin)
+}
+
 // UpdateOrder mocks base method.
-func (m *MockIStorage) UpdateOrder(username, id, status string, accrual float64) error {
+func (m *MockIStorage) UpdateOrder(username, id, status string, accrual float64, campaigns []schema.AppliedCampaign) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateOrder", username, id, status, accrual)
+	ret := m.ctrl.Call(m, "UpdateOrder", username, id, status, accrual, campaigns)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateOrder indicates an expected call of UpdateOrder.
-func (mr *MockIStorageMockRecorder) UpdateOrder(username, id, status, accrual interface{}) *gomock.Call {
+func (mr *MockIStorageMockRecorder) UpdateOrder(username, id, status, accrual, campaigns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockIStorage)(nil).UpdateOrder), username, id, status, accrual, campaigns)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockIStorage) UseRecoveryCode(username, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", username, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockIStorageMockRecorder) UseRecoveryCode(username, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockIStorage)(nil).UseRecoveryCode), username, hash)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockIStorage) UseTOTPStep(username string, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", username, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockIStorageMockRecorder) UseTOTPStep(username, step interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockIStorage)(nil).UpdateOrder), username, id, status, accrual)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockIStorage)(nil).UseTOTPStep), username, step)
 }
 
 // Withdraw mocks base method.
-func (m *MockIStorage) Withdraw(username string, amount float64, orderID string) error {
+func (m *MockIStorage) Withdraw(username string, amount float64, orderID string, limit storage.WithdrawalLimit) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Withdraw", username, amount, orderID)
+	ret := m.ctrl.Call(m, "Withdraw", username, amount, orderID, limit)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Withdraw indicates an expected call of Withdraw.
-func (mr *MockIStorageMockRecorder) Withdraw(username, amount, orderID interface{}) *gomock.Call {
+func (mr *MockIStorageMockRecorder) Withdraw(username, amount, orderID, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdraw", reflect.TypeOf((*MockIStorage)(nil).Withdraw), username, amount, orderID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdraw", reflect.TypeOf((*MockIStorage)(nil).Withdraw), username, amount, orderID, limit)
 }
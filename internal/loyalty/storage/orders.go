@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+)
+
+// AddOrders adds the orders of the user in one transaction
+// and returns the result for every id: OrderAccepted, OrderDuplicate or OrderOwnedByAnotherUser.
+func (s Storage) AddOrders(username string, ids []string) (map[string]string, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(addOrders, pq.Array(ids), username, s.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]string, len(ids))
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		results[id] = OrderAccepted
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(results) < len(ids) {
+		existing := make([]string, 0, len(ids)-len(results))
+		for _, id := range ids {
+			if _, ok := results[id]; !ok {
+				existing = append(existing, id)
+			}
+		}
+
+		err = s.classifyExisting(tx, username, existing, results)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, tx.Commit()
+}
+
+func (s Storage) classifyExisting(tx *sql.Tx, username string, ids []string, results map[string]string) error {
+	rows, err := tx.Query(getOwnersByIDs, pq.Array(ids), s.Program)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, owner string
+		err = rows.Scan(&id, &owner)
+		if err != nil {
+			return err
+		}
+
+		if owner == username {
+			results[id] = OrderDuplicate
+		} else {
+			results[id] = OrderOwnedByAnotherUser
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+func (s Storage) GetTokenGeneration(username string) (int64, error) {
+	var generation int64
+	err := s.DB.QueryRow(getTokenGeneration, username, s.Program).Scan(&generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+
+	return generation, err
+}
+
+// ChangePassword sets the password and returns the next token generation of the user.
+func (s Storage) ChangePassword(username, passwd string) (int64, error) {
+	var generation int64
+	err := s.DB.QueryRow(changePassword, passwd, username, s.Program).Scan(&generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+
+	return generation, err
+}
+
+// CreatePasswordReset stores the hash of a reset token, the earlier tokens of the user stop working.
+func (s Storage) CreatePasswordReset(username, hash string, expiresAt time.Time) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(deleteUnusedPasswordResets, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(addPasswordReset, username, hash, expiresAt, s.Program)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetPassword spends the reset token with the hash and changes the password of its owner,
+// it returns the owner and the next token generation.
+func (s Storage) ResetPassword(hash, passwd string, now time.Time) (string, int64, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
+
+	var username string
+	err = tx.QueryRow(usePasswordReset, hash, now, s.Program).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, ErrBadResetToken
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	var generation int64
+	err = tx.QueryRow(changePassword, passwd, username, s.Program).Scan(&generation)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return username, generation, tx.Commit()
+}
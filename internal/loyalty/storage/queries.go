@@ -1,55 +1,564 @@
 package storage
 
-const createUser = `INSERT INTO "Users" VALUES ($1, $2, 0.0, 0.0)`
+// Every query is scoped by the "Program" parameter, it goes last except in the ledger queries.
+
+const createUser = `
+INSERT INTO "Users" ("Name", "Password", "Balance", "Withdrawn", "ReferralCode", "Program", "TokenGeneration")
+VALUES ($1, $2, 0.0, 0.0, $3, $4,
+        (SELECT COALESCE(MAX("TokenGeneration") + 1, 0) FROM "Deletions" WHERE "UserHash" = $5 AND "Program" = $4))
+`
 const validatePassword = `
-SELECT 1 FROM "Users" WHERE "Name" = $1 AND "Password" = $2
+SELECT "Blocked" FROM "Users" WHERE "Name" = $1 AND "Password" = $2 AND "Program" = $3
 `
 const addOrder = `
-INSERT INTO "Orders" VALUES ($1, $2, now()::timestamp, 'NEW', 0)
+INSERT INTO "Orders" ("UID", "Owner", "Date", "Status", "Accrual", "Program")
+VALUES ($1, $2, now()::timestamp, 'NEW', 0, $3)
 `
 const getOwnerByID = `
-SELECT "Owner" FROM "Orders" WHERE "UID" = $1
+SELECT "Owner" FROM "Orders" WHERE "UID" = $1 AND "Program" = $2
 `
 const getOrders = `
-SELECT "UID", "Status", "Accrual", "Date" FROM "Orders" WHERE "Owner" = $1
+SELECT "UID", "Status", "Accrual", "Date" FROM "Orders" WHERE "Owner" = $1 AND "Program" = $2
 `
 const getBalance = `
-SELECT "Balance", "Withdrawn" FROM "Users" WHERE "Name" = $1
+SELECT "Balance", "Withdrawn", "Tier" FROM "Users" WHERE "Name" = $1 AND "Program" = $2
 `
 const changeOrer = `
 UPDATE "Orders"
 SET "Accrual" = $1,
     "Status" = $2
-WHERE "UID" = $3
+WHERE "UID" = $3 AND "Program" = $4
 `
 const updateBalance = `
 UPDATE "Users"
 SET "Balance" = "Balance" + $1
-WHERE "Name" = $2
+WHERE "Name" = $2 AND "Program" = $3
 `
 const changeOrerWithoutAccrual = `
 UPDATE "Orders"
 SET "Status" = $1
-WHERE "UID" = $2
+WHERE "UID" = $2 AND "Program" = $3
 `
-const checkBalance = `
-SELECT 
-    CASE
-         WHEN "Balance" >= $1 THEN TRUE
-		 ELSE FALSE
-    END
-FROM "Users"
-WHERE "Name" = $2
+const lockUser = `
+SELECT "Balance", "Blocked" FROM "Users" WHERE "Name" = $1 AND "Program" = $2 FOR UPDATE
 `
 const drawBonuses = `
 UPDATE "Users"
 SET "Balance" = "Balance" - $1, 
     "Withdrawn" = "Withdrawn" + $1
-WHERE "Name" = $2
+WHERE "Name" = $2 AND "Program" = $3
 `
 const stageDraw = `
-INSERT INTO Withdrawals VALUES ($1, $2, $3, now()::timestamp)
+INSERT INTO Withdrawals ("Client", "ID", "Sum", "Date", "Program") VALUES ($1, $2, $3, now()::timestamp, $4)
 `
 const getWithdrawals = `
-SELECT "ID", "Sum", "Date" FROM Withdrawals WHERE "Client" = $1
+SELECT "ID", "Sum", "Date" FROM Withdrawals WHERE "Client" = $1 AND "Program" = $2
+`
+const addCredit = `
+INSERT INTO "Credits" ("Owner", "Source", "Reference", "Amount", "Remaining", "Date", "Program")
+VALUES ($1, $2, $3, $4, $4, now()::timestamp, $5)
+`
+const getSpendableCredits = `
+SELECT "ID", "Remaining", "Date" FROM "Credits"
+WHERE "Owner" = $1 AND "Program" = $2 AND "Remaining" > 0
+ORDER BY "Date", "ID"
+FOR UPDATE
+`
+const spendCredit = `
+UPDATE "Credits"
+SET "Remaining" = "Remaining" - $1
+WHERE "ID" = $2
+`
+const getExpiringCredits = `
+SELECT "ID", "Remaining", "Date" FROM "Credits"
+WHERE "Owner" = $1 AND "Remaining" > 0 AND "Date" < $2 AND "Program" = $3
+ORDER BY "Date", "ID"
+`
+
+// getOwnersWithExpiredCredits is the only query for all the programs, the points expire everywhere at once.
+const getOwnersWithExpiredCredits = `
+SELECT DISTINCT "Program", "Owner" FROM "Credits" WHERE "Remaining" > 0 AND "Date" < $1
+`
+const lockExpiredCredits = `
+SELECT "ID", "Remaining", "Date" FROM "Credits"
+WHERE "Owner" = $1 AND "Remaining" > 0 AND "Date" < $2 AND "Program" = $3
+ORDER BY "Date", "ID"
+FOR UPDATE
+`
+const stageExpiration = `
+INSERT INTO "Expirations" ("Owner", "Credit", "Sum", "Date", "Program") VALUES ($1, $2, $3, now()::timestamp, $4)
+`
+const writeOffBalance = `
+UPDATE "Users"
+SET "Balance" = "Balance" - $1
+WHERE "Name" = $2 AND "Program" = $3
+`
+const getAccrued = `
+SELECT COALESCE(SUM(COALESCE(o."Reported", c."Amount")), 0) FROM "Credits" AS c
+JOIN "Orders" AS o ON o."UID" = c."Reference" AND o."Program" = c."Program"
+WHERE c."Owner" = $1 AND c."Source" = $2 AND c."Date" > $3 AND c."Program" = $4
+`
+const getOrderWithdrawal = `
+SELECT "Sum" FROM Withdrawals WHERE "Client" = $1 AND "ID" = $2 AND "Program" = $3 LIMIT 1
+`
+const getWithdrawnSince = `
+SELECT COALESCE(SUM("Sum"), 0) FROM Withdrawals WHERE "Client" = $1 AND "Date" > $2 AND "Program" = $3
+`
+const getFreshPoints = `
+SELECT COALESCE(SUM("Remaining"), 0) FROM "Credits" WHERE "Owner" = $1 AND "Date" > $2 AND "Program" = $3
+`
+const lockTier = `
+SELECT "Tier" FROM "Users" WHERE "Name" = $1 AND "Program" = $2 FOR UPDATE
+`
+const setTier = `
+UPDATE "Users"
+SET "Tier" = $1
+WHERE "Name" = $2 AND "Program" = $3
+`
+const stageTierChange = `
+INSERT INTO "TierHistory" ("Owner", "Tier", "Previous", "Accrued", "Date", "Program")
+VALUES ($1, $2, $3, $4, now()::timestamp, $5)
+`
+const lockUsers = `
+SELECT "Name", "Balance", "Blocked" FROM "Users"
+WHERE "Name" = ANY($1) AND "Program" = $2
+ORDER BY "Name"
+FOR UPDATE
+`
+const getTransferredSince = `
+SELECT COALESCE(SUM("Sum"), 0) FROM "Transfers"
+WHERE "Sender" = $1 AND "Status" <> 'DECLINED' AND "Date" > $2 AND "Program" = $3
+`
+const addTransfer = `
+INSERT INTO "Transfers" ("Sender", "Recipient", "Sum", "Status", "Date", "Program")
+VALUES ($1, $2, $3, $4, now()::timestamp, $5)
+RETURNING "ID", "Date"
+`
+const addTransferPart = `
+INSERT INTO "TransferParts" VALUES ($1, $2, $3)
+`
+const getTransferParts = `
+SELECT "Sum", "Date" FROM "TransferParts" WHERE "Transfer" = $1
+`
+const addCreditAt = `
+INSERT INTO "Credits" ("Owner", "Source", "Reference", "Amount", "Remaining", "Date", "Program")
+VALUES ($1, $2, $3, $4, $4, $5, $6)
+`
+const getTransfer = `
+SELECT "ID", "Sender", "Recipient", "Sum", "Status", "Date", "CompletedAt"
+FROM "Transfers" WHERE "ID" = $1 AND "Program" = $2
+`
+const lockTransfer = `
+SELECT "ID", "Sender", "Recipient", "Sum", "Status", "Date", "CompletedAt"
+FROM "Transfers" WHERE "ID" = $1 AND "Program" = $2
+FOR UPDATE
+`
+const completeTransfer = `
+UPDATE "Transfers"
+SET "Status" = $1,
+    "CompletedAt" = now()::timestamp
+WHERE "ID" = $2
+RETURNING "CompletedAt"
+`
+const getTransfers = `
+SELECT "ID", "Sender", "Recipient", "Sum", "Status", "Date", "CompletedAt"
+FROM "Transfers"
+WHERE ("Sender" = $1 OR "Recipient" = $1) AND "Program" = $2
+ORDER BY "Date" DESC
+`
+const isBlocked = `
+SELECT "Blocked" FROM "Users" WHERE "Name" = $1 AND "Program" = $2
+`
+const findUsers = `
+SELECT "Name", "Balance", COALESCE("Withdrawn", 0), "Tier", "Blocked" FROM "Users"
+WHERE "Name" ILIKE $1 AND "Program" = $4
+ORDER BY "Name"
+LIMIT $2 OFFSET $3
+`
+const getUser = `
+SELECT "Name", "Balance", COALESCE("Withdrawn", 0), "Tier", "Blocked" FROM "Users"
+WHERE "Name" = $1 AND "Program" = $2
+`
+const addAdjustment = `
+INSERT INTO "Adjustments" ("Owner", "Sum", "Reason", "Admin", "Date", "Program")
+VALUES ($1, $2, $3, $4, now()::timestamp, $5)
+RETURNING "ID", "Date"
+`
+const setBlocked = `
+UPDATE "Users"
+SET "Blocked" = $1
+WHERE "Name" = $2 AND "Program" = $3
+`
+const addAdminAction = `
+INSERT INTO "AdminActions" ("Admin", "Action", "Target", "Details", "Date", "Program")
+VALUES ($1, $2, $3, $4, now()::timestamp, $5)
+`
+const getAdminActions = `
+SELECT "ID", "Admin", "Action", "Target", "Details", "Date" FROM "AdminActions"
+WHERE ($1 = '' OR "Target" = $1) AND "Program" = $4
+ORDER BY "Date" DESC, "ID" DESC
+LIMIT $2 OFFSET $3
+`
+
+// ledger lists every balance movement of the user $1 in the program $2. Transferred credits keep
+// the dates of the original accruals, so transfers come from "Transfers" instead.
+// The 'migration' credits hold the balances net of the withdrawals made before the credits existed,
+// so the withdrawals before the first credit of the user are left out.
+const ledger = `
+SELECT "Date", "Source" AS "Type", "Reference", "Amount" FROM "Credits"
+WHERE "Owner" = $1 AND "Program" = $2 AND "Source" <> 'transfer'
+UNION ALL
+SELECT "Date", 'withdrawal', "ID", -"Sum" FROM Withdrawals WHERE "Client" = $1 AND "Program" = $2
+AND "Date" >= (SELECT MIN("Date") FROM "Credits" WHERE "Owner" = $1 AND "Program" = $2)
+UNION ALL
+SELECT "Date", 'transfer', "ID"::TEXT, -"Sum" FROM "Transfers" WHERE "Sender" = $1 AND "Program" = $2
+UNION ALL
+SELECT "CompletedAt", 'transfer', "ID"::TEXT, "Sum" FROM "Transfers"
+WHERE "Recipient" = $1 AND "Program" = $2 AND "Status" = 'COMPLETED'
+UNION ALL
+SELECT "CompletedAt", 'transfer', "ID"::TEXT, "Sum" FROM "Transfers"
+WHERE "Sender" = $1 AND "Program" = $2 AND "Status" = 'DECLINED'
+UNION ALL
+SELECT "Date", 'adjustment', "ID"::TEXT, "Sum" FROM "Adjustments"
+WHERE "Owner" = $1 AND "Program" = $2 AND "Sum" < 0
+UNION ALL
+SELECT "Date", 'expiration', "Credit"::TEXT, -"Sum" FROM "Expirations" WHERE "Owner" = $1 AND "Program" = $2
+`
+const getOpeningBalance = `
+SELECT COALESCE(SUM("Amount"), 0) FROM (` + ledger + `) AS l
+WHERE "Date" < $3
+`
+const getStatement = `
+SELECT "Date", "Type", "Reference", "Amount" FROM (` + ledger + `) AS l
+WHERE "Date" >= $3 AND "Date" < $4
+ORDER BY "Date"
+`
+const addOrders = `
+INSERT INTO "Orders" ("UID", "Owner", "Date", "Status", "Accrual", "Program")
+SELECT "UID", $2, now()::timestamp, 'NEW', 0, $3 FROM unnest($1::VARCHAR[]) AS "UID"
+ON CONFLICT ("Program", "UID") DO NOTHING
+RETURNING "UID"
+`
+const getOwnersByIDs = `
+SELECT "UID", "Owner" FROM "Orders" WHERE "UID" = ANY($1) AND "Program" = $2
+`
+const addOrderCampaign = `
+INSERT INTO "OrderCampaigns" ("Order", "Campaign", "Bonus", "Program") VALUES ($1, $2, $3, $4)
+`
+const getOrderCampaigns = `
+SELECT oc."Order", oc."Campaign", c."Name", oc."Bonus"
+FROM "OrderCampaigns" oc
+JOIN "Campaigns" c ON c."ID" = oc."Campaign"
+JOIN "Orders" o ON o."UID" = oc."Order" AND o."Program" = oc."Program"
+WHERE o."Owner" = $1 AND oc."Program" = $2
+ORDER BY oc."Campaign"
+`
+const addCampaign = `
+INSERT INTO "Campaigns" ("Name", "Multiplier", "Bonus", "FirstOrders", "StartsAt", "EndsAt", "Disabled", "Date", "Program")
+VALUES ($1, $2, $3, $4, $5, $6, $7, now()::timestamp, $8)
+RETURNING "ID", "Date"
+`
+const updateCampaign = `
+UPDATE "Campaigns"
+SET "Name" = $1,
+    "Multiplier" = $2,
+    "Bonus" = $3,
+    "FirstOrders" = $4,
+    "StartsAt" = $5,
+    "EndsAt" = $6,
+    "Disabled" = $7
+WHERE "ID" = $8 AND "Program" = $9
+RETURNING "Date"
+`
+const getCampaigns = `
+SELECT "ID", "Name", "Multiplier", "Bonus", "FirstOrders", "StartsAt", "EndsAt", "Disabled", "Date"
+FROM "Campaigns" WHERE "Program" = $1
+ORDER BY "ID"
+`
+const lockReferrer = `
+SELECT "Name", "Blocked" FROM "Users" WHERE "ReferralCode" = $1 AND "Program" = $2 FOR UPDATE
+`
+const countReferralsSince = `
+SELECT COUNT(*) FROM "Referrals" WHERE "Referrer" = $1 AND "Date" > $2 AND "Program" = $3
+`
+const addReferral = `
+INSERT INTO "Referrals" ("Referrer", "Referred", "Status", "Bonus", "Date", "Program")
+VALUES ($1, $2, 'PENDING', 0, now()::timestamp, $3)
+`
+const lockPendingReferral = `
+SELECT "ID", "Referrer" FROM "Referrals"
+WHERE "Referred" = $1 AND "Status" = 'PENDING' AND "Program" = $2
+FOR UPDATE
+`
+const countRewardedReferrals = `
+SELECT COUNT(*) FROM "Referrals" WHERE "Referrer" = $1 AND "Status" = 'REWARDED' AND "Program" = $2
+`
+const completeReferral = `
+UPDATE "Referrals"
+SET "Status" = $1,
+    "Bonus" = $2,
+    "CompletedAt" = now()::timestamp
+WHERE "ID" = $3
+`
+const rejectPendingReferral = `
+UPDATE "Referrals"
+SET "Status" = 'REJECTED',
+    "CompletedAt" = now()::timestamp
+WHERE "Referred" = $1 AND "Status" = 'PENDING' AND "Program" = $2
+`
+const getReferralCode = `
+SELECT "ReferralCode" FROM "Users" WHERE "Name" = $1 AND "Program" = $2
+`
+const getReferrals = `
+SELECT "Referred", "Status", "Bonus", "Date", "CompletedAt" FROM "Referrals"
+WHERE "Referrer" = $1 AND "Program" = $2
+ORDER BY "Date" DESC, "ID" DESC
+`
+const addSession = `
+INSERT INTO "Sessions" ("Owner", "IP", "UserAgent", "Date", "Program") VALUES ($1, $2, $3, now()::timestamp, $4)
+`
+const getSessions = `
+SELECT "IP", "UserAgent", "Date" FROM "Sessions" WHERE "Owner" = $1 AND "Program" = $2 ORDER BY "Date" DESC
+`
+const getUserAdjustments = `
+SELECT "ID", "Sum", "Reason", "Admin", "Date" FROM "Adjustments" WHERE "Owner" = $1 AND "Program" = $2 ORDER BY "Date"
+`
+const countPendingTransfers = `
+SELECT COUNT(*) FROM "Transfers" WHERE ("Sender" = $1 OR "Recipient" = $1) AND "Status" = 'PENDING' AND "Program" = $2
+`
+const addAlias = `
+INSERT INTO "Users" ("Name", "Password", "Balance", "Withdrawn", "Tier", "Blocked", "ReferralCode", "Program")
+SELECT $1, '', 0, "Withdrawn", '', TRUE, $2, "Program" FROM "Users" WHERE "Name" = $3 AND "Program" = $4
+`
+const deleteOrderCampaigns = `
+DELETE FROM "OrderCampaigns"
+WHERE "Program" = $2 AND "Order" IN (SELECT "UID" FROM "Orders" WHERE "Owner" = $1 AND "Program" = $2)
+`
+const freezeCredits = `
+UPDATE "Credits" SET "Remaining" = 0 WHERE "Owner" = $1 AND "Program" = $2
+`
+const deleteUser = `
+DELETE FROM "Users" WHERE "Name" = $1 AND "Program" = $2
+`
+const addDeletion = `
+INSERT INTO "Deletions" ("UserHash", "Alias", "Mode", "Forfeited", "TokenGeneration", "Date", "Program")
+VALUES ($1, $2, $3, $4, $5, now()::timestamp, $6)
+RETURNING "ID", "Date"
+`
+
+// moveUserRows hand the rows of the deleted user over to the alias, $1 is the alias and $2 is the user.
+var moveUserRows = []string{
+	`UPDATE "Orders" SET "Owner" = $1 WHERE "Owner" = $2 AND "Program" = $3`,
+	`UPDATE Withdrawals SET "Client" = $1 WHERE "Client" = $2 AND "Program" = $3`,
+	`UPDATE "Credits" SET "Owner" = $1 WHERE "Owner" = $2 AND "Program" = $3`,
+	`UPDATE "Expirations" SET "Owner" = $1 WHERE "Owner" = $2 AND "Program" = $3`,
+	`UPDATE "TierHistory" SET "Owner" = $1 WHERE "Owner" = $2 AND "Program" = $3`,
+	`UPDATE "Adjustments" SET "Owner" = $1 WHERE "Owner" = $2 AND "Program" = $3`,
+}
+
+// moveSharedRows hand over the rows other users see in their history, so they are kept in every mode.
+var moveSharedRows = []string{
+	`UPDATE "Transfers" SET "Sender" = $1 WHERE "Sender" = $2 AND "Program" = $3`,
+	`UPDATE "Transfers" SET "Recipient" = $1 WHERE "Recipient" = $2 AND "Program" = $3`,
+	`UPDATE "Referrals" SET "Referrer" = $1 WHERE "Referrer" = $2 AND "Program" = $3`,
+	`UPDATE "Referrals" SET "Referred" = $1 WHERE "Referred" = $2 AND "Program" = $3`,
+	`UPDATE "AdminActions" SET "Target" = $1 WHERE "Target" = $2 AND "Program" = $3`,
+	`UPDATE "RiskFlags" SET "Owner" = $1 WHERE "Owner" = $2 AND "Program" = $3`,
+	`UPDATE "Discrepancies" SET "Owner" = $1 WHERE "Owner" = $2 AND "Program" = $3`,
+}
+
+// deleteUserRows are in the order of the foreign keys between them, $1 is the user.
+// The orders are kept as tombstones by tombstoneOrders.
+var deleteUserRows = []string{
+	deleteOrderCampaigns,
+	`DELETE FROM Withdrawals WHERE "Client" = $1 AND "Program" = $2`,
+	`DELETE FROM "Expirations" WHERE "Owner" = $1 AND "Program" = $2`,
+	`DELETE FROM "Credits" WHERE "Owner" = $1 AND "Program" = $2`,
+	`DELETE FROM "TierHistory" WHERE "Owner" = $1 AND "Program" = $2`,
+	`DELETE FROM "Adjustments" WHERE "Owner" = $1 AND "Program" = $2`,
+}
+
+// tombstoneOrders keeps only the numbers of the orders of the deleted user under the alias,
+// so they can't be uploaded and credited again. INVALID leaves them out of the reconciliation.
+const tombstoneOrders = `
+UPDATE "Orders" SET "Owner" = $1, "Status" = 'INVALID', "Accrual" = 0, "Reported" = NULL, "Multiplier" = NULL
+WHERE "Owner" = $2 AND "Program" = $3
+`
+const deleteSessions = `
+DELETE FROM "Sessions" WHERE "Owner" = $1 AND "Program" = $2
+`
+const deleteRecoveryCodes = `
+DELETE FROM "RecoveryCodes" WHERE "Owner" = $1 AND "Program" = $2
+`
+const getTwoFactor = `
+SELECT COALESCE("TOTPSecret", ''), "TOTPEnabled", "TOTPStep" FROM "Users" WHERE "Name" = $1 AND "Program" = $2
+`
+const setTwoFactorSecret = `
+UPDATE "Users" SET "TOTPSecret" = $1, "TOTPEnabled" = FALSE, "TOTPStep" = 0
+WHERE "Name" = $2 AND "Program" = $3 AND NOT "TOTPEnabled"
+`
+const addRecoveryCode = `
+INSERT INTO "RecoveryCodes" ("Owner", "Hash", "Program") VALUES ($1, $2, $3)
+`
+const enableTwoFactor = `
+UPDATE "Users" SET "TOTPEnabled" = TRUE, "TOTPStep" = $1
+WHERE "Name" = $2 AND "Program" = $3 AND "TOTPSecret" IS NOT NULL AND NOT "TOTPEnabled"
+`
+const disableTwoFactor = `
+UPDATE "Users" SET "TOTPSecret" = NULL, "TOTPEnabled" = FALSE, "TOTPStep" = 0
+WHERE "Name" = $1 AND "Program" = $2
+`
+const useTOTPStep = `
+UPDATE "Users" SET "TOTPStep" = $1
+WHERE "Name" = $2 AND "Program" = $3 AND "TOTPEnabled" AND "TOTPStep" < $1
+`
+const useRecoveryCode = `
+UPDATE "RecoveryCodes" SET "UsedAt" = now()::timestamp
+WHERE "ID" = (
+    SELECT "ID" FROM "RecoveryCodes"
+    WHERE "Owner" = $1 AND "Hash" = $2 AND "UsedAt" IS NULL AND "Program" = $3
+    LIMIT 1
+)
+`
+const getTokenGeneration = `
+SELECT "TokenGeneration" FROM "Users" WHERE "Name" = $1 AND "Program" = $2
+`
+const changePassword = `
+UPDATE "Users" SET "Password" = $1, "TokenGeneration" = "TokenGeneration" + 1
+WHERE "Name" = $2 AND "Program" = $3
+RETURNING "TokenGeneration"
+`
+const deleteUnusedPasswordResets = `
+DELETE FROM "PasswordResets" WHERE "Owner" = $1 AND "UsedAt" IS NULL AND "Program" = $2
+`
+const addPasswordReset = `
+INSERT INTO "PasswordResets" ("Owner", "Hash", "Date", "ExpiresAt", "Program")
+VALUES ($1, $2, now()::timestamp, $3, $4)
+`
+const usePasswordReset = `
+UPDATE "PasswordResets" SET "UsedAt" = now()::timestamp
+WHERE "Hash" = $1 AND "UsedAt" IS NULL AND "ExpiresAt" > $2 AND "Program" = $3
+RETURNING "Owner"
+`
+const deletePasswordResets = `
+DELETE FROM "PasswordResets" WHERE "Owner" = $1 AND "Program" = $2
+`
+
+const addSecurityEvent = `
+INSERT INTO "SecurityEvents" ("Event", "Login", "Success", "IP", "UserAgent", "RequestID", "Details", "Date", "Program")
+VALUES ($1, $2, $3, $4, $5, $6, $7, now()::timestamp, $8)
+`
+
+// getSecurityEvents filters by the login $1, the event $2, the IP $3 and the success $4 when they are set,
+// and by the period [$5, $6) when its ends are not null.
+const getSecurityEvents = `
+SELECT "ID", "Event", "Login", "Success", "IP", "UserAgent", "RequestID", "Details", "Date" FROM "SecurityEvents"
+WHERE ($1 = '' OR "Login" = $1) AND ($2 = '' OR "Event" = $2) AND ($3 = '' OR "IP" = $3)
+  AND ($4::boolean IS NULL OR "Success" = $4)
+  AND ($5::timestamp IS NULL OR "Date" >= $5) AND ($6::timestamp IS NULL OR "Date" < $6)
+  AND "Program" = $9
+ORDER BY "Date" DESC, "ID" DESC
+LIMIT $7 OFFSET $8
+`
+
+const addRiskActivity = `
+INSERT INTO "RiskActivity" ("Owner", "Uploads", "Invalid", "Foreign", "Withdrawals", "Withdrawn", "Date", "Program")
+VALUES ($1, $2, $3, $4, $5, $6, now()::timestamp, $7)
+`
+const getRiskActivity = `
+SELECT COALESCE(SUM("Uploads"), 0), COALESCE(SUM("Invalid"), 0), COALESCE(SUM("Foreign"), 0),
+       COALESCE(SUM("Withdrawals"), 0), COALESCE(SUM("Withdrawn"), 0)
+FROM "RiskActivity" WHERE "Owner" = $1 AND "Date" > $2 AND "Program" = $3
+`
+const deleteRiskActivity = `
+DELETE FROM "RiskActivity" WHERE "Owner" = $1 AND "Program" = $2
+`
+
+// addRiskFlag skips the flag when the user already has a flag of the rule since $7.
+const addRiskFlag = `
+INSERT INTO "RiskFlags" ("Owner", "Signal", "Value", "Threshold", "Action", "Until", "Date", "Program")
+SELECT $1::varchar, $2::varchar, $3::decimal, $4::decimal, $5::varchar, $6::timestamp, now()::timestamp, $8::varchar
+WHERE NOT EXISTS (
+    SELECT 1 FROM "RiskFlags"
+    WHERE "Owner" = $1 AND "Signal" = $2 AND "Action" = $5 AND "Date" > $7 AND "Program" = $8
+)
+`
+
+// getRiskFlags filters by the login $1 when it is set.
+const getRiskFlags = `
+SELECT "ID", "Owner", "Signal", "Value", "Threshold", "Action", "Until", "Date" FROM "RiskFlags"
+WHERE ($1 = '' OR "Owner" = $1) AND "Program" = $4
+ORDER BY "Date" DESC, "ID" DESC
+LIMIT $2 OFFSET $3
+`
+const getThrottledUntil = `
+SELECT MAX("Until") FROM "RiskFlags" WHERE "Owner" = $1 AND "Until" IS NOT NULL AND "Program" = $2
+`
+
+const getProcessedOrders = `
+SELECT "UID", "Owner", COALESCE("Accrual", 0), "Reported", "Multiplier" FROM "Orders"
+WHERE "Status" = 'PROCESSED' AND "Date" > $1 AND "Program" = $2
+ORDER BY "Date", "UID"
+`
+const setReported = `
+UPDATE "Orders" SET "Reported" = $1 WHERE "UID" = $2 AND "Program" = $3
+`
+const setMultiplier = `
+UPDATE "Orders" SET "Multiplier" = $1 WHERE "UID" = $2 AND "Program" = $3
+`
+const addReconciliation = `
+INSERT INTO "Reconciliations" ("Since", "Checked", "Failed", "Mismatched", "Applied", "Admin", "Date", "Program")
+VALUES ($1, $2, $3, $4, $5, $6, now()::timestamp, $7)
+RETURNING "ID", "Date"
+`
+const addDiscrepancy = `
+INSERT INTO "Discrepancies" ("Reconciliation", "Number", "Owner", "Accrual", "Reported", "Status", "Fresh",
+                             "Correction", "Adjustment", "Error", "Program")
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+`
+const getReconciliations = `
+SELECT "ID", "Since", "Checked", "Failed", "Mismatched", "Applied", "Admin", "Date" FROM "Reconciliations"
+WHERE "Program" = $3
+ORDER BY "Date" DESC, "ID" DESC
+LIMIT $1 OFFSET $2
+`
+const getReconciliation = `
+SELECT "ID", "Since", "Checked", "Failed", "Mismatched", "Applied", "Admin", "Date" FROM "Reconciliations"
+WHERE "ID" = $1 AND "Program" = $2
+`
+const getDiscrepancies = `
+SELECT "Number", "Owner", "Accrual", "Reported", "Status", "Fresh", "Correction", "Adjustment", "Error"
+FROM "Discrepancies" WHERE "Reconciliation" = $1 AND "Program" = $2
+ORDER BY "ID"
+`
+
+// checkBalances recomputes the balances from the history instead of the credits, the migrated
+// credits hold the balances left after the withdrawals made before the credits existed.
+// The aliases of the deleted users are skipped, their history is removed or forfeited.
+const checkBalances = `
+SELECT u."Name", u."Balance", COALESCE(u."Withdrawn", 0),
+       (SELECT COALESCE(SUM("Accrual"), 0) FROM "Orders"
+        WHERE "Owner" = u."Name" AND "Status" = 'PROCESSED' AND "Program" = $1)
+     + (SELECT COALESCE(SUM("Amount"), 0) FROM "Credits"
+        WHERE "Owner" = u."Name" AND "Source" = 'referral' AND "Program" = $1)
+     + (SELECT COALESCE(SUM("Sum"), 0) FROM "Adjustments" WHERE "Owner" = u."Name" AND "Program" = $1)
+     + (SELECT COALESCE(SUM("Sum"), 0) FROM "Transfers"
+        WHERE "Recipient" = u."Name" AND "Status" = 'COMPLETED' AND "Program" = $1)
+     - (SELECT COALESCE(SUM("Sum"), 0) FROM "Transfers"
+        WHERE "Sender" = u."Name" AND "Status" <> 'DECLINED' AND "Program" = $1)
+     - (SELECT COALESCE(SUM("Sum"), 0) FROM Withdrawals WHERE "Client" = u."Name" AND "Program" = $1)
+     - (SELECT COALESCE(SUM("Sum"), 0) FROM "Expirations" WHERE "Owner" = u."Name" AND "Program" = $1),
+       (SELECT COALESCE(SUM("Sum"), 0) FROM Withdrawals WHERE "Client" = u."Name" AND "Program" = $1)
+FROM "Users" u
+WHERE u."Program" = $1 AND u."Name" NOT IN (SELECT "Alias" FROM "Deletions" WHERE "Program" = $1)
+ORDER BY u."Name"
+`
+const lockProgramUsers = `
+SELECT "Name" FROM "Users" WHERE "Program" = $1 ORDER BY "Name" FOR UPDATE
+`
+const setBalance = `
+UPDATE "Users"
+SET "Balance" = $1,
+    "Withdrawn" = $2
+WHERE "Name" = $3 AND "Program" = $4
 `
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"gomarket/internal/loyalty/schema"
+	"time"
+)
+
+// GetProcessedOrders returns the processed orders uploaded after since, the oldest first.
+func (s Storage) GetProcessedOrders(since time.Time) ([]ProcessedOrder, error) {
+	rows, err := s.DB.Query(getProcessedOrders, since, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]ProcessedOrder, 0)
+	for rows.Next() {
+		var order ProcessedOrder
+		var reported, multiplier sql.NullFloat64
+		err = rows.Scan(&order.Number, &order.Owner, &order.Accrual, &reported, &multiplier)
+		if err != nil {
+			return nil, err
+		}
+
+		order.Reported = nullFloat(reported)
+		order.Multiplier = nullFloat(multiplier)
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// SetReported stores the accrual the accrual system reported for the order.
+func (s Storage) SetReported(id string, reported float64) error {
+	_, err := s.DB.Exec(setReported, reported, id, s.Program)
+	return err
+}
+
+// SetMultiplier stores the tier multiplier applied to the accrual of the order.
+func (s Storage) SetMultiplier(id string, multiplier float64) error {
+	_, err := s.DB.Exec(setMultiplier, multiplier, id, s.Program)
+	return err
+}
+
+// CorrectAccrual adjusts the balance by the correction of the order and stores the new reported accrual
+// in one transaction, so the next reconciliation doesn't correct the order again.
+func (s Storage) CorrectAccrual(username, id string, reported, sum float64, reason, admin string) (schema.Adjustment, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+	defer tx.Rollback()
+
+	adjustment, err := s.adjust(tx, username, sum, reason, admin)
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	_, err = tx.Exec(setReported, reported, id, s.Program)
+	if err != nil {
+		return schema.Adjustment{}, err
+	}
+
+	return adjustment, tx.Commit()
+}
+
+// AddReconciliation stores the report with its discrepancies.
+func (s Storage) AddReconciliation(r schema.Reconciliation) (schema.Reconciliation, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Reconciliation{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(addReconciliation, r.Since, r.Checked, r.Failed, r.Mismatched, r.Applied, r.Admin, s.Program).
+		Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		return schema.Reconciliation{}, err
+	}
+
+	for _, d := range r.Discrepancies {
+		var reported sql.NullFloat64
+		if d.Reported != nil {
+			reported = sql.NullFloat64{Float64: *d.Reported, Valid: true}
+		}
+
+		var adjustment sql.NullInt64
+		if d.AdjustmentID != nil {
+			adjustment = sql.NullInt64{Int64: *d.AdjustmentID, Valid: true}
+		}
+
+		_, err = tx.Exec(addDiscrepancy, r.ID, d.Number, d.Login, d.Accrual, reported, d.Status, d.Fresh,
+			d.Correction, adjustment, d.Error, s.Program)
+		if err != nil {
+			return schema.Reconciliation{}, err
+		}
+	}
+
+	return r, tx.Commit()
+}
+
+// GetReconciliations returns the reports without the discrepancies, the newest first.
+func (s Storage) GetReconciliations(limit, offset int) ([]schema.Reconciliation, error) {
+	rows, err := s.DB.Query(getReconciliations, limit, offset, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]schema.Reconciliation, 0)
+	for rows.Next() {
+		r, err := scanReconciliation(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		reports = append(reports, r)
+	}
+
+	return reports, rows.Err()
+}
+
+func (s Storage) GetReconciliation(id int64) (schema.Reconciliation, error) {
+	r, err := scanReconciliation(s.DB.QueryRow(getReconciliation, id, s.Program))
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema.Reconciliation{}, ErrReconciliationNotFound
+	}
+	if err != nil {
+		return schema.Reconciliation{}, err
+	}
+
+	rows, err := s.DB.Query(getDiscrepancies, id, s.Program)
+	if err != nil {
+		return schema.Reconciliation{}, err
+	}
+	defer rows.Close()
+
+	r.Discrepancies = make([]schema.Discrepancy, 0)
+	for rows.Next() {
+		var d schema.Discrepancy
+		var reported sql.NullFloat64
+		var adjustment sql.NullInt64
+		err = rows.Scan(&d.Number, &d.Login, &d.Accrual, &reported, &d.Status, &d.Fresh, &d.Correction, &adjustment, &d.Error)
+		if err != nil {
+			return schema.Reconciliation{}, err
+		}
+
+		d.Reported = nullFloat(reported)
+		if adjustment.Valid {
+			d.AdjustmentID = &adjustment.Int64
+		}
+
+		r.Discrepancies = append(r.Discrepancies, d)
+	}
+
+	return r, rows.Err()
+}
+
+func scanReconciliation(row scanner) (schema.Reconciliation, error) {
+	var r schema.Reconciliation
+	err := row.Scan(&r.ID, &r.Since, &r.Checked, &r.Failed, &r.Mismatched, &r.Applied, &r.Admin, &r.CreatedAt)
+	return r, err
+}
+
+// nullFloat turns NULL into nil.
+func nullFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+
+	return &f.Float64
+}
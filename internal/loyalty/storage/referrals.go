@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"gomarket/internal/loyalty/schema"
+	"strconv"
+)
+
+// referralAlphabet skips the characters easily confused when the code is typed by hand.
+const referralAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const referralCodeLength = 10
+
+func newReferralCode() (string, error) {
+	b := make([]byte, referralCodeLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = referralAlphabet[int(b[i])%len(referralAlphabet)]
+	}
+
+	return string(b), nil
+}
+
+func (s Storage) CreateReferredUser(login, passwd, code string, limit ReferralLimit) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var referrer string
+	var blocked bool
+	err = tx.QueryRow(lockReferrer, code, s.Program).Scan(&referrer, &blocked)
+	if errors.Is(err, sql.ErrNoRows) || blocked {
+		return ErrBadReferral
+	}
+	if err != nil {
+		return err
+	}
+
+	if limit.Max > 0 {
+		var referred int
+		err = tx.QueryRow(countReferralsSince, referrer, limit.Since, s.Program).Scan(&referred)
+		if err != nil {
+			return err
+		}
+
+		if referred >= limit.Max {
+			return ErrReferralLimit
+		}
+	}
+
+	err = s.insertUser(tx, login, passwd)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(addReferral, referrer, login, s.Program)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RewardReferral credits the bonus to the referred user and the referrer, or rejects the referral
+// if any of them is blocked or the referrer has maxRewarded rewarded referrals already.
+// It does nothing unless the user has a pending referral.
+func (s Storage) RewardReferral(referred string, bonus float64, maxRewarded int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var referrer string
+	err = tx.QueryRow(lockPendingReferral, referred, s.Program).Scan(&id, &referrer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	users, err := s.lockUsersState(tx, referrer, referred)
+	if err != nil {
+		return err
+	}
+
+	status := ReferralRewarded
+	if users[referrer].Blocked || users[referred].Blocked {
+		status = ReferralRejected
+	}
+
+	if status == ReferralRewarded && maxRewarded > 0 {
+		var rewarded int
+		err = tx.QueryRow(countRewardedReferrals, referrer, s.Program).Scan(&rewarded)
+		if err != nil {
+			return err
+		}
+
+		if rewarded >= maxRewarded {
+			status = ReferralRejected
+		}
+	}
+
+	if status == ReferralRejected {
+		bonus = 0
+	}
+
+	if bonus > 0 {
+		err = s.creditReferral(tx, id, bonus, referrer, referred)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(completeReferral, status, bonus, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s Storage) creditReferral(tx *sql.Tx, id int64, bonus float64, usernames ...string) error {
+	reference := strconv.FormatInt(id, 10)
+	for _, username := range usernames {
+		_, err := tx.Exec(addCredit, username, CreditSourceReferral, reference, bonus, s.Program)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(updateBalance, bonus, username, s.Program)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s Storage) RejectReferral(referred string) error {
+	_, err := s.DB.Exec(rejectPendingReferral, referred, s.Program)
+	return err
+}
+
+func (s Storage) GetReferrals(username string) (schema.Referrals, error) {
+	referrals := schema.Referrals{Referrals: make([]schema.Referral, 0)}
+	err := s.DB.QueryRow(getReferralCode, username, s.Program).Scan(&referrals.Code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema.Referrals{}, ErrUserNotFound
+	}
+	if err != nil {
+		return schema.Referrals{}, err
+	}
+
+	rows, err := s.DB.Query(getReferrals, username, s.Program)
+	if err != nil {
+		return schema.Referrals{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var referral schema.Referral
+		var completedAt sql.NullTime
+		err = rows.Scan(&referral.Login, &referral.Status, &referral.Bonus, &referral.CreatedAt, &completedAt)
+		if err != nil {
+			return schema.Referrals{}, err
+		}
+
+		if completedAt.Valid {
+			referral.CompletedAt = &completedAt.Time
+		}
+
+		if referral.Status == ReferralRewarded {
+			referrals.Earned += referral.Bonus
+		}
+
+		referrals.Referrals = append(referrals.Referrals, referral)
+	}
+
+	return referrals, rows.Err()
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"database/sql"
+	"gomarket/internal/loyalty/schema"
+	"time"
+)
+
+func (s Storage) AddRiskActivity(username string, a RiskActivity) error {
+	_, err := s.DB.Exec(addRiskActivity, username, a.Uploads, a.Invalid, a.Foreign, a.Withdrawals, a.Withdrawn, s.Program)
+	return err
+}
+
+// GetRiskActivity sums the activity of the user after since.
+func (s Storage) GetRiskActivity(username string, since time.Time) (RiskActivity, error) {
+	var a RiskActivity
+	err := s.DB.QueryRow(getRiskActivity, username, since, s.Program).
+		Scan(&a.Uploads, &a.Invalid, &a.Foreign, &a.Withdrawals, &a.Withdrawn)
+	return a, err
+}
+
+// AddRiskFlag adds the flag unless the user has a flag of the same signal and action after since,
+// so a rule that keeps triggering within the window is recorded once.
+func (s Storage) AddRiskFlag(flag schema.RiskFlag, since time.Time) (bool, error) {
+	var until sql.NullTime
+	if flag.Until != nil {
+		until = sql.NullTime{Time: *flag.Until, Valid: true}
+	}
+
+	res, err := s.DB.Exec(addRiskFlag, flag.Login, flag.Signal, flag.Value, flag.Threshold, flag.Action,
+		until, since, s.Program)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s Storage) GetRiskFlags(login string, limit, offset int) ([]schema.RiskFlag, error) {
+	rows, err := s.DB.Query(getRiskFlags, login, limit, offset, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := make([]schema.RiskFlag, 0)
+	for rows.Next() {
+		var flag schema.RiskFlag
+		var until sql.NullTime
+		err = rows.Scan(&flag.ID, &flag.Login, &flag.Signal, &flag.Value, &flag.Threshold, &flag.Action, &until, &flag.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if until.Valid {
+			flag.Until = &until.Time
+		}
+
+		flags = append(flags, flag)
+	}
+
+	return flags, rows.Err()
+}
+
+// GetThrottledUntil returns the end of the latest throttle of the user, the zero time if there was none.
+func (s Storage) GetThrottledUntil(username string) (time.Time, error) {
+	var until sql.NullTime
+	err := s.DB.QueryRow(getThrottledUntil, username, s.Program).Scan(&until)
+	return until.Time, err
+}
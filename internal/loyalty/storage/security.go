@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"database/sql"
+	"gomarket/internal/loyalty/schema"
+	"time"
+)
+
+func (s Storage) AddSecurityEvent(e schema.SecurityEvent) error {
+	_, err := s.DB.Exec(addSecurityEvent, e.Event, e.Login, e.Success, e.IP, e.UserAgent, e.RequestID, e.Details, s.Program)
+	return err
+}
+
+func (s Storage) GetSecurityEvents(f SecurityEventFilter, limit, offset int) ([]schema.SecurityEvent, error) {
+	var success sql.NullBool
+	if f.Success != nil {
+		success = sql.NullBool{Bool: *f.Success, Valid: true}
+	}
+
+	rows, err := s.DB.Query(getSecurityEvents, f.Login, f.Event, f.IP, success,
+		nullTime(f.From), nullTime(f.To), limit, offset, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]schema.SecurityEvent, 0)
+	for rows.Next() {
+		var e schema.SecurityEvent
+		err = rows.Scan(&e.ID, &e.Event, &e.Login, &e.Success, &e.IP, &e.UserAgent, &e.RequestID, &e.Details, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// nullTime turns the zero time into NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
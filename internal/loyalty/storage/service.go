@@ -3,10 +3,10 @@ package storage
 import (
 	"database/sql"
 	"errors"
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"gomarket/internal/loyalty/program"
 	"gomarket/internal/loyalty/schema"
-	"log"
+	"gomarket/internal/loyalty/withdrawal"
+	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
@@ -16,13 +16,72 @@ type IStorage interface {
 	CheckID(username, id string) error
 	GetOrders(username string) (Orders, error)
 	GetBalance(username string) (schema.Balance, error)
-	UpdateOrder(username, id, status string, accrual float64) error
-	Withdraw(username string, amount float64, orderID string) error
+	UpdateOrder(username, id, status string, accrual float64, campaigns []schema.AppliedCampaign) error
+	Withdraw(username string, amount float64, orderID string, limit WithdrawalLimit) error
 	GetWithdrawals(username string) ([]schema.Withdrawn, error)
+	GetExpiringCredits(username string, before time.Time) ([]schema.Credit, error)
+	ExpirePoints(before time.Time) (float64, error)
+	// GetAccrued sums the accruals the calculation system reported, without the tier multipliers and the campaigns.
+	GetAccrued(username string, since time.Time) (float64, error)
+	GetWithdrawnSince(username string, since time.Time) (float64, error)
+	GetFreshPoints(username string, since time.Time) (float64, error)
+	ChangeTier(username, tier string, accrued float64) (bool, error)
+	CreateTransfer(sender, recipient string, sum float64, pending bool, limit TransferLimit) (schema.Transfer, error)
+	CompleteTransfer(id int64, username string, accept bool) (schema.Transfer, error)
+	GetTransfers(username string) ([]schema.Transfer, error)
+	IsBlocked(username string) (bool, error)
+	FindUsers(query string, limit, offset int) ([]schema.User, error)
+	GetUser(username string) (schema.User, error)
+	Adjust(username string, sum float64, reason, admin string) (schema.Adjustment, error)
+	SetBlocked(username string, blocked bool, reason, admin string) error
+	AddAdminAction(action schema.AdminAction) error
+	GetAdminActions(target string, limit, offset int) ([]schema.AdminAction, error)
+	GetOpeningBalance(username string, before time.Time) (float64, error)
+	GetStatement(username string, from, to time.Time, fn func(schema.StatementEntry) error) error
+	AddOrders(username string, ids []string) (map[string]string, error)
+	CreateCampaign(c schema.Campaign, admin string) (schema.Campaign, error)
+	UpdateCampaign(c schema.Campaign, admin string) (schema.Campaign, error)
+	GetCampaigns() ([]schema.Campaign, error)
+	CreateReferredUser(login, passwd, code string, limit ReferralLimit) error
+	RewardReferral(referred string, bonus float64, maxRewarded int) error
+	RejectReferral(referred string) error
+	GetReferrals(username string) (schema.Referrals, error)
+	AddSession(username string, session schema.Session) error
+	Export(username string) (schema.Export, error)
+	DeleteUser(username string, policy DeletionPolicy) (schema.Deletion, error)
+	GetTwoFactor(username string) (TwoFactor, error)
+	SetTwoFactor(username, secret string, recoveryHashes []string) error
+	EnableTwoFactor(username string, step int64) error
+	DisableTwoFactor(username string) error
+	UseTOTPStep(username string, step int64) error
+	UseRecoveryCode(username, hash string) error
+	GetTokenGeneration(username string) (int64, error)
+	ChangePassword(username, passwd string) (int64, error)
+	CreatePasswordReset(username, hash string, expiresAt time.Time) error
+	ResetPassword(hash, passwd string, now time.Time) (string, int64, error)
+	AddSecurityEvent(e schema.SecurityEvent) error
+	GetSecurityEvents(f SecurityEventFilter, limit, offset int) ([]schema.SecurityEvent, error)
+	AddRiskActivity(username string, a RiskActivity) error
+	GetRiskActivity(username string, since time.Time) (RiskActivity, error)
+	AddRiskFlag(flag schema.RiskFlag, since time.Time) (bool, error)
+	GetRiskFlags(login string, limit, offset int) ([]schema.RiskFlag, error)
+	GetThrottledUntil(username string) (time.Time, error)
+	GetProcessedOrders(since time.Time) ([]ProcessedOrder, error)
+	SetReported(id string, reported float64) error
+	SetMultiplier(id string, multiplier float64) error
+	CorrectAccrual(username, id string, reported, sum float64, reason, admin string) (schema.Adjustment, error)
+	AddReconciliation(r schema.Reconciliation) (schema.Reconciliation, error)
+	GetReconciliations(limit, offset int) ([]schema.Reconciliation, error)
+	GetReconciliation(id int64) (schema.Reconciliation, error)
+	CheckBalances(repair bool, admin string) (schema.BalanceCheck, error)
+	// ForProgram returns the storage of the program sharing the connection.
+	ForProgram(id string) IStorage
 }
 
 type Storage struct {
 	DB *sql.DB
+	// Program scopes every query except ExpirePoints.
+	Program string
 }
 
 type Orders []schema.UserOrder
@@ -36,13 +95,176 @@ var ErrBadID = errors.New("wrong id format")
 var ErrNoResult = errors.New("the user has no orders")
 var ErrNotEnoughMoney = errors.New("insufficient funds for payment")
 var ErrNoWithdrawals = errors.New("user don't have withdrawals operations")
+var ErrUserNotFound = errors.New("user not found")
+var ErrTransferLimit = errors.New("transfer limit exceeded")
+var ErrBadTransfer = errors.New("bad transfer")
+var ErrTransferNotFound = errors.New("pending transfer not found")
+var ErrNoTransfers = errors.New("user don't have transfers")
+var ErrUserBlocked = errors.New("user is blocked")
+var ErrBadAdjustment = errors.New("adjustment requires a non-zero sum and a reason")
+var ErrBadStatement = errors.New("bad statement period or format")
+var ErrBadBatch = errors.New("bad number of orders in the batch")
+var ErrBadCampaign = errors.New("campaign requires a name, a positive multiplier, a non-negative bonus and a valid period")
+var ErrCampaignNotFound = errors.New("campaign not found")
+var ErrBadReferral = errors.New("unknown referral code")
+var ErrReferralLimit = errors.New("referral limit exceeded")
+var ErrBalanceRemaining = errors.New("the account still has points")
+var ErrPendingTransfers = errors.New("the account has pending transfers")
+var ErrBadDeletionPolicy = errors.New("deletion mode must be anonymize or delete and balance policy must be refuse or forfeit")
+var ErrTwoFactorRequired = errors.New("two-factor code required")
+var ErrWrongTwoFactorCode = errors.New("wrong two-factor code")
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+var ErrTwoFactorDisabled = errors.New("two-factor authentication is not enabled")
+var ErrTokenRevoked = errors.New("the token was revoked")
+var ErrBadPassword = errors.New("password must not be empty")
+var ErrBadResetToken = errors.New("invalid or expired password reset token")
+var ErrReconciliationNotFound = errors.New("reconciliation not found")
+var ErrWithdrawalExists = errors.New("another sum was already withdrawn for the order")
+var ErrPollQueueFull = errors.New("too many orders are waiting for the accrual system, try again later")
+
+// CreditSourceAccrual marks points credited for a processed order.
+const CreditSourceAccrual = "accrual"
+
+// CreditSourceAdjustment marks points credited manually by an admin.
+const CreditSourceAdjustment = "adjustment"
+
+// CreditSourceTransfer marks points received from another user or returned after a declined transfer.
+const CreditSourceTransfer = "transfer"
+
+// CreditSourceReferral marks the bonus credited to both users of a rewarded referral.
+const CreditSourceReferral = "referral"
+
+// Results of uploading an order in a batch.
+const (
+	OrderAccepted           = "accepted"
+	OrderDuplicate          = "duplicate"
+	OrderOwnedByAnotherUser = "owned_by_another_user"
+	OrderInvalid            = "invalid"
+)
+
+const (
+	TransferPending   = "PENDING"
+	TransferCompleted = "COMPLETED"
+	TransferDeclined  = "DECLINED"
+)
+
+// TransferLimit caps the sum the sender can transfer since the given moment, zero Max means no limit.
+type TransferLimit struct {
+	Since time.Time
+	Max   float64
+}
+
+// WithdrawalLimit is the withdrawal policy checked under the lock of the user,
+// so concurrent withdrawals can't exceed the caps together. OrderTotal is the value of the order.
+type WithdrawalLimit struct {
+	Policy     withdrawal.Policy
+	OrderTotal float64
+}
+
+const (
+	ReferralPending  = "PENDING"
+	ReferralRewarded = "REWARDED"
+	ReferralRejected = "REJECTED"
+)
+
+// ReferralLimit caps the number of users the referrer can invite since the given moment, zero Max means no limit.
+type ReferralLimit struct {
+	Since time.Time
+	Max   int
+}
+
+// Deletion modes. DeletionAnonymize keeps the orders, withdrawals and credits of the user under an alias
+// for accounting, DeletionDelete removes them. The transfers and referrals other users see
+// are kept under the alias in both modes.
+const (
+	DeletionAnonymize = "anonymize"
+	DeletionDelete    = "delete"
+)
+
+// Balance policies of the deletion, BalanceRefuse keeps the account while it has points,
+// BalanceForfeit writes them off.
+const (
+	BalanceRefuse  = "refuse"
+	BalanceForfeit = "forfeit"
+)
+
+type DeletionPolicy struct {
+	Mode    string
+	Balance string
+}
+
+func (p DeletionPolicy) Validate() error {
+	if (p.Mode != DeletionAnonymize && p.Mode != DeletionDelete) || (p.Balance != BalanceRefuse && p.Balance != BalanceForfeit) {
+		return ErrBadDeletionPolicy
+	}
+
+	return nil
+}
+
+// TwoFactor is the TOTP state of the user, the secret is pending until Enabled.
+// Step is the last accepted period, the codes of it and the earlier ones are rejected.
+type TwoFactor struct {
+	Secret  string
+	Enabled bool
+	Step    int64
+}
+
+// Security events, the failed attempts are recorded with Success false.
+const (
+	EventRegistration   = "registration"
+	EventLogin          = "login"
+	EventTokenIssued    = "token_issued"
+	EventWithdrawal     = "withdrawal"
+	EventAdjustment     = "adjustment"
+	EventPasswordChange = "password_change"
+	EventPasswordReset  = "password_reset"
+)
+
+// SecurityEventFilter selects the security events, the empty fields match any event.
+// To is exclusive.
+type SecurityEventFilter struct {
+	Login   string
+	Event   string
+	IP      string
+	Success *bool
+	From    time.Time
+	To      time.Time
+}
+
+// RiskActivity counts the uploads and the withdrawals the risk rules judge,
+// Invalid and Foreign are the parts of Uploads that fail the Luhn check or belong to other users.
+type RiskActivity struct {
+	Uploads     int
+	Invalid     int
+	Foreign     int
+	Withdrawals int
+	Withdrawn   float64
+}
+
+// ProcessedOrder is an order the reconciliation compares with the accrual system,
+// Reported is nil for the orders processed before the reported accruals were stored.
+type ProcessedOrder struct {
+	Number   string
+	Owner    string
+	Accrual  float64
+	Reported *float64
+	// Multiplier is the tier multiplier applied to the accrual, nil for the orders processed before it was stored.
+	Multiplier *float64
+}
 
 //var ErrWrongOrderID = errors.New("wrong order id")
 
+// DriverMemory selects the in-memory storage.
+const DriverMemory = "memory"
+
 type Config struct {
+	// DriverName is "postgres" or DriverMemory.
 	DriverName     string
 	DataSourceCred string
 	Name           string
+	// MigrateOnStart applies the migrations in Init,
+	// otherwise Init fails unless the schema is at the latest version.
+	MigrateOnStart bool
 }
 
 func Init(cfg *Config) (IStorage, error) {
@@ -50,35 +272,45 @@ func Init(cfg *Config) (IStorage, error) {
 		panic("конфигурация задана некорректно")
 	}
 
+	if cfg.DriverName == DriverMemory {
+		return NewMemory(), nil
+	}
+
 	db, err := sql.Open("postgres", cfg.DataSourceCred)
 	if err != nil {
 		return nil, err
 	}
 
-	return New(db, "file://internal/loyalty/storage/migrations"), nil
-}
-
-func New(db *sql.DB, pathToMigrations string) IStorage {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	err = prepareSchema(db, cfg.MigrateOnStart)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		db.Close()
+		return nil, err
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		pathToMigrations,
-		"gomarket", driver)
+	return New(db), nil
+}
+
+func prepareSchema(db *sql.DB, migrateOnStart bool) error {
+	m, err := NewMigrator(db)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return err
 	}
+	defer m.Close()
 
-	err = m.Up()
-	if err != nil {
-		if err.Error() != "no change" {
-			log.Fatal(err)
+	if migrateOnStart {
+		err = m.Up()
+		if err != nil {
+			return err
 		}
 	}
 
-	return Storage{DB: db}
+	return m.Check()
+}
+
+func New(db *sql.DB) IStorage {
+	return Storage{DB: db, Program: program.DefaultID}
+}
+
+func (s Storage) ForProgram(id string) IStorage {
+	return Storage{DB: s.DB, Program: id}
 }
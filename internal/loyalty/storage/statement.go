@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"gomarket/internal/loyalty/schema"
+	"time"
+)
+
+func (s Storage) GetOpeningBalance(username string, before time.Time) (float64, error) {
+	prepare, err := s.DB.Prepare(getOpeningBalance)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance float64
+	return balance, prepare.QueryRow(username, s.Program, before).Scan(&balance)
+}
+
+// GetStatement calls fn for every balance movement in [from, to) in chronological order.
+// Rows are passed as they are read, so the statement is never held in memory.
+func (s Storage) GetStatement(username string, from, to time.Time, fn func(schema.StatementEntry) error) error {
+	prepare, err := s.DB.Prepare(getStatement)
+	if err != nil {
+		return err
+	}
+
+	rows, err := prepare.Query(username, s.Program, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry schema.StatementEntry
+		err = rows.Scan(&entry.Date, &entry.Type, &entry.Reference, &entry.Amount)
+		if err != nil {
+			return err
+		}
+
+		err = fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -3,21 +3,32 @@ package storage
 import (
 	"database/sql"
 	"errors"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgerrcode"
 	"github.com/lib/pq"
 	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/withdrawal"
 	"log"
-	"sync"
+	"math"
+	"time"
 )
 
 func (s Storage) CreateUser(login, passwd string) error {
-	prepare, err := s.DB.Prepare(createUser)
+	return s.insertUser(s.DB, login, passwd)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// insertUser adds the user with a new referral code. A login deleted before starts
+// at the token generation after the last one of the deleted account.
+func (s Storage) insertUser(db execer, login, passwd string) error {
+	code, err := newReferralCode()
 	if err != nil {
 		return err
 	}
 
-	_, err = prepare.Exec(login, passwd)
+	_, err = db.Exec(createUser, login, passwd, code, s.Program, userHash(s.Program, login))
 	if err == nil {
 		return nil
 	}
@@ -41,17 +52,21 @@ func (s Storage) CheckPassword(login, passwd string) error {
 		return err
 	}
 
-	row := prepare.QueryRow(login, passwd)
+	row := prepare.QueryRow(login, passwd, s.Program)
 	if row.Err() != nil {
 		return err
 	}
 
-	var isValidPassword bool
-	err = row.Scan(&isValidPassword)
+	var blocked bool
+	err = row.Scan(&blocked)
 	if errors.Is(err, sql.ErrNoRows) {
 		return ErrWrongPassword
 	}
 
+	if err == nil && blocked {
+		return ErrUserBlocked
+	}
+
 	return err
 }
 
@@ -61,7 +76,7 @@ func (s Storage) CheckID(username, id string) error {
 		return err
 	}
 
-	_, err = prepare.Exec(id, username)
+	_, err = prepare.Exec(id, username, s.Program)
 	if err == nil {
 		return nil
 	}
@@ -79,7 +94,7 @@ func (s Storage) CheckID(username, id string) error {
 		}
 
 		var owner string
-		row := prepareSecondQuery.QueryRow(id)
+		row := prepareSecondQuery.QueryRow(id, s.Program)
 
 		err = row.Scan(&owner)
 		if err != nil {
@@ -102,7 +117,7 @@ func (s Storage) GetOrders(username string) (Orders, error) {
 		return nil, err
 	}
 
-	rows, err := prepare.Query(username)
+	rows, err := prepare.Query(username, s.Program)
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +143,15 @@ func (s Storage) GetOrders(username string) (Orders, error) {
 		return nil, ErrNoResult
 	}
 
+	campaigns, err := s.getOrderCampaigns(username)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		orders[i].Campaigns = campaigns[orders[i].Number]
+	}
+
 	return orders, nil
 }
 
@@ -137,20 +161,21 @@ func (s Storage) GetBalance(username string) (schema.Balance, error) {
 		return schema.Balance{}, err
 	}
 
-	row := prepare.QueryRow(username)
+	row := prepare.QueryRow(username, s.Program)
 
 	var balance schema.Balance
-	return balance, row.Scan(&balance.Current, &balance.Withdrawn)
+	return balance, row.Scan(&balance.Current, &balance.Withdrawn, &balance.Tier)
 }
 
-func (s Storage) UpdateOrder(username, id, status string, accrual float64) error {
+// UpdateOrder sets the status of the order and credits the accrual with the applied campaigns.
+func (s Storage) UpdateOrder(username, id, status string, accrual float64, campaigns []schema.AppliedCampaign) error {
 	if accrual == 0 {
 		prepare, err := s.DB.Prepare(changeOrerWithoutAccrual)
 		if err != nil {
 			return err
 		}
 
-		_, err = prepare.Exec(status, id)
+		_, err = prepare.Exec(status, id, s.Program)
 		if err != nil {
 			return err
 		}
@@ -158,85 +183,302 @@ func (s Storage) UpdateOrder(username, id, status string, accrual float64) error
 		return nil
 	}
 
-	prepare, err := s.DB.Prepare(changeOrer)
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = prepare.Exec(accrual, status, id)
+	_, err = tx.Exec(changeOrer, accrual, status, id, s.Program)
 	if err != nil {
 		return err
 	}
 
-	prepareBalance, err := s.DB.Prepare(updateBalance)
+	_, err = tx.Exec(updateBalance, accrual, username, s.Program)
 	if err != nil {
 		return err
 	}
 
-	_, err = prepareBalance.Exec(accrual, username)
-	return err
+	_, err = tx.Exec(addCredit, username, CreditSourceAccrual, id, accrual, s.Program)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range campaigns {
+		_, err = tx.Exec(addOrderCampaign, id, c.ID, c.Bonus, s.Program)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-var usersBlock = make(map[string]*sync.Mutex)
+func (s Storage) Withdraw(username string, amount float64, orderID string, limit WithdrawalLimit) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-func (s Storage) Withdraw(username string, amount float64, orderID string) error {
-	if _, ok := usersBlock[username]; !ok {
-		usersBlock[username] = &sync.Mutex{}
+	// the row lock serializes concurrent balance operations of the same user
+	var balance float64
+	var blocked bool
+	err = tx.QueryRow(lockUser, username, s.Program).Scan(&balance, &blocked)
+	if err != nil {
+		return err
 	}
 
-	usersBlock[username].Lock()
-	defer usersBlock[username].Unlock()
+	if blocked {
+		return ErrUserBlocked
+	}
 
-	prepare, err := s.DB.Prepare(checkBalance)
-	if err != nil {
+	// a retried withdrawal of the order is a replay, so the clients can retry on a lost response
+	var withdrawn float64
+	err = tx.QueryRow(getOrderWithdrawal, username, orderID, s.Program).Scan(&withdrawn)
+	if err == nil {
+		return sameWithdrawal(withdrawn, amount)
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
 
-	row := prepare.QueryRow(amount, username) //, orderID)
-	if row.Err() != nil {
+	usage, err := s.withdrawalUsage(tx, username, balance, limit.Policy)
+	if err != nil {
 		return err
 	}
 
-	var isEnoughMoney bool
-	err = row.Scan(&isEnoughMoney)
+	err = limit.Policy.Check(amount, limit.OrderTotal, usage)
 	if err != nil {
 		return err
 	}
 
-	if !isEnoughMoney {
+	if balance < amount {
 		return ErrNotEnoughMoney
 	}
 
-	prepareDraw, err := s.DB.Prepare(drawBonuses)
+	_, err = s.spendCredits(tx, username, amount)
 	if err != nil {
 		return err
 	}
 
-	_, err = prepareDraw.Exec(amount, username)
+	_, err = tx.Exec(drawBonuses, amount, username, s.Program)
 	if err != nil {
 		return err
 	}
 
-	prepareStageDraw, err := s.DB.Prepare(stageDraw)
+	_, err = tx.Exec(stageDraw, username, orderID, amount, s.Program)
 	if err != nil {
 		return err
 	}
 
-	_, err = prepareStageDraw.Exec(username, orderID, amount)
-	if err != nil {
-		return err
+	return tx.Commit()
+}
+
+// sameWithdrawal returns nil if the replayed withdrawal has the sum of the made one.
+func sameWithdrawal(withdrawn, amount float64) error {
+	if math.Abs(withdrawn-amount) >= balanceTolerance {
+		return ErrWithdrawalExists
 	}
 
 	return nil
 }
 
+// withdrawalUsage reads the usage of the enabled rules of the policy, the user row must be locked by the caller.
+func (s Storage) withdrawalUsage(tx *sql.Tx, username string, balance float64, p withdrawal.Policy) (withdrawal.Usage, error) {
+	now := time.Now()
+	usage := withdrawal.Usage{Balance: balance}
+	if p.DailyMax > 0 {
+		err := tx.QueryRow(getWithdrawnSince, username, now.Add(-24*time.Hour), s.Program).Scan(&usage.Day)
+		if err != nil {
+			return withdrawal.Usage{}, err
+		}
+	}
+
+	if p.MonthlyMax > 0 {
+		err := tx.QueryRow(getWithdrawnSince, username, withdrawal.MonthStart(now), s.Program).Scan(&usage.Month)
+		if err != nil {
+			return withdrawal.Usage{}, err
+		}
+	}
+
+	if p.CoolingOff > 0 {
+		err := tx.QueryRow(getFreshPoints, username, now.Add(-p.CoolingOff), s.Program).Scan(&usage.Fresh)
+		if err != nil {
+			return withdrawal.Usage{}, err
+		}
+	}
+
+	return usage, nil
+}
+
+// spendCredits consumes user's credits in FIFO order, so the oldest points go first.
+// It returns the spent parts of the credits. The user row must be locked by the caller.
+func (s Storage) spendCredits(tx *sql.Tx, username string, amount float64) ([]schema.Credit, error) {
+	credits, err := queryCredits(tx, getSpendableCredits, username, s.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := make([]schema.Credit, 0)
+	for _, credit := range credits {
+		if amount <= 0 {
+			break
+		}
+
+		part := math.Min(credit.Remaining, amount)
+		_, err = tx.Exec(spendCredit, part, credit.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		credit.Remaining = part
+		spent = append(spent, credit)
+		amount -= part
+	}
+
+	return spent, nil
+}
+
+func queryCredits(tx *sql.Tx, query string, args ...any) ([]schema.Credit, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCredits(rows)
+}
+
+func scanCredits(rows *sql.Rows) ([]schema.Credit, error) {
+	credits := make([]schema.Credit, 0)
+	for rows.Next() {
+		var credit schema.Credit
+		err := rows.Scan(&credit.ID, &credit.Remaining, &credit.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		credits = append(credits, credit)
+	}
+
+	return credits, rows.Err()
+}
+
+func (s Storage) GetExpiringCredits(username string, before time.Time) ([]schema.Credit, error) {
+	prepare, err := s.DB.Prepare(getExpiringCredits)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := prepare.Query(username, before, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCredits(rows)
+}
+
+type expiredOwner struct {
+	program  string
+	username string
+}
+
+// ExpirePoints expires the points in every program.
+func (s Storage) ExpirePoints(before time.Time) (float64, error) {
+	prepare, err := s.DB.Prepare(getOwnersWithExpiredCredits)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := prepare.Query(before)
+	if err != nil {
+		return 0, err
+	}
+
+	owners := make([]expiredOwner, 0)
+	for rows.Next() {
+		var owner expiredOwner
+		err = rows.Scan(&owner.program, &owner.username)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		owners = append(owners, owner)
+	}
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, owner := range owners {
+		expired, err := Storage{DB: s.DB, Program: owner.program}.expireUserPoints(owner.username, before)
+		if err != nil {
+			return total, err
+		}
+
+		total += expired
+	}
+
+	return total, nil
+}
+
+func (s Storage) expireUserPoints(username string, before time.Time) (float64, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// the user is locked before the credits, the same order as in Withdraw
+	var balance float64
+	var blocked bool
+	err = tx.QueryRow(lockUser, username, s.Program).Scan(&balance, &blocked)
+	if err != nil {
+		return 0, err
+	}
+
+	credits, err := queryCredits(tx, lockExpiredCredits, username, before, s.Program)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired float64
+	for _, credit := range credits {
+		_, err = tx.Exec(spendCredit, credit.Remaining, credit.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(stageExpiration, username, credit.ID, credit.Remaining, s.Program)
+		if err != nil {
+			return 0, err
+		}
+
+		expired += credit.Remaining
+	}
+
+	_, err = tx.Exec(writeOffBalance, expired, username, s.Program)
+	if err != nil {
+		return 0, err
+	}
+
+	return expired, tx.Commit()
+}
+
 func (s Storage) GetWithdrawals(username string) ([]schema.Withdrawn, error) {
 	prepare, err := s.DB.Prepare(getWithdrawals)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := prepare.Query(username)
+	rows, err := prepare.Query(username, s.Program)
 	if err != nil {
 		return nil, err
 	}
@@ -263,3 +505,56 @@ func (s Storage) GetWithdrawals(username string) ([]schema.Withdrawn, error) {
 
 	return withdrawals, nil
 }
+
+// GetAccrued sums the accruals the calculation system reported for the orders of the user processed after since.
+// The orders processed before the reported accruals were kept count with their points.
+func (s Storage) GetAccrued(username string, since time.Time) (float64, error) {
+	prepare, err := s.DB.Prepare(getAccrued)
+	if err != nil {
+		return 0, err
+	}
+
+	var accrued float64
+	return accrued, prepare.QueryRow(username, CreditSourceAccrual, since, s.Program).Scan(&accrued)
+}
+
+func (s Storage) GetWithdrawnSince(username string, since time.Time) (float64, error) {
+	var withdrawn float64
+	return withdrawn, s.DB.QueryRow(getWithdrawnSince, username, since, s.Program).Scan(&withdrawn)
+}
+
+// GetFreshPoints returns the unspent part of the points credited after since.
+func (s Storage) GetFreshPoints(username string, since time.Time) (float64, error) {
+	var fresh float64
+	return fresh, s.DB.QueryRow(getFreshPoints, username, since, s.Program).Scan(&fresh)
+}
+
+func (s Storage) ChangeTier(username, tier string, accrued float64) (bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRow(lockTier, username, s.Program).Scan(&previous)
+	if err != nil {
+		return false, err
+	}
+
+	if previous == tier {
+		return false, nil
+	}
+
+	_, err = tx.Exec(setTier, tier, username, s.Program)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(stageTierChange, username, tier, previous, accrued, s.Program)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
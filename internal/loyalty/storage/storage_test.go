@@ -10,6 +10,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 var TestDB Storage
@@ -35,7 +36,7 @@ func TestMain(m *testing.M) {
 		return
 	}
 
-	TestDB = New(vdb.DB, "file://migrations").(Storage)
+	TestDB = New(vdb.DB).(Storage)
 
 	queries := []string{
 		"DROP SCHEMA public CASCADE;",
@@ -61,7 +62,12 @@ func TestMain(m *testing.M) {
 		log.Fatal(err)
 	}
 
-	TestDB = New(vdb.DB, "file://migrations").(Storage)
+	err = prepareSchema(vdb.DB, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	TestDB = New(vdb.DB).(Storage)
 	// Run tests
 	exitVal := m.Run()
 
@@ -300,7 +306,7 @@ func TestStorage_Withdraw(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := TestDB.Withdraw(tt.args.username, tt.args.amount, tt.args.orderID); (err != nil) != tt.err.want {
+			if err := TestDB.Withdraw(tt.args.username, tt.args.amount, tt.args.orderID, WithdrawalLimit{}); (err != nil) != tt.err.want {
 				t.Errorf("Withdraw() error = %v, \nwantErr %v", err, tt.err.want)
 			} else if tt.err.want && !errors.Is(err, tt.err.Error) {
 				t.Errorf("Withdraw() error = %v, wantErr %v", err, tt.err.Error)
@@ -377,7 +383,7 @@ func TestStorage_UpdateOrder(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := TestDB.UpdateOrder(tt.args.username, tt.args.id, tt.args.status, tt.args.accrual); (err != nil) != tt.err.want {
+			if err := TestDB.UpdateOrder(tt.args.username, tt.args.id, tt.args.status, tt.args.accrual, nil); (err != nil) != tt.err.want {
 				t.Errorf("UpdateOrder() error = %v, \nwantErr %v", err, tt.err.want)
 			} else if tt.err.want && !errors.Is(err, tt.err.Error) {
 				t.Errorf("UpdateOrder() error = %v, wantErr %v", err, tt.err.Error)
@@ -385,3 +391,547 @@ func TestStorage_UpdateOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_GetExpiringCredits(t *testing.T) {
+	type args struct {
+		username string
+		before   time.Time
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []float64
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			args: args{"admin", time.Now().Add(time.Hour)},
+			want: []float64{500},
+		},
+		{
+			name: "not expiring yet",
+			args: args{"admin", time.Now().Add(-time.Hour)},
+			want: []float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TestDB.GetExpiringCredits(tt.args.username, tt.args.before)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetExpiringCredits() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			sums := make([]float64, 0, len(got))
+			for _, credit := range got {
+				sums = append(sums, credit.Remaining)
+			}
+
+			if !reflect.DeepEqual(sums, tt.want) {
+				t.Errorf("GetExpiringCredits() got = %v, want %v", sums, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorage_ExpirePoints(t *testing.T) {
+	before, err := TestDB.GetBalance("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := TestDB.ExpirePoints(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ExpirePoints() error = %v", err)
+	}
+
+	if expired != 500 {
+		t.Errorf("ExpirePoints() got = %v, want %v", expired, 500)
+	}
+
+	after, err := TestDB.GetBalance("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before.Current-after.Current != 500 {
+		t.Errorf("ExpirePoints() balance changed by %v, want %v", before.Current-after.Current, 500)
+	}
+
+	expired, err = TestDB.ExpirePoints(time.Now().Add(time.Hour))
+	if err != nil || expired != 0 {
+		t.Errorf("ExpirePoints() second run got = %v, %v, want 0, nil", expired, err)
+	}
+}
+
+func TestStorage_GetAccrued(t *testing.T) {
+	type args struct {
+		username string
+		since    time.Time
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{
+			name: "ok",
+			args: args{"admin", time.Time{}},
+			want: 500,
+		},
+		{
+			name: "outside of the window",
+			args: args{"admin", time.Now().Add(time.Hour)},
+			want: 0,
+		},
+		{
+			name: "no accruals",
+			args: args{"admin2", time.Time{}},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TestDB.GetAccrued(tt.args.username, tt.args.since)
+			if err != nil {
+				t.Errorf("GetAccrued() error = %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("GetAccrued() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorage_ChangeTier(t *testing.T) {
+	type args struct {
+		username string
+		tier     string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bool
+		wantErr error
+	}{
+		{
+			name: "upgrade",
+			args: args{"admin", "Silver"},
+			want: true,
+		},
+		{
+			name: "same tier",
+			args: args{"admin", "Silver"},
+			want: false,
+		},
+		{
+			name:    "bad user",
+			args:    args{"admin1", "Silver"},
+			wantErr: sql.ErrNoRows,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TestDB.ChangeTier(tt.args.username, tt.args.tier, 500)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ChangeTier() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ChangeTier() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorage_CreateTransfer(t *testing.T) {
+	err := TestDB.CreateUser("admin2", "admin2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		recipient string
+		sum       float64
+		pending   bool
+		limit     TransferLimit
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantStatus string
+		wantErr    error
+	}{
+		{
+			name:       "ok",
+			args:       args{recipient: "admin2", sum: 10},
+			wantStatus: TransferCompleted,
+		},
+		{
+			name:       "pending",
+			args:       args{recipient: "admin2", sum: 5, pending: true},
+			wantStatus: TransferPending,
+		},
+		{
+			name:    "not enough money",
+			args:    args{recipient: "admin2", sum: 1000},
+			wantErr: ErrNotEnoughMoney,
+		},
+		{
+			name:    "recipient not found",
+			args:    args{recipient: "admin1567", sum: 1},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:    "limit exceeded",
+			args:    args{recipient: "admin2", sum: 10, limit: TransferLimit{Max: 20}},
+			wantErr: ErrTransferLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TestDB.CreateTransfer("admin", tt.args.recipient, tt.args.sum, tt.args.pending, tt.args.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateTransfer() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got.Status != tt.wantStatus {
+				t.Errorf("CreateTransfer() status = %v, want %v", got.Status, tt.wantStatus)
+			}
+		})
+	}
+
+	balance, err := TestDB.GetBalance("admin2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Current != 10 {
+		t.Errorf("CreateTransfer() recipient balance = %v, want %v", balance.Current, 10)
+	}
+}
+
+func TestStorage_CompleteTransfer(t *testing.T) {
+	transfers, err := TestDB.GetTransfers("admin2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// transfers are sorted from the newest one
+	pending := transfers[0].ID
+
+	tests := []struct {
+		name     string
+		username string
+		wantErr  error
+	}{
+		{
+			name:     "sender can't accept",
+			username: "admin",
+			wantErr:  ErrTransferNotFound,
+		},
+		{
+			name:     "ok",
+			username: "admin2",
+		},
+		{
+			name:     "already completed",
+			username: "admin2",
+			wantErr:  ErrTransferNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TestDB.CompleteTransfer(pending, tt.username, true)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CompleteTransfer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	balance, err := TestDB.GetBalance("admin2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Current != 15 {
+		t.Errorf("CompleteTransfer() recipient balance = %v, want %v", balance.Current, 15)
+	}
+}
+
+func TestStorage_GetTransfers(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     int
+		wantErr  error
+	}{
+		{
+			name:     "ok",
+			username: "admin",
+			want:     2,
+		},
+		{
+			name:     "no res",
+			username: "admin1567",
+			wantErr:  ErrNoTransfers,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TestDB.GetTransfers(tt.username)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetTransfers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if len(got) != tt.want {
+				t.Errorf("GetTransfers() got %v transfers, want %v", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestStorage_Adjust(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		sum      float64
+		wantErr  error
+	}{
+		{
+			name:     "credit",
+			username: "admin2",
+			sum:      10,
+		},
+		{
+			name:     "debit",
+			username: "admin2",
+			sum:      -5,
+		},
+		{
+			name:     "not enough money",
+			username: "admin2",
+			sum:      -1000,
+			wantErr:  ErrNotEnoughMoney,
+		},
+		{
+			name:     "no user",
+			username: "admin1567",
+			sum:      10,
+			wantErr:  ErrUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TestDB.Adjust(tt.username, tt.sum, "test", "support")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Adjust() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	balance, err := TestDB.GetBalance("admin2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Current != 20 {
+		t.Errorf("Adjust() balance = %v, want %v", balance.Current, 20)
+	}
+}
+
+func TestStorage_SetBlocked(t *testing.T) {
+	err := TestDB.SetBlocked("admin1567", true, "test", "support")
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetBlocked() error = %v, wantErr %v", err, ErrUserNotFound)
+	}
+
+	err = TestDB.SetBlocked("admin2", true, "test", "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = TestDB.CheckPassword("admin2", "admin2")
+	if !errors.Is(err, ErrUserBlocked) {
+		t.Errorf("CheckPassword() error = %v, wantErr %v", err, ErrUserBlocked)
+	}
+
+	err = TestDB.SetBlocked("admin2", false, "test", "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocked, err := TestDB.IsBlocked("admin2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blocked {
+		t.Errorf("IsBlocked() = %v, want %v", blocked, false)
+	}
+}
+
+func TestStorage_GetAdminActions(t *testing.T) {
+	actions, err := TestDB.GetAdminActions("admin2", 50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two adjustments, block and unblock
+	if len(actions) != 4 {
+		t.Errorf("GetAdminActions() got %v actions, want %v", len(actions), 4)
+	}
+}
+
+func TestStorage_GetStatement(t *testing.T) {
+	from := time.Now().Add(-time.Hour)
+	to := time.Now().Add(time.Hour)
+
+	opening, err := TestDB.GetOpeningBalance("admin2", from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closing := opening
+	err = TestDB.GetStatement("admin2", from, to, func(entry schema.StatementEntry) error {
+		closing += entry.Amount
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance, err := TestDB.GetBalance("admin2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if float32(closing) != balance.Current {
+		t.Errorf("GetStatement() closing balance = %v, want %v", closing, balance.Current)
+	}
+}
+
+func TestStorage_GetStatementMigrated(t *testing.T) {
+	err := TestDB.CreateUser("migrated", "migrated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the user withdrew 30 of 100 before 00002, which credited the remaining 70
+	queries := []string{
+		`INSERT INTO "Orders" ("UID", "Owner", "Date", "Status", "Accrual") VALUES ('4532015112830366', 'migrated', now() - interval '3 days', 'PROCESSED', 100)`,
+		`INSERT INTO Withdrawals ("Client", "ID", "Sum", "Date") VALUES ('migrated', '79927398713', 30, now() - interval '2 days')`,
+		`INSERT INTO "Credits" ("Owner", "Source", "Reference", "Amount", "Remaining", "Date") VALUES ('migrated', 'migration', '', 70, 70, now() - interval '1 day')`,
+		`UPDATE "Users" SET "Balance" = 70, "Withdrawn" = 30 WHERE "Name" = 'migrated'`,
+	}
+	for _, query := range queries {
+		_, err = TestDB.DB.Exec(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opening, err := TestDB.GetOpeningBalance("migrated", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opening != 70 {
+		t.Errorf("GetOpeningBalance() = %v, want 70", opening)
+	}
+
+	closing := 0.0
+	err = TestDB.GetStatement("migrated", time.Now().Add(-4*24*time.Hour), time.Now(), func(entry schema.StatementEntry) error {
+		closing += entry.Amount
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if closing != 70 {
+		t.Errorf("GetStatement() closing balance = %v, want 70", closing)
+	}
+}
+
+func TestStorage_AddOrders(t *testing.T) {
+	got, err := TestDB.AddOrders("admin2", []string{"1234", "4561261212345467", "12345678903"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"1234":             OrderOwnedByAnotherUser,
+		"4561261212345467": OrderAccepted,
+		"12345678903":      OrderAccepted,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddOrders() got = %v, want %v", got, want)
+	}
+
+	got, err = TestDB.AddOrders("admin2", []string{"12345678903"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got["12345678903"] != OrderDuplicate {
+		t.Errorf("AddOrders() got = %v, want %v", got["12345678903"], OrderDuplicate)
+	}
+}
+
+func TestStorage_CheckBalances(t *testing.T) {
+	err := TestDB.CreateUser("drifted", "drifted")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = TestDB.Adjust("drifted", 40, "test", "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = TestDB.DB.Exec(`UPDATE "Users" SET "Balance" = 55, "Withdrawn" = 3 WHERE "Name" = 'drifted'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := schema.BalanceMismatch{Login: "drifted", Balance: 55, Expected: 40, Withdrawn: 3, ExpectedWithdrawn: 0}
+	for _, repair := range []bool{false, true} {
+		check, err := TestDB.CheckBalances(repair, "support")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found := false
+		for _, m := range check.Mismatches {
+			found = found || m == want
+		}
+
+		if !found {
+			t.Errorf("CheckBalances(%v) mismatches = %+v, want %+v", repair, check.Mismatches, want)
+		}
+	}
+
+	balance, err := TestDB.GetBalance("drifted")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Current != 40 || balance.Withdrawn != 0 {
+		t.Errorf("CheckBalances() repaired balance = %+v, want 40 and 0 withdrawn", balance)
+	}
+
+	check, err := TestDB.CheckBalances(false, "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if check.Mismatched != 0 {
+		t.Errorf("CheckBalances() after the repair = %+v, want no mismatches", check)
+	}
+}
@@ -0,0 +1,12 @@
+package storagetest
+
+import (
+	"gomarket/internal/loyalty/storage"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	Run(t, func(t *testing.T) storage.IStorage {
+		return storage.NewMemory()
+	})
+}
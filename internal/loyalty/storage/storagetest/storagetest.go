@@ -0,0 +1,1258 @@
+// Package storagetest is the conformance suite every storage.IStorage implementation must pass.
+package storagetest
+
+import (
+	"errors"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/withdrawal"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Run runs the suite, newStorage must return an empty storage for every test.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.IStorage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.IStorage)
+	}{
+		{"Users", testUsers},
+		{"Orders", testOrders},
+		{"Batch", testBatch},
+		{"Accrual", testAccrual},
+		{"Withdraw", testWithdraw},
+		{"Expiry", testExpiry},
+		{"Tiers", testTiers},
+		{"Transfers", testTransfers},
+		{"Admin", testAdmin},
+		{"Statement", testStatement},
+		{"Programs", testPrograms},
+		{"Campaigns", testCampaigns},
+		{"Referrals", testReferrals},
+		{"Accounts", testAccounts},
+		{"TwoFactor", testTwoFactor},
+		{"Passwords", testPasswords},
+		{"SecurityEvents", testSecurityEvents},
+		{"Risk", testRisk},
+		{"Reconciliation", testReconciliation},
+		{"Balances", testBalances},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func createUsers(t *testing.T, s storage.IStorage, logins ...string) {
+	t.Helper()
+	for _, login := range logins {
+		if err := s.CreateUser(login, login); err != nil {
+			t.Fatalf("CreateUser(%q) error = %v", login, err)
+		}
+	}
+}
+
+// credit gives the user points for a processed order.
+func credit(t *testing.T, s storage.IStorage, login, order string, sum float64) {
+	t.Helper()
+	if err := s.CheckID(login, order); err != nil {
+		t.Fatalf("CheckID(%q) error = %v", order, err)
+	}
+
+	if err := s.UpdateOrder(login, order, "PROCESSED", sum, nil); err != nil {
+		t.Fatalf("UpdateOrder(%q) error = %v", order, err)
+	}
+}
+
+func wantErr(t *testing.T, name string, got, want error) {
+	t.Helper()
+	if !errors.Is(got, want) {
+		t.Errorf("%s error = %v, want %v", name, got, want)
+	}
+}
+
+func wantBalance(t *testing.T, s storage.IStorage, login string, current, withdrawn float32) {
+	t.Helper()
+	balance, err := s.GetBalance(login)
+	if err != nil {
+		t.Fatalf("GetBalance(%q) error = %v", login, err)
+	}
+
+	if balance.Current != current || balance.Withdrawn != withdrawn {
+		t.Errorf("GetBalance(%q) = %v/%v, want %v/%v", login, balance.Current, balance.Withdrawn, current, withdrawn)
+	}
+}
+
+func testUsers(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+
+	wantErr(t, "CreateUser", s.CreateUser("alice", "other"), storage.ErrUsernameConflict)
+	wantErr(t, "CheckPassword", s.CheckPassword("alice", "alice"), nil)
+	wantErr(t, "CheckPassword", s.CheckPassword("alice", "wrong"), storage.ErrWrongPassword)
+	wantErr(t, "CheckPassword", s.CheckPassword("nobody", "nobody"), storage.ErrWrongPassword)
+	wantBalance(t, s, "alice", 0, 0)
+}
+
+func testOrders(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "bob")
+
+	_, err := s.GetOrders("alice")
+	wantErr(t, "GetOrders", err, storage.ErrNoResult)
+
+	wantErr(t, "CheckID", s.CheckID("alice", "12345678903"), nil)
+	wantErr(t, "CheckID", s.CheckID("alice", "12345678903"), storage.ErrCreatedByThisUser)
+	wantErr(t, "CheckID", s.CheckID("bob", "12345678903"), storage.ErrCreatedByAnotherUser)
+
+	err = s.UpdateOrder("alice", "12345678903", "PROCESSING", 0, nil)
+	if err != nil {
+		t.Fatalf("UpdateOrder() error = %v", err)
+	}
+
+	orders, err := s.GetOrders("alice")
+	if err != nil {
+		t.Fatalf("GetOrders() error = %v", err)
+	}
+
+	if len(orders) != 1 || orders[0].Number != "12345678903" || orders[0].Status != "PROCESSING" {
+		t.Errorf("GetOrders() = %+v, want one PROCESSING order", orders)
+	}
+
+	_, err = s.GetOrders("bob")
+	wantErr(t, "GetOrders", err, storage.ErrNoResult)
+}
+
+func testBatch(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "bob")
+	credit(t, s, "alice", "12345678903", 0)
+	credit(t, s, "bob", "4561261212345467", 0)
+
+	results, err := s.AddOrders("alice", []string{"12345678903", "4561261212345467", "79927398713"})
+	if err != nil {
+		t.Fatalf("AddOrders() error = %v", err)
+	}
+
+	want := map[string]string{
+		"12345678903":      storage.OrderDuplicate,
+		"4561261212345467": storage.OrderOwnedByAnotherUser,
+		"79927398713":      storage.OrderAccepted,
+	}
+	for id, status := range want {
+		if results[id] != status {
+			t.Errorf("AddOrders()[%q] = %q, want %q", id, results[id], status)
+		}
+	}
+
+	wantErr(t, "CheckID", s.CheckID("bob", "79927398713"), storage.ErrCreatedByAnotherUser)
+}
+
+func testAccrual(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+	credit(t, s, "alice", "12345678903", 150.5)
+
+	wantBalance(t, s, "alice", 150.5, 0)
+
+	orders, err := s.GetOrders("alice")
+	if err != nil {
+		t.Fatalf("GetOrders() error = %v", err)
+	}
+
+	if orders[0].Accrual != 150.5 || orders[0].Status != "PROCESSED" {
+		t.Errorf("GetOrders() = %+v, want PROCESSED with 150.5", orders)
+	}
+
+	accrued, err := s.GetAccrued("alice", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetAccrued() error = %v", err)
+	}
+
+	if accrued != 150.5 {
+		t.Errorf("GetAccrued() = %v, want 150.5", accrued)
+	}
+
+	wantErr(t, "SetReported", s.SetReported("12345678903", 100), nil)
+
+	accrued, err = s.GetAccrued("alice", time.Now().Add(-time.Hour))
+	if err != nil || accrued != 100 {
+		t.Errorf("GetAccrued() after SetReported = %v, %v, want the reported 100", accrued, err)
+	}
+}
+
+func testWithdraw(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+	credit(t, s, "alice", "12345678903", 100)
+
+	_, err := s.GetWithdrawals("alice")
+	wantErr(t, "GetWithdrawals", err, storage.ErrNoWithdrawals)
+
+	wantErr(t, "Withdraw", s.Withdraw("alice", 150, "79927398713", storage.WithdrawalLimit{}), storage.ErrNotEnoughMoney)
+	wantErr(t, "Withdraw", s.Withdraw("alice", 40, "79927398713", storage.WithdrawalLimit{}), nil)
+	wantErr(t, "Withdraw", s.Withdraw("alice", 60, "4561261212345467", storage.WithdrawalLimit{}), nil)
+	wantErr(t, "Withdraw", s.Withdraw("alice", 1, "12345678903", storage.WithdrawalLimit{}), storage.ErrNotEnoughMoney)
+	wantBalance(t, s, "alice", 0, 100)
+
+	withdrawals, err := s.GetWithdrawals("alice")
+	if err != nil {
+		t.Fatalf("GetWithdrawals() error = %v", err)
+	}
+
+	if len(withdrawals) != 2 {
+		t.Fatalf("GetWithdrawals() = %+v, want 2 withdrawals", withdrawals)
+	}
+
+	var sum float64
+	for _, withdrawal := range withdrawals {
+		sum += withdrawal.Sum
+	}
+
+	if sum != 100 {
+		t.Errorf("GetWithdrawals() sum = %v, want 100", sum)
+	}
+
+	hourAgo := time.Now().Add(-time.Hour)
+	for since, want := range map[time.Time]float64{hourAgo: 100, time.Now().Add(time.Hour): 0} {
+		withdrawn, err := s.GetWithdrawnSince("alice", since)
+		if err != nil || withdrawn != want {
+			t.Errorf("GetWithdrawnSince(%v) = %v, %v, want %v", since, withdrawn, err, want)
+		}
+	}
+
+	// the spent credit is not fresh anymore
+	credit(t, s, "alice", "5105105105105100", 30)
+	fresh, err := s.GetFreshPoints("alice", hourAgo)
+	if err != nil || fresh != 30 {
+		t.Errorf("GetFreshPoints() = %v, %v, want 30", fresh, err)
+	}
+
+	// the policy is checked with the usage read under the lock of the user
+	limits := []struct {
+		limit storage.WithdrawalLimit
+		want  error
+	}{
+		{storage.WithdrawalLimit{Policy: withdrawal.Policy{DailyMax: 120}}, withdrawal.ErrDailyLimit},
+		{storage.WithdrawalLimit{Policy: withdrawal.Policy{MonthlyMax: 110}}, withdrawal.ErrMonthlyLimit},
+		{storage.WithdrawalLimit{Policy: withdrawal.Policy{CoolingOff: time.Hour}}, withdrawal.ErrCoolingOff},
+		{storage.WithdrawalLimit{Policy: withdrawal.Policy{MaxShare: 0.5}, OrderTotal: 40}, withdrawal.ErrOrderShare},
+	}
+	for _, l := range limits {
+		wantErr(t, "Withdraw", s.Withdraw("alice", 25, "6011000990139424", l.limit), l.want)
+	}
+
+	limit := storage.WithdrawalLimit{Policy: withdrawal.Policy{DailyMax: 130, MaxShare: 0.5}, OrderTotal: 60}
+	wantErr(t, "Withdraw", s.Withdraw("alice", 30, "6011000990139424", limit), nil)
+	wantBalance(t, s, "alice", 0, 130)
+
+	// a retry of the withdrawal is a replay, another sum for the order is refused
+	wantErr(t, "Withdraw", s.Withdraw("alice", 30, "6011000990139424", limit), nil)
+	wantErr(t, "Withdraw", s.Withdraw("alice", 20, "6011000990139424", storage.WithdrawalLimit{}), storage.ErrWithdrawalExists)
+	wantBalance(t, s, "alice", 0, 130)
+}
+
+func testExpiry(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+	credit(t, s, "alice", "12345678903", 30)
+	credit(t, s, "alice", "79927398713", 20)
+
+	if err := s.Withdraw("alice", 40, "4561261212345467", storage.WithdrawalLimit{}); err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+
+	// the oldest credit is spent first
+	credits, err := s.GetExpiringCredits("alice", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetExpiringCredits() error = %v", err)
+	}
+
+	if len(credits) != 1 || credits[0].Remaining != 10 {
+		t.Fatalf("GetExpiringCredits() = %+v, want one credit with 10 left", credits)
+	}
+
+	expired, err := s.ExpirePoints(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ExpirePoints() error = %v", err)
+	}
+
+	if expired != 10 {
+		t.Errorf("ExpirePoints() = %v, want 10", expired)
+	}
+
+	wantBalance(t, s, "alice", 0, 40)
+}
+
+func testTiers(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+
+	for _, want := range []bool{true, false} {
+		changed, err := s.ChangeTier("alice", "Gold", 100)
+		if err != nil {
+			t.Fatalf("ChangeTier() error = %v", err)
+		}
+
+		if changed != want {
+			t.Errorf("ChangeTier() = %v, want %v", changed, want)
+		}
+	}
+
+	user, err := s.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+
+	if user.Tier != "Gold" {
+		t.Errorf("GetUser().Tier = %q, want Gold", user.Tier)
+	}
+
+	balance, err := s.GetBalance("alice")
+	if err != nil || balance.Tier != "Gold" {
+		t.Errorf("GetBalance().Tier = %q, %v, want Gold", balance.Tier, err)
+	}
+}
+
+func testTransfers(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "bob")
+	credit(t, s, "alice", "12345678903", 100)
+
+	_, err := s.GetTransfers("alice")
+	wantErr(t, "GetTransfers", err, storage.ErrNoTransfers)
+
+	_, err = s.CreateTransfer("alice", "nobody", 10, false, storage.TransferLimit{})
+	wantErr(t, "CreateTransfer", err, storage.ErrUserNotFound)
+
+	_, err = s.CreateTransfer("alice", "bob", 150, false, storage.TransferLimit{})
+	wantErr(t, "CreateTransfer", err, storage.ErrNotEnoughMoney)
+
+	transfer, err := s.CreateTransfer("alice", "bob", 30, false, storage.TransferLimit{})
+	if err != nil {
+		t.Fatalf("CreateTransfer() error = %v", err)
+	}
+
+	if transfer.Status != storage.TransferCompleted || transfer.CompletedAt == nil {
+		t.Errorf("CreateTransfer() = %+v, want completed", transfer)
+	}
+
+	limit := storage.TransferLimit{Since: time.Now().Add(-time.Hour), Max: 50}
+	_, err = s.CreateTransfer("alice", "bob", 30, false, limit)
+	wantErr(t, "CreateTransfer", err, storage.ErrTransferLimit)
+
+	declined, err := s.CreateTransfer("alice", "bob", 20, true, storage.TransferLimit{})
+	if err != nil {
+		t.Fatalf("CreateTransfer() error = %v", err)
+	}
+
+	accepted, err := s.CreateTransfer("alice", "bob", 10, true, storage.TransferLimit{})
+	if err != nil {
+		t.Fatalf("CreateTransfer() error = %v", err)
+	}
+
+	wantBalance(t, s, "alice", 40, 0)
+	wantBalance(t, s, "bob", 30, 0)
+
+	_, err = s.CompleteTransfer(declined.ID, "alice", true)
+	wantErr(t, "CompleteTransfer", err, storage.ErrTransferNotFound)
+
+	_, err = s.CompleteTransfer(declined.ID, "alice", false)
+	wantErr(t, "CompleteTransfer", err, nil)
+
+	_, err = s.CompleteTransfer(declined.ID, "bob", true)
+	wantErr(t, "CompleteTransfer", err, storage.ErrTransferNotFound)
+
+	_, err = s.CompleteTransfer(accepted.ID, "bob", true)
+	wantErr(t, "CompleteTransfer", err, nil)
+
+	wantBalance(t, s, "alice", 60, 0)
+	wantBalance(t, s, "bob", 40, 0)
+
+	transfers, err := s.GetTransfers("bob")
+	if err != nil {
+		t.Fatalf("GetTransfers() error = %v", err)
+	}
+
+	if len(transfers) != 3 {
+		t.Errorf("GetTransfers() = %+v, want 3 transfers", transfers)
+	}
+
+	// received points can be spent like own ones
+	wantErr(t, "Withdraw", s.Withdraw("bob", 40, "79927398713", storage.WithdrawalLimit{}), nil)
+}
+
+func testAdmin(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "alina", "bob")
+
+	users, err := s.FindUsers("AL", 10, 0)
+	if err != nil {
+		t.Fatalf("FindUsers() error = %v", err)
+	}
+
+	if len(users) != 2 || users[0].Login != "alice" || users[1].Login != "alina" {
+		t.Errorf("FindUsers() = %+v, want alice and alina", users)
+	}
+
+	users, err = s.FindUsers("", 1, 1)
+	if err != nil {
+		t.Fatalf("FindUsers() error = %v", err)
+	}
+
+	if len(users) != 1 || users[0].Login != "alina" {
+		t.Errorf("FindUsers() = %+v, want alina", users)
+	}
+
+	_, err = s.GetUser("nobody")
+	wantErr(t, "GetUser", err, storage.ErrUserNotFound)
+
+	_, err = s.Adjust("alice", -10, "fix", "support")
+	wantErr(t, "Adjust", err, storage.ErrNotEnoughMoney)
+
+	adjustment, err := s.Adjust("alice", 25, "compensation", "support")
+	if err != nil {
+		t.Fatalf("Adjust() error = %v", err)
+	}
+
+	if adjustment.ID == 0 || adjustment.Admin != "support" {
+		t.Errorf("Adjust() = %+v", adjustment)
+	}
+
+	_, err = s.Adjust("alice", -5, "fix", "support")
+	wantErr(t, "Adjust", err, nil)
+	wantBalance(t, s, "alice", 20, 0)
+
+	wantErr(t, "SetBlocked", s.SetBlocked("nobody", true, "", "support"), storage.ErrUserNotFound)
+	wantErr(t, "SetBlocked", s.SetBlocked("alice", true, "fraud", "support"), nil)
+	wantErr(t, "CheckPassword", s.CheckPassword("alice", "alice"), storage.ErrUserBlocked)
+	wantErr(t, "Withdraw", s.Withdraw("alice", 1, "12345678903", storage.WithdrawalLimit{}), storage.ErrUserBlocked)
+
+	_, err = s.CreateTransfer("alina", "alice", 1, false, storage.TransferLimit{})
+	wantErr(t, "CreateTransfer", err, storage.ErrUserBlocked)
+
+	blocked, err := s.IsBlocked("alice")
+	if err != nil || !blocked {
+		t.Errorf("IsBlocked() = %v, %v, want true", blocked, err)
+	}
+
+	wantErr(t, "SetBlocked", s.SetBlocked("alice", false, "", "support"), nil)
+	wantErr(t, "AddAdminAction", s.AddAdminAction(schema.AdminAction{Admin: "support", Action: "get_user", Target: "bob"}), nil)
+
+	actions, err := s.GetAdminActions("alice", 10, 0)
+	if err != nil {
+		t.Fatalf("GetAdminActions() error = %v", err)
+	}
+
+	wantActions := []string{"unblock", "block", "adjust", "adjust"}
+	if len(actions) != len(wantActions) {
+		t.Fatalf("GetAdminActions() = %+v, want %v", actions, wantActions)
+	}
+
+	for i, action := range actions {
+		if action.Action != wantActions[i] {
+			t.Errorf("GetAdminActions()[%d] = %q, want %q", i, action.Action, wantActions[i])
+		}
+	}
+
+	actions, err = s.GetAdminActions("", 10, 0)
+	if err != nil {
+		t.Fatalf("GetAdminActions() error = %v", err)
+	}
+
+	if len(actions) != 5 || actions[0].Target != "bob" {
+		t.Errorf("GetAdminActions() = %+v, want 5 actions starting with bob", actions)
+	}
+}
+
+func testStatement(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "bob")
+	from := time.Now().Add(-time.Hour)
+
+	credit(t, s, "alice", "12345678903", 100)
+	if err := s.Withdraw("alice", 30, "79927398713", storage.WithdrawalLimit{}); err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+
+	if _, err := s.CreateTransfer("alice", "bob", 20, false, storage.TransferLimit{}); err != nil {
+		t.Fatalf("CreateTransfer() error = %v", err)
+	}
+
+	if _, err := s.Adjust("alice", -10, "fix", "support"); err != nil {
+		t.Fatalf("Adjust() error = %v", err)
+	}
+
+	to := time.Now().Add(time.Hour)
+	opening, err := s.GetOpeningBalance("alice", from)
+	if err != nil {
+		t.Fatalf("GetOpeningBalance() error = %v", err)
+	}
+
+	if opening != 0 {
+		t.Errorf("GetOpeningBalance() = %v, want 0", opening)
+	}
+
+	balance := opening
+	types := make(map[string]int)
+	err = s.GetStatement("alice", from, to, func(entry schema.StatementEntry) error {
+		balance += entry.Amount
+		types[entry.Type]++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GetStatement() error = %v", err)
+	}
+
+	if balance != 40 {
+		t.Errorf("GetStatement() closing balance = %v, want 40", balance)
+	}
+
+	for _, kind := range []string{storage.CreditSourceAccrual, "withdrawal", "transfer", "adjustment"} {
+		if types[kind] != 1 {
+			t.Errorf("GetStatement() has %d %q entries, want 1", types[kind], kind)
+		}
+	}
+
+	closing, err := s.GetOpeningBalance("alice", to)
+	if err != nil {
+		t.Fatalf("GetOpeningBalance() error = %v", err)
+	}
+
+	if closing != balance {
+		t.Errorf("GetOpeningBalance(to) = %v, want %v", closing, balance)
+	}
+}
+
+func testPrograms(t *testing.T, s storage.IStorage) {
+	other := s.ForProgram("other")
+	createUsers(t, s, "alice")
+	createUsers(t, other, "alice")
+	wantErr(t, "CreateUser", other.CreateUser("alice", "other"), storage.ErrUsernameConflict)
+
+	// the same order number is a different order in every program
+	credit(t, s, "alice", "12345678903", 30)
+	credit(t, other, "alice", "12345678903", 10)
+	wantBalance(t, s, "alice", 30, 0)
+	wantBalance(t, other, "alice", 10, 0)
+
+	wantErr(t, "CheckPassword", other.CheckPassword("bob", "bob"), storage.ErrWrongPassword)
+	createUsers(t, s, "bob")
+	wantErr(t, "CheckPassword", other.CheckPassword("bob", "bob"), storage.ErrWrongPassword)
+
+	_, err := other.CreateTransfer("alice", "bob", 5, false, storage.TransferLimit{})
+	wantErr(t, "CreateTransfer", err, storage.ErrUserNotFound)
+
+	if err = other.Withdraw("alice", 4, "4561261212345467", storage.WithdrawalLimit{}); err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+
+	withdrawals, err := s.GetWithdrawals("alice")
+	wantErr(t, "GetWithdrawals", err, storage.ErrNoWithdrawals)
+	if len(withdrawals) != 0 {
+		t.Errorf("GetWithdrawals() = %v, want none in the default program", withdrawals)
+	}
+
+	expired, err := s.ExpirePoints(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ExpirePoints() error = %v", err)
+	}
+
+	if expired != 36 {
+		t.Errorf("ExpirePoints() = %v, want 36 from both programs", expired)
+	}
+
+	wantBalance(t, other, "alice", 0, 4)
+}
+
+func testCampaigns(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+
+	starts := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	created, err := s.CreateCampaign(schema.Campaign{Name: "Double", Multiplier: 2, StartsAt: starts}, "root")
+	if err != nil {
+		t.Fatalf("CreateCampaign() error = %v", err)
+	}
+
+	if created.ID == 0 || created.CreatedAt.IsZero() {
+		t.Errorf("CreateCampaign() = %+v, want an ID and a creation date", created)
+	}
+
+	created.Name = "Double weekend"
+	created.EndsAt = &starts
+	if _, err = s.UpdateCampaign(created, "root"); err != nil {
+		t.Fatalf("UpdateCampaign() error = %v", err)
+	}
+
+	_, err = s.UpdateCampaign(schema.Campaign{ID: created.ID + 100, Name: "nope", Multiplier: 1}, "root")
+	wantErr(t, "UpdateCampaign", err, storage.ErrCampaignNotFound)
+
+	campaigns, err := s.GetCampaigns()
+	if err != nil {
+		t.Fatalf("GetCampaigns() error = %v", err)
+	}
+
+	if len(campaigns) != 1 || campaigns[0].Name != "Double weekend" || campaigns[0].EndsAt == nil {
+		t.Fatalf("GetCampaigns() = %+v, want the updated campaign", campaigns)
+	}
+
+	if err = s.CheckID("alice", "12345678903"); err != nil {
+		t.Fatalf("CheckID() error = %v", err)
+	}
+
+	applied := []schema.AppliedCampaign{{ID: created.ID, Name: "Double weekend", Bonus: 15}}
+	if err = s.UpdateOrder("alice", "12345678903", "PROCESSED", 30, applied); err != nil {
+		t.Fatalf("UpdateOrder() error = %v", err)
+	}
+
+	orders, err := s.GetOrders("alice")
+	if err != nil {
+		t.Fatalf("GetOrders() error = %v", err)
+	}
+
+	if len(orders) != 1 || len(orders[0].Campaigns) != 1 || orders[0].Campaigns[0] != applied[0] {
+		t.Errorf("GetOrders() = %+v, want the order with %v", orders, applied)
+	}
+
+	if campaigns, _ = s.ForProgram("other").GetCampaigns(); len(campaigns) != 0 {
+		t.Errorf("GetCampaigns() of another program = %+v, want none", campaigns)
+	}
+}
+
+func testReferrals(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "mallory")
+
+	referrals, err := s.GetReferrals("alice")
+	if err != nil {
+		t.Fatalf("GetReferrals() error = %v", err)
+	}
+
+	if referrals.Code == "" || len(referrals.Referrals) != 0 {
+		t.Fatalf("GetReferrals() = %+v, want a code and no referrals", referrals)
+	}
+
+	code := referrals.Code
+	limit := storage.ReferralLimit{Since: time.Now().Add(-time.Hour), Max: 2}
+	wantErr(t, "CreateReferredUser", s.CreateReferredUser("bob", "bob", "NOPE", limit), storage.ErrBadReferral)
+	wantErr(t, "CreateReferredUser", s.CreateReferredUser("mallory", "x", code, limit), storage.ErrUsernameConflict)
+
+	for _, login := range []string{"bob", "carol"} {
+		if err = s.CreateReferredUser(login, login, code, limit); err != nil {
+			t.Fatalf("CreateReferredUser(%q) error = %v", login, err)
+		}
+	}
+
+	wantErr(t, "CreateReferredUser", s.CreateReferredUser("dave", "dave", code, limit), storage.ErrReferralLimit)
+	wantErr(t, "CheckPassword", s.CheckPassword("bob", "bob"), nil)
+
+	if err = s.RewardReferral("bob", 50, 1); err != nil {
+		t.Fatalf("RewardReferral() error = %v", err)
+	}
+
+	// the second reward is over the limit of rewarded referrals
+	if err = s.RewardReferral("carol", 50, 1); err != nil {
+		t.Fatalf("RewardReferral() error = %v", err)
+	}
+
+	// only a pending referral is rewarded
+	if err = s.RewardReferral("bob", 50, 0); err != nil {
+		t.Fatalf("RewardReferral() error = %v", err)
+	}
+
+	if err = s.RewardReferral("mallory", 50, 0); err != nil {
+		t.Fatalf("RewardReferral() of a user without a referral error = %v", err)
+	}
+
+	wantBalance(t, s, "alice", 50, 0)
+	wantBalance(t, s, "bob", 50, 0)
+	wantBalance(t, s, "carol", 0, 0)
+
+	referrals, err = s.GetReferrals("alice")
+	if err != nil {
+		t.Fatalf("GetReferrals() error = %v", err)
+	}
+
+	if referrals.Code != code || referrals.Earned != 50 || len(referrals.Referrals) != 2 {
+		t.Fatalf("GetReferrals() = %+v, want two referrals and 50 earned", referrals)
+	}
+
+	statuses := map[string]string{}
+	for _, referral := range referrals.Referrals {
+		statuses[referral.Login] = referral.Status
+		if referral.CompletedAt == nil {
+			t.Errorf("referral of %q is not completed", referral.Login)
+		}
+	}
+
+	want := map[string]string{"bob": storage.ReferralRewarded, "carol": storage.ReferralRejected}
+	for login, status := range want {
+		if statuses[login] != status {
+			t.Errorf("referral of %q = %q, want %q", login, statuses[login], status)
+		}
+	}
+
+	// the limit counts the sign-ups since the given moment only
+	limit.Since = time.Now().Add(time.Hour)
+	if err = s.CreateReferredUser("dave", "dave", code, limit); err != nil {
+		t.Fatalf("CreateReferredUser() error = %v", err)
+	}
+
+	if err = s.RejectReferral("dave"); err != nil {
+		t.Fatalf("RejectReferral() error = %v", err)
+	}
+
+	if err = s.RewardReferral("dave", 50, 0); err != nil {
+		t.Fatalf("RewardReferral() error = %v", err)
+	}
+
+	wantBalance(t, s, "dave", 0, 0)
+
+	if err = s.SetBlocked("alice", true, "abuse", "root"); err != nil {
+		t.Fatalf("SetBlocked() error = %v", err)
+	}
+
+	wantErr(t, "CreateReferredUser", s.CreateReferredUser("eve", "eve", code, storage.ReferralLimit{}), storage.ErrBadReferral)
+
+	other := s.ForProgram("other")
+	createUsers(t, other, "alice")
+	wantErr(t, "CreateReferredUser", other.CreateReferredUser("bob", "bob", code, storage.ReferralLimit{}), storage.ErrBadReferral)
+}
+
+func testAccounts(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "bob", "carol")
+	credit(t, s, "alice", "12345678903", 100)
+	credit(t, s, "carol", "5105105105105100", 10)
+
+	if err := s.AddSession("alice", schema.Session{IP: "10.0.0.1", UserAgent: "curl"}); err != nil {
+		t.Fatalf("AddSession() error = %v", err)
+	}
+
+	if err := s.Withdraw("alice", 30, "79927398713", storage.WithdrawalLimit{}); err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+
+	if _, err := s.CreateTransfer("alice", "bob", 20, false, storage.TransferLimit{}); err != nil {
+		t.Fatalf("CreateTransfer() error = %v", err)
+	}
+
+	export, err := s.Export("alice")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if export.Profile.Login != "alice" || export.Profile.Balance != 50 || export.ReferralCode == "" {
+		t.Errorf("Export() profile = %+v, code %q, want alice with 50 points and a code", export.Profile, export.ReferralCode)
+	}
+
+	if len(export.Orders) != 1 || len(export.Withdrawals) != 1 || len(export.Transfers) != 1 || len(export.Sessions) != 1 {
+		t.Fatalf("Export() = %+v, want an order, a withdrawal, a transfer and a session", export)
+	}
+
+	if export.Sessions[0].IP != "10.0.0.1" || export.Sessions[0].UserAgent != "curl" {
+		t.Errorf("Export() sessions = %+v, want the added session", export.Sessions)
+	}
+
+	export, err = s.Export("carol")
+	if err != nil || export.Withdrawals == nil || export.Adjustments == nil || export.Sessions == nil {
+		t.Errorf("Export() = %+v, %v, want empty lists", export, err)
+	}
+
+	policy := storage.DeletionPolicy{Mode: storage.DeletionAnonymize, Balance: storage.BalanceRefuse}
+	_, err = s.DeleteUser("alice", policy)
+	wantErr(t, "DeleteUser", err, storage.ErrBalanceRemaining)
+
+	_, err = s.DeleteUser("nobody", policy)
+	wantErr(t, "DeleteUser", err, storage.ErrUserNotFound)
+
+	policy.Balance = storage.BalanceForfeit
+	deletion, err := s.DeleteUser("alice", policy)
+	if err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	if deletion.Alias == "" || deletion.Alias == "alice" || deletion.Forfeited != 50 || deletion.Mode != storage.DeletionAnonymize {
+		t.Errorf("DeleteUser() = %+v, want an alias and 50 forfeited", deletion)
+	}
+
+	_, err = s.GetUser("alice")
+	wantErr(t, "GetUser", err, storage.ErrUserNotFound)
+
+	// the anonymized rows stay for accounting and the transfer stays in the history of the recipient
+	if orders, _ := s.GetOrders(deletion.Alias); len(orders) != 1 {
+		t.Errorf("GetOrders() of the alias = %+v, want the order", orders)
+	}
+
+	transfers, err := s.GetTransfers("bob")
+	if err != nil || len(transfers) != 1 || transfers[0].Sender != deletion.Alias {
+		t.Errorf("GetTransfers() = %+v, %v, want the transfer from the alias", transfers, err)
+	}
+
+	// the written off points can't expire from the alias
+	if expired, _ := s.ExpirePoints(time.Now().Add(time.Hour)); expired != 30 {
+		t.Errorf("ExpirePoints() = %v, want 30 points of bob and carol only", expired)
+	}
+
+	wantBalance(t, s, deletion.Alias, 0, 30)
+
+	// the login is free again
+	createUsers(t, s, "alice")
+	if orders, err := s.GetOrders("alice"); !errors.Is(err, storage.ErrNoResult) {
+		t.Errorf("GetOrders() of the new alice = %+v, %v, want no orders", orders, err)
+	}
+
+	policy.Mode = storage.DeletionDelete
+	if _, err = s.DeleteUser("carol", policy); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	// the number of a deleted order stays taken, so it can't be credited again
+	wantErr(t, "CheckID", s.CheckID("bob", "5105105105105100"), storage.ErrCreatedByAnotherUser)
+
+	orders, err := s.GetProcessedOrders(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetProcessedOrders() error = %v", err)
+	}
+
+	for _, order := range orders {
+		if order.Number == "5105105105105100" {
+			t.Errorf("GetProcessedOrders() = %+v, want no deleted order", orders)
+		}
+	}
+}
+
+func testTwoFactor(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+
+	if tf, err := s.GetTwoFactor("alice"); err != nil || tf != (storage.TwoFactor{}) {
+		t.Errorf("GetTwoFactor() = %+v, %v, want no secret", tf, err)
+	}
+
+	_, err := s.GetTwoFactor("nobody")
+	wantErr(t, "GetTwoFactor", err, storage.ErrUserNotFound)
+
+	err = s.EnableTwoFactor("alice", 10)
+	wantErr(t, "EnableTwoFactor", err, storage.ErrTwoFactorDisabled)
+
+	if err := s.SetTwoFactor("alice", "SECRET", []string{"hash1", "hash2"}); err != nil {
+		t.Fatalf("SetTwoFactor() error = %v", err)
+	}
+
+	// the codes are rejected until the secret is confirmed
+	err = s.UseTOTPStep("alice", 10)
+	wantErr(t, "UseTOTPStep", err, storage.ErrWrongTwoFactorCode)
+
+	if err := s.EnableTwoFactor("alice", 10); err != nil {
+		t.Fatalf("EnableTwoFactor() error = %v", err)
+	}
+
+	if tf, _ := s.GetTwoFactor("alice"); tf != (storage.TwoFactor{Secret: "SECRET", Enabled: true, Step: 10}) {
+		t.Errorf("GetTwoFactor() = %+v, want the enabled secret", tf)
+	}
+
+	err = s.SetTwoFactor("alice", "OTHER", nil)
+	wantErr(t, "SetTwoFactor", err, storage.ErrTwoFactorEnabled)
+
+	err = s.EnableTwoFactor("alice", 11)
+	wantErr(t, "EnableTwoFactor", err, storage.ErrTwoFactorEnabled)
+
+	// a code is accepted once and the earlier ones are rejected
+	err = s.UseTOTPStep("alice", 10)
+	wantErr(t, "UseTOTPStep", err, storage.ErrWrongTwoFactorCode)
+
+	if err := s.UseTOTPStep("alice", 12); err != nil {
+		t.Errorf("UseTOTPStep() error = %v", err)
+	}
+
+	err = s.UseTOTPStep("alice", 11)
+	wantErr(t, "UseTOTPStep", err, storage.ErrWrongTwoFactorCode)
+
+	if err := s.UseRecoveryCode("alice", "hash1"); err != nil {
+		t.Errorf("UseRecoveryCode() error = %v", err)
+	}
+
+	err = s.UseRecoveryCode("alice", "hash1")
+	wantErr(t, "UseRecoveryCode", err, storage.ErrWrongTwoFactorCode)
+
+	err = s.UseRecoveryCode("alice", "unknown")
+	wantErr(t, "UseRecoveryCode", err, storage.ErrWrongTwoFactorCode)
+
+	if err := s.DisableTwoFactor("alice"); err != nil {
+		t.Fatalf("DisableTwoFactor() error = %v", err)
+	}
+
+	if tf, _ := s.GetTwoFactor("alice"); tf != (storage.TwoFactor{}) {
+		t.Errorf("GetTwoFactor() = %+v, want no secret", tf)
+	}
+
+	err = s.UseRecoveryCode("alice", "hash2")
+	wantErr(t, "UseRecoveryCode", err, storage.ErrWrongTwoFactorCode)
+
+	// the recovery codes don't keep the account from being deleted
+	if err := s.SetTwoFactor("alice", "SECRET", []string{"hash3"}); err != nil {
+		t.Fatalf("SetTwoFactor() error = %v", err)
+	}
+
+	_, err = s.DeleteUser("alice", storage.DeletionPolicy{Mode: storage.DeletionDelete, Balance: storage.BalanceRefuse})
+	if err != nil {
+		t.Errorf("DeleteUser() error = %v", err)
+	}
+}
+
+func testPasswords(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "bob")
+
+	if generation, err := s.GetTokenGeneration("alice"); err != nil || generation != 0 {
+		t.Errorf("GetTokenGeneration() = %v, %v, want 0", generation, err)
+	}
+
+	_, err := s.GetTokenGeneration("nobody")
+	wantErr(t, "GetTokenGeneration", err, storage.ErrUserNotFound)
+
+	generation, err := s.ChangePassword("alice", "secret")
+	if err != nil || generation != 1 {
+		t.Fatalf("ChangePassword() = %v, %v, want generation 1", generation, err)
+	}
+
+	err = s.CheckPassword("alice", "alice")
+	wantErr(t, "CheckPassword", err, storage.ErrWrongPassword)
+
+	if err := s.CheckPassword("alice", "secret"); err != nil {
+		t.Errorf("CheckPassword() error = %v", err)
+	}
+
+	_, err = s.ChangePassword("nobody", "secret")
+	wantErr(t, "ChangePassword", err, storage.ErrUserNotFound)
+
+	now := time.Now()
+	if err := s.CreatePasswordReset("alice", "old", now.Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordReset() error = %v", err)
+	}
+
+	if err := s.CreatePasswordReset("alice", "new", now.Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordReset() error = %v", err)
+	}
+
+	if err := s.CreatePasswordReset("bob", "expired", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePasswordReset() error = %v", err)
+	}
+
+	// a new token replaces the earlier one
+	_, _, err = s.ResetPassword("old", "reset", now)
+	wantErr(t, "ResetPassword", err, storage.ErrBadResetToken)
+
+	_, _, err = s.ResetPassword("expired", "reset", now)
+	wantErr(t, "ResetPassword", err, storage.ErrBadResetToken)
+
+	login, generation, err := s.ResetPassword("new", "reset", now)
+	if err != nil || login != "alice" || generation != 2 {
+		t.Fatalf("ResetPassword() = %v, %v, %v, want alice with generation 2", login, generation, err)
+	}
+
+	if err := s.CheckPassword("alice", "reset"); err != nil {
+		t.Errorf("CheckPassword() error = %v", err)
+	}
+
+	// the token is single-use
+	_, _, err = s.ResetPassword("new", "again", now)
+	wantErr(t, "ResetPassword", err, storage.ErrBadResetToken)
+
+	// the pending tokens don't keep the account from being deleted
+	if err := s.CreatePasswordReset("alice", "pending", now.Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordReset() error = %v", err)
+	}
+
+	_, err = s.DeleteUser("alice", storage.DeletionPolicy{Mode: storage.DeletionDelete, Balance: storage.BalanceRefuse})
+	if err != nil {
+		t.Errorf("DeleteUser() error = %v", err)
+	}
+
+	// the login registered again doesn't accept the tokens of the deleted account
+	createUsers(t, s, "alice")
+	if generation, err := s.GetTokenGeneration("alice"); err != nil || generation != 3 {
+		t.Errorf("GetTokenGeneration() after registering again = %v, %v, want 3", generation, err)
+	}
+}
+
+func testSecurityEvents(t *testing.T, s storage.IStorage) {
+	// the events outlive the truncated tables, so the test keeps them in a program of its own
+	audit := s.ForProgram("audit")
+	events := []schema.SecurityEvent{
+		{Event: storage.EventLogin, Login: "mallory", IP: "192.0.2.1", UserAgent: "curl", RequestID: "r1", Details: "wrong password"},
+		{Event: storage.EventLogin, Login: "mallory", Success: true, IP: "192.0.2.1", RequestID: "r2"},
+		{Event: storage.EventWithdrawal, Login: "mallory", Success: true, IP: "192.0.2.2", RequestID: "r3", Details: "100"},
+		{Event: storage.EventLogin, Login: "trent", IP: "192.0.2.1", RequestID: "r4"},
+	}
+	for _, e := range events {
+		if err := audit.AddSecurityEvent(e); err != nil {
+			t.Fatalf("AddSecurityEvent() error = %v", err)
+		}
+	}
+
+	failed := false
+	tests := []struct {
+		name   string
+		filter storage.SecurityEventFilter
+		want   []string
+	}{
+		{name: "all", want: []string{"r4", "r3", "r2", "r1"}},
+		{name: "login", filter: storage.SecurityEventFilter{Login: "mallory"}, want: []string{"r3", "r2", "r1"}},
+		{name: "event", filter: storage.SecurityEventFilter{Event: storage.EventLogin, Login: "mallory"}, want: []string{"r2", "r1"}},
+		{name: "ip", filter: storage.SecurityEventFilter{IP: "192.0.2.1"}, want: []string{"r4", "r2", "r1"}},
+		{name: "failed", filter: storage.SecurityEventFilter{Success: &failed}, want: []string{"r4", "r1"}},
+		{name: "future", filter: storage.SecurityEventFilter{From: time.Now().Add(time.Hour)}, want: []string{}},
+		{name: "past", filter: storage.SecurityEventFilter{To: time.Now().Add(-time.Hour)}, want: []string{}},
+	}
+	for _, tt := range tests {
+		got, err := audit.GetSecurityEvents(tt.filter, 10, 0)
+		if err != nil {
+			t.Fatalf("GetSecurityEvents(%s) error = %v", tt.name, err)
+		}
+
+		ids := make([]string, 0, len(got))
+		for _, e := range got {
+			ids = append(ids, e.RequestID)
+		}
+
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("GetSecurityEvents(%s) = %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+
+	got, err := audit.GetSecurityEvents(storage.SecurityEventFilter{}, 2, 1)
+	if err != nil || len(got) != 2 || got[0].RequestID != "r3" {
+		t.Errorf("GetSecurityEvents() page = %+v, %v, want r3 and r2", got, err)
+	}
+
+	if got[0].IP != "192.0.2.2" || got[0].Details != "100" || got[0].CreatedAt.IsZero() {
+		t.Errorf("GetSecurityEvents()[0] = %+v", got[0])
+	}
+
+	got, err = s.GetSecurityEvents(storage.SecurityEventFilter{Login: "mallory"}, 10, 0)
+	if err != nil || len(got) != 0 {
+		t.Errorf("GetSecurityEvents() of another program = %+v, %v, want none", got, err)
+	}
+}
+
+func testRisk(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "mallory", "trent")
+	start := time.Now().Add(-time.Minute)
+
+	activity := []storage.RiskActivity{
+		{Uploads: 3, Invalid: 2},
+		{Uploads: 1, Foreign: 1},
+		{Withdrawals: 1, Withdrawn: 150.5},
+	}
+	for _, a := range activity {
+		if err := s.AddRiskActivity("mallory", a); err != nil {
+			t.Fatalf("AddRiskActivity() error = %v", err)
+		}
+	}
+
+	if err := s.AddRiskActivity("trent", storage.RiskActivity{Uploads: 10}); err != nil {
+		t.Fatalf("AddRiskActivity() error = %v", err)
+	}
+
+	got, err := s.GetRiskActivity("mallory", start)
+	want := storage.RiskActivity{Uploads: 4, Invalid: 2, Foreign: 1, Withdrawals: 1, Withdrawn: 150.5}
+	if err != nil || got != want {
+		t.Errorf("GetRiskActivity() = %+v, %v, want %+v", got, err, want)
+	}
+
+	got, err = s.GetRiskActivity("mallory", time.Now().Add(time.Hour))
+	if err != nil || got != (storage.RiskActivity{}) {
+		t.Errorf("GetRiskActivity() in the future = %+v, %v, want none", got, err)
+	}
+
+	until, err := s.GetThrottledUntil("mallory")
+	if err != nil || !until.IsZero() {
+		t.Errorf("GetThrottledUntil() = %v, %v, want the zero time", until, err)
+	}
+
+	end := time.Now().Add(time.Hour).Truncate(time.Second)
+	flags := []schema.RiskFlag{
+		{Login: "mallory", Signal: "invalid_rate", Value: 0.5, Threshold: 0.5, Action: "flag"},
+		{Login: "mallory", Signal: "foreign_rate", Value: 0.25, Threshold: 0.2, Action: "throttle", Until: &end},
+		{Login: "trent", Signal: "withdrawals", Value: 5, Threshold: 5, Action: "flag"},
+	}
+	for _, flag := range flags {
+		added, err := s.AddRiskFlag(flag, start)
+		if err != nil || !added {
+			t.Fatalf("AddRiskFlag() = %v, %v, want added", added, err)
+		}
+	}
+
+	added, err := s.AddRiskFlag(flags[0], start)
+	if err != nil || added {
+		t.Errorf("AddRiskFlag() of a flagged rule = %v, %v, want skipped", added, err)
+	}
+
+	added, err = s.AddRiskFlag(flags[0], time.Now().Add(time.Hour))
+	if err != nil || !added {
+		t.Errorf("AddRiskFlag() after the window = %v, %v, want added", added, err)
+	}
+
+	until, err = s.GetThrottledUntil("mallory")
+	if err != nil || !until.Equal(end) {
+		t.Errorf("GetThrottledUntil() = %v, %v, want %v", until, err, end)
+	}
+
+	gotFlags, err := s.GetRiskFlags("mallory", 10, 0)
+	if err != nil || len(gotFlags) != 3 {
+		t.Fatalf("GetRiskFlags() = %+v, %v, want 3 flags", gotFlags, err)
+	}
+
+	if gotFlags[1].Signal != "foreign_rate" || gotFlags[1].Until == nil || gotFlags[1].Value != 0.25 || gotFlags[1].CreatedAt.IsZero() {
+		t.Errorf("GetRiskFlags()[1] = %+v", gotFlags[1])
+	}
+
+	gotFlags, err = s.GetRiskFlags("", 1, 1)
+	if err != nil || len(gotFlags) != 1 || gotFlags[0].Login != "trent" {
+		t.Errorf("GetRiskFlags() page = %+v, %v, want the flag of trent", gotFlags, err)
+	}
+
+	_, err = s.DeleteUser("mallory", storage.DeletionPolicy{Mode: storage.DeletionDelete, Balance: storage.BalanceForfeit})
+	if err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	got, err = s.GetRiskActivity("mallory", start)
+	if err != nil || got != (storage.RiskActivity{}) {
+		t.Errorf("GetRiskActivity() of a deleted user = %+v, %v, want none", got, err)
+	}
+
+	gotFlags, err = s.GetRiskFlags("mallory", 10, 0)
+	if err != nil || len(gotFlags) != 0 {
+		t.Errorf("GetRiskFlags() of a deleted user = %+v, %v, want them under the alias", gotFlags, err)
+	}
+}
+
+func testReconciliation(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice")
+	since := time.Now().Add(-time.Hour)
+	credit(t, s, "alice", "12345678903", 100)
+	credit(t, s, "alice", "79927398713", 50)
+	if err := s.CheckID("alice", "4561261212345467"); err != nil {
+		t.Fatalf("CheckID() error = %v", err)
+	}
+
+	wantErr(t, "SetReported", s.SetReported("12345678903", 100), nil)
+	wantErr(t, "SetMultiplier", s.SetMultiplier("12345678903", 1.25), nil)
+
+	orders, err := s.GetProcessedOrders(since)
+	if err != nil || len(orders) != 2 {
+		t.Fatalf("GetProcessedOrders() = %+v, %v, want 2 orders", orders, err)
+	}
+
+	if orders[0].Number != "12345678903" || orders[0].Owner != "alice" || orders[0].Accrual != 100 ||
+		orders[0].Reported == nil || *orders[0].Reported != 100 || orders[1].Reported != nil {
+		t.Errorf("GetProcessedOrders() = %+v", orders)
+	}
+
+	if orders[0].Multiplier == nil || *orders[0].Multiplier != 1.25 || orders[1].Multiplier != nil {
+		t.Errorf("GetProcessedOrders() = %+v, want the multiplier 1.25 of the first order only", orders)
+	}
+
+	orders, err = s.GetProcessedOrders(time.Now().Add(time.Hour))
+	if err != nil || len(orders) != 0 {
+		t.Errorf("GetProcessedOrders() in the future = %+v, %v, want none", orders, err)
+	}
+
+	_, err = s.CorrectAccrual("alice", "79927398713", 0, -500, "reconciliation", "job")
+	wantErr(t, "CorrectAccrual", err, storage.ErrNotEnoughMoney)
+
+	adjustment, err := s.CorrectAccrual("alice", "79927398713", 70, 20, "reconciliation", "job")
+	if err != nil || adjustment.ID == 0 || adjustment.Sum != 20 {
+		t.Fatalf("CorrectAccrual() = %+v, %v", adjustment, err)
+	}
+
+	wantBalance(t, s, "alice", 170, 0)
+	orders, err = s.GetProcessedOrders(since)
+	if err != nil || orders[1].Reported == nil || *orders[1].Reported != 70 {
+		t.Errorf("GetProcessedOrders() after the correction = %+v, %v, want 70 reported", orders, err)
+	}
+
+	reported := 50.0
+	report := schema.Reconciliation{
+		Since:      since.Truncate(time.Second),
+		Checked:    2,
+		Failed:     1,
+		Mismatched: 1,
+		Applied:    true,
+		Admin:      "job",
+		Discrepancies: []schema.Discrepancy{{
+			Number: "79927398713", Login: "alice", Accrual: 50, Reported: &reported,
+			Status: "PROCESSED", Fresh: 70, Correction: 20, AdjustmentID: &adjustment.ID,
+		}},
+	}
+	added, err := s.AddReconciliation(report)
+	if err != nil || added.ID == 0 || added.CreatedAt.IsZero() {
+		t.Fatalf("AddReconciliation() = %+v, %v", added, err)
+	}
+
+	if _, err = s.AddReconciliation(schema.Reconciliation{Since: since, Admin: "job"}); err != nil {
+		t.Fatalf("AddReconciliation() error = %v", err)
+	}
+
+	reports, err := s.GetReconciliations(10, 0)
+	if err != nil || len(reports) != 2 || reports[1].ID != added.ID || reports[1].Discrepancies != nil {
+		t.Errorf("GetReconciliations() = %+v, %v, want 2 reports without discrepancies", reports, err)
+	}
+
+	got, err := s.GetReconciliation(added.ID)
+	if err != nil {
+		t.Fatalf("GetReconciliation() error = %v", err)
+	}
+
+	if got.Checked != 2 || got.Failed != 1 || !got.Applied || !got.Since.Equal(report.Since) || len(got.Discrepancies) != 1 {
+		t.Fatalf("GetReconciliation() = %+v", got)
+	}
+
+	d := got.Discrepancies[0]
+	if d.Number != "79927398713" || d.Reported == nil || *d.Reported != 50 || d.Fresh != 70 ||
+		d.AdjustmentID == nil || *d.AdjustmentID != adjustment.ID {
+		t.Errorf("GetReconciliation().Discrepancies[0] = %+v", d)
+	}
+
+	_, err = s.GetReconciliation(added.ID + 100)
+	wantErr(t, "GetReconciliation", err, storage.ErrReconciliationNotFound)
+}
+
+func testBalances(t *testing.T, s storage.IStorage) {
+	createUsers(t, s, "alice", "bob", "carol")
+	credit(t, s, "alice", "12345678903", 100)
+	credit(t, s, "carol", "5105105105105100", 10)
+
+	if err := s.Withdraw("alice", 30, "79927398713", storage.WithdrawalLimit{}); err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+
+	if _, err := s.CreateTransfer("alice", "bob", 20, false, storage.TransferLimit{}); err != nil {
+		t.Fatalf("CreateTransfer() error = %v", err)
+	}
+
+	// a pending transfer is written off the sender only
+	if _, err := s.CreateTransfer("bob", "alice", 5, true, storage.TransferLimit{}); err != nil {
+		t.Fatalf("CreateTransfer() error = %v", err)
+	}
+
+	if _, err := s.Adjust("alice", -10.5, "test", "support"); err != nil {
+		t.Fatalf("Adjust() error = %v", err)
+	}
+
+	if err := s.Withdraw("carol", 10, "4561261212345467", storage.WithdrawalLimit{}); err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+
+	// the alias keeps the withdrawn sum of carol without her withdrawals
+	policy := storage.DeletionPolicy{Mode: storage.DeletionDelete, Balance: storage.BalanceRefuse}
+	if _, err := s.DeleteUser("carol", policy); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	for _, repair := range []bool{false, true} {
+		check, err := s.CheckBalances(repair, "support")
+		if err != nil {
+			t.Fatalf("CheckBalances(%v) error = %v", repair, err)
+		}
+
+		if check.Checked != 2 || check.Mismatched != 0 || len(check.Mismatches) != 0 || check.Repaired != repair {
+			t.Errorf("CheckBalances(%v) = %+v, want 2 users checked and no mismatches", repair, check)
+		}
+	}
+
+	wantBalance(t, s, "alice", 39.5, 30)
+	wantBalance(t, s, "bob", 15, 0)
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"gomarket/internal/loyalty/schema"
+	"strconv"
+)
+
+func (s Storage) CreateTransfer(sender, recipient string, sum float64, pending bool, limit TransferLimit) (schema.Transfer, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+	defer tx.Rollback()
+
+	users, err := s.lockUsersState(tx, sender, recipient)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	user, ok := users[sender]
+	if !ok {
+		return schema.Transfer{}, sql.ErrNoRows
+	}
+
+	target, ok := users[recipient]
+	if !ok {
+		return schema.Transfer{}, ErrUserNotFound
+	}
+
+	// the points can't be moved out of or into a blocked account
+	if user.Blocked || target.Blocked {
+		return schema.Transfer{}, ErrUserBlocked
+	}
+
+	if user.Balance < sum {
+		return schema.Transfer{}, ErrNotEnoughMoney
+	}
+
+	if limit.Max > 0 {
+		var transferred float64
+		err = tx.QueryRow(getTransferredSince, sender, limit.Since, s.Program).Scan(&transferred)
+		if err != nil {
+			return schema.Transfer{}, err
+		}
+
+		if transferred+sum > limit.Max {
+			return schema.Transfer{}, ErrTransferLimit
+		}
+	}
+
+	transfer := schema.Transfer{
+		Sender:    sender,
+		Recipient: recipient,
+		Sum:       sum,
+		Status:    TransferCompleted,
+	}
+	if pending {
+		transfer.Status = TransferPending
+	}
+
+	err = tx.QueryRow(addTransfer, sender, recipient, sum, transfer.Status, s.Program).Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	parts, err := s.spendCredits(tx, sender, sum)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	for _, part := range parts {
+		_, err = tx.Exec(addTransferPart, transfer.ID, part.Remaining, part.Date)
+		if err != nil {
+			return schema.Transfer{}, err
+		}
+	}
+
+	_, err = tx.Exec(writeOffBalance, sum, sender, s.Program)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	if !pending {
+		transfer.CompletedAt = &transfer.CreatedAt
+		err = s.creditTransfer(tx, recipient, transfer)
+		if err != nil {
+			return schema.Transfer{}, err
+		}
+	}
+
+	return transfer, tx.Commit()
+}
+
+// CompleteTransfer accepts or declines the pending transfer. Only the recipient can accept it,
+// while both sides can decline it and the points go back to the sender.
+func (s Storage) CompleteTransfer(id int64, username string, accept bool) (schema.Transfer, error) {
+	transfer, err := scanTransfer(s.DB.QueryRow(getTransfer, id, s.Program))
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema.Transfer{}, ErrTransferNotFound
+	}
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	if transfer.Recipient != username && (accept || transfer.Sender != username) {
+		return schema.Transfer{}, ErrTransferNotFound
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+	defer tx.Rollback()
+
+	users, err := s.lockUsersState(tx, transfer.Sender, transfer.Recipient)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	// the recipient blocked after the transfer was sent can only decline it
+	if accept && users[transfer.Recipient].Blocked {
+		return schema.Transfer{}, ErrUserBlocked
+	}
+
+	transfer, err = scanTransfer(tx.QueryRow(lockTransfer, id, s.Program))
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	if transfer.Status != TransferPending {
+		return schema.Transfer{}, ErrTransferNotFound
+	}
+
+	owner := transfer.Sender
+	transfer.Status = TransferDeclined
+	if accept {
+		owner = transfer.Recipient
+		transfer.Status = TransferCompleted
+	}
+
+	var completedAt sql.NullTime
+	err = tx.QueryRow(completeTransfer, transfer.Status, transfer.ID).Scan(&completedAt)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+	transfer.CompletedAt = &completedAt.Time
+
+	err = s.creditTransfer(tx, owner, transfer)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	return transfer, tx.Commit()
+}
+
+// creditTransfer credits the transferred points to the owner keeping the dates
+// of the original credits, so the points expire at the same time.
+func (s Storage) creditTransfer(tx *sql.Tx, owner string, transfer schema.Transfer) error {
+	rows, err := tx.Query(getTransferParts, transfer.ID)
+	if err != nil {
+		return err
+	}
+
+	parts := make([]schema.Credit, 0)
+	for rows.Next() {
+		var part schema.Credit
+		err = rows.Scan(&part.Remaining, &part.Date)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		parts = append(parts, part)
+	}
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	reference := strconv.FormatInt(transfer.ID, 10)
+	for _, part := range parts {
+		_, err = tx.Exec(addCreditAt, owner, CreditSourceTransfer, reference, part.Remaining, part.Date, s.Program)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(updateBalance, transfer.Sum, owner, s.Program)
+	return err
+}
+
+type lockedUser struct {
+	Balance float64
+	Blocked bool
+}
+
+// lockUsersState locks the users in the same order for every caller to avoid deadlocks.
+func (s Storage) lockUsersState(tx *sql.Tx, usernames ...string) (map[string]lockedUser, error) {
+	rows, err := tx.Query(lockUsers, pq.Array(usernames), s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]lockedUser, len(usernames))
+	for rows.Next() {
+		var name string
+		var user lockedUser
+		err = rows.Scan(&name, &user.Balance, &user.Blocked)
+		if err != nil {
+			return nil, err
+		}
+
+		users[name] = user
+	}
+
+	return users, rows.Err()
+}
+
+func (s Storage) GetTransfers(username string) ([]schema.Transfer, error) {
+	prepare, err := s.DB.Prepare(getTransfers)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := prepare.Query(username, s.Program)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]schema.Transfer, 0)
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transfers) == 0 {
+		return nil, ErrNoTransfers
+	}
+
+	return transfers, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTransfer(row scanner) (schema.Transfer, error) {
+	var transfer schema.Transfer
+	var completedAt sql.NullTime
+	err := row.Scan(&transfer.ID, &transfer.Sender, &transfer.Recipient, &transfer.Sum,
+		&transfer.Status, &transfer.CreatedAt, &completedAt)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	if completedAt.Valid {
+		transfer.CompletedAt = &completedAt.Time
+	}
+
+	return transfer, nil
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func (s Storage) GetTwoFactor(username string) (TwoFactor, error) {
+	var tf TwoFactor
+	err := s.DB.QueryRow(getTwoFactor, username, s.Program).Scan(&tf.Secret, &tf.Enabled, &tf.Step)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TwoFactor{}, ErrUserNotFound
+	}
+
+	return tf, err
+}
+
+// SetTwoFactor stores the pending secret and replaces the recovery codes by the hashes.
+func (s Storage) SetTwoFactor(username, secret string, recoveryHashes []string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(setTwoFactorSecret, secret, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return s.twoFactorError(username)
+	}
+
+	_, err = tx.Exec(deleteRecoveryCodes, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range recoveryHashes {
+		_, err = tx.Exec(addRecoveryCode, username, hash, s.Program)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// EnableTwoFactor enables the pending secret, step is the period of the code that confirmed it.
+func (s Storage) EnableTwoFactor(username string, step int64) error {
+	res, err := s.DB.Exec(enableTwoFactor, step, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return s.twoFactorError(username)
+	}
+
+	return nil
+}
+
+func (s Storage) DisableTwoFactor(username string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(disableTwoFactor, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrUserNotFound
+	}
+
+	_, err = tx.Exec(deleteRecoveryCodes, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep accepts a code of the period once, the replayed codes return ErrWrongTwoFactorCode.
+func (s Storage) UseTOTPStep(username string, step int64) error {
+	res, err := s.DB.Exec(useTOTPStep, step, username, s.Program)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrWrongTwoFactorCode
+	}
+
+	return nil
+}
+
+// UseRecoveryCode spends an unused recovery code with the hash.
+func (s Storage) UseRecoveryCode(username, hash string) error {
+	res, err := s.DB.Exec(useRecoveryCode, username, hash, s.Program)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrWrongTwoFactorCode
+	}
+
+	return nil
+}
+
+// twoFactorError explains why the state of the two-factor authentication wasn't changed.
+func (s Storage) twoFactorError(username string) error {
+	tf, err := s.GetTwoFactor(username)
+	if err != nil {
+		return err
+	}
+
+	if tf.Enabled {
+		return ErrTwoFactorEnabled
+	}
+
+	return ErrTwoFactorDisabled
+}
@@ -0,0 +1,80 @@
+package tier
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tier is a loyalty level reached by accruing Threshold points within the tier window.
+type Tier struct {
+	Name       string
+	Threshold  float64
+	Multiplier float64
+}
+
+// Tiers is sorted by Threshold in ascending order.
+type Tiers []Tier
+
+var ErrBadFormat = errors.New("tiers must be set as Name:threshold:multiplier,...")
+
+// None is used when the user hasn't reached any tier.
+var None = Tier{Multiplier: 1}
+
+// Parse parses tiers written as "Bronze:0:1,Silver:1000:1.1,Gold:5000:1.25".
+func Parse(s string) (Tiers, error) {
+	tiers := make(Tiers, 0)
+	if strings.TrimSpace(s) == "" {
+		return tiers, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+		if len(fields) != 3 || fields[0] == "" {
+			return nil, ErrBadFormat
+		}
+
+		threshold, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || threshold < 0 {
+			return nil, ErrBadFormat
+		}
+
+		multiplier, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || multiplier <= 0 {
+			return nil, ErrBadFormat
+		}
+
+		tiers = append(tiers, Tier{Name: fields[0], Threshold: threshold, Multiplier: multiplier})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Threshold < tiers[j].Threshold
+	})
+
+	return tiers, nil
+}
+
+// For returns the highest tier reached with accrued points.
+func (t Tiers) For(accrued float64) Tier {
+	reached := None
+	for _, tier := range t {
+		if accrued < tier.Threshold {
+			break
+		}
+		reached = tier
+	}
+
+	return reached
+}
+
+// Named returns the tier with the name, None if there is no such tier.
+func (t Tiers) Named(name string) Tier {
+	for _, tier := range t {
+		if tier.Name == name {
+			return tier
+		}
+	}
+
+	return None
+}
@@ -0,0 +1,93 @@
+package tier
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Tiers
+		wantErr error
+	}{
+		{
+			name:  "ok",
+			input: "Gold:5000:1.25, Bronze:0:1,Silver:1000:1.1",
+			want: Tiers{
+				{Name: "Bronze", Threshold: 0, Multiplier: 1},
+				{Name: "Silver", Threshold: 1000, Multiplier: 1.1},
+				{Name: "Gold", Threshold: 5000, Multiplier: 1.25},
+			},
+		},
+		{
+			name:  "empty",
+			input: "",
+			want:  Tiers{},
+		},
+		{
+			name:    "no multiplier",
+			input:   "Bronze:0",
+			wantErr: ErrBadFormat,
+		},
+		{
+			name:    "negative multiplier",
+			input:   "Bronze:0:-1",
+			wantErr: ErrBadFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTiers_For(t *testing.T) {
+	tiers := Tiers{
+		{Name: "Silver", Threshold: 1000, Multiplier: 1.1},
+		{Name: "Gold", Threshold: 5000, Multiplier: 1.25},
+	}
+
+	tests := []struct {
+		name    string
+		accrued float64
+		want    Tier
+	}{
+		{name: "none", accrued: 999, want: None},
+		{name: "silver", accrued: 1000, want: tiers[0]},
+		{name: "gold", accrued: 10000, want: tiers[1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tiers.For(tt.accrued); got != tt.want {
+				t.Errorf("For() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTiers_Named(t *testing.T) {
+	tiers := Tiers{
+		{Name: "Silver", Threshold: 1000, Multiplier: 1.1},
+		{Name: "Gold", Threshold: 5000, Multiplier: 1.25},
+	}
+
+	if got := tiers.Named("Gold"); got != tiers[1] {
+		t.Errorf("Named() got = %v, want %v", got, tiers[1])
+	}
+
+	if got := tiers.Named("Platinum"); got != None {
+		t.Errorf("Named() of an unknown tier got = %v, want %v", got, None)
+	}
+}
@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret is the SHA-1 key of the test vectors of RFC 6238.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Code(%d) = %v, want %v", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	tests := []struct {
+		name     string
+		code     string
+		at       time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current", code: "005924", at: now, wantStep: Step(now), wantOK: true},
+		{name: "previous period", code: "005924", at: now.Add(Period), wantStep: Step(now), wantOK: true},
+		{name: "too old", code: "005924", at: now.Add(2 * Period)},
+		{name: "wrong", code: "005925", at: now},
+		{name: "short", code: "5924", at: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Verify(secret, tt.code, tt.at)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Verify() = %v, %v, want %v, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	s, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+
+	if len(s) != 32 {
+		t.Errorf("NewSecret() = %v, want 32 characters", s)
+	}
+
+	if _, err := Code(s, 1); err != nil {
+		t.Errorf("Code() of a new secret error = %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Gophermart", "admin", secret)
+	want := "otpauth://totp/Gophermart:admin?issuer=Gophermart&secret=" + secret
+	if got != want {
+		t.Errorf("URI() = %v, want %v", got, want)
+	}
+
+	if got := URI("Gopher Mart", "a b", secret); !strings.HasPrefix(got, "otpauth://totp/Gopher%20Mart:a%20b?") {
+		t.Errorf("URI() = %v, want an escaped label", got)
+	}
+}
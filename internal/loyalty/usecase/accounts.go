@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+)
+
+// AddSession records the sign-in of the user, it is a part of the personal data export.
+// It returns the token generation the session is issued for, the generation is valid even if the record fails.
+func (uc UseCase) AddSession(login, ip, userAgent string) (int64, error) {
+	generation, err := uc.storage.GetTokenGeneration(login)
+	if err != nil {
+		return 0, err
+	}
+
+	return generation, uc.storage.AddSession(login, schema.Session{IP: ip, UserAgent: userAgent})
+}
+
+func (uc UseCase) Export(cookie string) ([]byte, error) {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	export, err := uc.storage.Export(username)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(export)
+}
+
+// DeleteUser deletes the account of the cookie owner by the deletion policy.
+func (uc UseCase) DeleteUser(cookie string) ([]byte, error) {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	deletion, err := uc.storage.DeleteUser(username, uc.config.Deletion)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(deletion)
+}
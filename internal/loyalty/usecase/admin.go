@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"strings"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// FindUsers searches users by a part of the login.
+func (uc UseCase) FindUsers(admin, query string, limit, offset int) ([]byte, error) {
+	err := uc.audit(admin, "find_users", "", query)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	limit, offset = page(limit, offset)
+	users, err := uc.storage.FindUsers(query, limit, offset)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(users)
+}
+
+func (uc UseCase) GetUser(admin, login string) ([]byte, error) {
+	err := uc.audit(admin, "get_user", login, "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	user, err := uc.storage.GetUser(login)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(user)
+}
+
+func (uc UseCase) GetUserOrders(admin, login string) ([]byte, error) {
+	err := uc.audit(admin, "get_user_orders", login, "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	_, err = uc.storage.GetUser(login)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	orders, err := uc.storage.GetOrders(login)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(orders)
+}
+
+func (uc UseCase) GetUserWithdrawals(admin, login string) ([]byte, error) {
+	err := uc.audit(admin, "get_user_withdrawals", login, "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	_, err = uc.storage.GetUser(login)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	withdrawals, err := uc.storage.GetWithdrawals(login)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(withdrawals)
+}
+
+// AdjustBalance credits (positive sum) or debits (negative sum) user's balance.
+func (uc UseCase) AdjustBalance(admin, login string, sum float64, reason string) ([]byte, error) {
+	reason = strings.TrimSpace(reason)
+	if sum == 0 || reason == "" {
+		return []byte(""), storage.ErrBadAdjustment
+	}
+
+	adjustment, err := uc.storage.Adjust(login, sum, reason, admin)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(adjustment)
+}
+
+func (uc UseCase) BlockUser(admin, login, reason string, blocked bool) error {
+	return uc.storage.SetBlocked(login, blocked, strings.TrimSpace(reason), admin)
+}
+
+func (uc UseCase) GetAdminActions(admin, target string, limit, offset int) ([]byte, error) {
+	err := uc.audit(admin, "get_admin_actions", target, "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	limit, offset = page(limit, offset)
+	actions, err := uc.storage.GetAdminActions(target, limit, offset)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(actions)
+}
+
+func (uc UseCase) audit(admin, action, target, details string) error {
+	return uc.storage.AddAdminAction(schema.AdminAction{
+		Admin:   admin,
+		Action:  action,
+		Target:  target,
+		Details: details,
+	})
+}
+
+func page(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
@@ -0,0 +1,27 @@
+package usecase
+
+import (
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+)
+
+// CheckBalances recomputes the balances from the history and reports the mismatches,
+// they are replaced with the recomputed balances only with req.Repair.
+func (uc UseCase) CheckBalances(admin string, req schema.BalanceCheckRequest) ([]byte, error) {
+	mode := "dry run"
+	if req.Repair {
+		mode = "repair"
+	}
+
+	err := uc.audit(admin, "check_balances", "", mode)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	check, err := uc.storage.CheckBalances(req.Repair, admin)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(check)
+}
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"gomarket/internal/loyalty/campaign"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"log"
+	"strings"
+	"time"
+)
+
+// applyCampaigns boosts the accrual of the processed order by the active campaigns.
+func (uc UseCase) applyCampaigns(username, id string, accrual float64) (float64, []schema.AppliedCampaign) {
+	campaigns, err := uc.storage.GetCampaigns()
+	if err != nil {
+		log.Println("GetCampaigns:", err)
+		return accrual, nil
+	}
+
+	if len(campaigns) == 0 {
+		return accrual, nil
+	}
+
+	orders, err := uc.storage.GetOrders(username)
+	if err != nil && !errors.Is(err, storage.ErrNoResult) {
+		log.Println("GetOrders:", err)
+		return accrual, nil
+	}
+
+	var processed int
+	for _, order := range orders {
+		if order.Number != id && order.Status == "PROCESSED" {
+			processed++
+		}
+	}
+
+	return campaign.Apply(campaigns, accrual, time.Now(), processed)
+}
+
+func (uc UseCase) CreateCampaign(admin string, req schema.CampaignRequest) ([]byte, error) {
+	c, err := newCampaign(req)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	c, err = uc.storage.CreateCampaign(c, admin)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(c)
+}
+
+func (uc UseCase) UpdateCampaign(admin string, id int64, req schema.CampaignRequest) ([]byte, error) {
+	c, err := newCampaign(req)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	c.ID = id
+	c, err = uc.storage.UpdateCampaign(c, admin)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(c)
+}
+
+func (uc UseCase) GetCampaigns(admin string) ([]byte, error) {
+	err := uc.audit(admin, "get_campaigns", "", "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	campaigns, err := uc.storage.GetCampaigns()
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(campaigns)
+}
+
+// newCampaign validates the request, a missing multiplier means 1 and a missing start means now.
+func newCampaign(req schema.CampaignRequest) (schema.Campaign, error) {
+	c := schema.Campaign{
+		Name:        strings.TrimSpace(req.Name),
+		Multiplier:  req.Multiplier,
+		Bonus:       req.Bonus,
+		FirstOrders: req.FirstOrders,
+		StartsAt:    time.Now(),
+		EndsAt:      req.EndsAt,
+		Disabled:    req.Disabled,
+	}
+
+	if c.Multiplier == 0 {
+		c.Multiplier = 1
+	}
+
+	if req.StartsAt != nil {
+		c.StartsAt = *req.StartsAt
+	}
+
+	switch {
+	case c.Name == "", c.Multiplier < 0, c.Bonus < 0, c.FirstOrders < 0:
+		return schema.Campaign{}, storage.ErrBadCampaign
+	case c.Multiplier == 1 && c.Bonus == 0:
+		return schema.Campaign{}, storage.ErrBadCampaign
+	case c.EndsAt != nil && !c.EndsAt.After(c.StartsAt):
+		return schema.Campaign{}, storage.ErrBadCampaign
+	}
+
+	return c, nil
+}
@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"gomarket/internal/loyalty/schema"
+	"log"
+	"time"
+)
+
+// ExpirePoints writes off the points that were credited more than PointsTTL ago.
+func (uc UseCase) ExpirePoints() (float64, error) {
+	if uc.config.PointsTTL <= 0 {
+		return 0, nil
+	}
+
+	return uc.storage.ExpirePoints(time.Now().Add(-uc.config.PointsTTL))
+}
+
+// RunExpiry runs ExpirePoints every ExpiryInterval until ctx is done, it returns at once if the interval is zero.
+func (uc UseCase) RunExpiry(ctx context.Context) {
+	if uc.config.ExpiryInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(uc.config.ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := uc.ExpirePoints()
+		if err != nil {
+			log.Println("ExpirePoints:", err)
+		} else if expired > 0 {
+			log.Println("Expired points:", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (uc UseCase) expiringSoon(username string) ([]schema.ExpiringPoints, error) {
+	if uc.config.PointsTTL <= 0 || uc.config.ExpiringSoon <= 0 {
+		return nil, nil
+	}
+
+	before := time.Now().Add(uc.config.ExpiringSoon - uc.config.PointsTTL)
+	credits, err := uc.storage.GetExpiringCredits(username, before)
+	if err != nil {
+		return nil, err
+	}
+
+	expiring := make([]schema.ExpiringPoints, 0, len(credits))
+	for _, credit := range credits {
+		expiring = append(expiring, schema.ExpiringPoints{
+			Sum:       credit.Remaining,
+			ExpiresAt: credit.Date.Add(uc.config.PointsTTL),
+		})
+	}
+
+	return expiring, nil
+}
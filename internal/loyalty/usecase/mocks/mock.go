@@ -5,7 +5,13 @@
 package mock_usecase
 
 import (
+	program "gomarket/internal/loyalty/program"
+	schema "gomarket/internal/loyalty/schema"
+	storage "gomarket/internal/loyalty/storage"
+	usecase "gomarket/internal/loyalty/usecase"
+	io "io"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -33,6 +39,94 @@ func (m *MockIUseCase) EXPECT() *MockIUseCaseMockRecorder {
 	return m.recorder
 }
 
+// AcceptTransfer mocks base method.
+func (m *MockIUseCase) AcceptTransfer(cookie string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptTransfer", cookie, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AcceptTransfer indicates an expected call of AcceptTransfer.
+func (mr *MockIUseCaseMockRecorder) AcceptTransfer(cookie, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptTransfer", reflect.TypeOf((*MockIUseCase)(nil).AcceptTransfer), cookie, id)
+}
+
+// AddSession mocks base method.
+func (m *MockIUseCase) AddSession(login, ip, userAgent string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSession", login, ip, userAgent)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddSession indicates an expected call of AddSession.
+func (mr *MockIUseCaseMockRecorder) AddSession(login, ip, userAgent interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSession", reflect.TypeOf((*MockIUseCase)(nil).AddSession), login, ip, userAgent)
+}
+
+// AdjustBalance mocks base method.
+func (m *MockIUseCase) AdjustBalance(admin, login string, sum float64, reason string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", admin, login, sum, reason)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockIUseCaseMockRecorder) AdjustBalance(admin, login, sum, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockIUseCase)(nil).AdjustBalance), admin, login, sum, reason)
+}
+
+// BlockUser mocks base method.
+func (m *MockIUseCase) BlockUser(admin, login, reason string, blocked bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockUser", admin, login, reason, blocked)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockUser indicates an expected call of BlockUser.
+func (mr *MockIUseCaseMockRecorder) BlockUser(admin, login, reason, blocked interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockUser", reflect.TypeOf((*MockIUseCase)(nil).BlockUser), admin, login, reason, blocked)
+}
+
+// ChangePassword mocks base method.
+func (m *MockIUseCase) ChangePassword(cookie, oldPasswd, newPasswd string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", cookie, oldPasswd, newPasswd)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockIUseCaseMockRecorder) ChangePassword(cookie, oldPasswd, newPasswd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockIUseCase)(nil).ChangePassword), cookie, oldPasswd, newPasswd)
+}
+
+// CheckBalances mocks base method.
+func (m *MockIUseCase) CheckBalances(admin string, req schema.BalanceCheckRequest) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckBalances", admin, req)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckBalances indicates an expected call of CheckBalances.
+func (mr *MockIUseCaseMockRecorder) CheckBalances(admin, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckBalances", reflect.TypeOf((*MockIUseCase)(nil).CheckBalances), admin, req)
+}
+
 // CheckID mocks base method.
 func (m *MockIUseCase) CheckID(host, cookie, id string) error {
 	m.ctrl.T.Helper()
@@ -61,32 +155,206 @@ func (mr *MockIUseCaseMockRecorder) CheckPassword(login, passwd interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPassword", reflect.TypeOf((*MockIUseCase)(nil).CheckPassword), login, passwd)
 }
 
+// CheckToken mocks base method.
+func (m *MockIUseCase) CheckToken(cookie string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckToken", cookie)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckToken indicates an expected call of CheckToken.
+func (mr *MockIUseCaseMockRecorder) CheckToken(cookie interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckToken", reflect.TypeOf((*MockIUseCase)(nil).CheckToken), cookie)
+}
+
+// ConfirmTwoFactor mocks base method.
+func (m *MockIUseCase) ConfirmTwoFactor(cookie, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTwoFactor", cookie, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmTwoFactor indicates an expected call of ConfirmTwoFactor.
+func (mr *MockIUseCaseMockRecorder) ConfirmTwoFactor(cookie, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTwoFactor", reflect.TypeOf((*MockIUseCase)(nil).ConfirmTwoFactor), cookie, code)
+}
+
+// CreateCampaign mocks base method.
+func (m *MockIUseCase) CreateCampaign(admin string, req schema.CampaignRequest) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCampaign", admin, req)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCampaign indicates an expected call of CreateCampaign.
+func (mr *MockIUseCaseMockRecorder) CreateCampaign(admin, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCampaign", reflect.TypeOf((*MockIUseCase)(nil).CreateCampaign), admin, req)
+}
+
 // CreateUser mocks base method.
-func (m *MockIUseCase) CreateUser(login, passwd string) error {
+func (m *MockIUseCase) CreateUser(login, passwd, referralCode string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", login, passwd)
+	ret := m.ctrl.Call(m, "CreateUser", login, passwd, referralCode)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockIUseCaseMockRecorder) CreateUser(login, passwd interface{}) *gomock.Call {
+func (mr *MockIUseCaseMockRecorder) CreateUser(login, passwd, referralCode interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockIUseCase)(nil).CreateUser), login, passwd)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockIUseCase)(nil).CreateUser), login, passwd, referralCode)
+}
+
+// DeclineTransfer mocks base method.
+func (m *MockIUseCase) DeclineTransfer(cookie string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeclineTransfer", cookie, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeclineTransfer indicates an expected call of DeclineTransfer.
+func (mr *MockIUseCaseMockRecorder) DeclineTransfer(cookie, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeclineTransfer", reflect.TypeOf((*MockIUseCase)(nil).DeclineTransfer), cookie, id)
+}
+
+// DeleteUser mocks base method.
+func (m *MockIUseCase) DeleteUser(cookie string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", cookie)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockIUseCaseMockRecorder) DeleteUser(cookie interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockIUseCase)(nil).DeleteUser), cookie)
+}
+
+// DisableTwoFactor mocks base method.
+func (m *MockIUseCase) DisableTwoFactor(cookie, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTwoFactor", cookie, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTwoFactor indicates an expected call of DisableTwoFactor.
+func (mr *MockIUseCaseMockRecorder) DisableTwoFactor(cookie, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTwoFactor", reflect.TypeOf((*MockIUseCase)(nil).DisableTwoFactor), cookie, code)
 }
 
 // DrawBonuses mocks base method.
-func (m *MockIUseCase) DrawBonuses(cookie string, sum float64, orderID string) error {
+func (m *MockIUseCase) DrawBonuses(cookie string, sum, orderTotal float64, orderID, otp string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DrawBonuses", cookie, sum, orderID)
+	ret := m.ctrl.Call(m, "DrawBonuses", cookie, sum, orderTotal, orderID, otp)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DrawBonuses indicates an expected call of DrawBonuses.
-func (mr *MockIUseCaseMockRecorder) DrawBonuses(cookie, sum, orderID interface{}) *gomock.Call {
+func (mr *MockIUseCaseMockRecorder) DrawBonuses(cookie, sum, orderTotal, orderID, otp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DrawBonuses", reflect.TypeOf((*MockIUseCase)(nil).DrawBonuses), cookie, sum, orderTotal, orderID, otp)
+}
+
+// DrawServiceBonuses mocks base method.
+func (m *MockIUseCase) DrawServiceBonuses(cookie string, sum, orderTotal float64, orderID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DrawServiceBonuses", cookie, sum, orderTotal, orderID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DrawServiceBonuses indicates an expected call of DrawServiceBonuses.
+func (mr *MockIUseCaseMockRecorder) DrawServiceBonuses(cookie, sum, orderTotal, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DrawServiceBonuses", reflect.TypeOf((*MockIUseCase)(nil).DrawServiceBonuses), cookie, sum, orderTotal, orderID)
+}
+
+// EnrollTwoFactor mocks base method.
+func (m *MockIUseCase) EnrollTwoFactor(cookie string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTwoFactor", cookie)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTwoFactor indicates an expected call of EnrollTwoFactor.
+func (mr *MockIUseCaseMockRecorder) EnrollTwoFactor(cookie interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DrawBonuses", reflect.TypeOf((*MockIUseCase)(nil).DrawBonuses), cookie, sum, orderID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTwoFactor", reflect.TypeOf((*MockIUseCase)(nil).EnrollTwoFactor), cookie)
+}
+
+// Export mocks base method.
+func (m *MockIUseCase) Export(cookie string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", cookie)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockIUseCaseMockRecorder) Export(cookie interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockIUseCase)(nil).Export), cookie)
+}
+
+// FindUsers mocks base method.
+func (m *MockIUseCase) FindUsers(admin, query string, limit, offset int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUsers", admin, query, limit, offset)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUsers indicates an expected call of FindUsers.
+func (mr *MockIUseCaseMockRecorder) FindUsers(admin, query, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUsers", reflect.TypeOf((*MockIUseCase)(nil).FindUsers), admin, query, limit, offset)
+}
+
+// ForProgram mocks base method.
+func (m *MockIUseCase) ForProgram(p program.Program) usecase.IUseCase {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForProgram", p)
+	ret0, _ := ret[0].(usecase.IUseCase)
+	return ret0
+}
+
+// ForProgram indicates an expected call of ForProgram.
+func (mr *MockIUseCaseMockRecorder) ForProgram(p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForProgram", reflect.TypeOf((*MockIUseCase)(nil).ForProgram), p)
+}
+
+// GetAdminActions mocks base method.
+func (m *MockIUseCase) GetAdminActions(admin, target string, limit, offset int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAdminActions", admin, target, limit, offset)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAdminActions indicates an expected call of GetAdminActions.
+func (mr *MockIUseCaseMockRecorder) GetAdminActions(admin, target, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAdminActions", reflect.TypeOf((*MockIUseCase)(nil).GetAdminActions), admin, target, limit, offset)
 }
 
 // GetBalance mocks base method.
@@ -104,6 +372,21 @@ func (mr *MockIUseCaseMockRecorder) GetBalance(cookie interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockIUseCase)(nil).GetBalance), cookie)
 }
 
+// GetCampaigns mocks base method.
+func (m *MockIUseCase) GetCampaigns(admin string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCampaigns", admin)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCampaigns indicates an expected call of GetCampaigns.
+func (mr *MockIUseCaseMockRecorder) GetCampaigns(admin interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaigns", reflect.TypeOf((*MockIUseCase)(nil).GetCampaigns), admin)
+}
+
 // GetOrders mocks base method.
 func (m *MockIUseCase) GetOrders(cookie string) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -119,6 +402,141 @@ func (mr *MockIUseCaseMockRecorder) GetOrders(cookie interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockIUseCase)(nil).GetOrders), cookie)
 }
 
+// GetReconciliation mocks base method.
+func (m *MockIUseCase) GetReconciliation(admin string, id int64) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReconciliation", admin, id)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReconciliation indicates an expected call of GetReconciliation.
+func (mr *MockIUseCaseMockRecorder) GetReconciliation(admin, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliation", reflect.TypeOf((*MockIUseCase)(nil).GetReconciliation), admin, id)
+}
+
+// GetReconciliations mocks base method.
+func (m *MockIUseCase) GetReconciliations(admin string, limit, offset int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReconciliations", admin, limit, offset)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReconciliations indicates an expected call of GetReconciliations.
+func (mr *MockIUseCaseMockRecorder) GetReconciliations(admin, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliations", reflect.TypeOf((*MockIUseCase)(nil).GetReconciliations), admin, limit, offset)
+}
+
+// GetReferrals mocks base method.
+func (m *MockIUseCase) GetReferrals(cookie string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrals", cookie)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrals indicates an expected call of GetReferrals.
+func (mr *MockIUseCaseMockRecorder) GetReferrals(cookie interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrals", reflect.TypeOf((*MockIUseCase)(nil).GetReferrals), cookie)
+}
+
+// GetRiskFlags mocks base method.
+func (m *MockIUseCase) GetRiskFlags(admin, login string, limit, offset int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRiskFlags", admin, login, limit, offset)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRiskFlags indicates an expected call of GetRiskFlags.
+func (mr *MockIUseCaseMockRecorder) GetRiskFlags(admin, login, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRiskFlags", reflect.TypeOf((*MockIUseCase)(nil).GetRiskFlags), admin, login, limit, offset)
+}
+
+// GetSecurityEvents mocks base method.
+func (m *MockIUseCase) GetSecurityEvents(admin string, f storage.SecurityEventFilter, limit, offset int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecurityEvents", admin, f, limit, offset)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecurityEvents indicates an expected call of GetSecurityEvents.
+func (mr *MockIUseCaseMockRecorder) GetSecurityEvents(admin, f, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecurityEvents", reflect.TypeOf((*MockIUseCase)(nil).GetSecurityEvents), admin, f, limit, offset)
+}
+
+// GetTransfers mocks base method.
+func (m *MockIUseCase) GetTransfers(cookie string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", cookie)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockIUseCaseMockRecorder) GetTransfers(cookie interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockIUseCase)(nil).GetTransfers), cookie)
+}
+
+// GetUser mocks base method.
+func (m *MockIUseCase) GetUser(admin, login string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", admin, login)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockIUseCaseMockRecorder) GetUser(admin, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockIUseCase)(nil).GetUser), admin, login)
+}
+
+// GetUserOrders mocks base method.
+func (m *MockIUseCase) GetUserOrders(admin, login string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserOrders", admin, login)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserOrders indicates an expected call of GetUserOrders.
+func (mr *MockIUseCaseMockRecorder) GetUserOrders(admin, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrders", reflect.TypeOf((*MockIUseCase)(nil).GetUserOrders), admin, login)
+}
+
+// GetUserWithdrawals mocks base method.
+func (m *MockIUseCase) GetUserWithdrawals(admin, login string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserWithdrawals", admin, login)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserWithdrawals indicates an expected call of GetUserWithdrawals.
+func (mr *MockIUseCaseMockRecorder) GetUserWithdrawals(admin, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithdrawals", reflect.TypeOf((*MockIUseCase)(nil).GetUserWithdrawals), admin, login)
+}
+
 // GetWithdrawals mocks base method.
 func (m *MockIUseCase) GetWithdrawals(cookie string) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -134,14 +552,133 @@ func (mr *MockIUseCaseMockRecorder) GetWithdrawals(cookie interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockIUseCase)(nil).GetWithdrawals), cookie)
 }
 
-// updateStatus mocks base method.
-func (m *MockIUseCase) updateStatus(username, host, id string) {
+// Reconcile mocks base method.
+func (m *MockIUseCase) Reconcile(admin, host string, req schema.ReconciliationRequest) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reconcile", admin, host, req)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reconcile indicates an expected call of Reconcile.
+func (mr *MockIUseCaseMockRecorder) Reconcile(admin, host, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reconcile", reflect.TypeOf((*MockIUseCase)(nil).Reconcile), admin, host, req)
+}
+
+// RecordSecurityEvent mocks base method.
+func (m *MockIUseCase) RecordSecurityEvent(e schema.SecurityEvent) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "updateStatus", username, host, id)
+	ret := m.ctrl.Call(m, "RecordSecurityEvent", e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordSecurityEvent indicates an expected call of RecordSecurityEvent.
+func (mr *MockIUseCaseMockRecorder) RecordSecurityEvent(e interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordSecurityEvent", reflect.TypeOf((*MockIUseCase)(nil).RecordSecurityEvent), e)
+}
+
+// RequestPasswordReset mocks base method.
+func (m *MockIUseCase) RequestPasswordReset(login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPasswordReset", login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPasswordReset indicates an expected call of RequestPasswordReset.
+func (mr *MockIUseCaseMockRecorder) RequestPasswordReset(login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPasswordReset", reflect.TypeOf((*MockIUseCase)(nil).RequestPasswordReset), login)
+}
+
+// ResetPassword mocks base method.
+func (m *MockIUseCase) ResetPassword(token, passwd string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", token, passwd)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockIUseCaseMockRecorder) ResetPassword(token, passwd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockIUseCase)(nil).ResetPassword), token, passwd)
+}
+
+// Transfer mocks base method.
+func (m *MockIUseCase) Transfer(cookie, recipient string, sum float64) (schema.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", cookie, recipient, sum)
+	ret0, _ := ret[0].(schema.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockIUseCaseMockRecorder) Transfer(cookie, recipient, sum interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockIUseCase)(nil).Transfer), cookie, recipient, sum)
+}
+
+// UpdateCampaign mocks base method.
+func (m *MockIUseCase) UpdateCampaign(admin string, id int64, req schema.CampaignRequest) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCampaign", admin, id, req)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCampaign indicates an expected call of UpdateCampaign.
+func (mr *MockIUseCaseMockRecorder) UpdateCampaign(admin, id, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCampaign", reflect.TypeOf((*MockIUseCase)(nil).UpdateCampaign), admin, id, req)
+}
+
+// UploadOrders mocks base method.
+func (m *MockIUseCase) UploadOrders(host, cookie string, ids []string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadOrders", host, cookie, ids)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadOrders indicates an expected call of UploadOrders.
+func (mr *MockIUseCaseMockRecorder) UploadOrders(host, cookie, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadOrders", reflect.TypeOf((*MockIUseCase)(nil).UploadOrders), host, cookie, ids)
+}
+
+// VerifyTwoFactor mocks base method.
+func (m *MockIUseCase) VerifyTwoFactor(login, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyTwoFactor", login, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyTwoFactor indicates an expected call of VerifyTwoFactor.
+func (mr *MockIUseCaseMockRecorder) VerifyTwoFactor(login, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyTwoFactor", reflect.TypeOf((*MockIUseCase)(nil).VerifyTwoFactor), login, code)
+}
+
+// WriteStatement mocks base method.
+func (m *MockIUseCase) WriteStatement(cookie string, from, to time.Time, format string, w io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteStatement", cookie, from, to, format, w)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// updateStatus indicates an expected call of updateStatus.
-func (mr *MockIUseCaseMockRecorder) updateStatus(username, host, id interface{}) *gomock.Call {
+// WriteStatement indicates an expected call of WriteStatement.
+func (mr *MockIUseCaseMockRecorder) WriteStatement(cookie, from, to, format, w interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateStatus", reflect.TypeOf((*MockIUseCase)(nil).updateStatus), username, host, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteStatement", reflect.TypeOf((*MockIUseCase)(nil).WriteStatement), cookie, from, to, format, w)
 }
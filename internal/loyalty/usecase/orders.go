@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/pkg/luhn"
+)
+
+// MaxBatchOrders is the max number of orders uploaded at once.
+const MaxBatchOrders = 1000
+
+// UploadOrders adds a batch of orders and queues the accepted ones for polling the accrual system.
+// The result lists every number in the order of upload.
+func (uc UseCase) UploadOrders(host, cookie string, ids []string) ([]byte, error) {
+	if len(ids) == 0 || len(ids) > MaxBatchOrders {
+		return nil, storage.ErrBadBatch
+	}
+
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := uc.storage.IsBlocked(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if blocked {
+		return nil, storage.ErrUserBlocked
+	}
+
+	err = uc.checkThrottle(username)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]schema.BatchOrderResult, len(ids))
+	valid := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for i, id := range ids {
+		id = luhn.Normalize(id)
+		results[i].Number = id
+		if !luhn.Valid(id) {
+			results[i].Status = storage.OrderInvalid
+			continue
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			valid = append(valid, id)
+		}
+	}
+
+	// the places are taken first, the orders stored without them would never be polled
+	err = uc.config.poller.reserve(len(valid))
+	if err != nil {
+		return nil, err
+	}
+
+	added := map[string]string{}
+	if len(valid) > 0 {
+		added, err = uc.storage.AddOrders(username, valid)
+		if err != nil {
+			uc.config.poller.release(len(valid))
+			return nil, err
+		}
+	}
+
+	activity := storage.RiskActivity{Uploads: len(ids)}
+	polled := make(map[string]bool, len(added))
+	for i := range results {
+		id := results[i].Number
+		if results[i].Status == storage.OrderInvalid {
+			activity.Invalid++
+			continue
+		}
+
+		results[i].Status = added[id]
+		if results[i].Status == storage.OrderOwnedByAnotherUser {
+			activity.Foreign++
+		}
+
+		if results[i].Status != storage.OrderAccepted {
+			continue
+		}
+
+		// a number repeated in the batch is accepted once
+		if polled[id] {
+			results[i].Status = storage.OrderDuplicate
+			continue
+		}
+
+		polled[id] = true
+		uc.poll(username, host, id)
+	}
+
+	uc.config.poller.release(len(valid) - len(polled))
+	if hidesOwners(uc.recordRisk(username, activity)) {
+		hideOwners(results)
+	}
+
+	return json.Marshal(results)
+}
+
+// hideOwners reports the numbers of other users like the new ones, a repeated number is a duplicate.
+func hideOwners(results []schema.BatchOrderResult) {
+	hidden := make(map[string]bool)
+	for i := range results {
+		if results[i].Status != storage.OrderOwnedByAnotherUser {
+			continue
+		}
+
+		results[i].Status = storage.OrderAccepted
+		if hidden[results[i].Number] {
+			results[i].Status = storage.OrderDuplicate
+		}
+
+		hidden[results[i].Number] = true
+	}
+}
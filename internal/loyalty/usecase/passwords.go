@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gomarket/internal/loyalty/cookies"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/notify"
+	"log"
+	"time"
+)
+
+// CheckToken rejects the tokens of the previous generations and of the deleted users.
+func (uc UseCase) CheckToken(cookie string) error {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return err
+	}
+
+	generation, err := uc.storage.GetTokenGeneration(username)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return storage.ErrTokenRevoked
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if generation != cookies.Generation(cookie) {
+		return storage.ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// ChangePassword returns the next token generation, the tokens of the other sessions are revoked.
+func (uc UseCase) ChangePassword(cookie, oldPasswd, newPasswd string) (int64, error) {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return 0, err
+	}
+
+	if newPasswd == "" {
+		return 0, storage.ErrBadPassword
+	}
+
+	err = uc.storage.CheckPassword(username, oldPasswd)
+	if err != nil {
+		return 0, err
+	}
+
+	generation, err := uc.storage.ChangePassword(username, newPasswd)
+	if err != nil {
+		return 0, err
+	}
+
+	uc.notify(username, "Password changed", "The password of your account was changed, the other sessions were signed out.")
+	return generation, nil
+}
+
+// RequestPasswordReset sends a single-use reset token to the user.
+// Unknown logins are not reported, so the response doesn't tell which logins exist.
+// The token is delivered in the background, so the response takes as long for them as for the known ones.
+func (uc UseCase) RequestPasswordReset(login string) error {
+	token, err := newResetToken()
+	if err != nil {
+		return err
+	}
+
+	err = uc.storage.CreatePasswordReset(login, hashResetToken(token), time.Now().Add(uc.config.ResetTTL))
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Use the token %s to set a new password within %s. Ignore this message if you didn't ask for it.", token, uc.config.ResetTTL)
+	go uc.notify(login, "Password reset", body)
+	return nil
+}
+
+// ResetPassword sets the password with the reset token and revokes all the sessions of the user.
+// It returns the login the token was issued to.
+func (uc UseCase) ResetPassword(token, passwd string) (string, error) {
+	if passwd == "" {
+		return "", storage.ErrBadPassword
+	}
+
+	username, _, err := uc.storage.ResetPassword(hashResetToken(token), passwd, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	uc.notify(username, "Password changed", "The password of your account was reset, all the sessions were signed out.")
+	return username, nil
+}
+
+// notify tells the user about a change already made or sends the reset token, a failed delivery is only logged.
+func (uc UseCase) notify(username, subject, body string) {
+	err := uc.config.Notifier.Notify(notify.Message{To: program.Qualify(uc.program.ID, username), Subject: subject, Body: body})
+	if err != nil {
+		log.Println("notify:", err)
+	}
+}
+
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
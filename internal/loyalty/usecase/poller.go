@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"errors"
+	"gomarket/internal/loyalty/accrual"
+	"gomarket/internal/loyalty/storage"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPollWorkers = 16
+	defaultPollQueue   = 10000
+	defaultPollTimeout = 24 * time.Hour
+	// the order is polled every pollDelay while the accrual system processes it,
+	// the failed requests back off up to maxPollDelay
+	pollDelay    = time.Second
+	maxPollDelay = time.Minute
+)
+
+// poller polls the accrual system for the accepted orders with a fixed number of workers.
+// A worker makes one request per job and schedules the next one, so the orders the accrual
+// system doesn't know don't hold the workers. It's shared by the programs.
+type poller struct {
+	jobs    chan *pollJob
+	size    int64
+	pending int64
+	timeout time.Duration
+}
+
+type pollJob struct {
+	uc         UseCase
+	username   string
+	host       string
+	id         string
+	registered bool
+	delay      time.Duration
+	deadline   time.Time
+}
+
+func newPoller(workers, queue int, timeout time.Duration) *poller {
+	if workers <= 0 {
+		workers = defaultPollWorkers
+	}
+
+	if queue <= 0 {
+		queue = defaultPollQueue
+	}
+
+	if timeout <= 0 {
+		timeout = defaultPollTimeout
+	}
+
+	// every pending order has a place in the channel, so the jobs are put back without blocking
+	p := &poller{jobs: make(chan *pollJob, queue), size: int64(queue), timeout: timeout}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+// reserve takes the places of n orders, it fails without waiting when the queue is full.
+func (p *poller) reserve(n int) error {
+	if atomic.AddInt64(&p.pending, int64(n)) > p.size {
+		atomic.AddInt64(&p.pending, -int64(n))
+		return storage.ErrPollQueueFull
+	}
+
+	return nil
+}
+
+// release frees the places of n orders.
+func (p *poller) release(n int) {
+	atomic.AddInt64(&p.pending, -int64(n))
+}
+
+func (p *poller) work() {
+	for job := range p.jobs {
+		done, err := job.uc.pollStatus(job)
+		// the open circuit, the rate limit and the unknown orders are waited out without logging every request
+		if err != nil && !errors.Is(err, accrual.ErrOpen) && !errors.Is(err, accrual.ErrRateLimited) &&
+			!errors.Is(err, accrual.ErrNotRegistered) {
+			log.Println(err)
+		}
+
+		if done {
+			p.release(1)
+			continue
+		}
+
+		if time.Now().After(job.deadline) {
+			log.Printf("order %s wasn't processed by the accrual system in %s, the polling stopped", job.id, p.timeout)
+			p.release(1)
+			continue
+		}
+
+		switch {
+		case errors.Is(err, accrual.ErrRateLimited):
+			// the next request waits for the time the accrual system asked for
+			job.delay = time.Until(job.uc.config.Accrual.RetryAt(job.host))
+		case err != nil:
+			job.delay = minDuration(2*job.delay, maxPollDelay)
+		default:
+			job.delay = pollDelay
+		}
+
+		p.retry(job)
+	}
+}
+
+func (p *poller) retry(job *pollJob) {
+	time.AfterFunc(job.delay, func() {
+		p.jobs <- job
+	})
+}
+
+// poll queues the order on the place taken by reserve.
+func (uc UseCase) poll(username, host, id string) {
+	p := uc.config.poller
+	p.retry(&pollJob{
+		uc:       uc,
+		username: username,
+		host:     host,
+		id:       id,
+		delay:    pollDelay,
+		deadline: time.Now().Add(p.timeout),
+	})
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+
+	return b
+}
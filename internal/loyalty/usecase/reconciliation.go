@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"gomarket/internal/loyalty/accrual"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// reconciliationActor is the admin name of the periodic reconciliation and of its adjustments.
+const reconciliationActor = "reconciliation"
+
+// accrualTolerance is the least difference of the accruals that is a discrepancy,
+// the accrual system reports kopecks.
+const accrualTolerance = 0.005
+
+// Reconcile compares the processed orders with the accrual system and stores the report.
+// With req.Apply the differences are credited or written off through adjustments,
+// otherwise nothing but the report is changed.
+func (uc UseCase) Reconcile(admin, host string, req schema.ReconciliationRequest) ([]byte, error) {
+	report, err := uc.reconcile(admin, host, req)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(report)
+}
+
+func (uc UseCase) reconcile(admin, host string, req schema.ReconciliationRequest) (schema.Reconciliation, error) {
+	if uc.program.AccrualAddress != "" {
+		host = uc.program.AccrualAddress
+	}
+
+	window := uc.config.ReconcileWindow
+	if req.Days > 0 {
+		window = time.Duration(req.Days) * 24 * time.Hour
+	}
+
+	report := schema.Reconciliation{
+		Since:         time.Now().Add(-window),
+		Applied:       req.Apply,
+		Admin:         admin,
+		Discrepancies: make([]schema.Discrepancy, 0),
+	}
+
+	orders, err := uc.storage.GetProcessedOrders(report.Since)
+	if err != nil {
+		return schema.Reconciliation{}, err
+	}
+
+	if req.Sample > 0 && req.Sample < len(orders) {
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		random.Shuffle(len(orders), func(i, j int) {
+			orders[i], orders[j] = orders[j], orders[i]
+		})
+		orders = orders[:req.Sample]
+	}
+
+	for _, order := range orders {
+		fresh, err := uc.config.Accrual.Recheck(host, order.Number)
+		if err != nil {
+			report.Failed++
+			continue
+		}
+
+		// a status in between is reported while the accrual system processes the order again,
+		// the order is compared by the next reconciliation
+		if fresh.Status != accrual.StatusProcessed && fresh.Status != accrual.StatusInvalid {
+			report.Failed++
+			continue
+		}
+
+		report.Checked++
+		d, ok := uc.discrepancy(order, fresh)
+		if !ok {
+			continue
+		}
+
+		if req.Apply {
+			uc.correct(&d)
+		}
+
+		report.Mismatched++
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+
+	return uc.storage.AddReconciliation(report)
+}
+
+// discrepancy compares the order with the fresh final response of the accrual system.
+// The orders processed before the reported accruals were stored are compared at the rate of the program.
+func (uc UseCase) discrepancy(order storage.ProcessedOrder, fresh schema.ResponseFromTheCalculationSystem) (schema.Discrepancy, bool) {
+	reported := order.Accrual / uc.program.Rate
+	if order.Reported != nil {
+		reported = *order.Reported
+	}
+
+	// an order the accrual system found invalid is worth nothing
+	expected := 0.0
+	if fresh.Status == accrual.StatusProcessed {
+		expected = fresh.Accrual
+	}
+
+	diff := expected - reported
+	if math.Abs(diff) < accrualTolerance {
+		return schema.Discrepancy{}, false
+	}
+
+	// the difference is converted to points at the multiplier the order was credited at,
+	// without the campaigns that applied when the order was processed
+	correction := diff * uc.program.Rate * uc.orderMultiplier(order)
+	return schema.Discrepancy{
+		Number:     order.Number,
+		Login:      order.Owner,
+		Accrual:    order.Accrual,
+		Reported:   order.Reported,
+		Status:     fresh.Status,
+		Fresh:      expected,
+		Correction: math.Round(correction*100) / 100,
+	}, true
+}
+
+// orderMultiplier returns the tier multiplier the order was credited at. The orders processed
+// before it was stored are corrected at the tier of the user.
+func (uc UseCase) orderMultiplier(order storage.ProcessedOrder) float64 {
+	if order.Multiplier != nil {
+		return *order.Multiplier
+	}
+
+	t, err := uc.storedTier(order.Owner)
+	if err != nil {
+		log.Println("storedTier:", err)
+	}
+
+	return t.Multiplier
+}
+
+// correct applies the correction of the discrepancy, a failure is kept in the report
+// and the order is compared again by the next reconciliation.
+func (uc UseCase) correct(d *schema.Discrepancy) {
+	if d.Correction == 0 {
+		// nothing to credit, the reported accrual is updated only
+		if err := uc.storage.SetReported(d.Number, d.Fresh); err != nil {
+			d.Error = err.Error()
+		}
+		return
+	}
+
+	reason := "reconciliation of order " + d.Number
+	adjustment, err := uc.storage.CorrectAccrual(d.Login, d.Number, d.Fresh, d.Correction, reason, reconciliationActor)
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+
+	d.AdjustmentID = &adjustment.ID
+}
+
+func (uc UseCase) GetReconciliations(admin string, limit, offset int) ([]byte, error) {
+	err := uc.audit(admin, "get_reconciliations", "", "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	limit, offset = page(limit, offset)
+	reports, err := uc.storage.GetReconciliations(limit, offset)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(reports)
+}
+
+func (uc UseCase) GetReconciliation(admin string, id int64) ([]byte, error) {
+	err := uc.audit(admin, "get_reconciliation", "", strconv.FormatInt(id, 10))
+	if err != nil {
+		return []byte(""), err
+	}
+
+	report, err := uc.storage.GetReconciliation(id)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(report)
+}
+
+// RunReconciliation reconciles the default program and the programs every ReconcileInterval until ctx is done,
+// host is the accrual system of the programs without their own.
+func (uc UseCase) RunReconciliation(ctx context.Context, host string, programs []program.Program) {
+	ticker := time.NewTicker(uc.config.ReconcileInterval)
+	defer ticker.Stop()
+
+	req := schema.ReconciliationRequest{Sample: uc.config.ReconcileSample, Apply: uc.config.ReconcileApply}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		uc.logReconciliation(uc.program.ID, host, req)
+		for _, p := range programs {
+			uc.forProgram(p).logReconciliation(p.ID, host, req)
+		}
+	}
+}
+
+func (uc UseCase) logReconciliation(id, host string, req schema.ReconciliationRequest) {
+	report, err := uc.reconcile(reconciliationActor, host, req)
+	if err != nil {
+		log.Println("Reconcile:", id, err)
+		return
+	}
+
+	if report.Mismatched > 0 || report.Failed > 0 {
+		log.Printf("Reconciliation %d of %s: %d checked, %d mismatched, %d failed",
+			report.ID, id, report.Checked, report.Mismatched, report.Failed)
+	}
+}
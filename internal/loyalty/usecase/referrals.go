@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// completeReferral rewards the referral of the user once the first order is processed,
+// the referral is rejected if the accrual reported for the order is too small to be rewarded.
+func (uc UseCase) completeReferral(username string, accrual float64) {
+	if uc.config.ReferralBonus <= 0 {
+		return
+	}
+
+	var err error
+	if accrual < uc.config.ReferralMinAccrual {
+		err = uc.storage.RejectReferral(username)
+	} else {
+		err = uc.storage.RewardReferral(username, uc.config.ReferralBonus, uc.config.ReferralMax)
+	}
+
+	if err != nil {
+		log.Println("completeReferral:", err)
+	}
+}
+
+func (uc UseCase) GetReferrals(cookie string) ([]byte, error) {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	referrals, err := uc.storage.GetReferrals(username)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(referrals)
+}
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gomarket/internal/loyalty/risk"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"log"
+	"time"
+)
+
+// riskActor is the admin name of the blocks made by the risk rules.
+const riskActor = "risk"
+
+// checkThrottle rejects the uploads and the withdrawals of a throttled user.
+func (uc UseCase) checkThrottle(username string) error {
+	if !uc.config.Risk.Enabled() {
+		return nil
+	}
+
+	until, err := uc.storage.GetThrottledUntil(username)
+	if err != nil {
+		return err
+	}
+
+	if time.Now().Before(until) {
+		return risk.ErrThrottled
+	}
+
+	return nil
+}
+
+// recordRisk adds the activity, applies the rules triggered within the window and returns them.
+// The failures are only logged, the risk rules must not break the request they judge.
+func (uc UseCase) recordRisk(username string, a storage.RiskActivity) []risk.Hit {
+	policy := uc.config.Risk
+	if !policy.Enabled() {
+		return nil
+	}
+
+	err := uc.storage.AddRiskActivity(username, a)
+	if err != nil {
+		log.Println("AddRiskActivity:", err)
+		return nil
+	}
+
+	now := time.Now()
+	window := now.Add(-policy.Window)
+	total, err := uc.storage.GetRiskActivity(username, window)
+	if err != nil {
+		log.Println("GetRiskActivity:", err)
+		return nil
+	}
+
+	hits := policy.Evaluate(risk.Activity(total))
+	for _, hit := range hits {
+		flag := schema.RiskFlag{
+			Login:     username,
+			Signal:    hit.Signal,
+			Value:     hit.Value,
+			Threshold: hit.Threshold,
+			Action:    hit.Action,
+		}
+
+		// a throttle is renewed once the previous one ends
+		since := window
+		if hit.Action == risk.Throttle {
+			until := now.Add(policy.Throttle)
+			flag.Until = &until
+			since = now.Add(-policy.Throttle)
+		}
+
+		added, err := uc.storage.AddRiskFlag(flag, since)
+		if err != nil {
+			log.Println("AddRiskFlag:", err)
+			continue
+		}
+
+		if added && hit.Action == risk.Block {
+			reason := fmt.Sprintf("%s %g reached %g", hit.Signal, hit.Value, hit.Threshold)
+			err = uc.storage.SetBlocked(username, true, reason, riskActor)
+			if err != nil {
+				log.Println("SetBlocked:", err)
+			}
+		}
+	}
+
+	return hits
+}
+
+// hidesOwners tells whether the user is flagged for uploading the numbers of other users.
+// Such a user gets the same answer for those numbers as for the new ones, so the uploads
+// stop telling which numbers exist. They are still counted as foreign, and the order stays with its owner.
+func hidesOwners(hits []risk.Hit) bool {
+	for _, hit := range hits {
+		if hit.Signal == risk.ForeignRate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uploadActivity counts an upload by the error of storing it, the failed uploads are not counted.
+func uploadActivity(err error) (storage.RiskActivity, bool) {
+	switch {
+	case err == nil, errors.Is(err, storage.ErrCreatedByThisUser):
+		return storage.RiskActivity{Uploads: 1}, true
+	case errors.Is(err, storage.ErrCreatedByAnotherUser):
+		return storage.RiskActivity{Uploads: 1, Foreign: 1}, true
+	}
+
+	return storage.RiskActivity{}, false
+}
+
+// GetRiskFlags returns the flags of the user or of everyone when the login is empty.
+func (uc UseCase) GetRiskFlags(admin, login string, limit, offset int) ([]byte, error) {
+	err := uc.audit(admin, "get_risk_flags", login, "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	limit, offset = page(limit, offset)
+	flags, err := uc.storage.GetRiskFlags(login, limit, offset)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(flags)
+}
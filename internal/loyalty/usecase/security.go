@@ -0,0 +1,27 @@
+package usecase
+
+import (
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+)
+
+// RecordSecurityEvent appends the event to the security audit log.
+func (uc UseCase) RecordSecurityEvent(e schema.SecurityEvent) error {
+	return uc.storage.AddSecurityEvent(e)
+}
+
+func (uc UseCase) GetSecurityEvents(admin string, f storage.SecurityEventFilter, limit, offset int) ([]byte, error) {
+	err := uc.audit(admin, "get_security_events", f.Login, "")
+	if err != nil {
+		return []byte(""), err
+	}
+
+	limit, offset = page(limit, offset)
+	events, err := uc.storage.GetSecurityEvents(f, limit, offset)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(events)
+}
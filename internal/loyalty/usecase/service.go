@@ -1,24 +1,155 @@
 package usecase
 
 import (
+	"gomarket/internal/loyalty/accrual"
+	"gomarket/internal/loyalty/program"
+	"gomarket/internal/loyalty/risk"
+	"gomarket/internal/loyalty/schema"
 	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/tier"
+	"gomarket/internal/loyalty/withdrawal"
+	"gomarket/internal/notify"
+	"io"
+	"time"
 )
 
 type UseCase struct {
 	storage storage.IStorage
+	config  *Config
+	program program.Program
+}
+
+// Config contains the rules of the loyalty program.
+type Config struct {
+	// PointsTTL is the lifetime of credited points, zero means points never expire.
+	PointsTTL time.Duration
+	// ExpiringSoon is how far ahead the balance warns about expiring points.
+	ExpiringSoon time.Duration
+	// ExpiryInterval is how often the expiry job runs, zero disables the job.
+	ExpiryInterval time.Duration
+	// Tiers are disabled when empty.
+	Tiers tier.Tiers
+	// TierWindow is the rolling window of accruals that count towards a tier, zero means all time.
+	TierWindow time.Duration
+	// Transfer limits, zero TransferMax and TransferDailyMax mean no limit.
+	TransferMin      float64
+	TransferMax      float64
+	TransferDailyMax float64
+	// TransferConfirmation makes transfers wait until the recipient accepts them.
+	TransferConfirmation bool
+	// ReferralBonus is credited to both users when the first order of the referred one is processed,
+	// zero disables referrals and the codes sent at registration are ignored.
+	ReferralBonus float64
+	// Referral limits, zero ReferralMax and ReferralDailyMax mean no limit.
+	// ReferralMax caps the rewarded referrals of a user, ReferralDailyMax caps the sign-ups by a code per day.
+	ReferralMax      int
+	ReferralDailyMax int
+	// ReferralMinAccrual is the least accrual of the first order that rewards the referral,
+	// it's the reported accrual in points before the tier multiplier and the campaigns.
+	ReferralMinAccrual float64
+	// Withdrawal is checked before the points are spent on an order.
+	Withdrawal withdrawal.Policy
+	// Deletion decides what happens to the data and the balance of a deleted account.
+	Deletion storage.DeletionPolicy
+	// TOTPIssuer names the accounts of the programs without a name in the authenticator apps.
+	TOTPIssuer string
+	// StepUpAbove is the sum of a withdrawal above which the two-factor code is required, zero disables the step-up.
+	StepUpAbove float64
+	// Notifier delivers the password reset tokens and the security notices to the users.
+	Notifier notify.Notifier
+	// ResetTTL is the lifetime of a password reset token.
+	ResetTTL time.Duration
+	// Accrual requests the accrual system, it is shared by the programs.
+	Accrual *accrual.Client
+	// ReconcileInterval is how often the processed orders are compared with the accrual system, zero disables the job.
+	ReconcileInterval time.Duration
+	// ReconcileWindow is how far back the orders are compared.
+	ReconcileWindow time.Duration
+	// ReconcileSample is the number of random orders the job compares, zero compares all of them.
+	ReconcileSample int
+	// ReconcileApply makes the job correct the balances, otherwise it only reports the discrepancies.
+	ReconcileApply bool
+	// Risk flags, throttles or blocks the users whose uploads and withdrawals look like fraud.
+	Risk risk.Policy
+	// PollWorkers is the number of requests polling the accrual system at once,
+	// PollQueue is the number of orders being polled, the uploads over it are rejected.
+	// PollTimeout is how long an order is polled before it's given up. Zero means the defaults.
+	PollWorkers int
+	PollQueue   int
+	PollTimeout time.Duration
+
+	poller *poller
 }
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
 type IUseCase interface {
-	CreateUser(login, passwd string) error
+	CreateUser(login, passwd, referralCode string) error
 	CheckPassword(login, passwd string) error
 	CheckID(host, cookie, id string) error
 	GetBalance(cookie string) ([]byte, error)
-	DrawBonuses(cookie string, sum float64, orderID string) error
+	DrawBonuses(cookie string, sum, orderTotal float64, orderID, otp string) error
+	DrawServiceBonuses(cookie string, sum, orderTotal float64, orderID string) error
 	GetWithdrawals(cookie string) ([]byte, error)
 	GetOrders(cookie string) ([]byte, error)
+	Transfer(cookie, recipient string, sum float64) (schema.Transfer, error)
+	AcceptTransfer(cookie string, id int64) error
+	DeclineTransfer(cookie string, id int64) error
+	GetTransfers(cookie string) ([]byte, error)
+	FindUsers(admin, query string, limit, offset int) ([]byte, error)
+	GetUser(admin, login string) ([]byte, error)
+	GetUserOrders(admin, login string) ([]byte, error)
+	GetUserWithdrawals(admin, login string) ([]byte, error)
+	AdjustBalance(admin, login string, sum float64, reason string) ([]byte, error)
+	BlockUser(admin, login, reason string, blocked bool) error
+	GetAdminActions(admin, target string, limit, offset int) ([]byte, error)
+	WriteStatement(cookie string, from, to time.Time, format string, w io.Writer) error
+	UploadOrders(host, cookie string, ids []string) ([]byte, error)
+	CreateCampaign(admin string, req schema.CampaignRequest) ([]byte, error)
+	UpdateCampaign(admin string, id int64, req schema.CampaignRequest) ([]byte, error)
+	GetCampaigns(admin string) ([]byte, error)
+	GetReferrals(cookie string) ([]byte, error)
+	AddSession(login, ip, userAgent string) (int64, error)
+	Export(cookie string) ([]byte, error)
+	DeleteUser(cookie string) ([]byte, error)
+	EnrollTwoFactor(cookie string) ([]byte, error)
+	ConfirmTwoFactor(cookie, code string) error
+	DisableTwoFactor(cookie, code string) error
+	VerifyTwoFactor(login, code string) error
+	CheckToken(cookie string) error
+	ChangePassword(cookie, oldPasswd, newPasswd string) (int64, error)
+	RequestPasswordReset(login string) error
+	ResetPassword(token, passwd string) (string, error)
+	RecordSecurityEvent(e schema.SecurityEvent) error
+	GetSecurityEvents(admin string, f storage.SecurityEventFilter, limit, offset int) ([]byte, error)
+	GetRiskFlags(admin, login string, limit, offset int) ([]byte, error)
+	Reconcile(admin, host string, req schema.ReconciliationRequest) ([]byte, error)
+	GetReconciliations(admin string, limit, offset int) ([]byte, error)
+	GetReconciliation(admin string, id int64) ([]byte, error)
+	CheckBalances(admin string, req schema.BalanceCheckRequest) ([]byte, error)
+	// ForProgram returns the use case of the program, the rules of Config are shared by all programs.
+	ForProgram(p program.Program) IUseCase
+}
+
+func New(storage storage.IStorage, cfg *Config) UseCase {
+	if cfg == nil {
+		panic("конфиг равен nil")
+	}
+
+	if cfg.Accrual == nil {
+		cfg.Accrual = accrual.New(accrual.Config{})
+	}
+
+	if cfg.poller == nil {
+		cfg.poller = newPoller(cfg.PollWorkers, cfg.PollQueue, cfg.PollTimeout)
+	}
+
+	return UseCase{storage: storage, config: cfg, program: program.Default("")}
+}
+
+func (uc UseCase) ForProgram(p program.Program) IUseCase {
+	return uc.forProgram(p)
 }
 
-func New(storage storage.IStorage) UseCase {
-	return UseCase{storage: storage}
+func (uc UseCase) forProgram(p program.Program) UseCase {
+	return UseCase{storage: uc.storage.ForProgram(p.ID), config: uc.config, program: p}
 }
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	StatementCSV  = "csv"
+	StatementJSON = "json"
+)
+
+// WriteStatement writes the balance movements of the cookie owner in [from, to)
+// with the opening and closing balances. Entries are written as they are read from the storage.
+func (uc UseCase) WriteStatement(cookie string, from, to time.Time, format string, w io.Writer) error {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return err
+	}
+
+	if to.Before(from) || (format != StatementCSV && format != StatementJSON) {
+		return storage.ErrBadStatement
+	}
+
+	opening, err := uc.storage.GetOpeningBalance(username, from)
+	if err != nil {
+		return err
+	}
+
+	if format == StatementCSV {
+		return uc.writeStatementCSV(username, from, to, roundSum(opening), w)
+	}
+
+	return uc.writeStatementJSON(username, from, to, roundSum(opening), w)
+}
+
+func (uc UseCase) writeStatementCSV(username string, from, to time.Time, opening float64, w io.Writer) error {
+	out := csv.NewWriter(w)
+	balance := opening
+
+	err := out.Write([]string{"date", "type", "reference", "amount", "balance"})
+	if err != nil {
+		return err
+	}
+
+	err = out.Write([]string{from.Format(time.RFC3339), "opening", "", "", formatSum(opening)})
+	if err != nil {
+		return err
+	}
+
+	err = uc.storage.GetStatement(username, from, to, func(entry schema.StatementEntry) error {
+		balance = roundSum(balance + entry.Amount)
+		return out.Write([]string{
+			entry.Date.Format(time.RFC3339), entry.Type, entry.Reference,
+			formatSum(entry.Amount), formatSum(balance),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = out.Write([]string{to.Format(time.RFC3339), "closing", "", "", formatSum(balance)})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+func (uc UseCase) writeStatementJSON(username string, from, to time.Time, opening float64, w io.Writer) error {
+	balance := opening
+
+	header, err := json.Marshal(struct {
+		From           time.Time `json:"from"`
+		To             time.Time `json:"to"`
+		OpeningBalance float64   `json:"opening_balance"`
+	}{from, to, opening})
+	if err != nil {
+		return err
+	}
+
+	// the object is written by parts: {"from":..., "entries": [...], "closing_balance": ...}
+	_, err = w.Write(append(header[:len(header)-1], `,"entries":[`...))
+	if err != nil {
+		return err
+	}
+
+	first := true
+	err = uc.storage.GetStatement(username, from, to, func(entry schema.StatementEntry) error {
+		balance = roundSum(balance + entry.Amount)
+		entry.Balance = balance
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		if !first {
+			line = append([]byte{','}, line...)
+		}
+		first = false
+
+		_, err = w.Write(line)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write([]byte(`],"closing_balance":` + strconv.FormatFloat(balance, 'f', -1, 64) + `}`))
+	return err
+}
+
+func roundSum(sum float64) float64 {
+	return math.Round(sum*100) / 100
+}
+
+func formatSum(sum float64) string {
+	return strconv.FormatFloat(sum, 'f', 2, 64)
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"gomarket/internal/loyalty/tier"
+	"math"
+	"time"
+)
+
+// applyTier multiplies the accrual from the calculation system by the multiplier of the tier.
+func (uc UseCase) applyTier(t tier.Tier, accrual float64) float64 {
+	if len(uc.config.Tiers) == 0 || accrual == 0 {
+		return accrual
+	}
+
+	return math.Round(accrual*t.Multiplier*100) / 100
+}
+
+// storedTier returns the tier recorded for the user by the last refresh.
+func (uc UseCase) storedTier(username string) (tier.Tier, error) {
+	if len(uc.config.Tiers) == 0 {
+		return tier.None, nil
+	}
+
+	balance, err := uc.storage.GetBalance(username)
+	if err != nil {
+		return tier.None, err
+	}
+
+	return uc.config.Tiers.Named(balance.Tier), nil
+}
+
+// refreshTier resolves user's tier from the accruals the calculation system reported within the tier window
+// and records the change into the tier history. The multipliers and the campaigns don't count,
+// so a tier doesn't raise itself.
+func (uc UseCase) refreshTier(username string) (tier.Tier, error) {
+	if len(uc.config.Tiers) == 0 {
+		return tier.None, nil
+	}
+
+	var since time.Time
+	if uc.config.TierWindow > 0 {
+		since = time.Now().Add(-uc.config.TierWindow)
+	}
+
+	reported, err := uc.storage.GetAccrued(username, since)
+	if err != nil {
+		return tier.None, err
+	}
+
+	// the accrual system counts rubles, the thresholds are in points
+	accrued := reported * uc.program.Rate
+	t := uc.config.Tiers.For(accrued)
+	_, err = uc.storage.ChangeTier(username, t.Name, accrued)
+	if err != nil {
+		return tier.None, err
+	}
+
+	return t, nil
+}
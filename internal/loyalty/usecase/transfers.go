@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"time"
+)
+
+// Transfer moves points from the cookie owner to the recipient. When transfers require
+// confirmation the points are held until the recipient accepts or declines the transfer.
+func (uc UseCase) Transfer(cookie, recipient string, sum float64) (schema.Transfer, error) {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	if recipient == "" || recipient == username || sum <= 0 {
+		return schema.Transfer{}, storage.ErrBadTransfer
+	}
+
+	if sum < uc.config.TransferMin || (uc.config.TransferMax > 0 && sum > uc.config.TransferMax) {
+		return schema.Transfer{}, storage.ErrTransferLimit
+	}
+
+	err = uc.checkThrottle(username)
+	if err != nil {
+		return schema.Transfer{}, err
+	}
+
+	limit := storage.TransferLimit{
+		Since: time.Now().Add(-24 * time.Hour),
+		Max:   uc.config.TransferDailyMax,
+	}
+
+	return uc.storage.CreateTransfer(username, recipient, sum, uc.config.TransferConfirmation, limit)
+}
+
+func (uc UseCase) AcceptTransfer(cookie string, id int64) error {
+	return uc.completeTransfer(cookie, id, true)
+}
+
+func (uc UseCase) DeclineTransfer(cookie string, id int64) error {
+	return uc.completeTransfer(cookie, id, false)
+}
+
+func (uc UseCase) completeTransfer(cookie string, id int64, accept bool) error {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return err
+	}
+
+	_, err = uc.storage.CompleteTransfer(id, username, accept)
+	return err
+}
+
+func (uc UseCase) GetTransfers(cookie string) ([]byte, error) {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	transfers, err := uc.storage.GetTransfers(username)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(transfers)
+}
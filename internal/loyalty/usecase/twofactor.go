@@ -0,0 +1,186 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"gomarket/internal/loyalty/schema"
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/totp"
+	"strings"
+	"time"
+)
+
+// recoveryCodes is the number of the recovery codes issued at enrollment.
+const recoveryCodes = 10
+
+const recoveryAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// EnrollTwoFactor issues a new pending secret with the recovery codes,
+// two-factor authentication is enabled after ConfirmTwoFactor.
+func (uc UseCase) EnrollTwoFactor(cookie string) ([]byte, error) {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return []byte(""), err
+	}
+
+	enrollment := schema.TwoFactorEnrollment{
+		Secret:        secret,
+		URI:           totp.URI(uc.issuer(), username, secret),
+		RecoveryCodes: make([]string, 0, recoveryCodes),
+	}
+	hashes := make([]string, 0, recoveryCodes)
+	for i := 0; i < recoveryCodes; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return []byte(""), err
+		}
+
+		enrollment.RecoveryCodes = append(enrollment.RecoveryCodes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	err = uc.storage.SetTwoFactor(username, secret, hashes)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return json.Marshal(enrollment)
+}
+
+// ConfirmTwoFactor enables the pending secret once the user proves the authenticator has it.
+func (uc UseCase) ConfirmTwoFactor(cookie, code string) error {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return err
+	}
+
+	tf, err := uc.storage.GetTwoFactor(username)
+	if err != nil {
+		return err
+	}
+
+	if tf.Enabled {
+		return storage.ErrTwoFactorEnabled
+	}
+
+	if tf.Secret == "" {
+		return storage.ErrTwoFactorDisabled
+	}
+
+	step, ok := totp.Verify(tf.Secret, code, time.Now())
+	if !ok {
+		return storage.ErrWrongTwoFactorCode
+	}
+
+	return uc.storage.EnableTwoFactor(username, step)
+}
+
+// DisableTwoFactor requires a valid code, so a stolen token can't turn the protection off.
+func (uc UseCase) DisableTwoFactor(cookie, code string) error {
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return err
+	}
+
+	tf, err := uc.storage.GetTwoFactor(username)
+	if err != nil {
+		return err
+	}
+
+	if !tf.Enabled {
+		return storage.ErrTwoFactorDisabled
+	}
+
+	err = uc.verifyCode(username, tf, code)
+	if err != nil {
+		return err
+	}
+
+	return uc.storage.DisableTwoFactor(username)
+}
+
+// VerifyTwoFactor is the challenge of the login, it passes the users without two-factor authentication.
+func (uc UseCase) VerifyTwoFactor(login, code string) error {
+	tf, err := uc.storage.GetTwoFactor(login)
+	if err != nil {
+		return err
+	}
+
+	if !tf.Enabled {
+		return nil
+	}
+
+	return uc.verifyCode(login, tf, code)
+}
+
+// stepUp requires the code for the withdrawals above the threshold,
+// the users without two-factor authentication can't make them.
+func (uc UseCase) stepUp(username string, sum float64, code string) error {
+	threshold := uc.config.StepUpAbove
+	if threshold <= 0 || sum <= threshold {
+		return nil
+	}
+
+	tf, err := uc.storage.GetTwoFactor(username)
+	if err != nil {
+		return err
+	}
+
+	if !tf.Enabled {
+		return storage.ErrTwoFactorDisabled
+	}
+
+	return uc.verifyCode(username, tf, code)
+}
+
+// verifyCode accepts a code of the authenticator or an unused recovery code, each of them once.
+func (uc UseCase) verifyCode(username string, tf storage.TwoFactor, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return storage.ErrTwoFactorRequired
+	}
+
+	if step, ok := totp.Verify(tf.Secret, code, time.Now()); ok {
+		return uc.storage.UseTOTPStep(username, step)
+	}
+
+	return uc.storage.UseRecoveryCode(username, hashRecoveryCode(code))
+}
+
+// issuer names the account in the authenticator apps.
+func (uc UseCase) issuer() string {
+	if uc.program.Name != "" {
+		return uc.program.Name
+	}
+
+	return uc.config.TOTPIssuer
+}
+
+// newRecoveryCode returns a code like ABCDE-FGH23.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = recoveryAlphabet[int(b[i])%len(recoveryAlphabet)]
+	}
+
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// hashRecoveryCode ignores the case and the dash, so the codes can be typed either way.
+func hashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
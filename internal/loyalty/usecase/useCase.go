@@ -3,18 +3,26 @@ package usecase
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"gomarket/internal/loyalty/schema"
 	"gomarket/internal/loyalty/storage"
-	"io"
+	"gomarket/pkg/luhn"
 	"log"
-	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
 
-func (uc UseCase) CreateUser(login, passwd string) error {
-	return uc.storage.CreateUser(login, passwd)
+func (uc UseCase) CreateUser(login, passwd, referralCode string) error {
+	if referralCode == "" || uc.config.ReferralBonus <= 0 {
+		return uc.storage.CreateUser(login, passwd)
+	}
+
+	limit := storage.ReferralLimit{
+		Since: time.Now().Add(-24 * time.Hour),
+		Max:   uc.config.ReferralDailyMax,
+	}
+
+	return uc.storage.CreateReferredUser(login, passwd, strings.ToUpper(strings.TrimSpace(referralCode)), limit)
 }
 
 func (uc UseCase) CheckPassword(login, passwd string) error {
@@ -22,89 +30,132 @@ func (uc UseCase) CheckPassword(login, passwd string) error {
 }
 
 func (uc UseCase) CheckID(host, cookie, id string) error {
-	if !allCharsIsDigits(id) {
-		return storage.ErrBadID
+	username, err := getUsernameFromCookie(cookie)
+	if err != nil {
+		return err
 	}
 
-	username, err := getUsernameFromCookie(cookie)
+	blocked, err := uc.storage.IsBlocked(username)
 	if err != nil {
 		return err
 	}
 
-	ID, err := strconv.Atoi(id)
+	if blocked {
+		return storage.ErrUserBlocked
+	}
+
+	err = uc.checkThrottle(username)
 	if err != nil {
-		return storage.ErrBadID
+		return err
 	}
 
-	if !Valid(ID) {
+	id = luhn.Normalize(id)
+	if !luhn.Valid(id) {
+		uc.recordRisk(username, storage.RiskActivity{Uploads: 1, Invalid: 1})
 		return storage.ErrBadID
 	}
 
+	// the place is taken first, an order stored without it would never be polled
+	err = uc.config.poller.reserve(1)
+	if err != nil {
+		return err
+	}
+
 	err = uc.storage.CheckID(username, id)
+	if activity, ok := uploadActivity(err); ok {
+		hits := uc.recordRisk(username, activity)
+		if errors.Is(err, storage.ErrCreatedByAnotherUser) && hidesOwners(hits) {
+			uc.config.poller.release(1)
+			return nil
+		}
+	}
+
 	if err != nil {
+		uc.config.poller.release(1)
 		return err
 	}
 
-	go uc.updateStatus(username, host, id)
+	uc.poll(username, host, id)
 
 	return nil
 }
 
-func (uc UseCase) updateStatus(username, host, id string) {
-	ticker := time.NewTicker(1 * time.Second)
-	status := ""
-	firstTime := true
-	accrual := 0.0
+// pollStatus requests the order in the accrual system once and saves its final status.
+// It returns false while the order has to be requested again.
+func (uc UseCase) pollStatus(job *pollJob) (bool, error) {
+	host := job.host
+	if uc.program.AccrualAddress != "" {
+		host = uc.program.AccrualAddress
+	}
 
-	for status != "PROCESSED" && status != "INVALID" {
-		<-ticker.C
-		res, err := http.Get(host + "/api/orders/" + id)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-		switch res.StatusCode {
-		case http.StatusNoContent:
-			log.Println("No content")
-			continue
-		case http.StatusInternalServerError:
-			log.Println("Calc storage error")
-			continue
-		case http.StatusTooManyRequests:
-			log.Println("Too many request")
-			continue
-		}
+	response, err := uc.config.Accrual.Order(host, job.id)
+	if err != nil {
+		return false, err
+	}
 
-		read, err := io.ReadAll(res.Body)
+	if !job.registered {
+		err = uc.storage.UpdateOrder(job.username, job.id, "REGISTERED", 0, nil)
 		if err != nil {
 			log.Println(err)
-			continue
 		}
+		job.registered = true
+	}
+
+	if response.Status != "PROCESSED" && response.Status != "INVALID" {
+		return false, nil
+	}
+
+	uc.updateStatus(job.username, job.id, response.Status, response.Accrual)
+	return true, nil
+}
+
+// updateStatus saves the final status of the order with the points for the reported accrual.
+func (uc UseCase) updateStatus(username, id, status string, reported float64) {
+	// the tier of the current window, the accruals that left it don't count anymore
+	t, err := uc.refreshTier(username)
+	if err != nil {
+		log.Println("refreshTier:", err)
+	}
+
+	// the accrual system counts rubles, the program converts them to points
+	accrual := uc.applyTier(t, reported*uc.program.Rate)
 
-		res.Body.Close()
+	var campaigns []schema.AppliedCampaign
+	if status == "PROCESSED" {
+		accrual, campaigns = uc.applyCampaigns(username, id, accrual)
+	}
 
-		var response schema.ResponseFromTheCalculationSystem
-		err = json.Unmarshal(read, &response)
+	err = uc.storage.UpdateOrder(username, id, status, accrual, campaigns)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// the reconciliation compares the accrual system with what it reported, not with the points
+	if status == "PROCESSED" {
+		err = uc.storage.SetReported(id, reported)
 		if err != nil {
-			log.Println(err)
-			continue
+			log.Println("SetReported:", err)
 		}
 
-		if firstTime {
-			err = uc.storage.UpdateOrder(username, id, "REGISTERED", 0)
-			if err != nil {
-				log.Println(err)
-			}
-			firstTime = false
-			accrual = response.Accrual
+		// the reconciliation corrects the order at the multiplier it was credited at
+		err = uc.storage.SetMultiplier(id, t.Multiplier)
+		if err != nil {
+			log.Println("SetMultiplier:", err)
 		}
+	}
 
-		status = response.Status
+	// the accrual of the order counts towards the tier of the next ones
+	if status == "PROCESSED" && reported > 0 {
+		_, err = uc.refreshTier(username)
+		if err != nil {
+			log.Println("refreshTier:", err)
+		}
 	}
 
-	err := uc.storage.UpdateOrder(username, id, status, accrual)
-	if err != nil {
-		log.Println(err)
+	// the tier and the campaigns don't help the order to reach the referral minimum
+	if status == "PROCESSED" {
+		uc.completeReferral(username, reported*uc.program.Rate)
 	}
 }
 
@@ -119,6 +170,18 @@ func (uc UseCase) GetBalance(cookie string) ([]byte, error) {
 		return []byte(""), err
 	}
 
+	balance.ExpiringSoon, err = uc.expiringSoon(username)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	// the tier of the current window, it's empty while the tiers are disabled
+	t, err := uc.refreshTier(username)
+	if err != nil {
+		return []byte(""), err
+	}
+	balance.Tier = t.Name
+
 	res, err := json.Marshal(balance)
 	if err != nil {
 		return []byte(""), err
@@ -127,8 +190,19 @@ func (uc UseCase) GetBalance(cookie string) ([]byte, error) {
 	return res, nil
 }
 
-func (uc UseCase) DrawBonuses(cookie string, sum float64, orderID string) error {
-	if !allCharsIsDigits(orderID) {
+func (uc UseCase) DrawBonuses(cookie string, sum, orderTotal float64, orderID, otp string) error {
+	return uc.drawBonuses(cookie, sum, orderTotal, orderID, otp, false)
+}
+
+// DrawServiceBonuses withdraws on behalf of a trusted service without the step-up code,
+// the service can't ask the customer for one. The other rules apply as to DrawBonuses.
+func (uc UseCase) DrawServiceBonuses(cookie string, sum, orderTotal float64, orderID string) error {
+	return uc.drawBonuses(cookie, sum, orderTotal, orderID, "", true)
+}
+
+func (uc UseCase) drawBonuses(cookie string, sum, orderTotal float64, orderID, otp string, service bool) error {
+	orderID = luhn.Normalize(orderID)
+	if !luhn.Valid(orderID) {
 		return storage.ErrBadID
 	}
 
@@ -137,16 +211,30 @@ func (uc UseCase) DrawBonuses(cookie string, sum float64, orderID string) error
 		return err
 	}
 
-	ID, err := strconv.Atoi(orderID)
+	err = uc.checkWithdrawal(sum, orderTotal)
 	if err != nil {
-		return storage.ErrBadID
+		return err
 	}
 
-	if !Valid(ID) {
-		return storage.ErrBadID
+	err = uc.checkThrottle(username)
+	if err != nil {
+		return err
 	}
 
-	return uc.storage.Withdraw(username, sum, orderID)
+	if !service {
+		err = uc.stepUp(username, sum, otp)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = uc.storage.Withdraw(username, sum, orderID, uc.withdrawalLimit(orderTotal))
+	if err != nil {
+		return err
+	}
+
+	uc.recordRisk(username, storage.RiskActivity{Withdrawals: 1, Withdrawn: sum})
+	return nil
 }
 
 func (uc UseCase) GetWithdrawals(cookie string) ([]byte, error) {
@@ -183,32 +271,10 @@ func (uc UseCase) GetOrders(cookie string) ([]byte, error) {
 	return res, nil
 }
 
-func Valid(number int) bool {
-	return (number%10+checksum(number/10))%10 == 0
-}
-
-func checksum(number int) int {
-	var luhn int
-
-	for i := 0; number > 0; i++ {
-		cur := number % 10
-
-		if i%2 == 0 { // even
-			cur = cur * 2
-			if cur > 9 {
-				cur = cur%10 + cur/10
-			}
-		}
-
-		luhn += cur
-		number = number / 10
-	}
-	return luhn % 10
-}
-
 func getUsernameFromCookie(cookie string) (string, error) {
+	// the third part is the token generation
 	split := strings.Split(cookie, "-")
-	if len(split) != 2 {
+	if len(split) != 2 && len(split) != 3 {
 		return "", storage.ErrBadCookie
 	}
 
@@ -216,12 +282,3 @@ func getUsernameFromCookie(cookie string) (string, error) {
 	user, err := hex.DecodeString(username)
 	return string(user), err
 }
-
-func allCharsIsDigits(input string) bool {
-	for _, sym := range input {
-		if !strings.ContainsAny(string(sym), "0123456789") {
-			return false
-		}
-	}
-	return true
-}
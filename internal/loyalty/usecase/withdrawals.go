@@ -0,0 +1,17 @@
+package usecase
+
+import (
+	"gomarket/internal/loyalty/storage"
+	"gomarket/internal/loyalty/withdrawal"
+)
+
+// checkWithdrawal rejects early the withdrawals the policy rejects regardless of the usage,
+// so a step-up code isn't spent on them. The storage checks the caps and the cooling-off
+// with the usage under the lock of the user.
+func (uc UseCase) checkWithdrawal(sum, orderTotal float64) error {
+	return uc.config.Withdrawal.Check(sum, orderTotal, withdrawal.Usage{})
+}
+
+func (uc UseCase) withdrawalLimit(orderTotal float64) storage.WithdrawalLimit {
+	return storage.WithdrawalLimit{Policy: uc.config.Withdrawal, OrderTotal: orderTotal}
+}
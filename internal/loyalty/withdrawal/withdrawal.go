@@ -0,0 +1,68 @@
+package withdrawal
+
+import (
+	"errors"
+	"time"
+)
+
+// Policy restricts the withdrawals, zero values disable the rules.
+type Policy struct {
+	// Min is the least sum of a withdrawal.
+	Min float64
+	// MaxShare is the largest part of the order value payable by points, 0.5 allows paying a half.
+	MaxShare float64
+	// DailyMax caps the sum withdrawn within the last 24 hours.
+	DailyMax float64
+	// MonthlyMax caps the sum withdrawn since the start of the calendar month.
+	MonthlyMax float64
+	// CoolingOff is how long credited points can't be spent.
+	CoolingOff time.Duration
+}
+
+// Usage is the state of the account the withdrawal is checked against,
+// the fields of the disabled rules are not read.
+type Usage struct {
+	Balance float64
+	// Fresh is the unspent part of the points credited within the cooling-off period.
+	Fresh float64
+	// Day and Month are the sums withdrawn within the periods of the caps.
+	Day   float64
+	Month float64
+}
+
+var ErrBelowMin = errors.New("withdrawal is below the minimum")
+var ErrOrderShare = errors.New("withdrawal exceeds the share of the order value payable by points")
+var ErrDailyLimit = errors.New("daily withdrawal limit exceeded")
+var ErrMonthlyLimit = errors.New("monthly withdrawal limit exceeded")
+var ErrCoolingOff = errors.New("points credited recently can't be spent yet")
+
+// Check returns the error of the first rule the withdrawal breaks, orderTotal is the value of the order.
+// A withdrawal over the balance is left to the storage to reject.
+func (p Policy) Check(sum, orderTotal float64, u Usage) error {
+	if sum < p.Min {
+		return ErrBelowMin
+	}
+
+	if p.MaxShare > 0 && (orderTotal <= 0 || sum > orderTotal*p.MaxShare) {
+		return ErrOrderShare
+	}
+
+	if p.DailyMax > 0 && u.Day+sum > p.DailyMax {
+		return ErrDailyLimit
+	}
+
+	if p.MonthlyMax > 0 && u.Month+sum > p.MonthlyMax {
+		return ErrMonthlyLimit
+	}
+
+	if p.CoolingOff > 0 && sum <= u.Balance && sum > u.Balance-u.Fresh {
+		return ErrCoolingOff
+	}
+
+	return nil
+}
+
+// MonthStart returns the start of the calendar month of the moment in its location.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,108 @@
+package withdrawal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	policy := Policy{
+		Min:        10,
+		MaxShare:   0.5,
+		DailyMax:   300,
+		MonthlyMax: 1000,
+		CoolingOff: 24 * time.Hour,
+	}
+	tests := []struct {
+		name       string
+		policy     Policy
+		sum        float64
+		orderTotal float64
+		usage      Usage
+		wantErr    error
+	}{
+		{
+			name:       "ok",
+			policy:     policy,
+			sum:        100,
+			orderTotal: 200,
+			usage:      Usage{Balance: 500, Fresh: 100, Day: 200, Month: 900},
+		},
+		{
+			name:       "no rules",
+			sum:        1,
+			orderTotal: 0,
+		},
+		{
+			name:       "below min",
+			policy:     policy,
+			sum:        5,
+			orderTotal: 200,
+			usage:      Usage{Balance: 500},
+			wantErr:    ErrBelowMin,
+		},
+		{
+			name:       "over the share",
+			policy:     policy,
+			sum:        101,
+			orderTotal: 200,
+			usage:      Usage{Balance: 500},
+			wantErr:    ErrOrderShare,
+		},
+		{
+			name:    "no order total",
+			policy:  policy,
+			sum:     100,
+			usage:   Usage{Balance: 500},
+			wantErr: ErrOrderShare,
+		},
+		{
+			name:       "daily cap",
+			policy:     policy,
+			sum:        100,
+			orderTotal: 200,
+			usage:      Usage{Balance: 500, Day: 201},
+			wantErr:    ErrDailyLimit,
+		},
+		{
+			name:       "monthly cap",
+			policy:     policy,
+			sum:        100,
+			orderTotal: 200,
+			usage:      Usage{Balance: 500, Month: 901},
+			wantErr:    ErrMonthlyLimit,
+		},
+		{
+			name:       "fresh points",
+			policy:     policy,
+			sum:        100,
+			orderTotal: 200,
+			usage:      Usage{Balance: 150, Fresh: 100},
+			wantErr:    ErrCoolingOff,
+		},
+		{
+			name:       "over the balance",
+			policy:     policy,
+			sum:        100,
+			orderTotal: 200,
+			usage:      Usage{Balance: 50, Fresh: 50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.sum, tt.orderTotal, tt.usage)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMonthStart(t *testing.T) {
+	got := MonthStart(time.Date(2024, time.March, 17, 15, 4, 5, 0, time.UTC))
+	want := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("MonthStart() = %v, want %v", got, want)
+	}
+}
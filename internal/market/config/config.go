@@ -2,9 +2,13 @@ package config
 
 import (
 	"flag"
+	"gomarket/internal/bruteforce"
 	"gomarket/internal/loyalty/cookies"
 	"gomarket/internal/market/storage"
+	"gomarket/internal/market/usecase"
 	"os"
+	"strconv"
+	"time"
 )
 
 const (
@@ -17,6 +21,17 @@ type Flag struct {
 	asa     *string
 	loyalty *string
 	key     string
+	proxy   *bool
+
+	loginFailures *int
+	ipFailures    *int
+	loginLockout  *time.Duration
+
+	outboxInterval    *time.Duration
+	outboxMaxAttempts *int
+
+	bonusShare   *float64
+	serviceToken *string
 }
 
 var f Flag
@@ -26,6 +41,14 @@ func init() {
 	f.dsn = flag.String("d", "", "-d=connection_string")
 	f.asa = flag.String("r", "http://127.0.0.1:8070", "-r=host")
 	f.loyalty = flag.String("l", "http://127.0.0.1:8000", "-l=host")
+	f.proxy = flag.Bool("trust-proxy", false, "-trust-proxy")
+	f.loginFailures = flag.Int("login-max-failures", 0, "-login-max-failures=5")
+	f.ipFailures = flag.Int("login-max-ip-failures", 0, "-login-max-ip-failures=20")
+	f.loginLockout = flag.Duration("login-lockout", 0, "-login-lockout=15m")
+	f.outboxInterval = flag.Duration("outbox-interval", 0, "-outbox-interval=1s")
+	f.outboxMaxAttempts = flag.Int("outbox-max-attempts", 0, "-outbox-max-attempts=10")
+	f.bonusShare = flag.Float64("withdraw-max-share", 0, "-withdraw-max-share=0.5")
+	f.serviceToken = flag.String("loyalty-service-token", "", "-loyalty-service-token=token")
 }
 
 type Config struct {
@@ -34,6 +57,11 @@ type Config struct {
 	DBConfig             *storage.Config
 	AccrualSystemAddress string
 	LoyaltySystemAddress string
+	BruteForce           *bruteforce.Config
+	Logic                *usecase.Config
+	// TrustProxy takes the client address from X-Forwarded-For and X-Real-IP,
+	// it must be set only behind a proxy that overwrites them.
+	TrustProxy bool
 }
 
 func New() *Config {
@@ -60,6 +88,52 @@ func New() *Config {
 		cookies.SetSecret([]byte(key))
 	}
 
+	if proxy, ok := os.LookupEnv("TRUST_PROXY"); ok {
+		if b, err := strconv.ParseBool(proxy); err == nil {
+			f.proxy = &b
+		}
+	}
+
+	if failures, ok := os.LookupEnv("LOGIN_MAX_FAILURES"); ok {
+		if n, err := strconv.Atoi(failures); err == nil {
+			f.loginFailures = &n
+		}
+	}
+
+	if failures, ok := os.LookupEnv("LOGIN_MAX_IP_FAILURES"); ok {
+		if n, err := strconv.Atoi(failures); err == nil {
+			f.ipFailures = &n
+		}
+	}
+
+	if lockout, ok := os.LookupEnv("LOGIN_LOCKOUT"); ok {
+		if d, err := time.ParseDuration(lockout); err == nil {
+			f.loginLockout = &d
+		}
+	}
+
+	if interval, ok := os.LookupEnv("OUTBOX_INTERVAL"); ok {
+		if d, err := time.ParseDuration(interval); err == nil {
+			f.outboxInterval = &d
+		}
+	}
+
+	if attempts, ok := os.LookupEnv("OUTBOX_MAX_ATTEMPTS"); ok {
+		if n, err := strconv.Atoi(attempts); err == nil {
+			f.outboxMaxAttempts = &n
+		}
+	}
+
+	if share, ok := os.LookupEnv("WITHDRAW_MAX_SHARE"); ok {
+		if n, err := strconv.ParseFloat(share, 64); err == nil {
+			f.bonusShare = &n
+		}
+	}
+
+	if token, ok := os.LookupEnv("LOYALTY_SERVICE_TOKEN"); ok {
+		f.serviceToken = &token
+	}
+
 	return &Config{
 		Host: *f.host,
 		Key:  []byte("CHANGE ME"),
@@ -69,5 +143,17 @@ func New() *Config {
 		},
 		AccrualSystemAddress: *f.asa,
 		LoyaltySystemAddress: *f.loyalty,
+		TrustProxy:           *f.proxy,
+		BruteForce: &bruteforce.Config{
+			MaxLoginFailures: *f.loginFailures,
+			MaxIPFailures:    *f.ipFailures,
+			Lockout:          *f.loginLockout,
+		},
+		Logic: &usecase.Config{
+			OutboxInterval:      *f.outboxInterval,
+			OutboxMaxAttempts:   *f.outboxMaxAttempts,
+			BonusMaxShare:       *f.bonusShare,
+			LoyaltyServiceToken: *f.serviceToken,
+		},
 	}
 }
@@ -6,11 +6,13 @@ import (
 	echosession "github.com/go-session/echo-session"
 	"github.com/labstack/echo"
 	"go.mongodb.org/mongo-driver/mongo"
+	"gomarket/internal/bruteforce"
 	"gomarket/internal/logger"
 	"gomarket/internal/market/config"
 	"gomarket/internal/market/cookies"
 	"gomarket/internal/market/schema"
 	"gomarket/internal/market/usecase"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -20,6 +22,7 @@ type Handler struct {
 	conf   *config.Config
 	logic  usecase.IUseCase // IUseCase for mock tests
 	logger logger.ILogger
+	guard  *bruteforce.Guard
 }
 
 type H map[string]interface{}
@@ -30,7 +33,14 @@ func NewHandler(cfg *config.Config, logic usecase.IUseCase, loggerInstance logge
 	if cfg == nil {
 		panic("конфиг равен nil")
 	}
-	return &Handler{conf: cfg, logic: logic, logger: loggerInstance}
+	var guard *bruteforce.Guard
+	if cfg.BruteForce != nil {
+		guard = bruteforce.New(*cfg.BruteForce)
+	} else {
+		guard = bruteforce.New(bruteforce.Config{})
+	}
+
+	return &Handler{conf: cfg, logic: logic, logger: loggerInstance, guard: guard}
 }
 
 func (h Handler) GetMain(c echo.Context) error {
@@ -135,18 +145,44 @@ func (h Handler) Login(c echo.Context) error {
 		return err
 	}
 
+	ip := h.clientIP(c)
+	wait, err := h.guard.Allow(user.Login, ip)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, bruteforce.ErrTooManyAttempts) {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			status = http.StatusTooManyRequests
+		} else {
+			h.logger.Warn(err.Error())
+			err = usecase.ErrServer
+		}
+
+		err = c.Render(status, "auth.html", H{"error": err.Error(), "login": true})
+		if err != nil {
+			h.logger.Warn(err.Error())
+		}
+		return err
+	}
+
 	cookie.Value, err = h.logic.Authentication(user.Login, user.Password)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			h.logger.Warn("user don't exist")
-			err = errors.New("user don't exist")
+		// the attempt is counted as failed by Allow, only the wrong guesses keep it
+		if !errors.Is(err, usecase.ErrBadCredentials) {
+			h.release(user.Login, ip)
+			h.logger.Warn(err.Error())
+			err = usecase.ErrServer
 		}
+
 		err = c.Render(http.StatusBadRequest, "auth.html", H{"error": err.Error(), "login": true})
 		if err != nil {
 			h.logger.Warn(err.Error())
 		}
 		return err
 	}
+
+	if err := h.guard.Succeeded(user.Login, ip); err != nil {
+		h.logger.Warn(err.Error())
+	}
 	c.SetCookie(cookie)
 
 	store := echosession.FromContext(c)
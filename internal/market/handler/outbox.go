@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo"
+	"gomarket/internal/market/storage"
+	"net/http"
+)
+
+// GetOutbox lists the outbox messages that weren't delivered after all attempts.
+func (h Handler) GetOutbox(c echo.Context) error {
+	ctx := context.TODO()
+	if !h.checkAdmin(ctx, c) {
+		return nil
+	}
+
+	messages, err := h.logic.GetFailedOutbox(ctx)
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return c.JSON(http.StatusInternalServerError, H{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
+
+// PostRetryOutbox puts the failed message with the form value "id" back to the queue.
+func (h Handler) PostRetryOutbox(c echo.Context) error {
+	ctx := context.TODO()
+	if !h.checkAdmin(ctx, c) {
+		return nil
+	}
+
+	err := h.logic.RetryOutbox(ctx, c.FormValue("id"))
+	if errors.Is(err, storage.ErrOutboxMessageNotFound) {
+		return c.JSON(http.StatusNotFound, H{"error": err.Error()})
+	}
+
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return c.JSON(http.StatusInternalServerError, H{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	echosession "github.com/go-session/echo-session"
+	"github.com/labstack/echo"
+	"gomarket/internal/bruteforce"
+	"gomarket/internal/market/cookies"
+	"gomarket/internal/market/schema"
+	"gomarket/internal/market/usecase"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// RevokedSessions signs out the sessions whose cookie was replaced by a password change or reset.
+func (h Handler) RevokedSessions(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		store := echosession.FromContext(c)
+		user, login := store.Get(userkey)
+		if !login {
+			return next(c)
+		}
+
+		username, _ := user.(string)
+		cookie, _ := c.Cookie("session")
+		var value string
+		if cookie != nil {
+			value = cookie.Value
+		}
+
+		valid, err := h.logic.SessionValid(context.TODO(), username, value)
+		if err != nil {
+			h.logger.Warn(err.Error())
+			return next(c)
+		}
+
+		if !valid {
+			store.Delete(userkey)
+			if err := store.Save(); err != nil {
+				h.logger.Warn(err.Error())
+			}
+		}
+
+		return next(c)
+	}
+}
+
+// Password shows the password page and changes the password of the signed in user.
+func (h Handler) Password(c echo.Context) error {
+	store := echosession.FromContext(c)
+	user, login := store.Get(userkey)
+	if c.Request().Method == http.MethodGet {
+		return h.renderPassword(c, http.StatusOK, H{"login": login})
+	}
+
+	username, ok := user.(string)
+	if !login || !ok {
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return nil
+	}
+
+	var form schema.PasswordForm
+	err := c.Bind(&form)
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return h.renderPassword(c, http.StatusBadRequest, H{"login": login, "error": err.Error()})
+	}
+
+	ip := h.clientIP(c)
+	wait, err := h.guard.Allow(username, ip)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, bruteforce.ErrTooManyAttempts) {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			status = http.StatusTooManyRequests
+		} else {
+			h.logger.Warn(err.Error())
+			err = usecase.ErrServer
+		}
+
+		return h.renderPassword(c, status, H{"login": login, "error": err.Error()})
+	}
+
+	cookie, err := h.logic.ChangePassword(context.TODO(), username, form.OldPassword, form.Password, h.conf.LoyaltySystemAddress)
+	if err != nil {
+		status := http.StatusBadRequest
+		if !errors.Is(err, usecase.ErrBadCredentials) {
+			h.release(username, ip)
+		}
+
+		if !errors.Is(err, usecase.ErrBadCredentials) && !errors.Is(err, usecase.ErrBadPassword) {
+			h.logger.Warn(err.Error())
+			status = http.StatusInternalServerError
+			err = usecase.ErrServer
+		}
+
+		return h.renderPassword(c, status, H{"login": login, "error": err.Error()})
+	}
+
+	if err := h.guard.Succeeded(username, ip); err != nil {
+		h.logger.Warn(err.Error())
+	}
+	c.SetCookie(cookies.SetCookie(cookie))
+
+	return h.renderPassword(c, http.StatusOK, H{"login": login, "message": "the password was changed, the other sessions were signed out"})
+}
+
+// PostPasswordReset answers the same for the unknown logins, so the page doesn't tell which logins exist.
+func (h Handler) PostPasswordReset(c echo.Context) error {
+	var form schema.PasswordForm
+	err := c.Bind(&form)
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return h.renderPassword(c, http.StatusBadRequest, H{"error": err.Error()})
+	}
+
+	err = h.logic.RequestPasswordReset(context.TODO(), form.Login, h.conf.LoyaltySystemAddress)
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return h.renderPassword(c, http.StatusInternalServerError, H{"error": usecase.ErrServer.Error()})
+	}
+
+	return h.renderPassword(c, http.StatusOK, H{"reset": true, "message": "if the account exists, the reset token was sent to its owner"})
+}
+
+// PostPasswordResetConfirm sets the password with the reset token and signs the user in.
+func (h Handler) PostPasswordResetConfirm(c echo.Context) error {
+	var form schema.PasswordForm
+	err := c.Bind(&form)
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return h.renderPassword(c, http.StatusBadRequest, H{"reset": true, "error": err.Error()})
+	}
+
+	cookie, err := h.logic.ResetPassword(context.TODO(), form.Login, form.Token, form.Password, h.conf.LoyaltySystemAddress)
+	if err != nil {
+		status := http.StatusBadRequest
+		if !errors.Is(err, usecase.ErrBadResetToken) && !errors.Is(err, usecase.ErrBadPassword) {
+			h.logger.Warn(err.Error())
+			status = http.StatusInternalServerError
+			err = usecase.ErrServer
+		}
+
+		return h.renderPassword(c, status, H{"reset": true, "error": err.Error()})
+	}
+
+	c.SetCookie(cookies.SetCookie(cookie))
+
+	store := echosession.FromContext(c)
+	store.Set(userkey, form.Login)
+	err = store.Save()
+	if err != nil {
+		return err
+	}
+
+	c.Redirect(http.StatusTemporaryRedirect, "/")
+	return nil
+}
+
+func (h Handler) renderPassword(c echo.Context, status int, data H) error {
+	err := c.Render(status, "password.html", data)
+	if err != nil {
+		h.logger.Warn(err.Error())
+	}
+	return err
+}
@@ -8,6 +8,9 @@ func (h Handler) PublicRoutes(e *echo.Echo) {
 	e.Any("/", h.GetMain)
 	e.Any("/login", h.Login)
 	e.Any("/reg", h.Register)
+	e.Any("/password", h.Password)
+	e.POST("/password/reset", h.PostPasswordReset)
+	e.POST("/password/reset/confirm", h.PostPasswordResetConfirm)
 	e.GET("/orders", h.GetOrders)
 	e.GET("/order", h.GetOrderInfo)
 }
@@ -19,4 +22,6 @@ func (h Handler) PrivateRoutes(e *echo.Echo) {
 	g.GET("/remove", h.RemoveItem)
 	g.POST("/change", h.ChangeItem)
 	g.POST("/change-status", h.PostChangeStatus)
+	g.GET("/outbox", h.GetOutbox)
+	g.POST("/outbox/retry", h.PostRetryOutbox)
 }
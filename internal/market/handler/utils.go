@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"github.com/docker/distribution/uuid"
+	echosession "github.com/go-session/echo-session"
 	"github.com/labstack/echo"
 	"go.mongodb.org/mongo-driver/mongo"
 	"gomarket/internal/market/cookies"
 	"gomarket/internal/market/schema"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -149,3 +151,51 @@ func reverseSlice[S Slices](sl []S) []S {
 
 	return sl
 }
+
+// checkAdmin redirects the user away and returns false unless the user is an admin.
+func (h Handler) checkAdmin(ctx context.Context, c echo.Context) bool {
+	store := echosession.FromContext(c)
+	user, login := store.Get(userkey)
+	if !login {
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return false
+	}
+
+	username, ok := user.(string)
+	if !ok {
+		h.logger.Warn("Bad username")
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return false
+	}
+
+	isAdmin, err := h.logic.IsAdmin(ctx, username)
+	if err != nil || !isAdmin {
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+		return false
+	}
+
+	return true
+}
+
+// clientIP is the address of the client the login attempts are counted for. The proxy headers
+// are trusted only if the market is configured to run behind a proxy, anyone can set them otherwise.
+func (h Handler) clientIP(c echo.Context) string {
+	if h.conf.TrustProxy {
+		return c.RealIP()
+	}
+
+	r := c.Request()
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// release forgets the login attempt reserved by the guard, it was not a wrong guess.
+func (h Handler) release(login, ip string) {
+	if err := h.guard.Release(login, ip); err != nil {
+		h.logger.Warn(err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"gomarket/internal/loyalty/schema"
 	"time"
 )
@@ -35,11 +36,6 @@ type Order struct {
 }
 
 type AuthRequestJSON schema.AuthRequestJSON
-type Bonus struct {
-	schema.Balance
-	Err string `json:"err"`
-}
-
 type AccrualRequest struct {
 	Order string `json:"order"`
 	Goods []Item `json:"goods"`
@@ -53,3 +49,29 @@ type Status struct {
 	Name string `bson:"name"`
 	Code int    `bson:"code"`
 }
+
+// OutboxMessage is a request to the accrual or loyalty system saved together with the order
+// and delivered later by the outbox dispatcher.
+type OutboxMessage struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	URL         string             `bson:"url" json:"url"`
+	Cookie      string             `bson:"cookie" json:"-"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Body        []byte             `bson:"body" json:"-"`
+	// Expected are the status codes of a successful delivery.
+	Expected    []int     `bson:"expected" json:"expected"`
+	Status      string    `bson:"status" json:"status"`
+	Attempts    int       `bson:"attempts" json:"attempts"`
+	NextAttempt time.Time `bson:"next_attempt" json:"next_attempt"`
+	LastError   string    `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+}
+
+// PasswordForm is the form of the password change and reset pages.
+type PasswordForm struct {
+	Login       string `form:"username"`
+	OldPassword string `form:"old_password"`
+	Password    string `form:"password"`
+	Token       string `form:"token"`
+}
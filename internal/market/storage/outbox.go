@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gomarket/internal/market/schema"
+	"time"
+)
+
+const outboxCollection = "outbox"
+
+func (s Storage) addOutboxMessages(ctx context.Context, messages []schema.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(messages))
+	for i, message := range messages {
+		docs[i] = message
+	}
+
+	_, err := s.db.Collection(outboxCollection).InsertMany(ctx, docs)
+	return err
+}
+
+// NextOutboxMessage takes the pending message that is due the earliest and hides it
+// from other dispatchers for the lease. It returns mongo.ErrNoDocuments when nothing is due.
+func (s Storage) NextOutboxMessage(ctx context.Context, now time.Time, lease time.Duration) (schema.OutboxMessage, error) {
+	c := s.db.Collection(outboxCollection)
+	filter := bson.D{
+		primitive.E{Key: "status", Value: OutboxPending},
+		primitive.E{Key: "next_attempt", Value: bson.D{primitive.E{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "next_attempt", Value: now.Add(lease)},
+	}}}
+	option := options.FindOneAndUpdate().
+		SetSort(bson.D{primitive.E{Key: "next_attempt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var message schema.OutboxMessage
+	err := c.FindOneAndUpdate(ctx, filter, update, option).Decode(&message)
+	return message, err
+}
+
+func (s Storage) UpdateOutboxMessage(ctx context.Context, message schema.OutboxMessage) error {
+	c := s.db.Collection(outboxCollection)
+	filter := bson.D{primitive.E{Key: "_id", Value: message.ID}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "status", Value: message.Status},
+		primitive.E{Key: "attempts", Value: message.Attempts},
+		primitive.E{Key: "next_attempt", Value: message.NextAttempt},
+		primitive.E{Key: "last_error", Value: message.LastError},
+	}}}
+
+	_, err := c.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (s Storage) GetOutboxMessages(ctx context.Context, status string) ([]schema.OutboxMessage, error) {
+	c := s.db.Collection(outboxCollection)
+	filter := bson.D{primitive.E{Key: "status", Value: status}}
+	option := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
+
+	cur, err := c.Find(ctx, filter, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var messages = make([]schema.OutboxMessage, 0)
+	err = cur.All(ctx, &messages)
+	return messages, err
+}
+
+// RetryOutboxMessage puts a failed message back to the queue.
+func (s Storage) RetryOutboxMessage(ctx context.Context, id string) error {
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrOutboxMessageNotFound
+	}
+
+	c := s.db.Collection(outboxCollection)
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: ID},
+		primitive.E{Key: "status", Value: OutboxFailed},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "status", Value: OutboxPending},
+		primitive.E{Key: "attempts", Value: 0},
+		primitive.E{Key: "next_attempt", Value: time.Now()},
+	}}}
+
+	res, err := c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrOutboxMessageNotFound
+	}
+
+	return nil
+}
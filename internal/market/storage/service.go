@@ -6,6 +6,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"gomarket/internal/market/schema"
+	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
@@ -16,7 +17,7 @@ type IStorage interface {
 	GetBalance(ctx context.Context, cookie string) (schema.BalanceMarket, error)
 	GetItems(ctx context.Context) ([]schema.Item, error)
 	GetItem(ctx context.Context, id string) (schema.Item, error)
-	Buy(ctx context.Context, cookie, id string, balance schema.BalanceMarket, item schema.Item) error
+	Buy(ctx context.Context, cookie, id string, balance schema.BalanceMarket, item schema.Item, outbox []schema.OutboxMessage) error
 	GetOrders(ctx context.Context, cookie string) ([]schema.Order, error)
 	GetAllOrders(ctx context.Context) ([]schema.Order, error)
 	AddOrder(ctx context.Context, order schema.Order) error
@@ -26,10 +27,17 @@ type IStorage interface {
 	IsAdmin(ctx context.Context, username string) (bool, error)
 	ChangeOrderStatus(ctx context.Context, status schema.Status, orderID string) error
 	GetOrder(ctx context.Context, username, orderID string) (order schema.Order, err error)
+	NextOutboxMessage(ctx context.Context, now time.Time, lease time.Duration) (schema.OutboxMessage, error)
+	UpdateOutboxMessage(ctx context.Context, message schema.OutboxMessage) error
+	GetOutboxMessages(ctx context.Context, status string) ([]schema.OutboxMessage, error)
+	RetryOutboxMessage(ctx context.Context, id string) error
+	ChangePassword(ctx context.Context, login, passwd, cookie string) error
+	GetCookie(ctx context.Context, login string) (string, error)
 }
 
 type Storage struct {
-	db *mongo.Database
+	client *mongo.Client
+	db     *mongo.Database
 }
 
 type Type string
@@ -38,6 +46,13 @@ var ErrUsernameConflict = errors.New("username already exists")
 var ErrWrongPassword = errors.New("wrong password")
 var ErrBadCookie = errors.New("bad cookie")
 var ErrNotEnoughMoney = errors.New("insufficient funds for payment")
+var ErrOutboxMessageNotFound = errors.New("failed outbox message not found")
+
+const (
+	OutboxPending   = "PENDING"
+	OutboxDelivered = "DELIVERED"
+	OutboxFailed    = "FAILED"
+)
 
 type Config struct {
 	DriverName     Type
@@ -55,7 +70,8 @@ func Init(cfg *Config) (IStorage, error) {
 	}
 
 	dao := &Storage{
-		db: client.Database("test"),
+		client: client,
+		db:     client.Database("test"),
 	}
 
 	return dao, nil
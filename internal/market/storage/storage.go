@@ -104,32 +104,44 @@ func (s Storage) GetBalance(ctx context.Context, cookie string) (schema.BalanceM
 	return balance, err
 }
 
-func (s Storage) Buy(ctx context.Context, cookie, id string, balance schema.BalanceMarket, item schema.Item) error {
-	c := s.db.Collection("items")
+// Buy updates the item and the customer balance and saves the outbox messages of the order
+// in one transaction, so a purchase is never saved without its messages. The transactions need a replica set.
+func (s Storage) Buy(ctx context.Context, cookie, id string, balance schema.BalanceMarket, item schema.Item, outbox []schema.OutboxMessage) error {
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 
-	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
-	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
-		primitive.E{Key: "count", Value: item.Count},
-	}}}
-
-	_, err = c.UpdateOne(ctx, filter, update)
+	session, err := s.client.StartSession()
 	if err != nil {
-		// withdrawal rollback (implement deposit handler)
 		return err
 	}
+	defer session.EndSession(ctx)
 
-	c = s.db.Collection("customers")
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		filter := bson.D{primitive.E{Key: "_id", Value: ID}}
+		update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "count", Value: item.Count},
+		}}}
 
-	filter = bson.D{primitive.E{Key: "cookie", Value: cookie}}
-	update = bson.D{primitive.E{Key: "$set", Value: bson.D{
-		primitive.E{Key: "balance", Value: balance.Current - item.Price},
-	}}}
+		_, err := s.db.Collection("items").UpdateOne(sc, filter, update)
+		if err != nil {
+			return nil, err
+		}
+
+		filter = bson.D{primitive.E{Key: "cookie", Value: cookie}}
+		update = bson.D{primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "balance", Value: balance.Current - item.Price},
+		}}}
+
+		_, err = s.db.Collection("customers").UpdateOne(sc, filter, update)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, s.addOutboxMessages(sc, outbox)
+	})
 
-	_, err = c.UpdateOne(ctx, filter, update)
 	return err
 }
 
@@ -261,3 +273,39 @@ func (s Storage) GetOrder(ctx context.Context, username, orderID string) (order
 	filter := bson.D{primitive.E{Key: "owner", Value: username}, primitive.E{Key: "_id", Value: ID}}
 	return order, c.FindOne(ctx, filter).Decode(&order)
 }
+
+// ChangePassword sets the password and the loyalty token of the customer,
+// the pending outbox messages are moved to the new token since the old one is revoked.
+func (s Storage) ChangePassword(ctx context.Context, login, passwd, cookie string) error {
+	c := s.db.Collection("customers")
+	var old schema.Customer
+	err := c.FindOne(ctx, bson.M{"login": login}).Decode(&old)
+	if err != nil {
+		return err
+	}
+
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "password", Value: passwd},
+		primitive.E{Key: "cookie", Value: cookie},
+	}}}
+	_, err = c.UpdateOne(ctx, bson.M{"login": login}, update)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Collection(outboxCollection).UpdateMany(ctx,
+		bson.M{"cookie": old.Cookie, "status": bson.M{"$ne": OutboxDelivered}},
+		bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "cookie", Value: cookie}}}})
+	return err
+}
+
+// GetCookie returns the current cookie of the customer, the sessions with another one were revoked.
+func (s Storage) GetCookie(ctx context.Context, login string) (string, error) {
+	var user schema.Customer
+	err := s.db.Collection("customers").FindOne(ctx, bson.M{"login": login}).Decode(&user)
+	if err != nil {
+		return "", err
+	}
+
+	return user.Cookie, nil
+}
@@ -0,0 +1,216 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gomarket/internal/market/schema"
+	"gomarket/internal/market/storage"
+	"gomarket/pkg/loyaltyapi"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultOutboxInterval    = time.Second
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxBaseDelay   = time.Second
+	defaultOutboxMaxDelay    = 10 * time.Minute
+
+	// outboxLease hides a taken message from other dispatchers while it is being delivered.
+	outboxLease = time.Minute
+)
+
+const (
+	OutboxAccrualOrder      = "accrual_order"
+	OutboxLoyaltyOrder      = "loyalty_order"
+	OutboxLoyaltyWithdrawal = "loyalty_withdrawal"
+)
+
+func (cfg *Config) withDefaults() *Config {
+	c := *cfg
+	if c.OutboxInterval <= 0 {
+		c.OutboxInterval = defaultOutboxInterval
+	}
+
+	if c.OutboxMaxAttempts <= 0 {
+		c.OutboxMaxAttempts = defaultOutboxMaxAttempts
+	}
+
+	if c.OutboxBaseDelay <= 0 {
+		c.OutboxBaseDelay = defaultOutboxBaseDelay
+	}
+
+	if c.OutboxMaxDelay <= 0 {
+		c.OutboxMaxDelay = defaultOutboxMaxDelay
+	}
+
+	return &c
+}
+
+// orderMessages makes the requests registering a new order in the accrual and loyalty systems.
+// item.Price is the price of all the bought items.
+func orderMessages(cookie, accrualAddress, loyaltyAddress, orderID string, item schema.Item) ([]schema.OutboxMessage, error) {
+	item.Description = item.Name
+	accrualReq := schema.AccrualRequest{
+		Order: orderID,
+		Goods: []schema.Item{item},
+	}
+
+	ready, err := json.Marshal(accrualReq)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return []schema.OutboxMessage{
+		{
+			Kind:        OutboxAccrualOrder,
+			URL:         accrualAddress + "/api/orders",
+			Cookie:      cookie,
+			ContentType: "application/json",
+			Body:        ready,
+			// 409 means the order was registered by a previous attempt
+			Expected:    []int{http.StatusAccepted, http.StatusConflict},
+			Status:      storage.OutboxPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+		},
+		{
+			Kind:        OutboxLoyaltyOrder,
+			URL:         loyaltyAddress + "/api/user/orders",
+			Cookie:      cookie,
+			ContentType: "text/plain",
+			Body:        []byte(orderID),
+			// 200 means the order was uploaded by a previous attempt
+			Expected:    []int{http.StatusAccepted, http.StatusOK},
+			Status:      storage.OutboxPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+		},
+	}, nil
+}
+
+// withdrawalMessage makes the request spending the bonuses of the customer on the order.
+// The loyalty system treats a repeated withdrawal of the order as a replay, so the retries don't spend twice.
+// A withdrawal the loyalty system refuses ends in the failed messages for the admin,
+// the purchase is already paid with the bonuses by then.
+// orderTotal is the price of the order, the loyalty system checks the share payable by points with it.
+func withdrawalMessage(cookie, loyaltyAddress, orderID string, amount, orderTotal float64) (schema.OutboxMessage, error) {
+	ready, err := json.Marshal(loyaltyapi.WithdrawRequest{
+		Order:      orderID,
+		Sum:        amount,
+		OrderTotal: &orderTotal,
+	})
+	if err != nil {
+		return schema.OutboxMessage{}, err
+	}
+
+	now := time.Now()
+	return schema.OutboxMessage{
+		Kind:        OutboxLoyaltyWithdrawal,
+		URL:         loyaltyAddress + "/api/user/balance/withdraw",
+		Cookie:      cookie,
+		ContentType: "application/json",
+		Body:        ready,
+		Expected:    []int{http.StatusOK},
+		Status:      storage.OutboxPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+	}, nil
+}
+
+// RunOutbox delivers outbox messages until the context is done.
+func (uc UseCase) RunOutbox(ctx context.Context) {
+	ticker := time.NewTicker(uc.config.OutboxInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.DispatchOutbox(ctx)
+		}
+	}
+}
+
+// DispatchOutbox delivers all the messages that are due.
+func (uc UseCase) DispatchOutbox(ctx context.Context) {
+	for ctx.Err() == nil {
+		message, err := uc.storage.NextOutboxMessage(ctx, time.Now(), outboxLease)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return
+		}
+
+		if err != nil {
+			log.Println("outbox:", err)
+			return
+		}
+
+		uc.deliver(ctx, message)
+	}
+}
+
+func (uc UseCase) deliver(ctx context.Context, message schema.OutboxMessage) {
+	err := uc.send(ctx, message)
+	message.Attempts++
+
+	switch {
+	case err == nil:
+		message.Status = storage.OutboxDelivered
+		message.LastError = ""
+	case message.Attempts >= uc.config.OutboxMaxAttempts:
+		log.Printf("outbox: %s %s failed after %d attempts: %v", message.Kind, message.ID.Hex(), message.Attempts, err)
+		message.Status = storage.OutboxFailed
+		message.LastError = err.Error()
+	default:
+		message.NextAttempt = time.Now().Add(uc.backoff(message.Attempts))
+		message.LastError = err.Error()
+	}
+
+	err = uc.storage.UpdateOutboxMessage(ctx, message)
+	if err != nil {
+		log.Println("outbox:", err)
+	}
+}
+
+func (uc UseCase) send(ctx context.Context, message schema.OutboxMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, message.URL, bytes.NewReader(message.Body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", message.ContentType)
+	// the token isn't saved with the message, so it's read from the config on every attempt
+	if message.Kind == OutboxLoyaltyWithdrawal && uc.config.LoyaltyServiceToken != "" {
+		req.Header.Set("X-Service-Token", uc.config.LoyaltyServiceToken)
+	}
+
+	return uc.performRequest(req, message.Cookie, message.Expected...)
+}
+
+// backoff is the delay before the next attempt after the given number of failed ones.
+func (uc UseCase) backoff(attempts int) time.Duration {
+	delay := uc.config.OutboxBaseDelay
+	for i := 1; i < attempts && delay < uc.config.OutboxMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > uc.config.OutboxMaxDelay {
+		delay = uc.config.OutboxMaxDelay
+	}
+
+	return delay
+}
+
+func (uc UseCase) GetFailedOutbox(ctx context.Context) ([]schema.OutboxMessage, error) {
+	return uc.storage.GetOutboxMessages(ctx, storage.OutboxFailed)
+}
+
+func (uc UseCase) RetryOutbox(ctx context.Context, id string) error {
+	return uc.storage.RetryOutboxMessage(ctx, id)
+}
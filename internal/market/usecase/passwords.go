@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gomarket/pkg/loyaltyapi"
+	"log"
+	"net/http"
+)
+
+// ChangePassword changes the password in the loyalty system and here, it returns the new cookie.
+// The loyalty system revokes the old token, so the other sessions of the user are signed out.
+func (uc UseCase) ChangePassword(ctx context.Context, login, oldPasswd, newPasswd, loyaltyAddress string) (string, error) {
+	if newPasswd == "" {
+		return "", ErrBadPassword
+	}
+
+	cookie, err := uc.Authentication(login, oldPasswd)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := newLoyaltyClient(loyaltyAddress)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.ChangePasswordWithResponse(ctx, loyaltyapi.PasswordChangeRequest{
+		OldPassword: oldPasswd,
+		NewPassword: newPasswd,
+	}, withToken(cookie))
+	if err != nil {
+		log.Println(err)
+		return "", ErrDeadLoyalty
+	}
+
+	if resp.JSON401 != nil {
+		return "", ErrBadCredentials
+	} else if resp.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("%w %d", ErrUnexpectedStatus, resp.StatusCode())
+	}
+
+	newCookie := resp.HTTPResponse.Header.Get("Authorization")
+	if newCookie == "" {
+		return "", ErrBadCookie
+	}
+
+	return newCookie, uc.storage.ChangePassword(ctx, login, newPasswd, newCookie)
+}
+
+// RequestPasswordReset asks the loyalty system to send the reset token, it delivers the message with its notifier.
+func (uc UseCase) RequestPasswordReset(ctx context.Context, login, loyaltyAddress string) error {
+	client, err := newLoyaltyClient(loyaltyAddress)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.RequestPasswordResetWithResponse(ctx, loyaltyapi.PasswordResetRequest{Login: login})
+	if err != nil {
+		log.Println(err)
+		return ErrDeadLoyalty
+	}
+
+	if resp.StatusCode() != http.StatusAccepted {
+		return fmt.Errorf("%w %d", ErrUnexpectedStatus, resp.StatusCode())
+	}
+
+	return nil
+}
+
+// ResetPassword sets the password with the reset token and signs in to the loyalty system
+// with it, which also proves the token was issued to the login. It returns the new cookie.
+func (uc UseCase) ResetPassword(ctx context.Context, login, token, passwd, loyaltyAddress string) (string, error) {
+	if passwd == "" {
+		return "", ErrBadPassword
+	}
+
+	client, err := newLoyaltyClient(loyaltyAddress)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.ResetPasswordWithResponse(ctx, loyaltyapi.PasswordResetConfirm{Token: token, Password: passwd})
+	if err != nil {
+		log.Println(err)
+		return "", ErrDeadLoyalty
+	}
+
+	if resp.JSON422 != nil {
+		return "", ErrBadResetToken
+	} else if resp.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("%w %d", ErrUnexpectedStatus, resp.StatusCode())
+	}
+
+	auth, err := client.LoginUserWithResponse(ctx, loyaltyapi.AuthRequest{Login: login, Password: passwd})
+	if err != nil {
+		log.Println(err)
+		return "", ErrDeadLoyalty
+	}
+
+	if auth.JSON401 != nil {
+		return "", ErrBadResetToken
+	} else if auth.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("%w %d", ErrUnexpectedStatus, auth.StatusCode())
+	}
+
+	cookie := auth.HTTPResponse.Header.Get("Authorization")
+	if cookie == "" {
+		return "", ErrBadCookie
+	}
+
+	err = uc.storage.ChangePassword(ctx, login, passwd, cookie)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrBadResetToken
+	}
+
+	return cookie, err
+}
+
+// SessionValid reports whether the session of the login still has the current cookie of the customer.
+func (uc UseCase) SessionValid(ctx context.Context, login, cookie string) (bool, error) {
+	current, err := uc.storage.GetCookie(ctx, login)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return current == cookie, nil
+}
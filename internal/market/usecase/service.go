@@ -5,10 +5,29 @@ import (
 	"errors"
 	"gomarket/internal/market/schema"
 	"gomarket/internal/market/storage"
+	"time"
 )
 
 type UseCase struct {
 	storage storage.IStorage
+	config  *Config
+}
+
+// Config of the outbox dispatcher, zero values are replaced by defaults.
+type Config struct {
+	// OutboxInterval is how often the dispatcher looks for due messages.
+	OutboxInterval time.Duration
+	// OutboxMaxAttempts is the number of attempts before a message is moved to the failed queue.
+	OutboxMaxAttempts int
+	// OutboxBaseDelay is the delay after the first failure, it doubles after each next one up to OutboxMaxDelay.
+	OutboxBaseDelay time.Duration
+	OutboxMaxDelay  time.Duration
+	// BonusMaxShare is the largest part of the price payable by bonuses, it should match the share
+	// the loyalty system allows. 0 allows paying the whole price.
+	BonusMaxShare float64
+	// LoyaltyServiceToken is sent with the bonus withdrawals, the loyalty system skips the step-up code for it
+	// since the customer can't enter one for a withdrawal delivered by the outbox.
+	LoyaltyServiceToken string
 }
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
@@ -28,14 +47,34 @@ type IUseCase interface {
 	IsAdmin(ctx context.Context, username string) (bool, error)
 	ChangeOrderStatus(ctx context.Context, statusCode int, orderID string) error
 	GetOrder(ctx context.Context, username string, id string) (order schema.Order, err error)
+	GetFailedOutbox(ctx context.Context) ([]schema.OutboxMessage, error)
+	RetryOutbox(ctx context.Context, id string) error
+	ChangePassword(ctx context.Context, login, oldPasswd, newPasswd, loyaltyAddress string) (string, error)
+	RequestPasswordReset(ctx context.Context, login, loyaltyAddress string) error
+	ResetPassword(ctx context.Context, login, token, passwd, loyaltyAddress string) (string, error)
+	SessionValid(ctx context.Context, login, cookie string) (bool, error)
 }
 
-func New(storage storage.IStorage) UseCase {
-	return UseCase{storage: storage}
+func New(storage storage.IStorage, cfg *Config) UseCase {
+	if cfg == nil {
+		panic("конфиг равен nil")
+	}
+
+	return UseCase{storage: storage, config: cfg.withDefaults()}
 }
 
+// orderNumberLength is the length of generated order numbers.
+const orderNumberLength = 128
+
+// requestTimeout limits requests to the accrual and loyalty systems.
+const requestTimeout = 10 * time.Second
+
 var ErrBadOrder = errors.New("some items were not purchased")
 var ErrReservedUsername = errors.New("username is reserved")
 var ErrServer = errors.New("server error, sorry! we're already working on it")
 var ErrBadCookie = errors.New("bad cookie")
 var ErrDeadLoyalty = errors.New("we are sorry, registration is not available at the moment")
+var ErrBadCredentials = errors.New("wrong login or password")
+var ErrUnexpectedStatus = errors.New("unexpected status code")
+var ErrBadPassword = errors.New("password must not be empty")
+var ErrBadResetToken = errors.New("invalid or expired password reset token")
@@ -1,16 +1,17 @@
 package usecase
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/ShiraazMoollatjie/goluhn"
-	schema2 "gomarket/internal/loyalty/schema"
+	"go.mongodb.org/mongo-driver/mongo"
 	"gomarket/internal/market/schema"
 	"gomarket/internal/market/storage"
+	"gomarket/pkg/loyaltyapi"
+	"gomarket/pkg/luhn"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -19,26 +20,27 @@ import (
 )
 
 func (uc UseCase) CreateUser(user schema.Customer, cookie string, loyaltyAddress string) (string, error) {
-	jsonMSG, err := json.Marshal(&user)
+	client, err := newLoyaltyClient(loyaltyAddress)
 	if err != nil {
 		return "", err
 	}
 
-	reader := bytes.NewReader(jsonMSG)
-	resp, err := http.Post(loyaltyAddress+"/api/user/register", "application/json", reader)
+	resp, err := client.RegisterUserWithResponse(context.Background(), loyaltyapi.AuthRequest{
+		Login:    user.Login,
+		Password: user.Password,
+	})
 	if err != nil {
 		log.Println(err)
 		return "", ErrDeadLoyalty
 	}
-	defer resp.Body.Close()
 
-	if http.StatusConflict == resp.StatusCode {
+	if resp.JSON409 != nil {
 		return "", ErrReservedUsername
-	} else if resp.StatusCode != http.StatusOK {
+	} else if resp.StatusCode() != http.StatusOK {
 		return "", ErrServer
 	}
 
-	loyaltyCookie := resp.Header.Get("Authorization")
+	loyaltyCookie := resp.HTTPResponse.Header.Get("Authorization")
 	if loyaltyCookie == "" {
 		return "", ErrBadCookie
 	}
@@ -46,8 +48,15 @@ func (uc UseCase) CreateUser(user schema.Customer, cookie string, loyaltyAddress
 	return uc.storage.CreateUser(user.Login, user.Password, cookie, loyaltyCookie)
 }
 
+// Authentication returns the same ErrBadCredentials for unknown logins and wrong passwords,
+// so the response doesn't tell which logins exist.
 func (uc UseCase) Authentication(login, passwd string) (string, error) {
-	return uc.storage.Authentication(login, passwd)
+	cookie, err := uc.storage.Authentication(login, passwd)
+	if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, storage.ErrWrongPassword) {
+		return "", ErrBadCredentials
+	}
+
+	return cookie, err
 }
 
 func (uc UseCase) GetBalance(ctx context.Context, cookie string, loyaltyAddress string) (schema.BalanceMarket, error) {
@@ -58,29 +67,24 @@ func (uc UseCase) GetBalance(ctx context.Context, cookie string, loyaltyAddress
 	}
 
 	balance.Bonuses = 0
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loyaltyAddress+"/api/user/balance", nil)
+	client, err := newLoyaltyClient(loyaltyAddress)
 	if err != nil {
 		log.Println(err)
 		return balance, nil
 	}
 
-	req.Header.Set("Authorization", cookie)
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := client.GetBalanceWithResponse(ctx, withToken(cookie))
 	if err != nil {
 		log.Println(err)
 		return balance, nil
 	}
-	defer resp.Body.Close()
 
-	var bonus schema.Bonus
-	err = json.NewDecoder(resp.Body).Decode(&bonus)
-	if err != nil {
-		log.Println(err)
+	if resp.JSON200 == nil {
+		log.Println(fmt.Errorf("%w %d", ErrUnexpectedStatus, resp.StatusCode()))
 		return balance, nil
 	}
 
-	balance.Bonuses = bonus.Current
+	balance.Bonuses = resp.JSON200.Current
 
 	return balance, nil
 }
@@ -94,7 +98,10 @@ func (uc UseCase) GetItems(ctx context.Context) ([]schema.Item, error) {
 }
 
 func (uc UseCase) Buy(ctx context.Context, cookie, id, accrualAddress, loyaltyAddress string, count int, login bool) (schema.Item, error) {
-	orderID := fmt.Sprint(goluhn.Generate(16)) // TODO: change to 128
+	orderID, err := luhn.Generate(orderNumberLength)
+	if err != nil {
+		return schema.Item{}, err
+	}
 
 	balance, err := uc.GetBalance(ctx, cookie, loyaltyAddress)
 	if err != nil {
@@ -112,118 +119,89 @@ func (uc UseCase) Buy(ctx context.Context, cookie, id, accrualAddress, loyaltyAd
 
 	item.Price = float32(count) * item.Price
 
-	if balance.Bonuses+balance.Current < item.Price {
+	var amount float64
+	if balance.Bonuses > 0 {
+		amount = uc.bonusAmount(balance.Bonuses, item.Price)
+	}
+
+	if float64(balance.Current)+amount < float64(item.Price) {
 		return schema.Item{}, storage.ErrNotEnoughMoney
 	}
 
+	var outbox []schema.OutboxMessage
 	if login {
-		go uc.regNewOrderAccrual(cookie, id, accrualAddress+"/api/orders", orderID, count)
-		go uc.regNewOrderLoyalty(cookie, loyaltyAddress+"/api/user/orders", orderID)
+		outbox, err = orderMessages(cookie, accrualAddress, loyaltyAddress, orderID, item)
+		if err != nil {
+			return schema.Item{}, err
+		}
 	}
 
-	if balance.Bonuses > 0 {
-		var amount float32
-		if item.Price-balance.Bonuses >= 0 {
-			amount = balance.Bonuses
-		} else {
-			amount = item.Price
-		}
-		balance.Current = balance.Current + amount
-		err = uc.withdrawalBonuses(cookie, orderID, loyaltyAddress, amount)
+	if amount > 0 {
+		message, err := withdrawalMessage(cookie, loyaltyAddress, orderID, amount, float64(item.Price))
 		if err != nil {
-			log.Println("can't write off bonuses:", err)
+			return schema.Item{}, err
 		}
+		outbox = append(outbox, message)
+		balance.Current = balance.Current + float32(amount)
 	}
 
 	item.Count -= count
 
-	return item, uc.storage.Buy(ctx, cookie, id, balance, item)
+	return item, uc.storage.Buy(ctx, cookie, id, balance, item, outbox)
 }
 
-func (uc UseCase) withdrawalBonuses(cookie, id, loyaltyAddress string, amount float32) error {
-	wr := schema2.WithdrawnRequest{
-		Order: id,
-		Sum:   float64(amount),
+// bonusAmount is the part of the price paid by the bonuses, at most the share the loyalty system allows.
+func (uc UseCase) bonusAmount(bonuses, price float32) float64 {
+	amount := math.Min(float64(bonuses), float64(price))
+	if uc.config.BonusMaxShare > 0 {
+		// rounded down to kopecks, so the loyalty system doesn't see it above the share
+		amount = math.Min(amount, math.Floor(float64(price)*uc.config.BonusMaxShare*100)/100)
 	}
 
-	ready, err := json.Marshal(wr)
-	if err != nil {
-		return err
-	}
+	return amount
+}
 
-	req, err := http.NewRequest(http.MethodPost, loyaltyAddress+"/api/user/balance/withdraw", bytes.NewReader(ready))
-	if err != nil {
-		return err
-	}
+// newLoyaltyClient returns the client of the loyalty API at loyaltyAddress.
+func newLoyaltyClient(loyaltyAddress string) (*loyaltyapi.ClientWithResponses, error) {
+	return loyaltyapi.NewClientWithResponses(loyaltyAddress,
+		loyaltyapi.WithHTTPClient(&http.Client{Timeout: requestTimeout}))
+}
 
-	return uc.performRequest(req, cookie, http.StatusOK)
+// withToken authorizes a loyalty API request on behalf of the cookie owner.
+func withToken(cookie string) loyaltyapi.RequestEditorFn {
+	return func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", cookie)
+		return nil
+	}
 }
 
-func (uc UseCase) performRequest(req *http.Request, cookie string, code int) error {
+// performRequest sends the request on behalf of the cookie owner
+// and returns ErrUnexpectedStatus unless the response has one of the codes.
+func (uc UseCase) performRequest(req *http.Request, cookie string, codes ...int) error {
 	req.Header.Set("Authorization", cookie)
-	req.Header.Set("Content-Type", "application/json")
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	client := &http.Client{}
+	client := &http.Client{Timeout: requestTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != code {
-		log.Println("Error! Status code:", resp.StatusCode)
-		read, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return nil
 		}
-		log.Println("Err text:", string(read))
-	}
-	return nil
-}
-
-func (uc UseCase) regNewOrderAccrual(cookie, id, host, orderID string, count int) {
-	item, err := uc.storage.GetItem(context.Background(), id)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	item.Description = item.Name
-	item.Price *= float32(count)
-
-	accrualReq := schema.AccrualRequest{
-		Order: orderID,
-		Goods: []schema.Item{item},
-	}
-
-	ready, err := json.Marshal(accrualReq)
-	if err != nil {
-		log.Println(err)
-		return
 	}
 
-	req, err := http.NewRequest(http.MethodPost, host, bytes.NewReader(ready))
+	read, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	err = uc.performRequest(req, cookie, http.StatusAccepted)
-	if err != nil {
-		log.Println(err)
-	}
-}
-
-func (uc UseCase) regNewOrderLoyalty(cookie, host, orderID string) {
-	req, err := http.NewRequest(http.MethodPost, host, strings.NewReader(orderID))
-	if err != nil {
-		return
+		return err
 	}
 
-	err = uc.performRequest(req, cookie, http.StatusAccepted)
-	if err != nil {
-		log.Println(err)
-	}
+	return fmt.Errorf("%w %d: %s", ErrUnexpectedStatus, resp.StatusCode, strings.TrimSpace(string(read)))
 }
 
 type UserMutesMap map[string]*sync.Mutex
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+)
+
+type adminKey struct{}
+
+// AdminRequired authorizes admins by the X-Admin-Token header. tokens maps a token to the admin name.
+func AdminRequired(tokens map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			admin, ok := findAdmin(tokens, r.Header.Get("X-Admin-Token"))
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "bad admin token"}`))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), adminKey{}, admin)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Admin returns the name of the admin authorized by AdminRequired.
+func Admin(r *http.Request) string {
+	admin, _ := r.Context().Value(adminKey{}).(string)
+	return admin
+}
+
+func findAdmin(tokens map[string]string, token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+
+	var admin string
+	var found bool
+	for t, name := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			admin, found = name, true
+		}
+	}
+
+	return admin, found
+}
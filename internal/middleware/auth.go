@@ -15,7 +15,7 @@ func AuthRequired(next http.Handler) http.Handler {
 			return
 		}
 
-		if !cookies.Check(cookie) {
+		if !cookies.CheckProgram(Program(r), cookie) {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte(`{"error": "bad cookie"}`))
 			return
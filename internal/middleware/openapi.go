@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"gomarket/pkg/bettererror"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxBody is the largest request body read for the validation, the whole body is buffered to validate it.
+const maxBody = 1 << 20
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("text/csv", openapi3filter.RegisteredBodyDecoder("text/plain"))
+}
+
+// ValidateRequests checks requests against the OpenAPI document spec.
+// A body of a content type the operation doesn't accept gets 415, a body over maxBody gets 413,
+// any other mismatch gets 400.
+// Requests to paths missing from the document are passed through to the router.
+// Authentication is left to AuthRequired and AdminRequired.
+func ValidateRequests(spec []byte) (func(http.Handler) http.Handler, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, params, err := router.FindRoute(r)
+			var routeErr *routers.RouteError
+			if errors.As(err, &routeErr) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err != nil {
+				badRequest(w, http.StatusBadRequest, err)
+				return
+			}
+
+			if err := checkContentType(r, route.Operation.RequestBody); err != nil {
+				badRequest(w, http.StatusUnsupportedMediaType, err)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+			err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: params,
+				Route:      route,
+				Options:    options,
+			})
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				badRequest(w, http.StatusRequestEntityTooLarge, err)
+				return
+			}
+
+			if err != nil {
+				badRequest(w, http.StatusBadRequest, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// checkContentType returns an error if the request has a body
+// and its content type isn't declared for the operation.
+func checkContentType(r *http.Request, body *openapi3.RequestBodyRef) error {
+	if body == nil || body.Value == nil || r.ContentLength == 0 {
+		return nil
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if _, ok := body.Value.Content[mediaType]; ok {
+		return nil
+	}
+
+	accepted := make([]string, 0, len(body.Value.Content))
+	for mediaType := range body.Value.Content {
+		accepted = append(accepted, mediaType)
+	}
+	sort.Strings(accepted)
+
+	return fmt.Errorf("unsupported content type %q, want %s", contentType, strings.Join(accepted, " or "))
+}
+
+func badRequest(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(bettererror.New(err).SetAppLayer(bettererror.Handler).JSON())
+}
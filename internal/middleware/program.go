@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"gomarket/internal/loyalty/program"
+	"net/http"
+)
+
+type programKey struct{}
+
+// ProgramRequired resolves the X-Program-Key header to one of the programs,
+// requests without the header belong to the default program.
+func ProgramRequired(programs []program.Program) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-Program-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			p, ok := program.Find(programs, key)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "bad program key"}`))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), programKey{}, p.ID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Program returns the ID of the program resolved by ProgramRequired.
+func Program(r *http.Request) string {
+	id, ok := r.Context().Value(programKey{}).(string)
+	if !ok {
+		return program.DefaultID
+	}
+
+	return id
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile_Notify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	f := NewFile(path)
+
+	for _, to := range []string{"alice", "bob"} {
+		if err := f.Notify(Message{To: to, Subject: "Password reset", Body: "token"}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var got []Message
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var m Message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, m)
+	}
+
+	if len(got) != 2 || got[0].To != "alice" || got[1].To != "bob" {
+		t.Fatalf("outbox = %+v, want the messages to alice and bob", got)
+	}
+
+	if got[0].CreatedAt.IsZero() {
+		t.Errorf("CreatedAt is not set")
+	}
+}
+
+func TestMemory_Notify(t *testing.T) {
+	var m Memory
+	m.Notify(Message{To: "alice"})
+
+	if got := m.Messages(); len(got) != 1 || got[0].To != "alice" {
+		t.Errorf("Messages() = %+v, want the message to alice", got)
+	}
+}
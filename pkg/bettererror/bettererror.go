@@ -10,12 +10,15 @@ type IBetterError interface {
 	JSON() []byte
 	SetTime() IBetterError
 	SetAppLayer(layer string) IBetterError
+	SetCode(code string) IBetterError
 }
 
 type BetterError struct {
 	Measure *time.Time `json:"measure"`
 	Layer   string     `json:"layer,omitempty"`
 	Err     string     `json:"err"`
+	// Code tells the clients apart the errors that share a status.
+	Code string `json:"code,omitempty"`
 }
 
 const Storage string = "storage"
@@ -46,6 +49,11 @@ func (e *BetterError) SetAppLayer(layer string) IBetterError {
 	return e
 }
 
+func (e *BetterError) SetCode(code string) IBetterError {
+	e.Code = code
+	return e
+}
+
 func (e *BetterError) JSON() []byte {
 	marshal, err := json.Marshal(e)
 	if err != nil {
@@ -0,0 +1,12 @@
+// Package loyaltyapi contains the OpenAPI document of the loyalty HTTP API
+// and the client generated from it.
+package loyaltyapi
+
+import _ "embed"
+
+//go:generate oapi-codegen -generate types,client -package loyaltyapi -o client.gen.go openapi.json
+
+// Spec is the OpenAPI document served at /openapi.json.
+//
+//go:embed openapi.json
+var Spec []byte
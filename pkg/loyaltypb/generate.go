@@ -0,0 +1,4 @@
+// Package loyaltypb contains the gRPC API of the loyalty service.
+package loyaltypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative loyalty.proto
@@ -0,0 +1,100 @@
+// Package luhn validates and generates order numbers of any length with the Luhn algorithm.
+// Numbers are handled as strings, so they are not limited by the size of int.
+package luhn
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var ErrBadLength = errors.New("number length must be at least 2")
+
+// Normalize removes all whitespace, so "4561 2612 1234 5467\n" becomes "4561261212345467".
+func Normalize(number string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, number)
+}
+
+// Valid reports whether the number is a non-empty string of ASCII digits with a correct check digit.
+// The number is expected to be normalized.
+func Valid(number string) bool {
+	if number == "" || !digits(number) {
+		return false
+	}
+
+	return sum(number, false)%10 == 0
+}
+
+// CheckDigit returns the digit that makes the payload followed by it a valid number.
+func CheckDigit(payload string) (byte, bool) {
+	if !digits(payload) {
+		return 0, false
+	}
+
+	return byte('0' + (10-sum(payload, true)%10)%10), true
+}
+
+// Generate returns a random valid number of the given length without a leading zero.
+func Generate(length int) (string, error) {
+	if length < 2 {
+		return "", ErrBadLength
+	}
+
+	payload := make([]byte, length-1)
+	random := make([]byte, 1)
+	for i := range payload {
+		for {
+			_, err := rand.Read(random)
+			if err != nil {
+				return "", err
+			}
+
+			// 250 is the largest multiple of 10 in a byte, the rest would skew the digits
+			if random[0] >= 250 || (i == 0 && random[0]%10 == 0) {
+				continue
+			}
+
+			payload[i] = '0' + random[0]%10
+			break
+		}
+	}
+
+	check, _ := CheckDigit(string(payload))
+	return string(append(payload, check)), nil
+}
+
+// sum is the Luhn sum of the number. Every second digit from the right is doubled,
+// starting from the last one when the check digit is yet to be appended.
+func sum(number string, withoutCheckDigit bool) int {
+	var luhn int
+	double := withoutCheckDigit
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		luhn += digit
+		double = !double
+	}
+
+	return luhn
+}
+
+func digits(number string) bool {
+	for i := 0; i < len(number); i++ {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package luhn
+
+import (
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		number string
+		want   bool
+	}{
+		{name: "ok", number: "12345678903", want: true},
+		{name: "ok 16 digits", number: "4561261212345467", want: true},
+		{name: "zero", number: "0", want: true},
+		{name: "longer than int64", number: "123456789012345678901234567891", want: true},
+		{name: "bad check digit", number: "12345678904", want: false},
+		{name: "empty", number: "", want: false},
+		{name: "letters", number: "1234a678903", want: false},
+		{name: "not normalized", number: "1234567890 3", want: false},
+		{name: "unicode digits", number: "١٢٣", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Valid(tt.number); got != tt.want {
+				t.Errorf("Valid(%q) = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got := Normalize(" 4561 2612\t1234 5467\n")
+	if got != "4561261212345467" {
+		t.Errorf("Normalize() = %q, want %q", got, "4561261212345467")
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	got, ok := CheckDigit("1234567890")
+	if !ok || got != '3' {
+		t.Errorf("CheckDigit() = %q, %v, want %q, true", got, ok, '3')
+	}
+
+	if _, ok = CheckDigit("12a"); ok {
+		t.Errorf("CheckDigit() ok for not digits")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, length := range []int{2, 16, 128} {
+		number, err := Generate(length)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(number) != length {
+			t.Errorf("Generate(%d) length = %d", length, len(number))
+		}
+
+		if number[0] == '0' {
+			t.Errorf("Generate(%d) = %s has a leading zero", length, number)
+		}
+
+		if !Valid(number) {
+			t.Errorf("Generate(%d) = %s is not valid", length, number)
+		}
+	}
+
+	if _, err := Generate(1); err != ErrBadLength {
+		t.Errorf("Generate(1) error = %v, want %v", err, ErrBadLength)
+	}
+}